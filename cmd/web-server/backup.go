@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"log"
+
+	"github.com/kelvinatorr/restaurant-tracker/internal/backup"
+	"github.com/kelvinatorr/restaurant-tracker/internal/storage/sqlite"
+)
+
+// runBackup implements the backup subcommand which writes a backup of a sqlite database to a directory. It is safe to
+// run while the web-server is using the database.
+func runBackup(args []string) {
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	dbPathPtr := fs.String("db", "", "Path to the sqlite database to back up.")
+	dirPtr := fs.String("dir", "", "Directory to write the backup to.")
+	keepPtr := fs.Int("keep", 0, "How many backups to keep in the directory. 0 keeps them all.")
+	fs.Parse(args)
+	dbPath := *dbPathPtr
+	dir := *dirPtr
+	keep := *keepPtr
+
+	if dbPath == "" || dir == "" {
+		log.Fatalln("-db and -dir are required.")
+	}
+	s, err := sqlite.NewStorage(dbPath)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer s.CloseStorage()
+
+	path, err := backup.NewService(s, dir, keep).Backup()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	log.Printf("Wrote backup: %s\n", path)
+}
+
+// runRestore implements the restore subcommand which replaces a sqlite database with a backup. The web-server must be
+// stopped first.
+func runRestore(args []string) {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	dbPathPtr := fs.String("db", "", "Path to the sqlite database to replace.")
+	fromPtr := fs.String("from", "", "Path to the backup to restore.")
+	fs.Parse(args)
+	dbPath := *dbPathPtr
+	from := *fromPtr
+
+	if dbPath == "" || from == "" {
+		log.Fatalln("-db and -from are required.")
+	}
+	version, err := sqlite.Restore(from, dbPath)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	log.Printf("Restored %s to %s at schema version %d. The old database was kept at %s.pre-restore\n", from, dbPath,
+		version, dbPath)
+}
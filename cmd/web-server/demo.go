@@ -0,0 +1,85 @@
+package main
+
+import (
+	"log"
+
+	"github.com/kelvinatorr/restaurant-tracker/internal/adder"
+)
+
+// demoPassword is the password of every demo user. The demo data is thrown away when the server stops so it doesn't
+// need to be secret.
+const demoPassword = "restaurant-demo"
+
+var demoUsers = []adder.User{
+	{FirstName: "Alex", LastName: "Rivera", Email: "alex@example.com"},
+	{FirstName: "Sam", LastName: "Chen", Email: "sam@example.com"},
+}
+
+// A BusinessStatus of 1 means the restaurant is still operating. The Corner Diner doesn't have a location so Near Me
+// leaves it out.
+var demoRestaurants = []adder.Restaurant{
+	{Name: "Pho Saigon", Cuisine: "Vietnamese", BusinessStatus: 1, Note: "Get the rare steak pho.", Latitude: 47.5985, Longitude: -122.3236, CityState: adder.CityState{Name: "Seattle", State: "WA"}},
+	{Name: "Taqueria La Fiesta", Cuisine: "Mexican", BusinessStatus: 1, Latitude: 47.6150, Longitude: -122.3200, CityState: adder.CityState{Name: "Seattle", State: "WA"}},
+	{Name: "Golden Dragon", Cuisine: "Chinese", BusinessStatus: 1, Note: "Dim sum on weekends only.", Latitude: 45.5231, Longitude: -122.6765, CityState: adder.CityState{Name: "Portland", State: "OR"}},
+	{Name: "Trattoria Roma", Cuisine: "Italian", BusinessStatus: 1, Latitude: 45.5122, Longitude: -122.6587, CityState: adder.CityState{Name: "Portland", State: "OR"}},
+	{Name: "Sushi Kaito", Cuisine: "Japanese", BusinessStatus: 1, Note: "Want to try the omakase.", Latitude: 37.7858, Longitude: -122.4065, CityState: adder.CityState{Name: "San Francisco", State: "CA"}},
+	{Name: "The Corner Diner", Cuisine: "American", CityState: adder.CityState{Name: "San Francisco", State: "CA"}},
+}
+
+// demoVisit is a visit to demoRestaurants[restaurant] where ratings[i] is the rating of demoUsers[i]. A rating of 0
+// means that user wasn't there.
+type demoVisit struct {
+	restaurant int
+	date       string
+	note       string
+	ratings    []int64
+}
+
+var demoVisits = []demoVisit{
+	{restaurant: 0, date: "2020-03-14", note: "Busy but quick.", ratings: []int64{5, 4}},
+	{restaurant: 0, date: "2020-08-02", ratings: []int64{4, 4}},
+	{restaurant: 1, date: "2020-05-21", note: "Al pastor was great.", ratings: []int64{4, 5}},
+	{restaurant: 2, date: "2019-12-28", ratings: []int64{3, 0}},
+	{restaurant: 3, date: "2020-02-09", note: "Anniversary dinner.", ratings: []int64{5, 5}},
+	{restaurant: 3, date: "2020-09-19", ratings: []int64{0, 3}},
+	{restaurant: 5, date: "2019-07-04", note: "Closed now.", ratings: []int64{2, 3}},
+}
+
+// seedDemo adds the demo users, restaurants and visits. Sushi Kaito is left without visits to show a restaurant that
+// hasn't been tried yet.
+func seedDemo(add adder.Service) error {
+	userIDs := make([]int64, len(demoUsers))
+	for i, u := range demoUsers {
+		u.Password = demoPassword
+		u.RepeatPassword = demoPassword
+		id, err := add.AddUser(u, 0)
+		if err != nil {
+			return err
+		}
+		userIDs[i] = id
+		log.Printf("Demo user: %s, password: %s\n", u.Email, demoPassword)
+	}
+
+	// The first demo user added everything else so the history pages have someone to show.
+	restaurantIDs := make([]int64, len(demoRestaurants))
+	for i, r := range demoRestaurants {
+		id, err := add.AddRestaurant(r, userIDs[0])
+		if err != nil {
+			return err
+		}
+		restaurantIDs[i] = id
+	}
+
+	for _, dv := range demoVisits {
+		v := adder.Visit{RestaurantID: restaurantIDs[dv.restaurant], VisitDateTime: dv.date, Note: dv.note}
+		for i, rating := range dv.ratings {
+			if rating != 0 {
+				v.VisitUsers = append(v.VisitUsers, adder.VisitUser{UserID: userIDs[i], Rating: rating})
+			}
+		}
+		if _, err := add.AddVisit(v, userIDs[0]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"log"
+	"os"
+
+	"github.com/kelvinatorr/restaurant-tracker/internal/lister"
+)
+
+// runExport implements the export subcommand which writes every restaurant, visit, rating and user as a JSON document
+// that the import subcommand can read back, or as a zip of CSVs. Passwords aren't exported, so importing into a
+// database without the same users needs import's -create-users.
+func runExport(args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	dbPathPtr := fs.String("db", "", "Path to the sqlite database to export.")
+	dsnPtr := fs.String("dsn", "", "Postgres connection string to use instead of a sqlite database.")
+	formatPtr := fs.String("format", "json", "Format of the export, json or csv for a zip of CSVs. Passwords aren't exported, import a JSON export with -create-users to add its users.")
+	outPtr := fs.String("out", "", "Path to write the export to. It is written to stdout if not set.")
+	fs.Parse(args)
+	format := *formatPtr
+	out := *outPtr
+
+	if format != "csv" && format != "json" {
+		log.Fatalln("-format must be csv or json.")
+	}
+	s, err := openStorage(*dbPathPtr, *dsnPtr, false)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer s.CloseStorage()
+
+	applied, err := s.Migrate(false)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	logMigrations(applied, false)
+
+	e, err := lister.NewService(s).Export()
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	var w io.Writer = os.Stdout
+	if out != "" {
+		f, err := os.Create(out)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		defer f.Close()
+		w = f
+	}
+	if format == "csv" {
+		err = e.WriteCSVZip(w)
+	} else {
+		err = e.WriteJSON(w)
+	}
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if out != "" {
+		log.Printf("Exported %d restaurants and %d users to %s\n", len(e.Restaurants), len(e.Users), out)
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/kelvinatorr/restaurant-tracker/internal/adder"
+	"github.com/kelvinatorr/restaurant-tracker/internal/lister"
+	"github.com/kelvinatorr/restaurant-tracker/internal/mapper"
+)
+
+// ratingFlags is the value of the repeatable -rating flag, each an email=column pair.
+type ratingFlags []string
+
+func (f *ratingFlags) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *ratingFlags) Set(v string) error {
+	if !strings.Contains(v, "=") {
+		return fmt.Errorf("%s must be email=column", v)
+	}
+	*f = append(*f, v)
+	return nil
+}
+
+// runImport implements the import subcommand which adds the restaurants and visits in a CSV, e.g. one exported from
+// a spreadsheet, or in a JSON export from the export subcommand.
+func runImport(args []string) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	dbPathPtr := fs.String("db", "", "Path to the sqlite database to import into.")
+	dsnPtr := fs.String("dsn", "", "Postgres connection string to use instead of a sqlite database.")
+	filePtr := fs.String("file", "", "Path to the file to import. A CSV's first row must be the column headers.")
+	formatPtr := fs.String("format", "csv", "Format of the file, csv or json. The column flags are only for a CSV.")
+	dryRunPtr := fs.Bool("dry-run", false, "Show what would be imported without changing the database.")
+	createUsersPtr := fs.Bool("create-users", false, "Create the users in a JSON export who don't have an account with a temporary password.")
+	nameColPtr := fs.String("name", adder.DefaultImportColumns.Name, "Column with the restaurant names.")
+	cuisineColPtr := fs.String("cuisine", adder.DefaultImportColumns.Cuisine, "Column with the cuisines.")
+	cityColPtr := fs.String("city", adder.DefaultImportColumns.City, "Column with the cities.")
+	stateColPtr := fs.String("state", adder.DefaultImportColumns.State, "Column with the 2 letter states.")
+	addressColPtr := fs.String("address", "", "Column with the addresses. Not imported if empty.")
+	noteColPtr := fs.String("note", "", "Column with the restaurant notes. Not imported if empty.")
+	dateColPtr := fs.String("date", "", "Column with the visit dates. Rows without one only add their restaurant.")
+	visitNoteColPtr := fs.String("visit-note", "", "Column with the visit notes. Not imported if empty.")
+	var ratings ratingFlags
+	fs.Var(&ratings, "rating", "A user's rating column as email=column, e.g. alex@example.com=Alex. Repeat it for each user.")
+	fs.Parse(args)
+	dryRun := *dryRunPtr
+	format := *formatPtr
+
+	if format != "csv" && format != "json" {
+		log.Fatalln("-format must be csv or json.")
+	}
+	if *filePtr == "" {
+		log.Fatalln("-file is required.")
+	}
+	f, err := os.Open(*filePtr)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer f.Close()
+
+	if dryRun {
+		checkDryRunDB(*dbPathPtr)
+	}
+	s, err := openStorage(*dbPathPtr, *dsnPtr, false)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer s.CloseStorage()
+
+	// A dry run can't change the schema, and the import can't be checked against an old one.
+	applied, err := s.Migrate(dryRun)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	logMigrations(applied, dryRun)
+	if dryRun && len(applied) > 0 {
+		log.Fatalln("Run the migrate command before a dry run of the import.")
+	}
+
+	cols := adder.ImportColumns{
+		Name:      *nameColPtr,
+		Cuisine:   *cuisineColPtr,
+		City:      *cityColPtr,
+		State:     *stateColPtr,
+		Address:   *addressColPtr,
+		Note:      *noteColPtr,
+		VisitDate: *dateColPtr,
+		VisitNote: *visitNoteColPtr,
+		Ratings:   make(map[int64]string),
+	}
+	if len(ratings) > 0 {
+		users, err := lister.NewService(s).GetUsers()
+		if err != nil {
+			log.Fatalln(err)
+		}
+		userIDs := make(map[string]int64)
+		for _, u := range users {
+			userIDs[strings.ToLower(u.Email)] = u.ID
+		}
+		for _, r := range ratings {
+			parts := strings.SplitN(r, "=", 2)
+			id, ok := userIDs[strings.ToLower(parts[0])]
+			if !ok {
+				log.Fatalf("There is no user with the email %s.\n", parts[0])
+			}
+			cols.Ratings[id] = parts[1]
+		}
+	}
+
+	// Nothing is looked up on Google Maps so the mapper doesn't need a key.
+	add := adder.NewService(s.Adder(), mapper.NewService(""))
+	// There is no signed in user to record in the audit log.
+	var result adder.ImportResult
+	if format == "json" {
+		result, err = add.ImportJSON(f, dryRun, *createUsersPtr, 0)
+	} else {
+		result, err = add.ImportCSV(f, cols, dryRun, 0)
+	}
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	verb, item := "Imported", "row"
+	if format == "json" {
+		item = "restaurant"
+	}
+	if dryRun {
+		verb = "Would import"
+	}
+	for _, r := range result.Restaurants {
+		log.Printf("%s %s %d: %s (%s) in %s, %s with %d visits\n", verb, item, r.Row, r.Name, r.Cuisine, r.City,
+			r.State, r.Visits)
+	}
+	for _, u := range result.Users {
+		if dryRun {
+			log.Printf("Would create user %s <%s>\n", u.Name, u.Email)
+			continue
+		}
+		log.Printf("Created user %s <%s>\n", u.Name, u.Email)
+	}
+	for _, r := range result.Duplicates {
+		log.Printf("Skipped %s %d: %s in %s, %s is already in the database\n", item, r.Row, r.Name, r.City, r.State)
+	}
+	log.Printf("%s %d restaurants, %d visits and %d ratings. Skipped %d duplicate restaurants.\n", verb,
+		len(result.Restaurants), result.Visits, result.Ratings, len(result.Duplicates))
+
+	// The temporary passwords go to stdout for whoever ran the import instead of the log, and aren't shown again.
+	if !dryRun && len(result.Users) > 0 {
+		fmt.Println("Temporary passwords for the created users, give them out so they can sign in and change them:")
+		for _, u := range result.Users {
+			fmt.Printf("%s\t%s\n", u.Email, u.Password)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/kelvinatorr/restaurant-tracker/internal/storage"
+)
+
+// runMigrate implements the migrate subcommand which brings a database up to the latest schema version without
+// starting the web server.
+func runMigrate(args []string) {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	dbPathPtr := fs.String("db", "", "Path to the sqlite database. It will be created if it does not exist.")
+	dsnPtr := fs.String("dsn", "", "Postgres connection string to use instead of a sqlite database.")
+	dryRunPtr := fs.Bool("dry-run", false, "Show the migrations that would be applied without changing the database.")
+	fs.Parse(args)
+	dbPath := *dbPathPtr
+	dsn := *dsnPtr
+	dryRun := *dryRunPtr
+
+	if dryRun {
+		checkDryRunDB(dbPath)
+	}
+
+	s, err := openStorage(dbPath, dsn, false)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer s.CloseStorage()
+
+	version, err := s.SchemaVersion()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	log.Printf("Database is at schema version %d. Latest is %d.\n", version, s.LatestSchemaVersion())
+
+	applied, err := s.Migrate(dryRun)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	logMigrations(applied, dryRun)
+}
+
+// checkDryRunDB stops if the sqlite database at dbPath doesn't exist, because opening it would create it and a dry run
+// must not change anything.
+func checkDryRunDB(dbPath string) {
+	if dbPath == "" {
+		return
+	}
+	if _, err := os.Stat(dbPath); os.IsNotExist(err) {
+		log.Fatalf("%s does not exist. It would be created with every migration applied.\n", dbPath)
+	} else if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+func logMigrations(applied []storage.Migration, dryRun bool) {
+	verb := "Applied"
+	if dryRun {
+		verb = "Would apply"
+	}
+	if len(applied) == 0 {
+		log.Println("Database schema is up to date.")
+	}
+	for _, m := range applied {
+		log.Printf("%s migration %d: %s\n", verb, m.Version, m.Description)
+	}
+}
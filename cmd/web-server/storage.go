@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"log"
+
+	"github.com/kelvinatorr/restaurant-tracker/internal/adder"
+	"github.com/kelvinatorr/restaurant-tracker/internal/auther"
+	"github.com/kelvinatorr/restaurant-tracker/internal/lister"
+	"github.com/kelvinatorr/restaurant-tracker/internal/remover"
+	"github.com/kelvinatorr/restaurant-tracker/internal/storage"
+	"github.com/kelvinatorr/restaurant-tracker/internal/storage/memory"
+	"github.com/kelvinatorr/restaurant-tracker/internal/storage/postgres"
+	"github.com/kelvinatorr/restaurant-tracker/internal/storage/sqlite"
+	"github.com/kelvinatorr/restaurant-tracker/internal/updater"
+)
+
+// repository is what web-server needs from a storage backend.
+type repository interface {
+	lister.Repository
+	Adder() adder.Repository
+	Updater() updater.Repository
+	Remover() remover.Repository
+	Auther() auther.Repository
+	SchemaVersion() (int, error)
+	LatestSchemaVersion() int
+	Migrate(bool) ([]storage.Migration, error)
+	CloseStorage()
+}
+
+// openStorage returns an empty in-memory storage if demo is true. Otherwise it connects to the Postgres database at dsn
+// if it is set or to the sqlite database at dbPath.
+func openStorage(dbPath string, dsn string, demo bool) (repository, error) {
+	switch {
+	case demo && (dbPath != "" || dsn != ""):
+		return nil, errors.New("-demo can't be used with -db or -dsn.")
+	case demo:
+		log.Println("Running in demo mode. Nothing will be saved when the server stops.")
+		return memory.NewStorage(), nil
+	case dbPath != "" && dsn != "":
+		return nil, errors.New("Only one of -db or -dsn can be set.")
+	case dsn != "":
+		log.Println("Connecting to Postgres database")
+		return postgres.NewStorage(dsn)
+	case dbPath != "":
+		log.Printf("Connecting to database: %s\n", dbPath)
+		return sqlite.NewStorage(dbPath)
+	default:
+		return nil, errors.New("A -db path or a -dsn is required.")
+	}
+}
@@ -6,7 +6,7 @@ require (
 	github.com/gorilla/csrf v1.7.0
 	github.com/gorilla/schema v1.2.0
 	github.com/julienschmidt/httprouter v1.3.0
+	github.com/lib/pq v1.10.9
 	github.com/mattn/go-sqlite3 v2.0.3+incompatible
-	github.com/rs/cors v1.7.0
 	golang.org/x/crypto v0.0.0-20200820211705-5c72a883971a
 )
@@ -0,0 +1,15 @@
+package adder
+
+// APIToken is a personal access token a user makes to use the API from scripts. UserID is the user who owns it and
+// TokenHash is the hash of the token, which is all that is saved.
+type APIToken struct {
+	UserID   int64  `json:"user_id" schema:"-"`
+	Name     string `json:"name" schema:"name,required"`
+	ReadOnly bool   `json:"read_only" schema:"readOnly"`
+	// ExpiresOn is the date, in YYYY-MM-DD, the token stops working. It never expires when it is empty.
+	ExpiresOn string `json:"expires_on" schema:"expiresOn"`
+	TokenHash string `json:"-" schema:"-"`
+	CreatedAt string `json:"-" schema:"-"`
+	// ExpiresAt is ExpiresOn as an RFC3339 UTC time.
+	ExpiresAt string `json:"-" schema:"-"`
+}
@@ -0,0 +1,494 @@
+package adder
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kelvinatorr/restaurant-tracker/internal/audit"
+	"github.com/kelvinatorr/restaurant-tracker/internal/auther"
+	"github.com/kelvinatorr/restaurant-tracker/internal/lister"
+	"github.com/kelvinatorr/restaurant-tracker/internal/storage"
+)
+
+// ImportColumns says which column of a CSV has each field, by its header. Headers are matched ignoring case. Name,
+// Cuisine, City and State are required and the other fields aren't imported when their column is empty.
+type ImportColumns struct {
+	Name      string `schema:"name"`
+	Cuisine   string `schema:"cuisine"`
+	City      string `schema:"city"`
+	State     string `schema:"state"`
+	Address   string `schema:"address"`
+	Note      string `schema:"note"`
+	VisitDate string `schema:"visitDate"`
+	VisitNote string `schema:"visitNote"`
+	// Ratings is the column of each user's ratings by user id.
+	Ratings map[int64]string `schema:"-"`
+}
+
+// DefaultImportColumns are the headers the required columns are expected to have unless they are mapped to others.
+var DefaultImportColumns = ImportColumns{Name: "Name", Cuisine: "Cuisine", City: "City", State: "State"}
+
+// ImportResult is what an import added, or would have added if it is a dry run.
+type ImportResult struct {
+	DryRun      bool
+	Restaurants []ImportedRestaurant
+	// Duplicates are the restaurants that were already in the database. They and their visits were skipped.
+	Duplicates []ImportedRestaurant
+	Visits     int
+	Ratings    int
+	// Users are the users a JSON import created.
+	Users []ImportedUser
+}
+
+// ImportedUser is a user a JSON import created. Password is their temporary password, which is empty for a dry run.
+type ImportedUser struct {
+	Name     string
+	Email    string
+	Password string
+}
+
+// ImportedRestaurant is a restaurant in an import. Row is the first row it is on in a CSV, counting the header as row
+// 1, or its position in a JSON export counting from 1. ID is 0 for a dry run or a duplicate.
+type ImportedRestaurant struct {
+	Row     int
+	ID      int64
+	Name    string
+	Cuisine string
+	City    string
+	State   string
+	Visits  int
+}
+
+// importDateLayouts are the visit date formats an import understands, the first is the one this app uses and the rest
+// are what spreadsheets usually export.
+var importDateLayouts = []string{"2006-01-02", "1/2/2006", "1/2/06"}
+
+// errDryRun rolls back the transaction of a dry run.
+var errDryRun = errors.New("dry run")
+
+// importRestaurant is a restaurant read from a CSV with the visits on its rows.
+type importRestaurant struct {
+	row        int
+	restaurant Restaurant
+	visits     []importVisit
+}
+
+type importVisit struct {
+	row   int
+	visit Visit
+}
+
+// ImportCSV adds the restaurants and visits in a CSV with a row per visit. Rows for the same restaurant, by name, city
+// and state, are grouped together and a row without a visit date only adds its restaurant. Restaurants already in the
+// database are skipped. Everything is added in one transaction so a bad row adds nothing. A dry run does the same
+// checks and returns what would be added without saving anything.
+func (s *service) ImportCSV(r io.Reader, cols ImportColumns, dryRun bool, userID int64) (ImportResult, error) {
+	restaurants, err := readImportCSV(r, cols)
+	if err != nil {
+		return ImportResult{DryRun: dryRun}, err
+	}
+
+	ratingUserIDs := make([]int64, 0, len(cols.Ratings))
+	for id := range cols.Ratings {
+		ratingUserIDs = append(ratingUserIDs, id)
+	}
+	return s.runImport(restaurants, ratingUserIDs, nil, "Row", dryRun, userID)
+}
+
+// runImport adds the restaurants and their visits in one transaction, skipping the ones already in the database.
+// Errors start with the label and the row or position the restaurant or visit came from.
+func (s *service) runImport(restaurants []importRestaurant, ratingUserIDs []int64, newUsers []User, label string,
+	dryRun bool, userID int64) (ImportResult, error) {
+	result := ImportResult{DryRun: dryRun}
+	err := s.r.WithTx(func(tx TxRepository) error {
+		newUserIDs, err := addImportUsers(tx, newUsers, userID)
+		if err != nil {
+			return err
+		}
+		for _, u := range newUsers {
+			imported := ImportedUser{Name: u.FirstName + " " + u.LastName, Email: u.Email, Password: u.Password}
+			if dryRun {
+				imported.Password = ""
+			}
+			result.Users = append(result.Users, imported)
+		}
+		for _, ratingUserID := range ratingUserIDs {
+			if _, err := tx.GetUser(ratingUserID); storage.IsNotFound(err) {
+				return fmt.Errorf("There is no user with id: %d.", ratingUserID)
+			} else if err != nil {
+				return err
+			}
+		}
+		for _, ir := range restaurants {
+			imported := ImportedRestaurant{
+				Row:     ir.row,
+				Name:    ir.restaurant.Name,
+				Cuisine: ir.restaurant.Cuisine,
+				City:    ir.restaurant.CityState.Name,
+				State:   ir.restaurant.CityState.State,
+				Visits:  len(ir.visits),
+			}
+			isDuplicate, err := tx.IsDuplicateRestaurant(ir.restaurant)
+			if err != nil {
+				return err
+			}
+			if isDuplicate {
+				result.Duplicates = append(result.Duplicates, imported)
+				continue
+			}
+			imported.ID, err = addRestaurant(tx, ir.restaurant, userID)
+			if err != nil {
+				return fmt.Errorf("%s %d: %s", label, ir.row, err)
+			}
+			for _, iv := range ir.visits {
+				iv.visit.RestaurantID = imported.ID
+				for i, vu := range iv.visit.VisitUsers {
+					if vu.UserID < 0 {
+						iv.visit.VisitUsers[i].UserID = newUserIDs[-vu.UserID-1]
+					}
+				}
+				if _, err := addVisit(tx, iv.visit, userID); err != nil {
+					return fmt.Errorf("%s %d: %s", label, iv.row, err)
+				}
+				result.Visits++
+				result.Ratings += len(iv.visit.VisitUsers)
+			}
+			if dryRun {
+				imported.ID = 0
+			}
+			result.Restaurants = append(result.Restaurants, imported)
+		}
+		if dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && err != errDryRun {
+		return ImportResult{DryRun: dryRun}, err
+	}
+	return result, nil
+}
+
+// addImportUsers adds the users a JSON import creates and returns their ids. Like AddUser the first user is the admin,
+// so an import into an empty database leaves someone who can manage it.
+func addImportUsers(tx TxRepository, users []User, userID int64) ([]int64, error) {
+	ids := make([]int64, len(users))
+	for i, u := range users {
+		passwordHash, err := auther.HashPassword(u.Password)
+		if err != nil {
+			return nil, err
+		}
+		u.PasswordHash = passwordHash
+		u.Password = ""
+		userCount, err := tx.GetUserCount()
+		if err != nil {
+			return nil, err
+		}
+		u.IsAdmin = userCount == 0
+		if ids[i], err = tx.AddUser(u); err != nil {
+			return nil, err
+		}
+		saved, err := tx.GetUser(ids[i])
+		if err != nil {
+			return nil, err
+		}
+		if err := audit.Record(tx, userID, audit.EntityUser, ids[i], 0, audit.ActionCreate, nil, saved); err != nil {
+			return nil, err
+		}
+	}
+	return ids, nil
+}
+
+// readImportCSV reads and checks the rows of a CSV and groups them by restaurant, in the order they are first seen.
+func readImportCSV(r io.Reader, cols ImportColumns) ([]importRestaurant, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, errors.New("The CSV is empty")
+	} else if err != nil {
+		return nil, fmt.Errorf("The CSV is not valid: %s", err)
+	}
+	// Spreadsheets often start the file with a byte order mark.
+	header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	headers := make(map[string]int)
+	for i, h := range header {
+		headers[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	column := func(field string, name string, required bool) (int, error) {
+		if strings.TrimSpace(name) == "" {
+			if required {
+				return -1, fmt.Errorf("The %s column is required", field)
+			}
+			return -1, nil
+		}
+		i, ok := headers[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return -1, fmt.Errorf("There is no column called %s for the %s", name, field)
+		}
+		return i, nil
+	}
+
+	var nameCol, cuisineCol, cityCol, stateCol, addressCol, noteCol, dateCol, visitNoteCol int
+	for _, c := range []struct {
+		i        *int
+		field    string
+		name     string
+		required bool
+	}{
+		{&nameCol, "name", cols.Name, true},
+		{&cuisineCol, "cuisine", cols.Cuisine, true},
+		{&cityCol, "city", cols.City, true},
+		{&stateCol, "state", cols.State, true},
+		{&addressCol, "address", cols.Address, false},
+		{&noteCol, "notes", cols.Note, false},
+		{&dateCol, "visit date", cols.VisitDate, false},
+		{&visitNoteCol, "visit notes", cols.VisitNote, false},
+	} {
+		if *c.i, err = column(c.field, c.name, c.required); err != nil {
+			return nil, err
+		}
+	}
+	// Go through the users in order so the ratings are always added in the same order. Users without a column
+	// aren't rated.
+	userIDs := make([]int64, 0, len(cols.Ratings))
+	for id, name := range cols.Ratings {
+		if strings.TrimSpace(name) != "" {
+			userIDs = append(userIDs, id)
+		}
+	}
+	sort.Slice(userIDs, func(i, j int) bool { return userIDs[i] < userIDs[j] })
+	ratingCols := make(map[int64]int)
+	for _, id := range userIDs {
+		if ratingCols[id], err = column("ratings", cols.Ratings[id], true); err != nil {
+			return nil, err
+		}
+	}
+	if dateCol == -1 && (visitNoteCol != -1 || len(ratingCols) > 0) {
+		return nil, errors.New("The visit date column is required to import visit notes or ratings")
+	}
+
+	var restaurants []importRestaurant
+	byKey := make(map[string]int)
+	for row := 2; ; row++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("The CSV is not valid: %s", err)
+		}
+		cell := func(i int) string {
+			if i == -1 {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
+		}
+
+		res := Restaurant{
+			Name:      cell(nameCol),
+			Cuisine:   cell(cuisineCol),
+			Address:   cell(addressCol),
+			Note:      cell(noteCol),
+			CityState: CityState{Name: cell(cityCol), State: strings.ToUpper(cell(stateCol))},
+		}
+		if err := checkRestaurantData(res); err != nil {
+			return nil, fmt.Errorf("Row %d: %s", row, err)
+		}
+		key := strings.ToUpper(res.Name + "\x00" + res.CityState.Name + "\x00" + res.CityState.State)
+		i, ok := byKey[key]
+		if !ok {
+			i = len(restaurants)
+			byKey[key] = i
+			restaurants = append(restaurants, importRestaurant{row: row, restaurant: res})
+		}
+
+		visit := Visit{Note: cell(visitNoteCol)}
+		for _, id := range userIDs {
+			rating := cell(ratingCols[id])
+			if rating == "" {
+				continue
+			}
+			n, err := strconv.Atoi(rating)
+			if err != nil || n < 1 || n > 5 {
+				return nil, fmt.Errorf("Row %d: %s in the %s column must be a rating from 1 to 5", row, rating,
+					cols.Ratings[id])
+			}
+			visit.VisitUsers = append(visit.VisitUsers, VisitUser{UserID: id, Rating: int64(n)})
+		}
+		date := cell(dateCol)
+		if date == "" {
+			if visit.Note != "" || len(visit.VisitUsers) > 0 {
+				return nil, fmt.Errorf("Row %d has a visit but no visit date", row)
+			}
+			continue
+		}
+		visit.VisitDateTime, err = parseImportDate(date)
+		if err != nil {
+			return nil, fmt.Errorf("Row %d: %s", row, err)
+		}
+		restaurants[i].visits = append(restaurants[i].visits, importVisit{row: row, visit: visit})
+	}
+	if len(restaurants) == 0 {
+		return nil, errors.New("The CSV has no restaurants in it")
+	}
+	return restaurants, nil
+}
+
+// parseImportDate returns a visit date in one of the importDateLayouts in RFC3339.
+func parseImportDate(date string) (string, error) {
+	for _, layout := range importDateLayouts {
+		if t, err := time.Parse(layout, date); err == nil {
+			return t.Format(time.RFC3339), nil
+		}
+	}
+	return "", fmt.Errorf("Cannot format %s as date, use YYYY-MM-DD or M/D/YYYY", date)
+}
+
+// ImportJSON adds the restaurants, visits and ratings in a JSON export, the way ImportCSV does. Ratings are added to the
+// users here with the same email as the users in the export. An export doesn't have passwords, so a user who isn't here
+// is an error unless createUsers is true, in which case they are added with a temporary password that is in the result.
+// A rating of 0 means the user went but didn't rate it.
+func (s *service) ImportJSON(r io.Reader, dryRun bool, createUsers bool, userID int64) (ImportResult, error) {
+	var e lister.Export
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&e); err != nil {
+		return ImportResult{DryRun: dryRun}, fmt.Errorf("The export is not valid JSON: %s", err)
+	}
+	if e.Version != lister.ExportVersion {
+		return ImportResult{DryRun: dryRun}, fmt.Errorf("Cannot import version %d of the export, only version %d",
+			e.Version, lister.ExportVersion)
+	}
+	restaurants, ratingUserIDs, newUsers, err := s.readImportJSON(e, createUsers)
+	if err != nil {
+		return ImportResult{DryRun: dryRun}, err
+	}
+	return s.runImport(restaurants, ratingUserIDs, newUsers, "Restaurant", dryRun, userID)
+}
+
+// readImportJSON checks the restaurants of an export and maps the users in it to the users here by email. The users
+// who aren't here are returned to be created if createUsers is true. Until they are, the ratings of the nth of them
+// have the user id -n.
+func (s *service) readImportJSON(e lister.Export, createUsers bool) ([]importRestaurant, []int64, []User, error) {
+	exportUsers := make(map[int64]lister.User)
+	for _, u := range e.Users {
+		u.Email = strings.ToLower(u.Email)
+		exportUsers[u.ID] = u
+	}
+	userIDs := make(map[int64]int64)
+	var newUsers []User
+	for _, er := range e.Restaurants {
+		for _, v := range er.Visits {
+			for _, vr := range v.Ratings {
+				if _, ok := userIDs[vr.UserID]; ok {
+					continue
+				}
+				eu, ok := exportUsers[vr.UserID]
+				if !ok {
+					return nil, nil, nil, fmt.Errorf("There is no user with id %d in the export", vr.UserID)
+				}
+				u, err := s.r.GetUserBy("email", eu.Email)
+				if storage.IsNotFound(err) {
+					password, err := auther.NewTemporaryPassword()
+					if err != nil {
+						return nil, nil, nil, err
+					}
+					nu := User{FirstName: eu.FirstName, LastName: eu.LastName, Email: eu.Email, Password: password,
+						RepeatPassword: password}
+					if err := checkUserData(nu); err != nil {
+						return nil, nil, nil, fmt.Errorf("User %s: %s", eu.Email, err)
+					}
+					newUsers = append(newUsers, nu)
+					userIDs[vr.UserID] = -int64(len(newUsers))
+					continue
+				} else if err != nil {
+					return nil, nil, nil, err
+				}
+				userIDs[vr.UserID] = u.ID
+			}
+		}
+	}
+	if len(newUsers) > 0 && !createUsers {
+		missing := make([]string, len(newUsers))
+		for i, u := range newUsers {
+			missing[i] = u.Email
+		}
+		sort.Strings(missing)
+		return nil, nil, nil, fmt.Errorf("Add users for %s before importing their visits, or let the import create them",
+			strings.Join(missing, ", "))
+	}
+
+	var restaurants []importRestaurant
+	for i, er := range e.Restaurants {
+		position := i + 1
+		res := Restaurant{
+			Name:           strings.TrimSpace(er.Name),
+			Cuisine:        strings.TrimSpace(er.Cuisine),
+			BusinessStatus: er.BusinessStatus,
+			Note:           er.Note,
+			Address:        er.Address,
+			Zipcode:        er.Zipcode,
+			CityState:      CityState{Name: strings.TrimSpace(er.City), State: strings.ToUpper(strings.TrimSpace(er.State))},
+			Latitude:       er.Latitude,
+			Longitude:      er.Longitude,
+		}
+		if g := er.GmapsPlace; g != nil {
+			res.GmapsPlace = GmapsPlace{
+				PlaceID:              g.PlaceID,
+				BusinessStatus:       g.BusinessStatus,
+				FormattedPhoneNumber: g.FormattedPhoneNumber,
+				Name:                 g.Name,
+				PriceLevel:           g.PriceLevel,
+				Rating:               g.Rating,
+				URL:                  g.URL,
+				UserRatingsTotal:     g.UserRatingsTotal,
+				UTCOffset:            g.UTCOffset,
+				Website:              g.Website,
+			}
+		}
+		if err := checkRestaurantData(res); err != nil {
+			return nil, nil, nil, fmt.Errorf("Restaurant %d: %s", position, err)
+		}
+		ir := importRestaurant{row: position, restaurant: res}
+		for _, ev := range er.Visits {
+			t, err := time.Parse(time.RFC3339, ev.VisitDateTime)
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("Restaurant %d: %s is not an RFC3339 visit date", position,
+					ev.VisitDateTime)
+			}
+			// Visit dates are saved in UTC.
+			visit := Visit{VisitDateTime: t.UTC().Format(time.RFC3339), Note: ev.Note}
+			for _, vr := range ev.Ratings {
+				// 0 is saved as no rating.
+				if vr.Rating < 0 || vr.Rating > 5 {
+					return nil, nil, nil, fmt.Errorf("Restaurant %d: %d is not a rating from 1 to 5, or 0 for no rating",
+						position, vr.Rating)
+				}
+				visit.VisitUsers = append(visit.VisitUsers, VisitUser{UserID: userIDs[vr.UserID], Rating: vr.Rating})
+			}
+			ir.visits = append(ir.visits, importVisit{row: position, visit: visit})
+		}
+		restaurants = append(restaurants, ir)
+	}
+	if len(restaurants) == 0 {
+		return nil, nil, nil, errors.New("The export has no restaurants in it")
+	}
+
+	ratingUserIDs := make([]int64, 0, len(userIDs))
+	for _, id := range userIDs {
+		if id > 0 {
+			ratingUserIDs = append(ratingUserIDs, id)
+		}
+	}
+	return restaurants, ratingUserIDs, newUsers, nil
+}
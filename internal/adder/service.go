@@ -3,15 +3,18 @@ package adder
 import (
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"regexp"
 	"strings"
 	"time"
 	"unicode/utf8"
 
+	"github.com/kelvinatorr/restaurant-tracker/internal/audit"
 	"github.com/kelvinatorr/restaurant-tracker/internal/auther"
 	"github.com/kelvinatorr/restaurant-tracker/internal/lister"
 	"github.com/kelvinatorr/restaurant-tracker/internal/mapper"
+	"github.com/kelvinatorr/restaurant-tracker/internal/storage"
 )
 
 // ErrDuplicate is used when a resturant already exists.
@@ -23,32 +26,56 @@ func (m *ErrDuplicate) Error() string {
 	return m.msg
 }
 
-// Service provides adding operations.
+// Service provides adding operations. The int64 each takes is the id of the signed in user who is adding, which is
+// recorded in the audit log. It is 0 if no one is signed in.
 type Service interface {
+	AddRestaurant(Restaurant, int64) (int64, error)
+	AddVisit(Visit, int64) (int64, error)
+	AddUser(User, int64) (int64, error)
+	AddSavedView(SavedView, int64) (int64, error)
+	// AddAPIToken returns the id of the new token and the token itself, which can't be seen again.
+	AddAPIToken(APIToken, int64) (int64, string, error)
+	// ImportCSV adds the restaurants and visits in a CSV, or only checks them when the bool is true.
+	ImportCSV(io.Reader, ImportColumns, bool, int64) (ImportResult, error)
+	// ImportJSON adds the restaurants and visits in a JSON export, or only checks them when the first bool is true.
+	// The users in it who aren't here are created when the second bool is true.
+	ImportJSON(io.Reader, bool, bool, int64) (ImportResult, error)
+}
+
+// TxRepository provides access to restaurant repository within a transaction.
+type TxRepository interface {
+	// AddRestaurant saves a given restaurant to the repository.
 	AddRestaurant(Restaurant) (int64, error)
+	// IsDuplicateRestaurant checks if a restaurant with the same name in the same city and state is already in the db
+	IsDuplicateRestaurant(Restaurant) (bool, error)
+	// GetCityIDByNameAndState gets the id of a city with the same name and state from the database
+	GetCityIDByNameAndState(string, string) (int64, error)
+	AddCity(string, string) (int64, error)
+	AddGmapsPlace(GmapsPlace) (int64, error)
 	AddVisit(Visit) (int64, error)
+	AddVisitUser(VisitUser) (int64, error)
+	GetRestaurant(int64) (lister.Restaurant, error)
+	GetUser(int64) (lister.User, error)
+	GetUserBy(string, string) (lister.User, error)
+	GetUserCount() (int64, error)
 	AddUser(User) (int64, error)
+	GetVisit(int64, int64) (lister.Visit, error)
+	GetVisitUsersByVisitID(int64) ([]lister.VisitUser, error)
+	AddAuditEntry(audit.Entry) (int64, error)
+	AddSavedView(SavedView) (int64, error)
+	GetSavedView(int64) (lister.SavedView, error)
+	// ClearDefaultSavedView makes none of the user's views their default.
+	ClearDefaultSavedView(int64) (int64, error)
+	AddAPIToken(APIToken) (int64, error)
+	GetAPIToken(int64) (lister.APIToken, error)
 }
 
 // Repository provides access to restaurant repository.
 type Repository interface {
-	Begin()
-	Commit()
-	Rollback()
-	// AddRestaurant saves a given restaurant to the repository.
-	AddRestaurant(Restaurant) int64
-	// IsDuplicateRestaurant checks if a restaurant with the same name in the same city and state is already in the db
-	IsDuplicateRestaurant(Restaurant) bool
-	// GetCityIDByNameAndState gets the id of a city with the same name and state from the database
-	GetCityIDByNameAndState(string, string) int64
-	AddCity(string, string) int64
-	AddGmapsPlace(GmapsPlace) int64
-	AddVisit(Visit) int64
-	AddVisitUser(VisitUser) int64
-	GetRestaurant(int64) lister.Restaurant
-	GetUser(int64) lister.User
-	GetUserBy(string, string) lister.User
-	AddUser(User) int64
+	TxRepository
+	// WithTx calls fn with a TxRepository bound to a new transaction. The transaction is committed if fn returns nil
+	// and rolled back otherwise.
+	WithTx(fn func(TxRepository) error) error
 }
 
 type Map interface {
@@ -60,33 +87,14 @@ type service struct {
 	m Map
 }
 
-func (s *service) AddRestaurant(r Restaurant) (int64, error) {
+func (s *service) AddRestaurant(r Restaurant, userID int64) (int64, error) {
 	err := checkRestaurantData(r)
 	if err != nil {
 		return 0, err
 	}
 
-	// Check that there isn't a duplicate restaurant with the same name in the same city, state already
-	if s.r.IsDuplicateRestaurant(r) {
-		errorMsg := fmt.Sprintf("%s in %s, %s is already in the database.", r.Name, r.CityState.Name, r.CityState.State)
-		return 0, &ErrDuplicate{msg: errorMsg}
-	}
-	// Check if the city and state is already in the database, If it is, get the city id
-	cityID := s.r.GetCityIDByNameAndState(r.CityState.Name, r.CityState.State)
-	s.r.Begin()
-	// Defer rollback just in case there is a problem.
-	defer s.r.Rollback()
-	if cityID == 0 {
-		// If not, then add it to the city table and get the city id back
-		log.Println(fmt.Sprintf("%s, %s not found, adding...", r.CityState.Name, r.CityState.State))
-		cityID = s.r.AddCity(r.CityState.Name, r.CityState.State)
-	}
-	log.Println(fmt.Sprintf("%s, %s has cityID %d", r.CityState.Name, r.CityState.State, cityID))
-	// Add the city id to the restaurant object
-	r.CityID = cityID
-
-	var newRestaurantID int64
-	// Only add gmaps place if we actually have it.
+	// Only get the gmaps place if we actually have it. This is done before the transaction is started so we don't hold
+	// it open while waiting on Google.
 	if r.GmapsPlace.PlaceID != "" {
 		// Get the Google Maps Details
 		pd, err := s.m.PlaceDetails(r.GmapsPlace.PlaceID)
@@ -108,36 +116,87 @@ func (s *service) AddRestaurant(r Restaurant) (int64, error) {
 		r.GmapsPlace.UserRatingsTotal = pd.Result.UserRatingsTotal
 		r.GmapsPlace.UTCOffset = pd.Result.UTCOffset
 		r.GmapsPlace.Website = pd.Result.Website
+	}
+
+	var newRestaurantID int64
+	err = s.r.WithTx(func(tx TxRepository) error {
+		var err error
+		newRestaurantID, err = addRestaurant(tx, r, userID)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return newRestaurantID, nil
+}
 
-		// First add the restaurant
-		newRestaurantID = s.r.AddRestaurant(r)
+// addRestaurant adds a restaurant that has been checked with checkRestaurantData in the transaction tx.
+func addRestaurant(tx TxRepository, r Restaurant, userID int64) (int64, error) {
+	// Check that there isn't a duplicate restaurant with the same name in the same city, state already
+	isDuplicate, err := tx.IsDuplicateRestaurant(r)
+	if err != nil {
+		return 0, err
+	}
+	if isDuplicate {
+		errorMsg := fmt.Sprintf("%s in %s, %s is already in the database.", r.Name, r.CityState.Name, r.CityState.State)
+		return 0, &ErrDuplicate{msg: errorMsg}
+	}
+	// Check if the city and state is already in the database, If it is, get the city id
+	cityID, err := tx.GetCityIDByNameAndState(r.CityState.Name, r.CityState.State)
+	if err != nil {
+		return 0, err
+	}
+	if cityID == 0 {
+		// If not, then add it to the city table and get the city id back
+		log.Println(fmt.Sprintf("%s, %s not found, adding...", r.CityState.Name, r.CityState.State))
+		cityID, err = tx.AddCity(r.CityState.Name, r.CityState.State)
+		if err != nil {
+			return 0, err
+		}
+	}
+	log.Println(fmt.Sprintf("%s, %s has cityID %d", r.CityState.Name, r.CityState.State, cityID))
+	// Add the city id to the restaurant object
+	r.CityID = cityID
+
+	// First add the restaurant
+	newRestaurantID, err := tx.AddRestaurant(r)
+	if err != nil {
+		return 0, err
+	}
+	// Only add gmaps place if we actually have it.
+	if r.GmapsPlace.PlaceID != "" {
 		// Set the restaurant id on the GmapsPlace for foreign key relationships
 		r.GmapsPlace.RestaurantID = newRestaurantID
 		// Finally add the GmapsPlace
-		s.r.AddGmapsPlace(r.GmapsPlace)
-	} else {
-		// Just add the restaurant because there is no Gmaps Place data
-		newRestaurantID = s.r.AddRestaurant(r)
+		if _, err := tx.AddGmapsPlace(r.GmapsPlace); err != nil {
+			return 0, err
+		}
 	}
-
-	s.r.Commit()
-	return newRestaurantID, nil
+	saved, err := tx.GetRestaurant(newRestaurantID)
+	if err != nil {
+		return 0, err
+	}
+	err = audit.Record(tx, userID, audit.EntityRestaurant, newRestaurantID, newRestaurantID, audit.ActionCreate, nil,
+		audit.Restaurant(saved))
+	return newRestaurantID, err
 }
 
-func (s *service) AddVisit(v Visit) (int64, error) {
+func (s *service) AddVisit(v Visit, userID int64) (int64, error) {
 	// Check that the restaurant id is valid
-	r := s.r.GetRestaurant(v.RestaurantID)
-	if r.ID == 0 {
+	if _, err := s.r.GetRestaurant(v.RestaurantID); storage.IsNotFound(err) {
 		errorMsg := fmt.Sprintf("There is no restaurant with id: %d.", v.RestaurantID)
 		return 0, errors.New(errorMsg)
+	} else if err != nil {
+		return 0, err
 	}
 	// Check that the user id is valid and that there is only 1 entry per user id
 	userIDs := make(map[int64]bool)
 	for _, vu := range v.VisitUsers {
-		u := s.r.GetUser(vu.UserID)
-		if u.ID == 0 {
+		if _, err := s.r.GetUser(vu.UserID); storage.IsNotFound(err) {
 			errorMsg := fmt.Sprintf("There is no user with id: %d.", vu.UserID)
 			return 0, errors.New(errorMsg)
+		} else if err != nil {
+			return 0, err
 		}
 		if _, ok := userIDs[vu.UserID]; ok {
 			errorMsg := fmt.Sprintf("The data has multiple users with id: %d.", vu.UserID)
@@ -153,19 +212,42 @@ func (s *service) AddVisit(v Visit) (int64, error) {
 	}
 	v.VisitDateTime = visitDateTime.Format(time.RFC3339)
 
-	s.r.Begin()
-	// Defer rollback just in case there is a problem.
-	defer s.r.Rollback()
+	var visitID int64
+	err = s.r.WithTx(func(tx TxRepository) error {
+		visitID, err = addVisit(tx, v, userID)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return visitID, nil
+}
 
-	visitID := s.r.AddVisit(v)
+// addVisit adds a visit whose restaurant and users have been checked, and whose date is in RFC3339, in the
+// transaction tx.
+func addVisit(tx TxRepository, v Visit, userID int64) (int64, error) {
+	// A rating of 0 means the user went but didn't rate it and is saved as no rating.
+	for _, vu := range v.VisitUsers {
+		if vu.Rating < 0 || vu.Rating > 5 {
+			return 0, errors.New("Ratings must be between 1 and 5")
+		}
+	}
+	visitID, err := tx.AddVisit(v)
+	if err != nil {
+		return 0, err
+	}
 	for i := range v.VisitUsers {
 		v.VisitUsers[i].VisitID = visitID
-		s.r.AddVisitUser(v.VisitUsers[i])
+		if _, err := tx.AddVisitUser(v.VisitUsers[i]); err != nil {
+			return 0, err
+		}
 	}
-
-	s.r.Commit()
-
-	return visitID, nil
+	saved, err := audit.GetVisit(tx, visitID, v.RestaurantID)
+	if err != nil {
+		return 0, err
+	}
+	return visitID, audit.Record(tx, userID, audit.EntityVisit, visitID, v.RestaurantID, audit.ActionCreate, nil, saved)
 }
 
 func checkRestaurantData(r Restaurant) error {
@@ -179,8 +261,8 @@ func checkRestaurantData(r Restaurant) error {
 		return fmt.Errorf("You must provide a city and state for %s", r.Name)
 	}
 
-	if utf8.RuneCountInString(r.CityState.State) > 2 {
-		return fmt.Errorf("State is limited to 2 characters")
+	if utf8.RuneCountInString(r.CityState.State) != 2 {
+		return fmt.Errorf("State must be 2 characters")
 	}
 
 	// Check that Cuisine is not null
@@ -191,15 +273,17 @@ func checkRestaurantData(r Restaurant) error {
 	return nil
 }
 
-func (s *service) AddUser(u User) (int64, error) {
+func (s *service) AddUser(u User, userID int64) (int64, error) {
 	if err := checkUserData(u); err != nil {
 		return 0, err
 	}
 	// Lower case it to normalize it.
 	u.Email = strings.ToLower(u.Email)
 	// Check email is not duplicate
-	if existingUser := s.r.GetUserBy("email", u.Email); existingUser.ID != 0 {
+	if _, err := s.r.GetUserBy("email", u.Email); err == nil {
 		return 0, errors.New("This user already exists")
+	} else if !storage.IsNotFound(err) {
+		return 0, err
 	}
 
 	// Hash password using the auther service
@@ -212,11 +296,30 @@ func (s *service) AddUser(u User) (int64, error) {
 	u.Password = ""
 
 	// Add the user
-	s.r.Begin()
-	// Defer rollback just in case there is a problem.
-	defer s.r.Rollback()
-	newUserID := s.r.AddUser(u)
-	s.r.Commit()
+	var newUserID int64
+	err = s.r.WithTx(func(tx TxRepository) error {
+		// The first user is the admin.
+		userCount, err := tx.GetUserCount()
+		if err != nil {
+			return err
+		}
+		u.IsAdmin = userCount == 0
+		newUserID, err = tx.AddUser(u)
+		if storage.IsUniqueViolation(err) {
+			// Another request added the same email after we checked above.
+			return errors.New("This user already exists")
+		} else if err != nil {
+			return err
+		}
+		saved, err := tx.GetUser(newUserID)
+		if err != nil {
+			return err
+		}
+		return audit.Record(tx, userID, audit.EntityUser, newUserID, 0, audit.ActionCreate, nil, saved)
+	})
+	if err != nil {
+		return 0, err
+	}
 
 	return newUserID, nil
 }
@@ -250,6 +353,91 @@ func checkUserData(u User) error {
 	return nil
 }
 
+// AddSavedView saves a view for the user who is adding it. Making it the default replaces the user's default view.
+func (s *service) AddSavedView(v SavedView, userID int64) (int64, error) {
+	query, err := lister.CheckSavedView(v.Name, v.Query)
+	if err != nil {
+		return 0, err
+	}
+	v.Query = query
+	v.UserID = userID
+
+	var newViewID int64
+	err = s.r.WithTx(func(tx TxRepository) error {
+		if v.IsDefault {
+			if _, err := tx.ClearDefaultSavedView(userID); err != nil {
+				return err
+			}
+		}
+		var err error
+		newViewID, err = tx.AddSavedView(v)
+		if storage.IsUniqueViolation(err) {
+			return fmt.Errorf("You already have a view called %s", v.Name)
+		} else if err != nil {
+			return err
+		}
+		saved, err := tx.GetSavedView(newViewID)
+		if err != nil {
+			return err
+		}
+		return audit.Record(tx, userID, audit.EntitySavedView, newViewID, 0, audit.ActionCreate, nil, saved)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return newViewID, nil
+}
+
+// AddAPIToken makes a new API token for the user who is adding it.
+func (s *service) AddAPIToken(t APIToken, userID int64) (int64, string, error) {
+	t.Name = strings.TrimSpace(t.Name)
+	if t.Name == "" {
+		return 0, "", errors.New("A name is required")
+	}
+	if utf8.RuneCountInString(t.Name) > 100 {
+		return 0, "", errors.New("The name is limited to 100 characters")
+	}
+	now := time.Now().UTC()
+	t.ExpiresAt = ""
+	if t.ExpiresOn != "" {
+		expiresOn, err := time.Parse("2006-01-02", t.ExpiresOn)
+		if err != nil {
+			return 0, "", errors.New("The expiry date must be in YYYY-MM-DD format")
+		}
+		if !expiresOn.After(now) {
+			return 0, "", errors.New("The expiry date must be in the future")
+		}
+		t.ExpiresAt = expiresOn.Format(time.RFC3339)
+	}
+	token, err := auther.NewAPIToken()
+	if err != nil {
+		return 0, "", errors.New("There was an error generating the token")
+	}
+	t.TokenHash = auther.HashAPIToken(token)
+	t.UserID = userID
+	t.CreatedAt = now.Format(time.RFC3339)
+
+	var newTokenID int64
+	err = s.r.WithTx(func(tx TxRepository) error {
+		var err error
+		newTokenID, err = tx.AddAPIToken(t)
+		if storage.IsUniqueViolation(err) {
+			return fmt.Errorf("You already have a token called %s", t.Name)
+		} else if err != nil {
+			return err
+		}
+		saved, err := tx.GetAPIToken(newTokenID)
+		if err != nil {
+			return err
+		}
+		return audit.Record(tx, userID, audit.EntityAPIToken, newTokenID, 0, audit.ActionCreate, nil, saved)
+	})
+	if err != nil {
+		return 0, "", err
+	}
+	return newTokenID, token, nil
+}
+
 // NewService creates an adding service with the necessary dependencies
 func NewService(r Repository, m Map) Service {
 	return &service{r, m}
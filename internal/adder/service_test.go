@@ -0,0 +1,342 @@
+package adder_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/kelvinatorr/restaurant-tracker/internal/adder"
+	"github.com/kelvinatorr/restaurant-tracker/internal/lister"
+	"github.com/kelvinatorr/restaurant-tracker/internal/mapper"
+	"github.com/kelvinatorr/restaurant-tracker/internal/storage/memory"
+)
+
+// newService returns an adder backed by an empty in-memory storage. Nothing is looked up on Google Maps so the mapper
+// doesn't need a key.
+func newService() (adder.Service, *memory.Storage) {
+	s := memory.NewStorage()
+	return adder.NewService(s.Adder(), mapper.NewService("")), s
+}
+
+func newUser(t *testing.T, a adder.Service, email string) int64 {
+	t.Helper()
+	id, err := a.AddUser(adder.User{FirstName: "Alex", LastName: "Rivera", Email: email, Password: "pw",
+		RepeatPassword: "pw"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func pho() adder.Restaurant {
+	return adder.Restaurant{Name: "Pho Saigon", Cuisine: "Vietnamese",
+		CityState: adder.CityState{Name: "Seattle", State: "WA"}}
+}
+
+func TestAddRestaurant(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  func(r *adder.Restaurant)
+		wantErr string
+	}{
+		{"valid", func(r *adder.Restaurant) {}, ""},
+		{"no name", func(r *adder.Restaurant) { r.Name = "" }, "A name is required"},
+		{"no cuisine", func(r *adder.Restaurant) { r.Cuisine = "" }, "You must provide a cuisine"},
+		{"no city", func(r *adder.Restaurant) { r.CityState.Name = "" },
+			"You must provide a city and state for Pho Saigon"},
+		{"long state", func(r *adder.Restaurant) { r.CityState.State = "WAS" }, "State must be 2 characters"},
+		{"short state", func(r *adder.Restaurant) { r.CityState.State = "W" }, "State must be 2 characters"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, s := newService()
+			r := pho()
+			tt.change(&r)
+			id, err := a.AddRestaurant(r, 0)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, err := s.GetRestaurant(id); err != nil {
+					t.Errorf("GetRestaurant() of the new restaurant returned %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("AddRestaurant() returned %v, want %s", err, tt.wantErr)
+			}
+			if n, _ := s.CountRestaurants(nil); n != 0 {
+				t.Errorf("%d restaurants were added, want 0", n)
+			}
+		})
+	}
+}
+
+func TestAddRestaurantDuplicate(t *testing.T) {
+	a, _ := newService()
+	if _, err := a.AddRestaurant(pho(), 0); err != nil {
+		t.Fatal(err)
+	}
+	r := pho()
+	r.Name = "PHO SAIGON"
+	_, err := a.AddRestaurant(r, 0)
+	var dup *adder.ErrDuplicate
+	if !errors.As(err, &dup) {
+		t.Errorf("AddRestaurant() of a duplicate returned %v, want an ErrDuplicate", err)
+	}
+}
+
+func TestAddVisit(t *testing.T) {
+	tests := []struct {
+		name    string
+		visit   func(restaurantID int64, userID int64) adder.Visit
+		wantErr string
+	}{
+		{"valid", func(restaurantID int64, userID int64) adder.Visit {
+			return adder.Visit{RestaurantID: restaurantID, VisitDateTime: "2021-03-04",
+				VisitUsers: []adder.VisitUser{{UserID: userID, Rating: 4}}}
+		}, ""},
+		{"no rating", func(restaurantID int64, userID int64) adder.Visit {
+			return adder.Visit{RestaurantID: restaurantID, VisitDateTime: "2021-03-04",
+				VisitUsers: []adder.VisitUser{{UserID: userID}}}
+		}, ""},
+		{"bad date", func(restaurantID int64, userID int64) adder.Visit {
+			return adder.Visit{RestaurantID: restaurantID, VisitDateTime: "03/04/2021"}
+		}, "Cannot format 03/04/2021 as date"},
+		{"rating too high", func(restaurantID int64, userID int64) adder.Visit {
+			return adder.Visit{RestaurantID: restaurantID, VisitDateTime: "2021-03-04",
+				VisitUsers: []adder.VisitUser{{UserID: userID, Rating: 6}}}
+		}, "Ratings must be between 1 and 5"},
+		{"missing restaurant", func(restaurantID int64, userID int64) adder.Visit {
+			return adder.Visit{RestaurantID: restaurantID + 1, VisitDateTime: "2021-03-04"}
+		}, "There is no restaurant with id: 2."},
+		{"missing user", func(restaurantID int64, userID int64) adder.Visit {
+			return adder.Visit{RestaurantID: restaurantID, VisitDateTime: "2021-03-04",
+				VisitUsers: []adder.VisitUser{{UserID: userID + 1, Rating: 3}}}
+		}, "There is no user with id: 2."},
+		{"user twice", func(restaurantID int64, userID int64) adder.Visit {
+			return adder.Visit{RestaurantID: restaurantID, VisitDateTime: "2021-03-04",
+				VisitUsers: []adder.VisitUser{{UserID: userID, Rating: 3}, {UserID: userID, Rating: 4}}}
+		}, "The data has multiple users with id: 1."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, s := newService()
+			userID := newUser(t, a, "alex@example.com")
+			restaurantID, err := a.AddRestaurant(pho(), userID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = a.AddVisit(tt.visit(restaurantID, userID), userID)
+			if tt.wantErr == "" && err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+				t.Errorf("AddVisit() returned %v, want %s", err, tt.wantErr)
+			}
+			visits, err := s.GetVisits(nil, nil, lister.PageOperation{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := map[bool]int{true: 1, false: 0}[tt.wantErr == ""]; len(visits) != want {
+				t.Errorf("%d visits were added, want %d", len(visits), want)
+			}
+		})
+	}
+}
+
+func TestAddUser(t *testing.T) {
+	tests := []struct {
+		name    string
+		user    adder.User
+		wantErr string
+	}{
+		{"no name", adder.User{LastName: "Rivera", Email: "sam@example.com", Password: "pw", RepeatPassword: "pw"},
+			"First name and last name are required"},
+		{"no email", adder.User{FirstName: "Sam", LastName: "Rivera", Password: "pw", RepeatPassword: "pw"},
+			"An email address is required"},
+		{"passwords differ", adder.User{FirstName: "Sam", LastName: "Rivera", Email: "sam@example.com",
+			Password: "pw", RepeatPassword: "wp"}, "Passwords do not match"},
+		{"bad email", adder.User{FirstName: "Sam", LastName: "Rivera", Email: "sam", Password: "pw",
+			RepeatPassword: "pw"}, "Invalid email address"},
+		{"existing email", adder.User{FirstName: "Sam", LastName: "Rivera", Email: "ALEX@example.com",
+			Password: "pw", RepeatPassword: "pw"}, "This user already exists"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, s := newService()
+			newUser(t, a, "alex@example.com")
+			_, err := a.AddUser(tt.user, 0)
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("AddUser() returned %v, want %s", err, tt.wantErr)
+			}
+			if n, _ := s.GetUserCount(); n != 1 {
+				t.Errorf("There are %d users, want 1", n)
+			}
+		})
+	}
+}
+
+// TestUniqueViolations checks that a write the storage rejects as a duplicate is reported by what was duplicated.
+func TestUniqueViolations(t *testing.T) {
+	tests := []struct {
+		name    string
+		add     func(a adder.Service, userID int64) error
+		wantErr string
+	}{
+		{"saved view", func(a adder.Service, userID int64) error {
+			_, err := a.AddSavedView(adder.SavedView{Name: "Thai", Query: "filter=cuisine:Thai"}, userID)
+			return err
+		}, "You already have a view called Thai"},
+		{"api token", func(a adder.Service, userID int64) error {
+			_, _, err := a.AddAPIToken(adder.APIToken{Name: "backup script"}, userID)
+			return err
+		}, "You already have a token called backup script"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, _ := newService()
+			userID := newUser(t, a, "alex@example.com")
+			if err := tt.add(a, userID); err != nil {
+				t.Fatal(err)
+			}
+			if err := tt.add(a, userID); err == nil || err.Error() != tt.wantErr {
+				t.Errorf("Adding it again returned %v, want %s", err, tt.wantErr)
+			}
+			// Someone else can use the same name.
+			if err := tt.add(a, newUser(t, a, "sam@example.com")); err != nil {
+				t.Errorf("Adding it for another user returned %v", err)
+			}
+		})
+	}
+}
+
+func TestWithTxRollback(t *testing.T) {
+	_, s := newService()
+	errFail := errors.New("fail")
+	err := s.Adder().WithTx(func(tx adder.TxRepository) error {
+		if _, err := tx.AddCity("Seattle", "WA"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tx.AddUser(adder.User{FirstName: "Alex", LastName: "Rivera", Email: "alex@example.com",
+			PasswordHash: "hash"}); err != nil {
+			t.Fatal(err)
+		}
+		return errFail
+	})
+	if err != errFail {
+		t.Errorf("WithTx() returned %v, want the callback's error", err)
+	}
+	if id, _ := s.GetCityIDByNameAndState("Seattle", "WA"); id != 0 {
+		t.Error("The city added in the failed transaction was saved")
+	}
+	if n, _ := s.GetUserCount(); n != 0 {
+		t.Error("The user added in the failed transaction was saved")
+	}
+}
+
+func TestImportCSVRollback(t *testing.T) {
+	a, s := newService()
+	csv := strings.Join([]string{
+		"Name,Cuisine,City,State,Date",
+		"Pho Saigon,Vietnamese,Seattle,WA,2021-03-04",
+		"Bangkok Kitchen,Thai,Seattle,WA,2021-03-05",
+		"Taqueria,Mexican,Tacoma,WAS,2021-03-06",
+	}, "\n")
+	cols := adder.DefaultImportColumns
+	cols.VisitDate = "Date"
+	_, err := a.ImportCSV(strings.NewReader(csv), cols, false, 0)
+	if err == nil || err.Error() != "Row 4: State must be 2 characters" {
+		t.Errorf("ImportCSV() returned %v, want the error of row 4", err)
+	}
+	if n, _ := s.CountRestaurants(nil); n != 0 {
+		t.Errorf("%d restaurants were imported, want 0", n)
+	}
+
+	// Without the bad row everything is added, and importing it again adds nothing.
+	csv = csv[:strings.LastIndex(csv, "\n")]
+	for i, want := range []int{2, 0} {
+		result, err := a.ImportCSV(strings.NewReader(csv), cols, false, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result.Restaurants) != want || len(result.Duplicates) != 2-want {
+			t.Errorf("Import %d added %d and skipped %d restaurants, want %d and %d", i+1, len(result.Restaurants),
+				len(result.Duplicates), want, 2-want)
+		}
+	}
+}
+
+func TestImportJSON(t *testing.T) {
+	tests := []struct {
+		name        string
+		createUsers bool
+		ratings     []lister.ExportRating
+		wantErr     string
+		wantUsers   int64
+	}{
+		{"missing user", false, []lister.ExportRating{{UserID: 7, Rating: 4}, {UserID: 9, Rating: 3}},
+			"Add users for sam@example.com before importing their visits, or let the import create them", 1},
+		{"create user", true, []lister.ExportRating{{UserID: 7, Rating: 4}, {UserID: 9, Rating: 3}}, "", 2},
+		{"no rating", false, []lister.ExportRating{{UserID: 7, Rating: 0}}, "", 1},
+		{"rating too high", true, []lister.ExportRating{{UserID: 7, Rating: 6}},
+			"Restaurant 1: 6 is not a rating from 1 to 5, or 0 for no rating", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, s := newService()
+			newUser(t, a, "alex@example.com")
+			b, err := json.Marshal(lister.Export{
+				Version: lister.ExportVersion,
+				Users: []lister.User{
+					{ID: 7, FirstName: "Alex", LastName: "Rivera", Email: "alex@example.com"},
+					{ID: 9, FirstName: "Sam", LastName: "Chen", Email: "Sam@example.com"},
+				},
+				Restaurants: []lister.ExportRestaurant{{Name: "Pho Saigon", Cuisine: "Vietnamese", City: "Seattle",
+					State: "WA", Visits: []lister.ExportVisit{
+						{VisitDateTime: "2021-03-04T18:30:00-07:00", Ratings: tt.ratings},
+					}}},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := a.ImportJSON(bytes.NewReader(b), false, tt.createUsers, 0)
+			if n, _ := s.GetUserCount(); n != tt.wantUsers {
+				t.Errorf("There are %d users, want %d", n, tt.wantUsers)
+			}
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("ImportJSON() returned %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if int64(len(result.Users)) != tt.wantUsers-1 || tt.wantUsers > 1 && result.Users[0].Password == "" {
+				t.Errorf("ImportJSON() created %+v", result.Users)
+			}
+			visits, err := s.GetVisits(nil, nil, lister.PageOperation{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(visits) != 1 || visits[0].VisitDateTime != "2021-03-05T01:30:00Z" {
+				t.Fatalf("The imported visits are %+v, want one on 2021-03-05T01:30:00Z", visits)
+			}
+			vus, err := s.GetVisitUsersByVisitID(visits[0].ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(vus) != len(tt.ratings) {
+				t.Errorf("The visit has %d users, want %d", len(vus), len(tt.ratings))
+			}
+			for i, vu := range vus {
+				if vu.Rating != tt.ratings[i].Rating {
+					t.Errorf("User %d rated it %d, want %d", vu.User.ID, vu.Rating, tt.ratings[i].Rating)
+				}
+			}
+		})
+	}
+}
@@ -7,4 +7,5 @@ type User struct {
 	Password       string `json:"password" schema:"password,required"`
 	RepeatPassword string `schema:"repeatPassword,required"`
 	PasswordHash   string `schema:"-"`
+	IsAdmin        bool   `schema:"-"`
 }
@@ -0,0 +1,10 @@
+package adder
+
+// SavedView is a named filter and sort of the restaurant list. UserID is the user who owns it.
+type SavedView struct {
+	UserID    int64  `json:"user_id" schema:"-"`
+	Name      string `json:"name" schema:"name,required"`
+	Query     string `json:"query" schema:"query"`
+	Shared    bool   `json:"shared" schema:"shared"`
+	IsDefault bool   `json:"is_default" schema:"isDefault"`
+}
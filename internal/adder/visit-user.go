@@ -1,7 +1,7 @@
 package adder
 
 type VisitUser struct {
-	VisitID int64
+	VisitID int64 `json:"-"`
 	UserID  int64 `json:"user_id"`
 	Rating  int64 `json:"rating"`
 }
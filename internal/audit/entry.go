@@ -0,0 +1,142 @@
+package audit
+
+import (
+	"encoding/json"
+	"reflect"
+	"time"
+)
+
+// The actions an Entry can record.
+const (
+	ActionCreate  = "create"
+	ActionUpdate  = "update"
+	ActionDelete  = "delete"
+	ActionRestore = "restore"
+	ActionPurge   = "purge"
+)
+
+// The entity types an Entry can be about.
+const (
+	EntityRestaurant = "restaurant"
+	EntityVisit      = "visit"
+	EntityUser       = "user"
+	EntityGmapsPlace = "gmaps_place"
+	EntitySavedView  = "saved_view"
+	EntityAPIToken   = "api_token"
+)
+
+// Entry is one change to the audit log.
+type Entry struct {
+	// UserID is the user who made the change. It is 0 when no user did, e.g. the initial signup or a purge of the trash.
+	UserID     int64
+	EntityType string
+	EntityID   int64
+	// RestaurantID is the restaurant the entity belongs to so a restaurant's history includes its visits. It is the
+	// entity's own id for restaurants and 0 for things that don't belong to a restaurant.
+	RestaurantID int64
+	Action       string
+	// CreatedAt is when the change was made in RFC3339 UTC.
+	CreatedAt string
+	// Diff is a JSON object of the fields that changed, see Diff.
+	Diff string
+}
+
+// Change is the value of a field before and after a change.
+type Change struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// NewEntry returns an Entry made now with the diff between before and after.
+func NewEntry(userID int64, entityType string, entityID int64, restaurantID int64, action string, before interface{},
+	after interface{}) (Entry, error) {
+	diff, err := Diff(before, after)
+	if err != nil {
+		return Entry{}, err
+	}
+	return Entry{
+		UserID:       userID,
+		EntityType:   entityType,
+		EntityID:     entityID,
+		RestaurantID: restaurantID,
+		Action:       action,
+		CreatedAt:    time.Now().UTC().Format(time.RFC3339),
+		Diff:         diff,
+	}, nil
+}
+
+// Diff returns a JSON object with a Change for every field whose JSON value is different between before and after.
+// Nested objects are flattened so a changed city is "city_state.name". before or after can be nil for things that were
+// created or removed, in which case every field that isn't null is in the diff.
+func Diff(before interface{}, after interface{}) (string, error) {
+	beforeFields, err := fields(before)
+	if err != nil {
+		return "", err
+	}
+	afterFields, err := fields(after)
+	if err != nil {
+		return "", err
+	}
+
+	changes := make(map[string]Change)
+	if before == nil {
+		removeNull(afterFields)
+	}
+	if after == nil {
+		removeNull(beforeFields)
+	}
+	for k, b := range beforeFields {
+		if a, ok := afterFields[k]; !ok || !reflect.DeepEqual(a, b) {
+			changes[k] = Change{Before: b, After: a}
+		}
+	}
+	for k, a := range afterFields {
+		if _, ok := beforeFields[k]; !ok {
+			changes[k] = Change{After: a}
+		}
+	}
+	// encoding/json sorts the keys of a map so the same change always has the same diff.
+	diff, err := json.Marshal(changes)
+	if err != nil {
+		return "", err
+	}
+	return string(diff), nil
+}
+
+// fields returns the JSON fields of v with nested objects flattened into dotted names.
+func fields(v interface{}) (map[string]interface{}, error) {
+	flat := make(map[string]interface{})
+	if v == nil {
+		return flat, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var obj map[string]interface{}
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return nil, err
+	}
+	flatten("", obj, flat)
+	return flat, nil
+}
+
+func flatten(prefix string, obj map[string]interface{}, flat map[string]interface{}) {
+	for k, v := range obj {
+		if nested, ok := v.(map[string]interface{}); ok {
+			flatten(prefix+k+".", nested, flat)
+			continue
+		}
+		flat[prefix+k] = v
+	}
+}
+
+// removeNull deletes the fields whose JSON value is null, like the Google Maps place of a restaurant that doesn't have
+// one. Zero values are kept because they were really saved, like a rating of 0.
+func removeNull(flat map[string]interface{}) {
+	for k, v := range flat {
+		if v == nil {
+			delete(flat, k)
+		}
+	}
+}
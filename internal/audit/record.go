@@ -0,0 +1,39 @@
+package audit
+
+import "github.com/kelvinatorr/restaurant-tracker/internal/lister"
+
+// Repository saves entries to the audit log.
+type Repository interface {
+	AddAuditEntry(Entry) (int64, error)
+}
+
+// VisitRepository gets a visit and its users.
+type VisitRepository interface {
+	GetVisit(int64, int64) (lister.Visit, error)
+	GetVisitUsersByVisitID(int64) ([]lister.VisitUser, error)
+}
+
+// Record adds an entry for a change to the audit log. r should be the transaction the change was made in so the entry
+// is only saved if the change is.
+func Record(r Repository, userID int64, entityType string, entityID int64, restaurantID int64, action string,
+	before interface{}, after interface{}) error {
+	e, err := NewEntry(userID, entityType, entityID, restaurantID, action, before, after)
+	if err != nil {
+		return err
+	}
+	_, err = r.AddAuditEntry(e)
+	return err
+}
+
+// GetVisit returns what Visit records for the visit with the given id and restaurant id.
+func GetVisit(r VisitRepository, id int64, restaurantID int64) (map[string]interface{}, error) {
+	v, err := r.GetVisit(id, restaurantID)
+	if err != nil {
+		return nil, err
+	}
+	visitUsers, err := r.GetVisitUsersByVisitID(id)
+	if err != nil {
+		return nil, err
+	}
+	return Visit(v, visitUsers), nil
+}
@@ -0,0 +1,44 @@
+package audit
+
+import (
+	"fmt"
+
+	"github.com/kelvinatorr/restaurant-tracker/internal/lister"
+)
+
+// Restaurant returns the fields of a restaurant that are recorded in the audit log. Fields that are worked out from its
+// visits are left out because they don't change when the restaurant does.
+func Restaurant(r lister.Restaurant) map[string]interface{} {
+	// A restaurant without a Google Maps place has null for it rather than a place with every field empty.
+	var gmapsPlace interface{}
+	if r.GmapsPlace.ID != 0 {
+		gmapsPlace = r.GmapsPlace
+	}
+	return map[string]interface{}{
+		"name":            r.Name,
+		"cuisine":         r.Cuisine,
+		"business_status": r.BusinessStatus,
+		"note":            r.Note,
+		"address":         r.Address,
+		"city_state":      r.CityState,
+		"zipcode":         r.Zipcode,
+		"latitude":        r.Latitude,
+		"longitude":       r.Longitude,
+		"gmaps_place":     gmapsPlace,
+	}
+}
+
+// Visit returns the fields of a visit and its users' ratings that are recorded in the audit log. Ratings are keyed by
+// the user's name so the diff says whose rating changed.
+func Visit(v lister.Visit, visitUsers []lister.VisitUser) map[string]interface{} {
+	ratings := make(map[string]int64)
+	for _, vu := range visitUsers {
+		ratings[fmt.Sprintf("%s %s", vu.User.FirstName, vu.User.LastName)] = vu.Rating
+	}
+	return map[string]interface{}{
+		"restaurant_id":  v.RestaurantID,
+		"visit_datetime": v.VisitDateTime,
+		"note":           v.Note,
+		"ratings":        ratings,
+	}
+}
@@ -0,0 +1,71 @@
+package auther
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kelvinatorr/restaurant-tracker/internal/storage"
+)
+
+// APITokenPrefix starts every API token so one can be told apart from a JWT.
+const APITokenPrefix = "rtpat_"
+
+// lastUsedInterval is how stale an API token's last used time can get before it is saved again, so a script making
+// lots of requests doesn't write to the database on every one.
+const lastUsedInterval = time.Minute
+
+// APIToken is what is needed to check an API token.
+type APIToken struct {
+	ID       int64
+	UserID   int64
+	ReadOnly bool
+	// ExpiresAt and LastUsedAt are RFC3339 UTC, or empty if it never expires or hasn't been used.
+	ExpiresAt  string
+	LastUsedAt string
+}
+
+// NewAPIToken returns a new random API token.
+func NewAPIToken() (string, error) {
+	s, err := genRandomString(rememberTokenBytes)
+	if err != nil {
+		return "", err
+	}
+	return APITokenPrefix + s, nil
+}
+
+// IsAPIToken is true when a bearer token looks like an API token rather than a JWT.
+func IsAPIToken(token string) bool {
+	return strings.HasPrefix(token, APITokenPrefix)
+}
+
+// HashAPIToken returns the hash of an API token that is saved instead of the token. The tokens are random so, unlike
+// passwords, they don't need a slow hash.
+func HashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CheckAPIToken returns the API token if it exists and hasn't expired, and saves when it was used.
+func (s service) CheckAPIToken(token string) (APIToken, error) {
+	t, err := s.r.GetAPITokenByHash(HashAPIToken(token))
+	if storage.IsNotFound(err) {
+		return APIToken{}, fmt.Errorf("API token is not valid")
+	} else if err != nil {
+		return APIToken{}, err
+	}
+	now := time.Now().UTC()
+	if t.ExpiresAt != "" && t.ExpiresAt <= now.Format(time.RFC3339) {
+		return APIToken{}, fmt.Errorf("API token has expired")
+	}
+	lastUsed, err := time.Parse(time.RFC3339, t.LastUsedAt)
+	if err != nil || now.Sub(lastUsed) >= lastUsedInterval {
+		t.LastUsedAt = now.Format(time.RFC3339)
+		if _, err := s.r.UpdateAPITokenLastUsed(t.ID, t.LastUsedAt); err != nil {
+			return APIToken{}, err
+		}
+	}
+	return t, nil
+}
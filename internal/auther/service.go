@@ -11,6 +11,7 @@ import (
 	"log"
 	"strings"
 
+	"github.com/kelvinatorr/restaurant-tracker/internal/storage"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -19,16 +20,24 @@ type Service interface {
 	SignIn(UserSignIn) (string, error)
 	CheckJWT(string) error
 	GetCookiePayload(string) (UserJWT, error)
+	CheckAPIToken(string) (APIToken, error)
+}
+
+// TxRepository provides access to User repository within a transaction.
+type TxRepository interface {
+	GetUserAuthByEmail(string) (User, error)
+	GetUserAuthByID(int64) (User, error)
+	UpdateUserRememberToken(User) (int64, error)
+	GetAPITokenByHash(string) (APIToken, error)
+	UpdateAPITokenLastUsed(id int64, lastUsedAt string) (int64, error)
 }
 
 // Repository provides access to User repository.
 type Repository interface {
-	Begin()
-	Commit()
-	Rollback()
-	GetUserAuthByEmail(string) User
-	GetUserAuthByID(int64) User
-	UpdateUserRememberToken(User) int64
+	TxRepository
+	// WithTx calls fn with a TxRepository bound to a new transaction. The transaction is committed if fn returns nil
+	// and rolled back otherwise.
+	WithTx(fn func(TxRepository) error) error
 }
 
 type service struct {
@@ -38,15 +47,20 @@ type service struct {
 
 const rememberTokenBytes int = 32
 
+// temporaryPasswordBytes makes a 16 character temporary password.
+const temporaryPasswordBytes int = 12
+
 func (s service) SignIn(u UserSignIn) (string, error) {
 	var err error
 	// Lower case to normalize it.
 	u.Email = strings.ToLower(u.Email)
 	// Check this email exists
-	foundUser := s.r.GetUserAuthByEmail(u.Email)
-	if foundUser.ID == 0 {
+	foundUser, err := s.r.GetUserAuthByEmail(u.Email)
+	if storage.IsNotFound(err) {
 		err = fmt.Errorf("There is no user with this email address")
 		return "", err
+	} else if err != nil {
+		return "", err
 	}
 
 	// Check the password
@@ -56,21 +70,35 @@ func (s service) SignIn(u UserSignIn) (string, error) {
 	}
 
 	if foundUser.RememberToken == "" {
-		log.Printf("%s has no remember_hash. Generating...", u.Email)
-		// Generate a new remember hash
-		rT, err := rememberToken()
+		err = s.r.WithTx(func(tx TxRepository) error {
+			// Read the user again in this transaction in case a concurrent sign in has already saved a remember
+			// token. Overwriting it would sign that session out.
+			txUser, err := tx.GetUserAuthByID(foundUser.ID)
+			if err != nil {
+				return err
+			}
+			if txUser.RememberToken != "" {
+				foundUser.RememberToken = txUser.RememberToken
+				return nil
+			}
+			log.Printf("%s has no remember_hash. Generating...", u.Email)
+			// Generate a new remember hash
+			rT, err := rememberToken()
+			if err != nil {
+				return fmt.Errorf("There was an error generating a remember token")
+			}
+			foundUser.RememberToken = rT
+			// Save it to the database
+			recordsAffected, err := tx.UpdateUserRememberToken(foundUser)
+			if err != nil {
+				return err
+			}
+			log.Printf("%d user records affected.", recordsAffected)
+			return nil
+		})
 		if err != nil {
-			err = fmt.Errorf("There was an error generating a remember token")
 			return "", err
 		}
-		foundUser.RememberToken = rT
-		// Save it to the database
-		s.r.Begin()
-		// Defer rollback just in case there is a problem.
-		defer s.r.Rollback()
-		recordsAffected := s.r.UpdateUserRememberToken(foundUser)
-		log.Printf("%d user records affected.", recordsAffected)
-		s.r.Commit()
 	}
 
 	// Generate new jwt
@@ -108,9 +136,11 @@ func (s service) CheckJWT(jwt string) error {
 		return err
 	}
 	// Check the token is still valid
-	foundUser := s.r.GetUserAuthByID(uJWT.ID)
-	if foundUser.ID == 0 {
+	foundUser, err := s.r.GetUserAuthByID(uJWT.ID)
+	if storage.IsNotFound(err) {
 		return fmt.Errorf("JWT has a non-existent user")
+	} else if err != nil {
+		return err
 	}
 	if foundUser.RememberToken != uJWT.RememberToken {
 		return fmt.Errorf("JWT has an invalid remember token")
@@ -158,6 +188,11 @@ func HashPassword(password string) (string, error) {
 	return string(hashedBytes), nil
 }
 
+// NewTemporaryPassword returns a random password for a user who was added without one, like a user an import created.
+func NewTemporaryPassword() (string, error) {
+	return genRandomString(temporaryPasswordBytes)
+}
+
 func decodeCookiePayload(payload string) (UserJWT, error) {
 	var uJWT UserJWT
 	pB, err := base64.RawURLEncoding.DecodeString(payload)
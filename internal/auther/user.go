@@ -1,8 +1,8 @@
 package auther
 
 type UserSignIn struct {
-	Email    string
-	Password string
+	Email    string `json:"email"`
+	Password string `json:"password"`
 }
 
 type User struct {
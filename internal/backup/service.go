@@ -0,0 +1,144 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	filePrefix = "restaurant-tracker-"
+	fileSuffix = ".db"
+	// timeFormat sorts in time order so the newest backup has the last file name.
+	timeFormat = "20060102T150405Z"
+)
+
+// Service provides backup operations.
+type Service interface {
+	// Backup writes a new backup to the backup directory, removes the backups past the retention limit and returns
+	// the new backup's path.
+	Backup() (string, error)
+	// Snapshot writes a new backup to a temporary file and returns it open for reading. Closing it deletes it.
+	Snapshot() (*TempFile, error)
+	// Run calls Backup every interval until stop is closed.
+	Run(interval time.Duration, stop <-chan struct{})
+}
+
+// Repository writes a consistent copy of the database to a new file at the given path.
+type Repository interface {
+	Backup(string) error
+}
+
+// TempFile is a backup that is deleted when it is closed.
+type TempFile struct {
+	*os.File
+	dir string
+}
+
+// Close closes the file and deletes it.
+func (f *TempFile) Close() error {
+	defer os.RemoveAll(f.dir)
+	return f.File.Close()
+}
+
+type service struct {
+	r    Repository
+	dir  string
+	keep int
+}
+
+// FileName returns the name of a backup made at t.
+func FileName(t time.Time) string {
+	return filePrefix + t.UTC().Format(timeFormat) + fileSuffix
+}
+
+func (s service) Backup() (string, error) {
+	if s.dir == "" {
+		return "", errors.New("No backup directory is set")
+	}
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return "", err
+	}
+	path := filepath.Join(s.dir, FileName(time.Now()))
+	if err := s.r.Backup(path); err != nil {
+		// Don't leave a partial backup behind to be mistaken for a good one.
+		os.Remove(path)
+		return "", err
+	}
+	return path, s.removeOld()
+}
+
+// removeOld deletes all but the newest keep backups in the backup directory. Other files are left alone.
+func (s service) removeOld() error {
+	if s.keep <= 0 {
+		return nil
+	}
+	files, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+	var backups []string
+	for _, f := range files {
+		name := f.Name()
+		if !f.IsDir() && strings.HasPrefix(name, filePrefix) && strings.HasSuffix(name, fileSuffix) {
+			backups = append(backups, name)
+		}
+	}
+	sort.Strings(backups)
+	for i := 0; i < len(backups)-s.keep; i++ {
+		path := filepath.Join(s.dir, backups[i])
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		log.Printf("Removed old backup: %s\n", path)
+	}
+	return nil
+}
+
+func (s service) Snapshot() (*TempFile, error) {
+	tmpDir, err := ioutil.TempDir("", "restaurant-tracker-backup")
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(tmpDir, FileName(time.Now()))
+	if err := s.r.Backup(path); err != nil {
+		os.RemoveAll(tmpDir)
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		os.RemoveAll(tmpDir)
+		return nil, err
+	}
+	return &TempFile{f, tmpDir}, nil
+}
+
+func (s service) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			path, err := s.Backup()
+			if err != nil {
+				log.Println(fmt.Sprintf("Scheduled backup failed: %s", err))
+				continue
+			}
+			log.Printf("Wrote backup: %s\n", path)
+		case <-stop:
+			return
+		}
+	}
+}
+
+// NewService returns a new backup.service that writes backups to dir and keeps the newest keep of them. keep <= 0
+// keeps every backup.
+func NewService(r Repository, dir string, keep int) Service {
+	return service{r, dir, keep}
+}
@@ -0,0 +1,479 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/kelvinatorr/restaurant-tracker/internal/adder"
+	"github.com/kelvinatorr/restaurant-tracker/internal/auther"
+	"github.com/kelvinatorr/restaurant-tracker/internal/lister"
+	"github.com/kelvinatorr/restaurant-tracker/internal/mapper"
+	"github.com/kelvinatorr/restaurant-tracker/internal/remover"
+	"github.com/kelvinatorr/restaurant-tracker/internal/storage"
+	"github.com/kelvinatorr/restaurant-tracker/internal/updater"
+)
+
+// Path is where the API is served. Every route starts with it.
+const Path = "/api/v1"
+
+type contextKey int
+
+const (
+	contextKeyUser contextKey = iota
+)
+
+// errorResponse is the body of every response that isn't a success.
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// tokenResponse is the body of a response to sign-in.
+type tokenResponse struct {
+	Token string `json:"token"`
+}
+
+// filtersResponse is the body of a response listing the fields that can be filtered by. Values has the values the
+// cuisine, city and state of a restaurant can be filtered to.
+type filtersResponse struct {
+	Fields []lister.FilterField `json:"fields"`
+	Values map[string][]string  `json:"values,omitempty"`
+}
+
+type sortsResponse struct {
+	Fields []string `json:"fields"`
+}
+
+type responseMessage struct {
+	Message string `json:"message"`
+}
+
+// idResponse is the body of a response to a request that added something.
+type idResponse struct {
+	ID int64 `json:"id"`
+}
+
+// route is an API endpoint. The OpenAPI document is made from the routes so it always matches them.
+type route struct {
+	method string
+	// path is the httprouter path under Path.
+	path string
+	// name is the operationId of the route in the OpenAPI document.
+	name    string
+	summary string
+	handle  httprouter.Handle
+	// public routes don't need a token.
+	public bool
+	query  []parameter
+	// body is the zero value of the request body's type, or nil for routes without one. pathFields are the body's
+	// fields that are taken from the path so they aren't required in it.
+	body       interface{}
+	pathFields []string
+	bodySchema *schema
+	// response is the zero value of the type of the body of a success, which has the status code status.
+	response interface{}
+	status   int
+}
+
+// listQuery are the query params that pick and page through a list of restaurants or visits.
+var listQuery = []parameter{
+	{Name: "filter", In: "query", Schema: &schema{Type: "string"},
+		Description: "A filter expression, e.g. (cuisine:Thai OR cuisine:Lao) AND avg_rating>=4. Fields can also be " +
+			"filtered with filter[field|operator]=value params."},
+	{Name: "sort", In: "query", Schema: &schema{Type: "string"},
+		Description: "The fields to sort by in order, e.g. avg_rating:desc,name:asc"},
+	{Name: "limit", In: "query", Schema: &schema{Type: "integer"}, Description: "How many to return, at most 200"},
+	{Name: "after", In: "query", Schema: &schema{Type: "string"}, Description: "The next cursor of the previous page"},
+	{Name: "before", In: "query", Schema: &schema{Type: "string"}, Description: "The prev cursor of the next page"},
+}
+
+// routes returns every endpoint of the API.
+func routes(l lister.Service, a adder.Service, u updater.Service, r remover.Service, auth auther.Service, m mapper.Service) []route {
+	return []route{
+		{method: http.MethodPost, path: "/sign-in", name: "signIn", handle: postSignIn(auth), public: true,
+			summary: "Get a token for the Authorization: Bearer header", body: auther.UserSignIn{},
+			response: tokenResponse{}},
+
+		// Restaurant Endpoints
+		{method: http.MethodGet, path: "/restaurants", name: "listRestaurants", handle: getRestaurants(l),
+			summary: "List a page of restaurants", query: listQuery, response: lister.RestaurantPage{}},
+		{method: http.MethodPost, path: "/restaurants", name: "addRestaurant", handle: addRestaurant(a),
+			summary: "Add a restaurant", body: adder.Restaurant{}, response: idResponse{}, status: http.StatusCreated},
+		{method: http.MethodGet, path: "/restaurants/:id", name: "getRestaurant", handle: getRestaurant(l),
+			summary: "Get a restaurant", response: lister.Restaurant{}},
+		{method: http.MethodPut, path: "/restaurants/:id", name: "updateRestaurant", handle: updateRestaurant(u, l),
+			summary: "Update a restaurant", body: updater.Restaurant{}, pathFields: []string{"id"},
+			response: lister.Restaurant{}},
+		{method: http.MethodDelete, path: "/restaurants/:id", name: "removeRestaurant", handle: removeRestaurant(r),
+			summary: "Move a restaurant and its visits to the trash", response: responseMessage{}},
+
+		// Visit Endpoints
+		{method: http.MethodGet, path: "/visits", name: "listVisits", handle: getAllVisits(l),
+			summary: "List a page of the visits to every restaurant", query: listQuery, response: lister.VisitPage{}},
+		{method: http.MethodGet, path: "/restaurants/:id/visits", name: "listRestaurantVisits", handle: getVisits(l),
+			summary: "List a page of a restaurant's visits", query: listQuery, response: lister.VisitPage{}},
+		{method: http.MethodPost, path: "/restaurants/:id/visits", name: "addVisit", handle: addVisit(a, l),
+			summary: "Add a visit to a restaurant", body: adder.Visit{}, pathFields: []string{"restaurant_id"},
+			response: idResponse{}, status: http.StatusCreated},
+		{method: http.MethodGet, path: "/restaurants/:id/visits/:visitID", name: "getVisit", handle: getVisit(l),
+			summary: "Get a visit", response: lister.Visit{}},
+		{method: http.MethodPut, path: "/restaurants/:id/visits/:visitID", name: "updateVisit",
+			handle: updateVisit(u, l), summary: "Update a visit", body: updater.Visit{},
+			pathFields: []string{"id", "restaurant_id"}, response: lister.Visit{}},
+		{method: http.MethodDelete, path: "/restaurants/:id/visits/:visitID", name: "removeVisit",
+			handle: removeVisit(r), summary: "Move a visit to the trash", response: responseMessage{}},
+
+		// User Endpoints
+		{method: http.MethodGet, path: "/users", name: "listUsers", handle: getUsers(l), summary: "List the users",
+			response: []lister.User{}},
+		{method: http.MethodPost, path: "/users", name: "addUser", handle: addUser(a), summary: "Add a user",
+			body: newUser{}, response: idResponse{}, status: http.StatusCreated},
+		{method: http.MethodGet, path: "/users/:id", name: "getUser", handle: getUser(l), summary: "Get a user",
+			response: lister.User{}},
+		{method: http.MethodPut, path: "/users/:id", name: "updateUser", handle: checkUser(updateUser(u, l)),
+			summary: "Update the signed in user", body: updater.User{}, pathFields: []string{"id"},
+			response: lister.User{}},
+		{method: http.MethodPut, path: "/users/:id/password", name: "changePassword",
+			handle: checkUser(updateUserPassword(u)), summary: "Change the signed in user's password",
+			body: changePassword{}, response: responseMessage{}},
+
+		// The fields restaurants and visits can be filtered and sorted by
+		{method: http.MethodGet, path: "/filters/:object", name: "listFilterFields", handle: getFilters(l),
+			summary: "List the fields restaurants or visits can be filtered by", response: filtersResponse{}},
+		{method: http.MethodGet, path: "/sorts/:object", name: "listSortFields", handle: getSorts(l),
+			summary: "List the fields restaurants or visits can be sorted by", response: sortsResponse{}},
+
+		// Google Maps Endpoints
+		{method: http.MethodGet, path: "/maps/place-search", name: "searchPlaces", handle: getPlaceSearch(m),
+			summary: "Search Google Maps for a place", response: []mapper.Candidate{},
+			query: []parameter{{Name: "searchTerm", In: "query", Required: true, Schema: &schema{Type: "string"}}}},
+		{method: http.MethodGet, path: "/maps/place-refresh/:placeID", name: "refreshPlace",
+			handle: getPlaceRefresh(m), summary: "Get the latest details of a Google Maps place",
+			response: mapper.PlaceDetail{}.Result},
+	}
+}
+
+// Handler sets the httprouter routes for the rest package. Every route except sign-in needs an API token or the token
+// from sign-in in an Authorization: Bearer header, and the request bodies are checked against the OpenAPI document served at SpecPath.
+func Handler(l lister.Service, a adder.Service, u updater.Service, r remover.Service, auth auther.Service, m mapper.Service, verbose bool) http.Handler {
+	router := httprouter.New()
+
+	g := newSchemaGenerator()
+	apiRoutes := routes(l, a, u, r, auth, m)
+	for i, rt := range apiRoutes {
+		if rt.status == 0 {
+			apiRoutes[i].status = http.StatusOK
+		}
+		h := rt.handle
+		if rt.body != nil {
+			apiRoutes[i].bodySchema = g.structSchema(reflect.TypeOf(rt.body), rt.pathFields)
+			h = validateBody(g, apiRoutes[i].bodySchema, h)
+		}
+		if !rt.public {
+			h = tokenRequired(h, auth, l)
+		}
+		router.Handle(rt.method, Path+rt.path, h)
+	}
+	router.GET(SpecPath, getSpec(newSpec(apiRoutes, g)))
+
+	router.PanicHandler = func(w http.ResponseWriter, r *http.Request, err interface{}) {
+		log.Printf("ERROR http rest handler: %s\n", err)
+		writeError(w, "The server encountered an error processing your request.", http.StatusInternalServerError)
+	}
+
+	router.NotFound = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, fmt.Sprintf("%s is not an API endpoint.", r.URL.Path), http.StatusNotFound)
+	})
+
+	router.MethodNotAllowed = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, fmt.Sprintf("%s can't be used on %s.", r.Method, r.URL.Path), http.StatusMethodNotAllowed)
+	})
+
+	if verbose {
+		return verboseLogger(router)
+	}
+	return router
+}
+
+// verboseLogger logs each request. Bodies aren't logged because sign-in and the user endpoints have passwords in them.
+func verboseLogger(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log.Printf("Received API %s with URL: %s\n", r.Method, r.URL)
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// tokenRequired checks the token in the Authorization header and saves its user to the context before calling
+// handler. The token is either the JWT from sign-in or an API token. Read-only API tokens can only GET.
+func tokenRequired(handler httprouter.Handle, auth auther.Service, l lister.Service) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" || token == r.Header.Get("Authorization") {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, "An Authorization: Bearer header with an API token or the token from sign-in is required",
+				http.StatusUnauthorized)
+			return
+		}
+		var userID int64
+		if auther.IsAPIToken(token) {
+			apiToken, err := auth.CheckAPIToken(token)
+			if err != nil {
+				log.Println(err.Error())
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				writeError(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+			if apiToken.ReadOnly && r.Method != http.MethodGet && r.Method != http.MethodHead {
+				writeError(w, "This API token is read-only", http.StatusForbidden)
+				return
+			}
+			userID = apiToken.UserID
+		} else {
+			if err := auth.CheckJWT(token); err != nil {
+				log.Println(err.Error())
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				writeError(w, "The token is not valid, sign in again", http.StatusUnauthorized)
+				return
+			}
+			// Decode the payload (we already know it is valid because it was checked above)
+			signedInUser, err := auth.GetCookiePayload(token)
+			if err != nil {
+				log.Println(err.Error())
+				writeError(w, "The token is not valid, sign in again", http.StatusUnauthorized)
+				return
+			}
+			userID = signedInUser.ID
+		}
+		user, err := l.GetUserByID(userID)
+		if err != nil {
+			log.Println(err.Error())
+			writeError(w, "The token's user no longer exists", http.StatusUnauthorized)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), contextKeyUser, user)
+		handler(w, r.WithContext(ctx), p)
+	}
+}
+
+func signedInUserID(r *http.Request) int64 {
+	user, _ := r.Context().Value(contextKeyUser).(lister.User)
+	return user.ID
+}
+
+// writeJSON writes v as the JSON body of a response with the given status code.
+func writeJSON(w http.ResponseWriter, v interface{}, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes an error response with the message in the body.
+func writeError(w http.ResponseWriter, msg string, status int) {
+	writeJSON(w, errorResponse{Error: msg}, status)
+}
+
+// writeServiceError writes an error returned by a service. Errors for something that doesn't exist or would be a
+// duplicate get their own status codes and the rest get status.
+func writeServiceError(w http.ResponseWriter, err error, status int) {
+	log.Println(err.Error())
+	switch err.(type) {
+	case *lister.ErrDoesNotExist:
+		status = http.StatusNotFound
+	case *adder.ErrDuplicate:
+		status = http.StatusConflict
+	default:
+		if storage.IsNotFound(err) {
+			status = http.StatusNotFound
+		} else if storage.IsUniqueViolation(err) {
+			status = http.StatusConflict
+		}
+	}
+	msg := err.Error()
+	if status == http.StatusInternalServerError {
+		// Don't show the details of errors that aren't the client's.
+		msg = "There was a problem processing your request"
+	}
+	writeError(w, msg, status)
+}
+
+// decodeJSON decodes the request body into dest. Unknown fields are an error so a misspelled field isn't silently
+// ignored.
+func decodeJSON(w http.ResponseWriter, r *http.Request, dest interface{}) bool {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(dest); err != nil {
+		writeError(w, fmt.Sprintf("The request body is not valid JSON: %s", err), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// maxBodyBytes is the largest request body the API reads.
+const maxBodyBytes = 1 << 20
+
+// validateBody only calls handler if the request body is JSON that matches s.
+func validateBody(g *schemaGenerator, s *schema, handler httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+		if err != nil {
+			log.Println(err)
+			writeError(w, "The request body couldn't be read, it can be at most 1 MB", http.StatusBadRequest)
+			return
+		}
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.UseNumber()
+		var v interface{}
+		if err := dec.Decode(&v); err != nil {
+			writeError(w, fmt.Sprintf("The request body is not valid JSON: %s", err), http.StatusBadRequest)
+			return
+		}
+		if dec.More() {
+			writeError(w, "The request body has more than one JSON value", http.StatusBadRequest)
+			return
+		}
+		if err := g.validate(v, s, ""); err != nil {
+			writeError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// Let the handler read the body again.
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		handler(w, r, p)
+	}
+}
+
+// paramID parses the route parameter name as an ID. It writes the error response and returns false if it isn't one.
+func paramID(w http.ResponseWriter, p httprouter.Params, name string, entity string) (int64, bool) {
+	ID, err := strconv.ParseInt(p.ByName(name), 10, 64)
+	if err != nil || ID < 1 {
+		writeError(w, fmt.Sprintf("%s is not a valid %s ID, it must be a number.", p.ByName(name), entity),
+			http.StatusBadRequest)
+		return 0, false
+	}
+	return ID, true
+}
+
+func postSignIn(a auther.Service) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		var u auther.UserSignIn
+		if !decodeJSON(w, r, &u) {
+			return
+		}
+		jwt, err := a.SignIn(u)
+		if err != nil {
+			log.Println(err)
+			writeError(w, "The email or password is not correct", http.StatusUnauthorized)
+			return
+		}
+		writeJSON(w, tokenResponse{jwt}, http.StatusOK)
+	}
+}
+
+// getFilters returns the fields restaurants or visits can be filtered by with the operators each can use. Restaurants
+// also get the values their cuisine, city and state can be filtered to.
+func getFilters(l lister.Service) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		object, ok := objectParam(w, p)
+		if !ok {
+			return
+		}
+		fields, err := l.GetFilterFields(object)
+		if err != nil {
+			writeServiceError(w, err, http.StatusInternalServerError)
+			return
+		}
+		filters := filtersResponse{Fields: fields}
+		if object == "restaurant" {
+			filters.Values = make(map[string][]string)
+			for _, d := range [][3]string{{"cuisine", "cuisine", "restaurant"}, {"city", "name", "city"},
+				{"state", "state", "city"}} {
+				values, err := l.GetDistinct(d[1], d[2])
+				if err != nil {
+					writeServiceError(w, err, http.StatusInternalServerError)
+					return
+				}
+				if values == nil {
+					values = []string{}
+				}
+				filters.Values[d[0]] = values
+			}
+		}
+		writeJSON(w, filters, http.StatusOK)
+	}
+}
+
+// getSorts returns the fields restaurants or visits can be sorted by.
+func getSorts(l lister.Service) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		object, ok := objectParam(w, p)
+		if !ok {
+			return
+		}
+		fields, err := l.GetSortFields(object)
+		if err != nil {
+			writeServiceError(w, err, http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, sortsResponse{fields}, http.StatusOK)
+	}
+}
+
+// objectParam returns the object the object route parameter, restaurants or visits, is for.
+func objectParam(w http.ResponseWriter, p httprouter.Params) (string, bool) {
+	switch p.ByName("object") {
+	case "restaurants":
+		return "restaurant", true
+	case "visits":
+		return "visit", true
+	}
+	writeError(w, fmt.Sprintf("%s is not restaurants or visits.", p.ByName("object")), http.StatusNotFound)
+	return "", false
+}
+
+func getPlaceSearch(m mapper.Service) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		if !m.HaveGmapsKey() {
+			writeError(w, "No Google Maps Key", http.StatusNotImplemented)
+			return
+		}
+		searchTerm := r.URL.Query().Get("searchTerm")
+		if searchTerm == "" {
+			writeError(w, "A ?searchTerm query parameter is required", http.StatusBadRequest)
+			return
+		}
+		candidates, err := m.PlaceSearch(searchTerm)
+		if err != nil {
+			log.Println(err.Error())
+			writeError(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		if candidates == nil {
+			candidates = []mapper.Candidate{}
+		}
+		writeJSON(w, candidates, http.StatusOK)
+	}
+}
+
+func getPlaceRefresh(m mapper.Service) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		if !m.HaveGmapsKey() {
+			writeError(w, "No Google Maps Key", http.StatusNotImplemented)
+			return
+		}
+		placeDetails, err := m.PlaceDetails(p.ByName("placeID"))
+		if err != nil {
+			log.Println(err.Error())
+			writeError(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		writeJSON(w, placeDetails.Result, http.StatusOK)
+	}
+}
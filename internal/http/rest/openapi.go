@@ -0,0 +1,358 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// SpecPath is where the OpenAPI document describing the API is served.
+const SpecPath = "/api/openapi.json"
+
+const schemaRefPrefix = "#/components/schemas/"
+
+// openAPI is an OpenAPI 3 document. Only the parts the API uses are here.
+type openAPI struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       openAPIInfo                      `json:"info"`
+	Servers    []openAPIServer                  `json:"servers"`
+	Security   []map[string][]string            `json:"security"`
+	Paths      map[string]map[string]*operation `json:"paths"`
+	Components components                       `json:"components"`
+}
+
+type openAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type openAPIServer struct {
+	URL string `json:"url"`
+}
+
+type components struct {
+	Schemas         map[string]*schema        `json:"schemas"`
+	SecuritySchemes map[string]securityScheme `json:"securitySchemes"`
+}
+
+type securityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme"`
+	BearerFormat string `json:"bearerFormat"`
+}
+
+type operation struct {
+	OperationID string              `json:"operationId"`
+	Summary     string              `json:"summary"`
+	Parameters  []parameter         `json:"parameters,omitempty"`
+	RequestBody *requestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]response `json:"responses"`
+	// Security is an empty list for the routes that don't need a token and nil for the rest, which use the document's.
+	Security *[]map[string][]string `json:"security,omitempty"`
+}
+
+type parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required"`
+	Schema      *schema `json:"schema"`
+}
+
+type requestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]mediaType `json:"content"`
+}
+
+type response struct {
+	Description string               `json:"description"`
+	Content     map[string]mediaType `json:"content,omitempty"`
+}
+
+type mediaType struct {
+	Schema *schema `json:"schema"`
+}
+
+// schema is an OpenAPI schema object. AdditionalProperties is false or a *schema.
+type schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Items                *schema            `json:"items,omitempty"`
+	Properties           map[string]*schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties interface{}        `json:"additionalProperties,omitempty"`
+}
+
+// pathParams describes every route parameter the routes use. A route with a parameter that isn't here panics when the
+// spec is made so it can't go undocumented.
+var pathParams = map[string]parameter{
+	"id":      {Description: "The ID of the restaurant or user", Schema: &schema{Type: "integer", Format: "int64"}},
+	"visitID": {Description: "The ID of the visit", Schema: &schema{Type: "integer", Format: "int64"}},
+	"placeID": {Description: "The Google Maps place ID", Schema: &schema{Type: "string"}},
+	"object": {Description: "What the fields are for",
+		Schema: &schema{Type: "string", Enum: []string{"restaurants", "visits"}}},
+}
+
+// schemaGenerator makes schemas from Go types the way encoding/json encodes them. Named structs go in schemas and are
+// referenced by their package and type name, e.g. lister.Restaurant.
+type schemaGenerator struct {
+	schemas map[string]*schema
+}
+
+func newSchemaGenerator() *schemaGenerator {
+	return &schemaGenerator{schemas: make(map[string]*schema)}
+}
+
+func (g *schemaGenerator) schemaFor(t reflect.Type) *schema {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return g.schemaFor(t.Elem())
+	case reflect.Bool:
+		return &schema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &schema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return &schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &schema{Type: "string"}
+	case reflect.Slice:
+		// A nil slice is encoded as null.
+		return &schema{Type: "array", Items: g.schemaFor(t.Elem()), Nullable: true}
+	case reflect.Array:
+		return &schema{Type: "array", Items: g.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &schema{Type: "object", AdditionalProperties: g.schemaFor(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t, nil)
+		}
+		name := path.Base(t.PkgPath()) + "." + t.Name()
+		if _, ok := g.schemas[name]; !ok {
+			// Save it before making it so a struct that refers to itself doesn't recurse forever.
+			g.schemas[name] = &schema{}
+			*g.schemas[name] = *g.structSchema(t, nil)
+		}
+		return &schema{Ref: schemaRefPrefix + name}
+	}
+	// interface{} can be anything.
+	return &schema{}
+}
+
+// structSchema returns the schema of a struct with its fields inline. A field is required when its schema tag says so,
+// which is how the HTML forms check them, unless it is one of skipRequired.
+func (g *schemaGenerator) structSchema(t reflect.Type, skipRequired []string) *schema {
+	s := &schema{Type: "object", Properties: make(map[string]*schema), AdditionalProperties: false}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			// Unexported fields aren't encoded.
+			continue
+		}
+		name := f.Name
+		if tag := f.Tag.Get("json"); tag == "-" {
+			continue
+		} else if n := strings.Split(tag, ",")[0]; n != "" {
+			name = n
+		} else if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			// The fields of an embedded struct are encoded as if they were in this one.
+			embedded := g.structSchema(f.Type, skipRequired)
+			for k, v := range embedded.Properties {
+				s.Properties[k] = v
+			}
+			s.Required = append(s.Required, embedded.Required...)
+			continue
+		}
+		s.Properties[name] = g.schemaFor(f.Type)
+		if opts := strings.Split(f.Tag.Get("schema"), ","); len(opts) > 1 && opts[1] == "required" &&
+			!containsString(skipRequired, name) {
+			s.Required = append(s.Required, name)
+		}
+	}
+	return s
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// validate checks that v, which was decoded from JSON with numbers as json.Number, matches s. at is where v is in the
+// request body, e.g. visit_users[0].rating, and is empty for the whole body.
+func (g *schemaGenerator) validate(v interface{}, s *schema, at string) error {
+	if s.Ref != "" {
+		return g.validate(v, g.schemas[strings.TrimPrefix(s.Ref, schemaRefPrefix)], at)
+	}
+	if v == nil {
+		if s.Nullable || s.Type == "" {
+			return nil
+		}
+		return fmt.Errorf("%s can't be null", describe(at))
+	}
+	switch s.Type {
+	case "object":
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s must be an object", describe(at))
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				return fmt.Errorf("%s is required", describe(joinPath(at, name)))
+			}
+		}
+		keys := make([]string, 0, len(obj))
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			ps, ok := s.Properties[k]
+			if !ok {
+				additional, ok := s.AdditionalProperties.(*schema)
+				if !ok {
+					return fmt.Errorf("%s is not a valid field", joinPath(at, k))
+				}
+				ps = additional
+			}
+			if err := g.validate(obj[k], ps, joinPath(at, k)); err != nil {
+				return err
+			}
+		}
+	case "array":
+		arr, ok := v.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s must be an array", describe(at))
+		}
+		for i, item := range arr {
+			if err := g.validate(item, s.Items, fmt.Sprintf("%s[%d]", at, i)); err != nil {
+				return err
+			}
+		}
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("%s must be a string", describe(at))
+		}
+		if len(s.Enum) > 0 && !containsString(s.Enum, str) {
+			return fmt.Errorf("%s must be one of %s", describe(at), strings.Join(s.Enum, ", "))
+		}
+	case "integer":
+		n, ok := v.(json.Number)
+		if _, err := n.Int64(); !ok || err != nil {
+			return fmt.Errorf("%s must be an integer", describe(at))
+		}
+	case "number":
+		if _, ok := v.(json.Number); !ok {
+			return fmt.Errorf("%s must be a number", describe(at))
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("%s must be true or false", describe(at))
+		}
+	}
+	return nil
+}
+
+func joinPath(at string, name string) string {
+	if at == "" {
+		return name
+	}
+	return at + "." + name
+}
+
+func describe(at string) string {
+	if at == "" {
+		return "The request body"
+	}
+	return at
+}
+
+// newSpec makes the OpenAPI document for the routes. The schemas of the request and response bodies come from the Go
+// types the handlers use, so the document can't drift from them.
+func newSpec(routes []route, g *schemaGenerator) openAPI {
+	spec := openAPI{
+		OpenAPI:  "3.0.3",
+		Info:     openAPIInfo{Title: "Restaurant Tracker API", Version: "1"},
+		Servers:  []openAPIServer{{URL: Path}},
+		Security: []map[string][]string{{"bearer": {}}},
+		Paths:    make(map[string]map[string]*operation),
+		Components: components{
+			Schemas: g.schemas,
+			SecuritySchemes: map[string]securityScheme{
+				"bearer": {Type: "http", Scheme: "bearer", BearerFormat: "API token or JWT"},
+			},
+		},
+	}
+	errorSchema := g.schemaFor(reflect.TypeOf(errorResponse{}))
+	for _, rt := range routes {
+		op := &operation{
+			OperationID: rt.name,
+			Summary:     rt.summary,
+			Responses: map[string]response{
+				"default": {
+					Description: "An error",
+					Content:     map[string]mediaType{"application/json": {Schema: errorSchema}},
+				},
+			},
+		}
+		var segments []string
+		for _, segment := range strings.Split(rt.path, "/") {
+			if strings.HasPrefix(segment, ":") {
+				name := segment[1:]
+				p, ok := pathParams[name]
+				if !ok {
+					panic(fmt.Sprintf("the route parameter %s of %s isn't in pathParams", name, rt.path))
+				}
+				p.Name, p.In, p.Required = name, "path", true
+				op.Parameters = append(op.Parameters, p)
+				segment = "{" + name + "}"
+			}
+			segments = append(segments, segment)
+		}
+		op.Parameters = append(op.Parameters, rt.query...)
+		if rt.body != nil {
+			op.RequestBody = &requestBody{
+				Required: true,
+				Content:  map[string]mediaType{"application/json": {Schema: rt.bodySchema}},
+			}
+		}
+		op.Responses[fmt.Sprint(rt.status)] = response{
+			Description: http.StatusText(rt.status),
+			Content: map[string]mediaType{
+				"application/json": {Schema: g.schemaFor(reflect.TypeOf(rt.response))},
+			},
+		}
+		if rt.public {
+			op.Security = &[]map[string][]string{}
+		}
+
+		p := strings.Join(segments, "/")
+		if spec.Paths[p] == nil {
+			spec.Paths[p] = make(map[string]*operation)
+		}
+		spec.Paths[p][strings.ToLower(rt.method)] = op
+	}
+	return spec
+}
+
+func getSpec(spec openAPI) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		writeJSON(w, spec, http.StatusOK)
+	}
+}
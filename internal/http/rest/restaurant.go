@@ -0,0 +1,114 @@
+package rest
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/kelvinatorr/restaurant-tracker/internal/adder"
+	"github.com/kelvinatorr/restaurant-tracker/internal/lister"
+	"github.com/kelvinatorr/restaurant-tracker/internal/remover"
+	"github.com/kelvinatorr/restaurant-tracker/internal/updater"
+)
+
+// getRestaurants returns a page of restaurants. It takes the same filter, sort and page query params as the home page.
+func getRestaurants(l lister.Service) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		rp, err := l.GetRestaurants(r.URL.Query())
+		if err != nil {
+			writeServiceError(w, err, http.StatusBadRequest)
+			return
+		}
+		if rp.Restaurants == nil {
+			rp.Restaurants = []lister.Restaurant{}
+		}
+		writeJSON(w, rp, http.StatusOK)
+	}
+}
+
+func getRestaurant(l lister.Service) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		ID, ok := paramID(w, p, "id", "restaurant")
+		if !ok {
+			return
+		}
+		restaurant, err := l.GetRestaurant(ID)
+		if err != nil {
+			writeServiceError(w, err, http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, restaurant, http.StatusOK)
+	}
+}
+
+func addRestaurant(a adder.Service) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		var newRestaurant adder.Restaurant
+		if !decodeJSON(w, r, &newRestaurant) {
+			return
+		}
+		newRestaurantID, err := a.AddRestaurant(newRestaurant, signedInUserID(r))
+		if err != nil {
+			writeServiceError(w, err, http.StatusBadRequest)
+			return
+		}
+		log.Printf("%s added with id %d", newRestaurant.Name, newRestaurantID)
+		w.Header().Set("Location", fmt.Sprintf("%s/restaurants/%d", Path, newRestaurantID))
+		writeJSON(w, idResponse{newRestaurantID}, http.StatusCreated)
+	}
+}
+
+// updateRestaurant replaces a restaurant with the one in the body. The ID in the body is ignored in favor of the one in
+// the path.
+func updateRestaurant(u updater.Service, l lister.Service) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		ID, ok := paramID(w, p, "id", "restaurant")
+		if !ok {
+			return
+		}
+		var resUpdate updater.Restaurant
+		if !decodeJSON(w, r, &resUpdate) {
+			return
+		}
+		resUpdate.ID = ID
+		// Make sure it exists first so a missing restaurant is a 404 rather than a validation error.
+		if _, err := l.GetRestaurant(ID); err != nil {
+			writeServiceError(w, err, http.StatusInternalServerError)
+			return
+		}
+		recordsAffected, err := u.UpdateRestaurant(resUpdate, signedInUserID(r))
+		if err != nil {
+			writeServiceError(w, err, http.StatusBadRequest)
+			return
+		}
+		log.Printf("Updated restaurant with ID: %d. %d records affected\n", ID, recordsAffected)
+		restaurant, err := l.GetRestaurant(ID)
+		if err != nil {
+			writeServiceError(w, err, http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, restaurant, http.StatusOK)
+	}
+}
+
+// removeRestaurant moves a restaurant and its visits to the trash.
+func removeRestaurant(s remover.Service) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		ID, ok := paramID(w, p, "id", "restaurant")
+		if !ok {
+			return
+		}
+		recordsAffected, err := s.RemoveRestaurant(remover.Restaurant{ID: ID}, signedInUserID(r))
+		if err != nil {
+			writeServiceError(w, err, http.StatusInternalServerError)
+			return
+		}
+		if recordsAffected == 0 {
+			writeError(w, fmt.Sprintf("No restaurant with id: %d", ID), http.StatusNotFound)
+			return
+		}
+		log.Printf("Moved restaurant ID: %d to the trash", ID)
+		writeJSON(w, responseMessage{fmt.Sprintf("Restaurant ID: %d moved to the trash", ID)}, http.StatusOK)
+	}
+}
@@ -0,0 +1,140 @@
+package rest
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/kelvinatorr/restaurant-tracker/internal/adder"
+	"github.com/kelvinatorr/restaurant-tracker/internal/auther"
+	"github.com/kelvinatorr/restaurant-tracker/internal/lister"
+	"github.com/kelvinatorr/restaurant-tracker/internal/updater"
+)
+
+// newUser is the body of a request to add a user.
+type newUser struct {
+	FirstName      string `json:"first_name"`
+	LastName       string `json:"last_name"`
+	Email          string `json:"email"`
+	Password       string `json:"password"`
+	RepeatPassword string `json:"repeat_password"`
+}
+
+// changePassword is the body of a request to change the signed in user's password.
+type changePassword struct {
+	CurrentPassword   string `json:"current_password"`
+	NewPassword       string `json:"new_password"`
+	RepeatNewPassword string `json:"repeat_new_password"`
+}
+
+func getUsers(l lister.Service) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		users, err := l.GetUsers()
+		if err != nil {
+			writeServiceError(w, err, http.StatusInternalServerError)
+			return
+		}
+		if users == nil {
+			users = []lister.User{}
+		}
+		writeJSON(w, users, http.StatusOK)
+	}
+}
+
+func getUser(l lister.Service) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		ID, ok := paramID(w, p, "id", "user")
+		if !ok {
+			return
+		}
+		user, err := l.GetUserByID(ID)
+		if err != nil {
+			writeServiceError(w, err, http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, user, http.StatusOK)
+	}
+}
+
+// addUser adds another user. Like the Add User page any signed in user can add one.
+func addUser(a adder.Service) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		var u newUser
+		if !decodeJSON(w, r, &u) {
+			return
+		}
+		newUserID, err := a.AddUser(adder.User{
+			FirstName:      u.FirstName,
+			LastName:       u.LastName,
+			Email:          u.Email,
+			Password:       u.Password,
+			RepeatPassword: u.RepeatPassword,
+		}, signedInUserID(r))
+		if err != nil {
+			writeServiceError(w, err, http.StatusBadRequest)
+			return
+		}
+		log.Printf("Added user with ID: %d\n", newUserID)
+		w.Header().Set("Location", fmt.Sprintf("%s/users/%d", Path, newUserID))
+		writeJSON(w, idResponse{newUserID}, http.StatusCreated)
+	}
+}
+
+// checkUser only calls handler if the id route parameter is the signed in user, who is the only one that can change
+// their profile.
+func checkUser(handler httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		ID, ok := paramID(w, p, "id", "user")
+		if !ok {
+			return
+		}
+		if ID != signedInUserID(r) {
+			writeError(w, "You can only change your own user", http.StatusForbidden)
+			return
+		}
+		handler(w, r, p)
+	}
+}
+
+func updateUser(u updater.Service, l lister.Service) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		var userUpdate updater.User
+		if !decodeJSON(w, r, &userUpdate) {
+			return
+		}
+		userUpdate.ID = signedInUserID(r)
+		recordsAffected, err := u.UpdateUser(userUpdate)
+		if err != nil {
+			writeServiceError(w, err, http.StatusBadRequest)
+			return
+		}
+		log.Printf("Updated user with ID: %d. %d records affected\n", userUpdate.ID, recordsAffected)
+		user, err := l.GetUserByID(userUpdate.ID)
+		if err != nil {
+			writeServiceError(w, err, http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, user, http.StatusOK)
+	}
+}
+
+func updateUserPassword(u updater.Service) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		var cp changePassword
+		if !decodeJSON(w, r, &cp) {
+			return
+		}
+		_, err := u.UpdateUserPassword(auther.UserChangePassword{
+			ID:                signedInUserID(r),
+			CurrentPassword:   cp.CurrentPassword,
+			NewPassword:       cp.NewPassword,
+			RepeatNewPassword: cp.RepeatNewPassword,
+		})
+		if err != nil {
+			writeServiceError(w, err, http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, responseMessage{"Password changed"}, http.StatusOK)
+	}
+}
@@ -0,0 +1,166 @@
+package rest
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/kelvinatorr/restaurant-tracker/internal/adder"
+	"github.com/kelvinatorr/restaurant-tracker/internal/lister"
+	"github.com/kelvinatorr/restaurant-tracker/internal/remover"
+	"github.com/kelvinatorr/restaurant-tracker/internal/updater"
+)
+
+// getAllVisits returns a page of the visits to every restaurant. It takes the same filter, sort and page query params
+// as the visits page.
+func getAllVisits(l lister.Service) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		vp, err := l.GetVisits(r.URL.Query())
+		if err != nil {
+			writeServiceError(w, err, http.StatusBadRequest)
+			return
+		}
+		writeVisitPage(w, vp)
+	}
+}
+
+// getVisits returns a page of a restaurant's visits.
+func getVisits(l lister.Service) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		resID, ok := restaurantParam(w, p, l)
+		if !ok {
+			return
+		}
+		vp, err := l.GetVisitsByRestaurantID(resID, r.URL.Query())
+		if err != nil {
+			writeServiceError(w, err, http.StatusBadRequest)
+			return
+		}
+		writeVisitPage(w, vp)
+	}
+}
+
+func writeVisitPage(w http.ResponseWriter, vp lister.VisitPage) {
+	if vp.Visits == nil {
+		vp.Visits = []lister.Visit{}
+	}
+	writeJSON(w, vp, http.StatusOK)
+}
+
+// restaurantParam returns the ID in the id route parameter after making sure the restaurant exists.
+func restaurantParam(w http.ResponseWriter, p httprouter.Params, l lister.Service) (int64, bool) {
+	resID, ok := paramID(w, p, "id", "restaurant")
+	if !ok {
+		return 0, false
+	}
+	if _, err := l.GetRestaurant(resID); err != nil {
+		writeServiceError(w, err, http.StatusInternalServerError)
+		return 0, false
+	}
+	return resID, true
+}
+
+func getVisit(l lister.Service) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		resID, ok := paramID(w, p, "id", "restaurant")
+		if !ok {
+			return
+		}
+		ID, ok := paramID(w, p, "visitID", "visit")
+		if !ok {
+			return
+		}
+		visit, err := l.GetVisit(ID, resID)
+		if err != nil {
+			writeServiceError(w, err, http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, visit, http.StatusOK)
+	}
+}
+
+func addVisit(a adder.Service, l lister.Service) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		resID, ok := restaurantParam(w, p, l)
+		if !ok {
+			return
+		}
+		var visitNew adder.Visit
+		if !decodeJSON(w, r, &visitNew) {
+			return
+		}
+		visitNew.RestaurantID = resID
+		newVisitID, err := a.AddVisit(visitNew, signedInUserID(r))
+		if err != nil {
+			writeServiceError(w, err, http.StatusBadRequest)
+			return
+		}
+		log.Printf("Added new visit to restaurant %d with ID: %d.\n", resID, newVisitID)
+		w.Header().Set("Location", fmt.Sprintf("%s/restaurants/%d/visits/%d", Path, resID, newVisitID))
+		writeJSON(w, idResponse{newVisitID}, http.StatusCreated)
+	}
+}
+
+// updateVisit replaces a visit with the one in the body. The IDs in the body are ignored in favor of the ones in the
+// path.
+func updateVisit(u updater.Service, l lister.Service) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		resID, ok := paramID(w, p, "id", "restaurant")
+		if !ok {
+			return
+		}
+		ID, ok := paramID(w, p, "visitID", "visit")
+		if !ok {
+			return
+		}
+		var visitUpdate updater.Visit
+		if !decodeJSON(w, r, &visitUpdate) {
+			return
+		}
+		visitUpdate.ID = ID
+		visitUpdate.RestaurantID = resID
+		// Make sure it exists first so a missing visit is a 404 rather than a validation error.
+		if _, err := l.GetVisit(ID, resID); err != nil {
+			writeServiceError(w, err, http.StatusInternalServerError)
+			return
+		}
+		recordsAffected, err := u.UpdateVisit(visitUpdate, signedInUserID(r))
+		if err != nil {
+			writeServiceError(w, err, http.StatusBadRequest)
+			return
+		}
+		log.Printf("Updated visit with ID: %d. %d records affected\n", ID, recordsAffected)
+		visit, err := l.GetVisit(ID, resID)
+		if err != nil {
+			writeServiceError(w, err, http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, visit, http.StatusOK)
+	}
+}
+
+// removeVisit moves a visit to the trash.
+func removeVisit(s remover.Service) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		resID, ok := paramID(w, p, "id", "restaurant")
+		if !ok {
+			return
+		}
+		ID, ok := paramID(w, p, "visitID", "visit")
+		if !ok {
+			return
+		}
+		recordsAffected, err := s.RemoveVisit(remover.Visit{ID: ID, RestaurantID: resID}, signedInUserID(r))
+		if err != nil {
+			writeServiceError(w, err, http.StatusInternalServerError)
+			return
+		}
+		if recordsAffected == 0 {
+			writeError(w, fmt.Sprintf("No visit with id: %d for restaurant: %d", ID, resID), http.StatusNotFound)
+			return
+		}
+		log.Printf("Moved visit ID: %d to the trash", ID)
+		writeJSON(w, responseMessage{fmt.Sprintf("Visit ID: %d moved to the trash", ID)}, http.StatusOK)
+	}
+}
@@ -0,0 +1,101 @@
+package rest_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kelvinatorr/restaurant-tracker/internal/adder"
+	"github.com/kelvinatorr/restaurant-tracker/internal/auther"
+	"github.com/kelvinatorr/restaurant-tracker/internal/http/rest"
+	"github.com/kelvinatorr/restaurant-tracker/internal/lister"
+	"github.com/kelvinatorr/restaurant-tracker/internal/mapper"
+	"github.com/kelvinatorr/restaurant-tracker/internal/remover"
+	"github.com/kelvinatorr/restaurant-tracker/internal/storage/memory"
+	"github.com/kelvinatorr/restaurant-tracker/internal/updater"
+)
+
+// newAPI returns the API backed by an in-memory storage with two users, a restaurant and a visit the first user went
+// to, and the token of the first user. Nothing is looked up on Google Maps so the mapper doesn't need a key.
+func newAPI(t *testing.T) (http.Handler, *memory.Storage, string) {
+	t.Helper()
+	s := memory.NewStorage()
+	m := mapper.NewService("")
+	a := adder.NewService(s.Adder(), m)
+	for _, email := range []string{"alex@example.com", "sam@example.com"} {
+		if _, err := a.AddUser(adder.User{FirstName: "Alex", LastName: "Rivera", Email: email, Password: "pw",
+			RepeatPassword: "pw"}, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	restaurantID, err := a.AddRestaurant(adder.Restaurant{Name: "Pho Saigon", Cuisine: "Vietnamese",
+		CityState: adder.CityState{Name: "Seattle", State: "WA"}}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.AddVisit(adder.Visit{RestaurantID: restaurantID, VisitDateTime: "2021-03-04",
+		VisitUsers: []adder.VisitUser{{UserID: 1, Rating: 4}}}, 1); err != nil {
+		t.Fatal(err)
+	}
+	auth := auther.NewService(s.Auther(), "test-key")
+	h := rest.Handler(lister.NewService(s), a, updater.NewService(s.Updater(), m), remover.NewService(s.Remover()),
+		auth, m, false)
+	token, err := auth.SignIn(auther.UserSignIn{Email: "alex@example.com", Password: "pw"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h, s, token
+}
+
+// TestUpdateVisitUsers checks that a visit's users can be kept, added without an id and removed by leaving them out.
+func TestUpdateVisitUsers(t *testing.T) {
+	tests := []struct {
+		name       string
+		visitUsers []map[string]interface{}
+		wantStatus int
+		// wantRatings is the rating of each user at the visit after the update, by user id.
+		wantRatings map[int64]int64
+	}{
+		{"add a user", []map[string]interface{}{{"id": 1, "user_id": 1, "rating": 4}, {"user_id": 2, "rating": 5}},
+			http.StatusOK, map[int64]int64{1: 4, 2: 5}},
+		{"replace a user", []map[string]interface{}{{"user_id": 2, "rating": 3}},
+			http.StatusOK, map[int64]int64{2: 3}},
+		{"no user id", []map[string]interface{}{{"rating": 3}},
+			http.StatusBadRequest, map[int64]int64{1: 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, s, token := newAPI(t)
+			body, err := json.Marshal(map[string]interface{}{"visit_datetime": "2021-03-05", "note": "",
+				"visit_users": tt.visitUsers})
+			if err != nil {
+				t.Fatal(err)
+			}
+			req := httptest.NewRequest(http.MethodPut, rest.Path+"/restaurants/1/visits/1", bytes.NewReader(body))
+			req.Header.Set("Authorization", "Bearer "+token)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			if w.Code != tt.wantStatus {
+				t.Errorf("PUT returned %d %s, want %d", w.Code, w.Body, tt.wantStatus)
+			}
+			vus, err := s.GetVisitUsersByVisitID(1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ratings := make(map[int64]int64)
+			for _, vu := range vus {
+				ratings[vu.User.ID] = vu.Rating
+			}
+			if len(ratings) != len(tt.wantRatings) {
+				t.Errorf("The visit's users are %v, want %v", ratings, tt.wantRatings)
+			}
+			for id, want := range tt.wantRatings {
+				if got, ok := ratings[id]; !ok || got != want {
+					t.Errorf("The visit's users are %v, want %v", ratings, tt.wantRatings)
+				}
+			}
+		})
+	}
+}
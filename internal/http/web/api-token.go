@@ -0,0 +1,105 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/kelvinatorr/restaurant-tracker/internal/adder"
+	"github.com/kelvinatorr/restaurant-tracker/internal/auther"
+	"github.com/kelvinatorr/restaurant-tracker/internal/lister"
+	"github.com/kelvinatorr/restaurant-tracker/internal/remover"
+)
+
+// apiTokenAuth lets scripts use a page with an API token. A request with an Authorization: Bearer header is signed in
+// as the token's user and one without goes through authRequired like any other page. Read-only tokens can only GET.
+func apiTokenAuth(handler httprouter.Handle, auth auther.Service, l lister.Service) httprouter.Handle {
+	cookieHandler := authRequired(handler, auth, l)
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		header := r.Header.Get("Authorization")
+		if header == "" {
+			cookieHandler(w, r, p)
+			return
+		}
+		token := strings.TrimPrefix(header, "Bearer ")
+		if token == header || !auther.IsAPIToken(token) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "The Authorization header must be Bearer and an API token", http.StatusUnauthorized)
+			return
+		}
+		apiToken, err := auth.CheckAPIToken(token)
+		if err != nil {
+			log.Println(err.Error())
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if apiToken.ReadOnly && r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "This API token is read-only", http.StatusForbidden)
+			return
+		}
+		user, err := l.GetUserByID(apiToken.UserID)
+		if err != nil {
+			log.Println(err.Error())
+			http.Error(w, "A server error occurred", http.StatusInternalServerError)
+			return
+		}
+		ctx := context.WithValue(r.Context(), contextKeyUser, user)
+		handler(w, r.WithContext(ctx), p)
+	}
+}
+
+// postAPIToken makes an API token and shows it on the profile page, which is the only time it can be seen.
+func postAPIToken(a adder.Service, l lister.Service) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		user, ok := r.Context().Value(contextKeyUser).(lister.User)
+		if !ok {
+			log.Println("user is not type lister.User")
+			http.Error(w, AlertErrorMsgGeneric, http.StatusInternalServerError)
+			return
+		}
+		var tokenNew adder.APIToken
+		if err := parseForm(r, &tokenNew); err != nil {
+			log.Println(err)
+			http.Error(w, AlertFormParseErrorGeneric, http.StatusInternalServerError)
+			return
+		}
+		newTokenID, token, err := a.AddAPIToken(tokenNew, user.ID)
+		if err != nil {
+			log.Println(err)
+			renderUser(w, r, l, user, Alert{Message: err.Error(), Class: AlertClassError}, "")
+			return
+		}
+		log.Printf("New API token added with id: %d\n", newTokenID)
+		renderUser(w, r, l, user, Alert{
+			Message: fmt.Sprintf("Your new API token %s was made. Copy it now, it won't be shown again.",
+				tokenNew.Name),
+			Class: AlertClassSuccess,
+		}, token)
+	}
+}
+
+// postDeleteAPIToken revokes one of the signed in user's API tokens.
+func postDeleteAPIToken(s remover.Service) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		ID, err := strconv.Atoi(p.ByName("id"))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("%s is not a valid API token ID, it must be a number.", p.ByName("id")),
+				http.StatusBadRequest)
+			return
+		}
+		userID := signedInUserID(r)
+		recordsAffected, err := s.RemoveAPIToken(int64(ID), userID)
+		if err != nil {
+			log.Println(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		log.Printf("Revoked API token id: %d. Records affected: %d\n", ID, recordsAffected)
+		http.Redirect(w, r, fmt.Sprintf("/users/%d#api-tokens", userID), http.StatusSeeOther)
+	}
+}
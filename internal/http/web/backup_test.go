@@ -0,0 +1,69 @@
+package web_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kelvinatorr/restaurant-tracker/internal/adder"
+	"github.com/kelvinatorr/restaurant-tracker/internal/auther"
+	"github.com/kelvinatorr/restaurant-tracker/internal/backup"
+	"github.com/kelvinatorr/restaurant-tracker/internal/http/web"
+	"github.com/kelvinatorr/restaurant-tracker/internal/lister"
+	"github.com/kelvinatorr/restaurant-tracker/internal/mapper"
+	"github.com/kelvinatorr/restaurant-tracker/internal/remover"
+	"github.com/kelvinatorr/restaurant-tracker/internal/storage/memory"
+	"github.com/kelvinatorr/restaurant-tracker/internal/updater"
+)
+
+// fakeBackups writes a backup that is just its content.
+type fakeBackups struct {
+	content string
+}
+
+func (f fakeBackups) Backup(path string) error {
+	return ioutil.WriteFile(path, []byte(f.content), 0600)
+}
+
+// TestGetBackup checks that only the admin, who is the first user, can download a backup.
+func TestGetBackup(t *testing.T) {
+	s := memory.NewStorage()
+	m := mapper.NewService("")
+	a := adder.NewService(s.Adder(), m)
+	for _, email := range []string{"alex@example.com", "sam@example.com"} {
+		if _, err := a.AddUser(adder.User{FirstName: "Alex", LastName: "Rivera", Email: email, Password: "pw",
+			RepeatPassword: "pw"}, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	auth := auther.NewService(s.Auther(), "test-key")
+	h := web.Handler(lister.NewService(s), a, updater.NewService(s.Updater(), m), remover.NewService(s.Remover()),
+		auth, m, backup.NewService(fakeBackups{"backup"}, "", 0), false)
+
+	tests := []struct {
+		email      string
+		wantStatus int
+		wantBody   string
+	}{
+		{"alex@example.com", http.StatusOK, "backup"},
+		{"sam@example.com", http.StatusForbidden, "Forbidden\n"},
+		{"", http.StatusFound, ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/admin/backup", nil)
+		if tt.email != "" {
+			jwt, err := auth.SignIn(auther.UserSignIn{Email: tt.email, Password: "pw"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.AddCookie(&http.Cookie{Name: "rt", Value: jwt})
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != tt.wantStatus || (tt.wantBody != "" && w.Body.String() != tt.wantBody) {
+			t.Errorf("GET /admin/backup as %q returned %d %q, want %d %q", tt.email, w.Code, w.Body.String(),
+				tt.wantStatus, tt.wantBody)
+		}
+	}
+}
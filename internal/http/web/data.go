@@ -22,4 +22,5 @@ type Alert struct {
 type User struct {
 	ID        int64
 	FirstName string
+	IsAdmin   bool
 }
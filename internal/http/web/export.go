@@ -0,0 +1,49 @@
+package web
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/kelvinatorr/restaurant-tracker/internal/lister"
+)
+
+// getExport downloads everything as a JSON document when the format is json or as a zip of CSVs when it is csv.
+func getExport(l lister.Service) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		format := p.ByName("format")
+		if format != "json" && format != "csv" {
+			http.Error(w, fmt.Sprintf("%s is not a valid export format.", format), http.StatusBadRequest)
+			return
+		}
+		e, err := l.Export()
+		if err != nil {
+			log.Println(err.Error())
+			http.Error(w, "There was a problem making the export", http.StatusInternalServerError)
+			return
+		}
+		// Write the export to a buffer first so an error can still be reported with the right status code.
+		var buf bytes.Buffer
+		contentType, ext := "application/json", "json"
+		if format == "csv" {
+			contentType, ext = "application/zip", "zip"
+			err = e.WriteCSVZip(&buf)
+		} else {
+			err = e.WriteJSON(&buf)
+		}
+		if err != nil {
+			log.Println(err.Error())
+			http.Error(w, "There was a problem making the export", http.StatusInternalServerError)
+			return
+		}
+		fileName := fmt.Sprintf("restaurant-tracker-export-%s.%s", time.Now().UTC().Format("20060102-150405"), ext)
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, fileName))
+		w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+		buf.WriteTo(w)
+	}
+}
@@ -9,13 +9,17 @@ import (
 	"io/ioutil"
 	"log"
 	"net/http"
+	"net/url"
 	"regexp"
 	"strconv"
+	"time"
 
 	"github.com/gorilla/schema"
 	"github.com/julienschmidt/httprouter"
 	"github.com/kelvinatorr/restaurant-tracker/internal/adder"
+	"github.com/kelvinatorr/restaurant-tracker/internal/audit"
 	"github.com/kelvinatorr/restaurant-tracker/internal/auther"
+	"github.com/kelvinatorr/restaurant-tracker/internal/backup"
 	"github.com/kelvinatorr/restaurant-tracker/internal/lister"
 	"github.com/kelvinatorr/restaurant-tracker/internal/mapper"
 	"github.com/kelvinatorr/restaurant-tracker/internal/remover"
@@ -30,7 +34,7 @@ const (
 )
 
 // Handler sets the httprouter routes for the web package
-func Handler(l lister.Service, a adder.Service, u updater.Service, r remover.Service, auth auther.Service, m mapper.Service, verbose bool) http.Handler {
+func Handler(l lister.Service, a adder.Service, u updater.Service, r remover.Service, auth auther.Service, m mapper.Service, b backup.Service, verbose bool) http.Handler {
 
 	router := httprouter.New()
 
@@ -55,6 +59,11 @@ func Handler(l lister.Service, a adder.Service, u updater.Service, r remover.Ser
 	router.GET(homePath, homeGETHandler)
 	router.HEAD(homePath, homeGETHandler)
 
+	searchPath := "/search"
+	searchGETHandler := authRequired(getSearch(l), auth, l)
+	router.GET(searchPath, searchGETHandler)
+	router.HEAD(searchPath, searchGETHandler)
+
 	userAddPath := "/users-add"
 	userAddGETHandler := authRequired(getUserAdd(), auth, l)
 	userAddPOSTHandler := authRequired(postUserAdd(a, "Add A New User",
@@ -65,12 +74,18 @@ func Handler(l lister.Service, a adder.Service, u updater.Service, r remover.Ser
 	dontLogBodyURLs[userAddPath] = true
 
 	userPath := "/users/:id"
-	userGETHandler := authRequired(checkUser(getUser()), auth, l)
-	userPOSTHandler := authRequired(checkUser(postUser(u)), auth, l)
+	userGETHandler := authRequired(checkUser(getUser(l)), auth, l)
+	userPOSTHandler := authRequired(checkUser(postUser(u, l)), auth, l)
 	router.GET(userPath, userGETHandler)
 	router.HEAD(userPath, userGETHandler)
 	router.POST(userPath, userPOSTHandler)
 
+	apiTokensPath := "/users/:id/api-tokens"
+	router.POST(apiTokensPath, authRequired(checkUser(postAPIToken(a, l)), auth, l))
+
+	deleteAPITokenPath := "/delete-api-token/:id"
+	router.POST(deleteAPITokenPath, authRequired(postDeleteAPIToken(r), auth, l))
+
 	changePasswordPath := "/users/:id/change-password"
 	changePasswordGETHandler := authRequired(checkUser(getChangePassword()), auth, l)
 	changePasswordPOSTHandler := authRequired(checkUser(postChangePassword(u)), auth, l)
@@ -83,6 +98,61 @@ func Handler(l lister.Service, a adder.Service, u updater.Service, r remover.Ser
 	signOutPOSTHandler := postSignOut()
 	router.POST(signOutPath, signOutPOSTHandler)
 
+	backupPath := "/admin/backup"
+	backupGETHandler := authRequired(adminRequired(getBackup(b)), auth, l)
+	router.GET(backupPath, backupGETHandler)
+
+	importPath := "/admin/import"
+	importGETHandler := authRequired(adminRequired(getImport(l)), auth, l)
+	router.GET(importPath, importGETHandler)
+	router.HEAD(importPath, importGETHandler)
+	router.POST(importPath, authRequired(adminRequired(postImport(a, l)), auth, l))
+
+	exportPath := "/admin/export/:format"
+	exportGETHandler := authRequired(adminRequired(getExport(l)), auth, l)
+	router.GET(exportPath, exportGETHandler)
+
+	historyPath := "/history/:entity/:id"
+	historyGETHandler := apiTokenAuth(getHistory(l), auth, l)
+	router.GET(historyPath, historyGETHandler)
+	router.HEAD(historyPath, historyGETHandler)
+
+	trashPath := "/trash"
+	trashGETHandler := authRequired(getTrash(l), auth, l)
+	router.GET(trashPath, trashGETHandler)
+	router.HEAD(trashPath, trashGETHandler)
+
+	restoreResPath := "/trash/restaurants/:id/restore"
+	router.POST(restoreResPath, authRequired(postRestoreRestaurant(r), auth, l))
+
+	restoreVisitPath := "/trash/visits/:id/restore"
+	router.POST(restoreVisitPath, authRequired(postRestoreVisit(r), auth, l))
+
+	statsPath := "/stats"
+	statsGETHandler := authRequired(getStats(l), auth, l)
+	router.GET(statsPath, statsGETHandler)
+	router.HEAD(statsPath, statsGETHandler)
+
+	statsJSONPath := "/stats.json"
+	statsJSONGETHandler := apiTokenAuth(getStatsJSON(l), auth, l)
+	router.GET(statsJSONPath, statsJSONGETHandler)
+	router.HEAD(statsJSONPath, statsJSONGETHandler)
+
+	tastePath := "/taste"
+	tasteGETHandler := authRequired(getTaste(l), auth, l)
+	router.GET(tastePath, tasteGETHandler)
+	router.HEAD(tastePath, tasteGETHandler)
+
+	recommendPath := "/recommend"
+	recommendGETHandler := authRequired(getRecommend(l), auth, l)
+	router.GET(recommendPath, recommendGETHandler)
+	router.HEAD(recommendPath, recommendGETHandler)
+
+	recommendJSONPath := "/recommend.json"
+	recommendJSONGETHandler := apiTokenAuth(getRecommendJSON(l), auth, l)
+	router.GET(recommendJSONPath, recommendJSONGETHandler)
+	router.HEAD(recommendJSONPath, recommendJSONGETHandler)
+
 	filterPath := "/filter"
 	filterGETHandler := authRequired(getFilter(l), auth, l)
 	router.GET(filterPath, filterGETHandler)
@@ -93,6 +163,17 @@ func Handler(l lister.Service, a adder.Service, u updater.Service, r remover.Ser
 	router.GET(sortPath, sortGETHandler)
 	router.HEAD(sortPath, sortGETHandler)
 
+	savedViewsPath := "/views"
+	savedViewsGETHandler := authRequired(getSavedViews(l), auth, l)
+	router.GET(savedViewsPath, savedViewsGETHandler)
+	router.HEAD(savedViewsPath, savedViewsGETHandler)
+
+	savedViewPath := "/views/:id"
+	router.POST(savedViewPath, authRequired(postSavedView(a, u, l), auth, l))
+
+	deleteSavedViewPath := "/delete-view/:id"
+	router.POST(deleteSavedViewPath, authRequired(postDeleteSavedView(r), auth, l))
+
 	restaurantPath := "/restaurants/:id"
 	restaurantGETHandler := authRequired(getRestaurant(l, m), auth, l)
 	restaurantPOSTHandler := authRequired(postRestaurant(u, a, m, l), auth, l)
@@ -121,6 +202,11 @@ func Handler(l lister.Service, a adder.Service, u updater.Service, r remover.Ser
 	mapPlaceDELETEHandler := authRequired(deletePlace(r), auth, l)
 	router.DELETE(mapPlacePath, mapPlaceDELETEHandler)
 
+	allVisitsPath := "/visits"
+	allVisitsGETHandler := authRequired(getAllVisits(l), auth, l)
+	router.GET(allVisitsPath, allVisitsGETHandler)
+	router.HEAD(allVisitsPath, allVisitsGETHandler)
+
 	visitsPath := "/r/:resid/visits"
 	visitsGETHandler := authRequired(getVisits(l), auth, l)
 	router.GET(visitsPath, visitsGETHandler)
@@ -237,10 +323,11 @@ func authRequired(handler httprouter.Handle, auth auther.Service, l lister.Servi
 			return
 		}
 
-		user := l.GetUserByID(signedInUser.ID)
 		// Get the user's info
-		if user.ID == 0 {
-			http.Error(w, fmt.Sprintf("There is no user with id %s", p.ByName("id")), http.StatusBadRequest)
+		user, err := l.GetUserByID(signedInUser.ID)
+		if err != nil {
+			log.Println(err.Error())
+			http.Redirect(w, r, "/sign-in", http.StatusFound)
 			return
 		}
 
@@ -271,7 +358,12 @@ func parseForm(r *http.Request, dest interface{}) error {
 func getInitialSignup(l lister.Service) func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 		// If there already are users in the database then send them to the home page
-		userCount := l.GetUserCount()
+		userCount, err := l.GetUserCount()
+		if err != nil {
+			log.Println(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
 		if userCount > 0 {
 			http.Redirect(w, r, "/", http.StatusFound)
 			return
@@ -299,7 +391,12 @@ func getInitialSignup(l lister.Service) func(w http.ResponseWriter, r *http.Requ
 func getSignIn(l lister.Service) func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 		// If there are no users in the database then send them to the initial signup page
-		userCount := l.GetUserCount()
+		userCount, err := l.GetUserCount()
+		if err != nil {
+			log.Println(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
 		if userCount == 0 {
 			http.Redirect(w, r, "/initial-signup", http.StatusFound)
 			return
@@ -324,7 +421,7 @@ func postUserAdd(a adder.Service, heading string, text string) func(w http.Respo
 			http.Error(w, AlertFormParseErrorGeneric, http.StatusInternalServerError)
 			return
 		}
-		newUserID, err := a.AddUser(u)
+		newUserID, err := a.AddUser(u, signedInUserID(r))
 		if err != nil {
 			log.Println(err)
 			data.Alert = Alert{Message: err.Error()}
@@ -396,8 +493,20 @@ func getHome(s lister.Service) httprouter.Handle {
 		v := newView("base", "./web/template/index.html")
 		// TODO: Pull in Site Name from the database.
 
+		// Show the user's default view if they haven't asked for anything else
+		if r.URL.RawQuery == "" {
+			defaultView, err := s.GetDefaultSavedView(signedInUserID(r))
+			if err != nil {
+				log.Println(err.Error())
+			} else if defaultView.ID != 0 {
+				http.Redirect(w, r, savedViewURL(defaultView.Query), http.StatusSeeOther)
+				return
+			}
+		}
+
 		// get the query parameters parameter
 		queryParams := r.URL.Query()
+		currentQuery := lister.ViewQuery(queryParams)
 
 		// By default, sort by last_visit desc unless a last_visit sort is specified
 		lastVisitSortParam := s.GetSortParam("last_visit", queryParams)
@@ -417,6 +526,14 @@ func getHome(s lister.Service) httprouter.Handle {
 			showNotOperating = true
 		}
 
+		// The Near Me filter adds a distance column and picks the radius in its select
+		nearFilterOp := s.GetFilterParam("location", queryParams)
+		near := nearFilterOp.Operator == "near"
+		nearKm := "2"
+		if near && len(nearFilterOp.Values) == 3 {
+			nearKm = nearFilterOp.Values[2]
+		}
+
 		data := Data{}
 		data.Head = Head{"Our Restaurant Tracker"}
 		// Get all restaurants
@@ -426,12 +543,169 @@ func getHome(s lister.Service) httprouter.Handle {
 			http.Error(w, "There was a problem processing your request", http.StatusBadRequest)
 			return
 		}
+		views, err := getSavedViewLinks(s, signedInUserID(r), currentQuery)
+		if err != nil {
+			log.Println(err.Error())
+			http.Error(w, "There was a problem processing your request", http.StatusInternalServerError)
+			return
+		}
+		prevURL, nextURL := pageURLs(r.URL, restaurants.Page)
 		data.Yield = struct {
 			Restaurants      []lister.Restaurant
 			ShowNotOperating bool
+			Page             lister.Page
+			PrevURL          string
+			NextURL          string
+			Views            []savedViewLink
+			CurrentQuery     string
+			UserID           int64
+			Near             bool
+			NearKm           string
+			NearKmOptions    []string
 		}{
-			restaurants,
+			restaurants.Restaurants,
 			showNotOperating,
+			restaurants.Page,
+			prevURL,
+			nextURL,
+			views,
+			currentQuery,
+			signedInUserID(r),
+			near,
+			nearKm,
+			[]string{"0.5", "1", "2", "5", "10", "25"},
+		}
+		v.render(w, r, data)
+	}
+}
+
+// pageURLs returns the links to the previous and next pages of a list from the url of the current page. They keep the
+// sort and filter params and are empty when there is no such page.
+func pageURLs(u *url.URL, p lister.Page) (string, string) {
+	link := func(key string, cursor string) string {
+		if cursor == "" {
+			return ""
+		}
+		qp := u.Query()
+		qp.Del("after")
+		qp.Del("before")
+		qp.Set(key, cursor)
+		return u.Path + "?" + qp.Encode()
+	}
+	return link("before", p.Prev), link("after", p.Next)
+}
+
+// signedInUserID returns the id of the signed in user, or 0 if no one is signed in.
+func signedInUserID(r *http.Request) int64 {
+	user, _ := r.Context().Value(contextKeyUser).(lister.User)
+	return user.ID
+}
+
+// adminRequired only calls handler if the signed in user is an admin. It must be wrapped by authRequired.
+func adminRequired(handler httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		user, ok := r.Context().Value(contextKeyUser).(lister.User)
+		if !ok {
+			log.Println("user is not type lister.User")
+			http.Error(w, "A server error occurred", http.StatusInternalServerError)
+			return
+		}
+		if !user.IsAdmin {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		handler(w, r, p)
+	}
+}
+
+// getBackup downloads a fresh backup of the database. b is nil when the storage can't be backed up.
+func getBackup(b backup.Service) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		if b == nil {
+			http.Error(w, "Backups are only available for sqlite databases", http.StatusNotImplemented)
+			return
+		}
+		// Make the whole backup before anything is written so an error can still be reported with the right status
+		// code. It is streamed from the temporary file so a big database isn't held in memory.
+		f, err := b.Snapshot()
+		if err != nil {
+			log.Println(err.Error())
+			http.Error(w, "There was a problem making the backup", http.StatusInternalServerError)
+			return
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil {
+			log.Println(err.Error())
+			http.Error(w, "There was a problem making the backup", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/vnd.sqlite3")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, backup.FileName(time.Now())))
+		w.Header().Set("Content-Length", strconv.FormatInt(info.Size(), 10))
+		if _, err := io.Copy(w, f); err != nil {
+			log.Println(err.Error())
+		}
+	}
+}
+
+// getHistory returns the audit log of an entity as JSON. The history of a restaurant includes its visits.
+func getHistory(s lister.Service) httprouter.Handle {
+	entityTypes := map[string]bool{
+		audit.EntityRestaurant: true,
+		audit.EntityVisit:      true,
+		audit.EntityUser:       true,
+		audit.EntityGmapsPlace: true,
+	}
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		entityType := p.ByName("entity")
+		if !entityTypes[entityType] {
+			http.Error(w, fmt.Sprintf("%s is not a valid entity type.", entityType), http.StatusBadRequest)
+			return
+		}
+		ID, err := strconv.Atoi(p.ByName("id"))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("%s is not a valid ID, it must be a number.", p.ByName("id")),
+				http.StatusBadRequest)
+			return
+		}
+
+		history, err := s.GetHistory(entityType, int64(ID))
+		if err != nil {
+			log.Println(err.Error())
+			http.Error(w, "There was a problem processing your request", http.StatusInternalServerError)
+			return
+		}
+		if history == nil {
+			// Encode an empty list rather than null.
+			history = []lister.AuditEntry{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(history)
+	}
+}
+
+func getSearch(s lister.Service) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		v := newView("base", "./web/template/search.html")
+
+		query := r.URL.Query().Get("q")
+		results, err := s.Search(query)
+		if err != nil {
+			log.Println(err.Error())
+			http.Error(w, "There was a problem processing your request", http.StatusInternalServerError)
+			return
+		}
+
+		data := Data{}
+		data.Head = Head{"Search"}
+		data.Yield = struct {
+			Query   string
+			Results []lister.SearchResult
+		}{
+			query,
+			results,
 		}
 		v.render(w, r, data)
 	}
@@ -487,7 +761,7 @@ func checkUser(handler httprouter.Handle) httprouter.Handle {
 	}
 }
 
-func getUser() httprouter.Handle {
+func getUser(l lister.Service) httprouter.Handle {
 	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 		// Get the user from the context
 		user, ok := r.Context().Value(contextKeyUser).(lister.User)
@@ -496,26 +770,47 @@ func getUser() httprouter.Handle {
 			http.Error(w, "A server error occurred", http.StatusInternalServerError)
 			return
 		}
+		renderUser(w, r, l, user, Alert{}, "")
+	}
+}
 
-		v := newView("base", "./web/template/user.html")
-
-		data := Data{}
-		data.Head = Head{fmt.Sprintf("Profile: %s %s", user.FirstName, user.LastName)}
-		data.Yield = struct {
-			Heading string
-			Text    string
-			User    lister.User
-		}{
-			fmt.Sprintf("Profile: %s %s", user.FirstName, user.LastName),
-			"Edit your profile by changing the information below.",
-			user,
-		}
+// renderUser renders the profile page with the user's API tokens. user is the form's values, which are the user's or
+// what they submitted. newToken is only set right after a token is made.
+func renderUser(w http.ResponseWriter, r *http.Request, l lister.Service, user interface{}, alert Alert,
+	newToken string) {
+	signedInUser, _ := r.Context().Value(contextKeyUser).(lister.User)
+	tokens, err := l.GetAPITokens(signedInUser.ID)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, AlertErrorMsgGeneric, http.StatusInternalServerError)
+		return
+	}
 
-		v.render(w, r, data)
+	v := newView("base", "./web/template/user.html")
+
+	data := Data{}
+	data.Head = Head{fmt.Sprintf("Profile: %s %s", signedInUser.FirstName, signedInUser.LastName)}
+	data.Alert = alert
+	data.Yield = struct {
+		Heading   string
+		Text      string
+		User      interface{}
+		UserID    int64
+		APITokens []lister.APIToken
+		NewToken  string
+	}{
+		fmt.Sprintf("Profile: %s %s", signedInUser.FirstName, signedInUser.LastName),
+		"Edit your profile by changing the information below.",
+		user,
+		signedInUser.ID,
+		tokens,
+		newToken,
 	}
+
+	v.render(w, r, data)
 }
 
-func postUser(u updater.Service) httprouter.Handle {
+func postUser(u updater.Service, l lister.Service) httprouter.Handle {
 	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 		// Get the user from the context
 		user, ok := r.Context().Value(contextKeyUser).(lister.User)
@@ -535,22 +830,8 @@ func postUser(u updater.Service) httprouter.Handle {
 		recordsAffected, err := u.UpdateUser(userUpdate)
 		if err != nil {
 			log.Println(err)
-			v := newView("base", "./web/template/user.html")
-			data := Data{}
-			data.Head = Head{fmt.Sprintf("Profile: %s %s", user.FirstName, user.LastName)}
-			// Show the user the error.
-			data.Alert = Alert{Message: err.Error(), Class: AlertClassError}
-			// Fill in the form again for convenience
-			data.Yield = struct {
-				Heading string
-				Text    string
-				User    updater.User
-			}{
-				fmt.Sprintf("Profile: %s %s", user.FirstName, user.LastName),
-				"Edit your profile by changing the information below.",
-				userUpdate,
-			}
-			v.render(w, r, data)
+			// Show the user the error and fill in the form again for convenience.
+			renderUser(w, r, l, userUpdate, Alert{Message: err.Error(), Class: AlertClassError}, "")
 			return
 		}
 		log.Printf("Updated user with ID: %d. %d records affected\n", user.ID, recordsAffected)
@@ -651,9 +932,34 @@ func getFilter(s lister.Service) httprouter.Handle {
 		data := Data{}
 		data.Head = Head{"Filter Restaurants"}
 		// Get all select filters
-		filterOptions := s.GetFilterOptions(queryParams)
+		filterOptions, err := s.GetFilterOptions(queryParams)
+		if err != nil {
+			log.Println(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
 
-		lastVisitOp := s.GetFilterParam("last_visit", queryParams).Operator
+		// The Visited select uses is and isnt and the From and To dates use the other operators.
+		var lastVisitOp, lastVisitFrom, lastVisitTo string
+		for _, f := range s.GetFilterParams("last_visit", queryParams) {
+			switch f.Operator {
+			case "is", "isnt":
+				lastVisitOp = f.Operator
+			case "gteq":
+				lastVisitFrom = f.Value
+			case "lteq":
+				lastVisitTo = f.Value
+			case "between":
+				if len(f.Values) == 2 {
+					lastVisitFrom, lastVisitTo = f.Values[0], f.Values[1]
+				}
+			}
+		}
+
+		nameFilterOp := s.GetFilterParam("name", queryParams)
+		if nameFilterOp.Operator == "" {
+			nameFilterOp.Operator = "contains"
+		}
 
 		avgRatingFilterOp := s.GetFilterParam("avg_rating", queryParams)
 
@@ -665,20 +971,64 @@ func getFilter(s lister.Service) httprouter.Handle {
 			businessStatusOp.Operator = "eq"
 		}
 
+		// The Google Maps place fields
+		type filterChoice struct {
+			Value    string
+			Label    string
+			Selected bool
+		}
+		priceLevelFilterOp := s.GetFilterParam("price_level", queryParams)
+		var priceLevels []filterChoice
+		for i, label := range []string{"$", "$$", "$$$", "$$$$"} {
+			level := strconv.Itoa(i + 1)
+			selected := priceLevelFilterOp.Value == level
+			for _, v := range priceLevelFilterOp.Values {
+				selected = selected || v == level
+			}
+			priceLevels = append(priceLevels, filterChoice{level, label, selected})
+		}
+		gmapsStatusFilterOp := s.GetFilterParam("gmaps_business_status", queryParams)
+		var gmapsStatuses []filterChoice
+		for _, status := range [][2]string{
+			{"OPERATIONAL", "Operational"},
+			{"CLOSED_TEMPORARILY", "Closed Temporarily"},
+			{"CLOSED_PERMANENTLY", "Closed Permanently"},
+		} {
+			gmapsStatuses = append(gmapsStatuses, filterChoice{status[0], status[1], gmapsStatusFilterOp.Value == status[0]})
+		}
+
 		data.Yield = struct {
-			Heading        string
-			Text           string
-			FilterOptions  lister.FilterOptions
-			LastVisitOp    string
-			AvgRating      lister.FilterOperation
-			BusinessStatus lister.FilterOperation
+			Heading           string
+			Text              string
+			FilterOptions     lister.FilterOptions
+			Name              lister.FilterOperation
+			LastVisitOp       string
+			LastVisitFrom     string
+			LastVisitTo       string
+			AvgRating         lister.FilterOperation
+			BusinessStatus    lister.FilterOperation
+			PriceLevels       []filterChoice
+			GmapsStatuses     []filterChoice
+			GmapsRating       lister.FilterOperation
+			GmapsRatingsTotal lister.FilterOperation
+			RatingDiff        lister.FilterOperation
+			FilterExpr        string
 		}{
 			"Filter Restaurants",
 			"Filter the restaurant table by selecting options below.",
 			filterOptions,
+			nameFilterOp,
 			lastVisitOp,
+			lastVisitFrom,
+			lastVisitTo,
 			avgRatingFilterOp,
 			businessStatusOp,
+			priceLevels,
+			gmapsStatuses,
+			s.GetFilterParam("gmaps_rating", queryParams),
+			s.GetFilterParam("gmaps_ratings_total", queryParams),
+			s.GetFilterParam("rating_diff", queryParams),
+			queryParams.Get("filter"),
 		}
 		v.render(w, r, data)
 	}
@@ -694,24 +1044,41 @@ func getSort(s lister.Service) httprouter.Handle {
 		data := Data{}
 		data.Head = Head{"Sort Restaurants"}
 
+		// There is a row for each field the restaurants can be sorted by, filled in with the current sort in order.
+		type sortField struct {
+			Name  string
+			Label string
+		}
+		fields := []sortField{
+			{"name", "Name"},
+			{"cuisine", "Cuisine"},
+			{"city", "City"},
+			{"state", "State"},
+			{"last_visit", "Last Visit"},
+			{"avg_rating", "Average Rating"},
+			{"price_level", "Price Level"},
+			{"gmaps_rating", "Google Rating"},
+			{"gmaps_ratings_total", "Google Reviews"},
+			{"gmaps_business_status", "Google Status"},
+			{"rating_diff", "Our Rating − Google Rating"},
+		}
+		// Distance is measured from the near filter so it can only be sorted by with one.
+		if s.GetFilterParam("location", queryParams).Operator == "near" {
+			fields = append(fields, sortField{"distance", "Distance"})
+		}
+		keys := make([]lister.SortOperation, len(fields))
+		copy(keys, s.GetSortParams(queryParams))
+
 		data.Yield = struct {
-			Heading   string
-			Text      string
-			Name      lister.SortOperation
-			Cuisine   lister.SortOperation
-			City      lister.SortOperation
-			State     lister.SortOperation
-			LastVisit lister.SortOperation
-			AvgRating lister.SortOperation
+			Heading string
+			Text    string
+			Fields  []sortField
+			Keys    []lister.SortOperation
 		}{
 			"Sort Restaurants",
-			"Sort the restaurant table by selecting options below.",
-			s.GetSortParam("name", queryParams),
-			s.GetSortParam("cuisine", queryParams),
-			s.GetSortParam("city", queryParams),
-			s.GetSortParam("state", queryParams),
-			s.GetSortParam("last_visit", queryParams),
-			s.GetSortParam("avg_rating", queryParams),
+			"Sort the restaurant table by the fields below, in order. Later fields only break ties in the ones before them.",
+			fields,
+			keys,
 		}
 		v.render(w, r, data)
 	}
@@ -753,7 +1120,12 @@ func deletePlace(s remover.Service) httprouter.Handle {
 		}
 
 		log.Printf("Removing Gmaps Place ID: %d\n", ID)
-		recordsAffected := s.RemoveGmapsPlace(int64(ID))
+		recordsAffected, err := s.RemoveGmapsPlace(int64(ID), signedInUserID(r))
+		if err != nil {
+			log.Println(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
 		log.Printf("Number of records affected %d", recordsAffected)
 
 		rm := struct {
@@ -0,0 +1,163 @@
+package web
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/kelvinatorr/restaurant-tracker/internal/adder"
+	"github.com/kelvinatorr/restaurant-tracker/internal/lister"
+)
+
+// maxImportSize is the largest CSV or JSON export the import page takes.
+const maxImportSize = 10 << 20
+
+// importUser is a user and the column their ratings are in.
+type importUser struct {
+	ID        int64
+	FirstName string
+	Column    string
+}
+
+func getImport(l lister.Service) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		users, err := l.GetUsers()
+		if err != nil {
+			log.Println(err.Error())
+			http.Error(w, "There was a problem processing your request", http.StatusInternalServerError)
+			return
+		}
+		renderImport(w, r, users, adder.DefaultImportColumns, "csv", "", false, nil, Alert{})
+	}
+}
+
+// postImport previews an import when the preview button is pressed and imports the file otherwise. The file is a CSV,
+// or a JSON export when the format is json, that is uploaded or, after a preview, the text of the file that was
+// previewed.
+func postImport(a adder.Service, l lister.Service) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		r.Body = http.MaxBytesReader(w, r.Body, maxImportSize+1<<20)
+		if err := r.ParseMultipartForm(maxImportSize); err != nil {
+			log.Println(err)
+			http.Error(w, "The file is too big or the form could not be read", http.StatusBadRequest)
+			return
+		}
+		users, err := l.GetUsers()
+		if err != nil {
+			log.Println(err.Error())
+			http.Error(w, "There was a problem processing your request", http.StatusInternalServerError)
+			return
+		}
+		var cols adder.ImportColumns
+		if err := parseForm(r, &cols); err != nil {
+			log.Println(err)
+			http.Error(w, AlertFormParseErrorGeneric, http.StatusInternalServerError)
+			return
+		}
+		cols.Ratings = make(map[int64]string)
+		for _, u := range users {
+			cols.Ratings[u.ID] = r.PostFormValue(fmt.Sprintf("rating%d", u.ID))
+		}
+
+		format, kind := "csv", "CSV"
+		if r.PostFormValue("format") == "json" {
+			format, kind = "json", "export"
+			// The JSON form doesn't have the columns so the CSV form gets its defaults back.
+			cols = adder.DefaultImportColumns
+			cols.Ratings = make(map[int64]string)
+		}
+		text := r.PostFormValue(format)
+		if f, _, err := r.FormFile("file"); err == nil {
+			b, err := ioutil.ReadAll(f)
+			f.Close()
+			if err != nil {
+				log.Println(err)
+				http.Error(w, fmt.Sprintf("The %s could not be read", kind), http.StatusBadRequest)
+				return
+			}
+			text = string(b)
+		}
+		createUsers := format == "json" && r.PostFormValue("createUsers") == "true"
+		if text == "" {
+			alert := Alert{Message: fmt.Sprintf("Choose a %s to import", kind), Class: AlertClassError}
+			renderImport(w, r, users, cols, format, "", createUsers, nil, alert)
+			return
+		}
+
+		dryRun := r.PostFormValue("action") == "preview"
+		var result adder.ImportResult
+		if format == "json" {
+			result, err = a.ImportJSON(strings.NewReader(text), dryRun, createUsers, signedInUserID(r))
+		} else {
+			result, err = a.ImportCSV(strings.NewReader(text), cols, dryRun, signedInUserID(r))
+		}
+		if err != nil {
+			log.Println(err)
+			renderImport(w, r, users, cols, format, text, createUsers, nil,
+				Alert{Message: err.Error(), Class: AlertClassError})
+			return
+		}
+		alert := Alert{
+			Message: fmt.Sprintf("Imported %d restaurants and %d visits.", len(result.Restaurants), result.Visits),
+			Class:   AlertClassSuccess,
+		}
+		if dryRun {
+			alert.Message = fmt.Sprintf("Importing would add %d restaurants and %d visits. Nothing has been saved yet.",
+				len(result.Restaurants), result.Visits)
+		} else {
+			log.Printf("Imported %d restaurants and %d visits\n", len(result.Restaurants), result.Visits)
+			// There's nothing left to import.
+			text = ""
+		}
+		renderImport(w, r, users, cols, format, text, createUsers, &result, alert)
+	}
+}
+
+// renderImport renders the import page. text is the CSV, or JSON export when the format is json, that was previewed so
+// it can be imported without uploading it again, along with whether it creates the users who aren't here.
+func renderImport(w http.ResponseWriter, r *http.Request, users []lister.User, cols adder.ImportColumns, format string,
+	text string, createUsers bool, result *adder.ImportResult, alert Alert) {
+	var importUsers []importUser
+	for _, u := range users {
+		importUsers = append(importUsers, importUser{ID: u.ID, FirstName: u.FirstName, Column: cols.Ratings[u.ID]})
+	}
+
+	// Restaurants in a JSON export are numbered by their position instead of their row.
+	csv, json, rowLabel := text, "", "Row"
+	if format == "json" {
+		csv, json, rowLabel = "", text, "Restaurant"
+	}
+
+	v := newView("base", "./web/template/import.html")
+
+	data := Data{}
+	data.Head = Head{"Import"}
+	data.Alert = alert
+	data.Yield = struct {
+		Heading     string
+		Text        string
+		Columns     adder.ImportColumns
+		Users       []importUser
+		CSV         string
+		JSON        string
+		RowLabel    string
+		CreateUsers bool
+		Result      *adder.ImportResult
+	}{
+		"Import",
+		"Add restaurants and visits from a CSV with a row per visit, like one exported from a spreadsheet, or from " +
+			"a JSON export. Rows for the same restaurant are grouped and restaurants that are already here are " +
+			"skipped.",
+		cols,
+		importUsers,
+		csv,
+		json,
+		rowLabel,
+		createUsers,
+		result,
+	}
+	v.render(w, r, data)
+}
@@ -0,0 +1,93 @@
+package web
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/kelvinatorr/restaurant-tracker/internal/lister"
+)
+
+// recommendQuery returns the query params of a recommendation. The users checkboxes send a users param per user which
+// are joined into the comma separated list the lister wants, and without any the signed in user is going alone.
+func recommendQuery(r *http.Request) url.Values {
+	qp := r.URL.Query()
+	var users []string
+	for _, u := range qp["users"] {
+		if u != "" {
+			users = append(users, u)
+		}
+	}
+	if len(users) == 0 {
+		users = []string{strconv.FormatInt(signedInUserID(r), 10)}
+	}
+	qp.Set("users", strings.Join(users, ","))
+	return qp
+}
+
+func getRecommend(l lister.Service) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		v := newView("base", "./web/template/recommend.html")
+
+		qp := recommendQuery(r)
+		recs, err := l.Recommend(qp)
+		if err != nil {
+			log.Println(err.Error())
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		users, err := l.GetUsers()
+		if err != nil {
+			log.Println(err.Error())
+			http.Error(w, "There was a problem processing your request", http.StatusInternalServerError)
+			return
+		}
+		going := make(map[int64]bool)
+		for _, u := range recs.Attendees {
+			going[u.ID] = true
+		}
+		nearKm := "5"
+		if near := strings.Split(qp.Get("near"), ","); len(near) == 3 {
+			nearKm = near[2]
+		}
+
+		data := Data{}
+		data.Head = Head{"Where Should We Go?"}
+		data.Yield = struct {
+			Users           []lister.User
+			Going           map[int64]bool
+			Near            string
+			NearKm          string
+			NearKmOptions   []string
+			Recommendations lister.Recommendations
+			JSONURL         string
+		}{
+			users,
+			going,
+			qp.Get("near"),
+			nearKm,
+			[]string{"1", "2", "5", "10", "25"},
+			recs,
+			"/recommend.json?" + qp.Encode(),
+		}
+		v.render(w, r, data)
+	}
+}
+
+// getRecommendJSON returns the same recommendations as the recommend page as JSON.
+func getRecommendJSON(l lister.Service) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		recs, err := l.Recommend(recommendQuery(r))
+		if err != nil {
+			log.Println(err.Error())
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(recs)
+	}
+}
@@ -51,9 +51,15 @@ func addRestaurant(a adder.Service, m mapper.Service, w http.ResponseWriter, r *
 		return
 	}
 
-	newRestaurantID, err := a.AddRestaurant(resNew)
+	newRestaurantID, err := a.AddRestaurant(resNew, signedInUserID(r))
 	if err != nil {
 		log.Println(err)
+		cuisines, cities, states, err2 := getRestaurantOptions(l)
+		if err2 != nil {
+			log.Println(err2)
+			http.Error(w, err2.Error(), http.StatusInternalServerError)
+			return
+		}
 		v := newView("base", "./web/template/restaurant.html")
 		data := Data{}
 		data.Head = Head{"Add A New Restaurant"}
@@ -84,9 +90,9 @@ func addRestaurant(a adder.Service, m mapper.Service, w http.ResponseWriter, r *
 			"Add A New Restaurant",
 			"Add the new restaurant's details below",
 			restaurant,
-			l.GetDistinct("cuisine", "restaurant"),
-			l.GetDistinct("name", "city"),
-			l.GetDistinct("state", "city"),
+			cuisines,
+			cities,
+			states,
 			m.HaveGmapsKey(),
 		}
 		v.render(w, r, data)
@@ -104,9 +110,15 @@ func updateRestaurant(u updater.Service, m mapper.Service, w http.ResponseWriter
 		return
 	}
 
-	recordsAffected, err := u.UpdateRestaurant(resUpdate)
+	recordsAffected, err := u.UpdateRestaurant(resUpdate, signedInUserID(r))
 	if err != nil {
 		log.Println(err)
+		cuisines, cities, states, err2 := getRestaurantOptions(l)
+		if err2 != nil {
+			log.Println(err2)
+			http.Error(w, err2.Error(), http.StatusInternalServerError)
+			return
+		}
 		v := newView("base", "./web/template/restaurant.html")
 		data := Data{}
 		data.Head = Head{resUpdate.Name}
@@ -125,9 +137,9 @@ func updateRestaurant(u updater.Service, m mapper.Service, w http.ResponseWriter
 			resUpdate.Name,
 			"Edit this restuarant's details below",
 			resUpdate,
-			l.GetDistinct("cuisine", "restaurant"),
-			l.GetDistinct("name", "city"),
-			l.GetDistinct("state", "city"),
+			cuisines,
+			cities,
+			states,
 			m.HaveGmapsKey(),
 		}
 		v.render(w, r, data)
@@ -166,7 +178,7 @@ func getDeleteRestaurant(l lister.Service) httprouter.Handle {
 			Restaurant lister.Restaurant
 		}{
 			fmt.Sprintf("Delete %s", restaurant.Name),
-			fmt.Sprintf("Are you sure you want to delete %s? This will also delete all visit data for this restaurant.", restaurant.Name),
+			fmt.Sprintf("Are you sure you want to delete %s? It will be moved to the Trash along with all of its visits, where it can be restored until it is purged.", restaurant.Name),
 			restaurant,
 		}
 
@@ -219,7 +231,11 @@ func postDeleteRestaurant(s remover.Service) httprouter.Handle {
 			return
 		} else {
 			log.Printf("Confirmed request to remove %s with ID: %d", deleteConfirm.Name, ID)
-			s.RemoveRestaurant(remover.Restaurant{ID: int64(ID)})
+			if _, err := s.RemoveRestaurant(remover.Restaurant{ID: int64(ID)}, signedInUserID(r)); err != nil {
+				log.Println(err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
 			// Redirect to the list of other restaurants.
 			http.Redirect(w, r, "/", http.StatusSeeOther)
 		}
@@ -236,9 +252,12 @@ func renderRestaurant(w http.ResponseWriter, r *http.Request, s lister.Service,
 		data.Alert = a
 	}
 
-	cuisines := s.GetDistinct("cuisine", "restaurant")
-	cities := s.GetDistinct("name", "city")
-	states := s.GetDistinct("state", "city")
+	cuisines, cities, states, err := getRestaurantOptions(s)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
 	haveGmapsKey := m.HaveGmapsKey()
 
@@ -250,6 +269,12 @@ func renderRestaurant(w http.ResponseWriter, r *http.Request, s lister.Service,
 			http.Error(w, err.Error(), http.StatusNotFound)
 			return
 		}
+		history, err := s.GetHistory("restaurant", restaurant.ID)
+		if err != nil {
+			log.Println(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
 		data.Head = Head{restaurant.Name}
 		data.Yield = struct {
 			Heading      string
@@ -259,6 +284,7 @@ func renderRestaurant(w http.ResponseWriter, r *http.Request, s lister.Service,
 			Cities       []string
 			States       []string
 			HaveGmapsKey bool
+			History      []lister.AuditEntry
 		}{
 			restaurant.Name,
 			"Edit this restaurant's details below",
@@ -267,6 +293,7 @@ func renderRestaurant(w http.ResponseWriter, r *http.Request, s lister.Service,
 			cities,
 			states,
 			haveGmapsKey,
+			history,
 		}
 	} else {
 		// Adding a new restaurant
@@ -281,6 +308,7 @@ func renderRestaurant(w http.ResponseWriter, r *http.Request, s lister.Service,
 			Cities       []string
 			States       []string
 			HaveGmapsKey bool
+			History      []lister.AuditEntry
 		}{
 			"Add A New Restaurant",
 			"Add the new restaurant's details below",
@@ -289,8 +317,26 @@ func renderRestaurant(w http.ResponseWriter, r *http.Request, s lister.Service,
 			cities,
 			states,
 			haveGmapsKey,
+			nil,
 		}
 	}
 
 	v.render(w, r, data)
 }
+
+// getRestaurantOptions returns the distinct cuisines, cities and states used to fill in the restaurant form's datalists.
+func getRestaurantOptions(l lister.Service) ([]string, []string, []string, error) {
+	cuisines, err := l.GetDistinct("cuisine", "restaurant")
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	cities, err := l.GetDistinct("name", "city")
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	states, err := l.GetDistinct("state", "city")
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return cuisines, cities, states, nil
+}
@@ -0,0 +1,173 @@
+package web
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/kelvinatorr/restaurant-tracker/internal/adder"
+	"github.com/kelvinatorr/restaurant-tracker/internal/lister"
+	"github.com/kelvinatorr/restaurant-tracker/internal/remover"
+	"github.com/kelvinatorr/restaurant-tracker/internal/updater"
+)
+
+// savedViewLink is a saved view in the picker on the home page.
+type savedViewLink struct {
+	lister.SavedView
+	URL string
+	// Selected is true when the home page is showing the view.
+	Selected bool
+}
+
+// savedViewURL returns the home page url that shows a view. The view param is there so an empty query doesn't send
+// the user to their default view.
+func savedViewURL(query string) string {
+	if query == "" {
+		return "/?view="
+	}
+	return "/?" + query
+}
+
+// getSavedViewLinks returns the user's views for the picker on the home page. currentQuery is the query of the restaurant
+// list that is showing, see lister.ViewQuery.
+func getSavedViewLinks(l lister.Service, userID int64, currentQuery string) ([]savedViewLink, error) {
+	views, err := l.GetSavedViews(userID)
+	if err != nil {
+		return nil, err
+	}
+	links := make([]savedViewLink, len(views))
+	for i, sv := range views {
+		links[i] = savedViewLink{SavedView: sv, URL: savedViewURL(sv.Query), Selected: sv.Query == currentQuery}
+	}
+	return links, nil
+}
+
+func getSavedViews(l lister.Service) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		renderSavedViews(w, r, l, Alert{})
+	}
+}
+
+func renderSavedViews(w http.ResponseWriter, r *http.Request, l lister.Service, alert Alert) {
+	userID := signedInUserID(r)
+	views, err := l.GetSavedViews(userID)
+	if err != nil {
+		log.Println(err.Error())
+		http.Error(w, "There was a problem processing your request", http.StatusInternalServerError)
+		return
+	}
+	var own, shared []savedViewLink
+	for _, sv := range views {
+		link := savedViewLink{SavedView: sv, URL: savedViewURL(sv.Query)}
+		if sv.UserID == userID {
+			own = append(own, link)
+		} else {
+			shared = append(shared, link)
+		}
+	}
+
+	v := newView("base", "./web/template/saved-views.html")
+	data := Data{}
+	data.Alert = alert
+	data.Head = Head{"Saved Views"}
+	data.Yield = struct {
+		Heading string
+		Text    string
+		Own     []savedViewLink
+		Shared  []savedViewLink
+	}{
+		"Saved Views",
+		"Save a filter and sort of the restaurant list from the home page to get back to it quickly. Your default view is what you see on the home page.",
+		own,
+		shared,
+	}
+	v.render(w, r, data)
+}
+
+func postSavedView(a adder.Service, u updater.Service, l lister.Service) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		ID, err := strconv.Atoi(p.ByName("id"))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("%s is not a valid saved view ID, it must be a number.", p.ByName("id")),
+				http.StatusBadRequest)
+			return
+		}
+		if ID != 0 {
+			updateSavedView(u, l, w, r)
+		} else {
+			addSavedView(a, l, w, r)
+		}
+	}
+}
+
+func addSavedView(a adder.Service, l lister.Service, w http.ResponseWriter, r *http.Request) {
+	var viewNew adder.SavedView
+	if err := parseForm(r, &viewNew); err != nil {
+		log.Println(err)
+		http.Error(w, AlertFormParseErrorGeneric, http.StatusInternalServerError)
+		return
+	}
+	if err := l.CheckViewQuery(viewNew.Query); err != nil {
+		log.Println(err)
+		renderSavedViews(w, r, l, Alert{Message: err.Error(), Class: AlertClassError})
+		return
+	}
+	newViewID, err := a.AddSavedView(viewNew, signedInUserID(r))
+	if err != nil {
+		log.Println(err)
+		renderSavedViews(w, r, l, Alert{Message: err.Error(), Class: AlertClassError})
+		return
+	}
+	log.Printf("New saved view added with id: %d\n", newViewID)
+	// Go back to the list the view was saved from.
+	saved, err := l.GetSavedView(newViewID, signedInUserID(r))
+	if err != nil {
+		log.Println(err)
+		http.Redirect(w, r, "/views", http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, savedViewURL(saved.Query), http.StatusSeeOther)
+}
+
+func updateSavedView(u updater.Service, l lister.Service, w http.ResponseWriter, r *http.Request) {
+	var viewUpdate updater.SavedView
+	if err := parseForm(r, &viewUpdate); err != nil {
+		log.Println(err)
+		http.Error(w, AlertFormParseErrorGeneric, http.StatusInternalServerError)
+		return
+	}
+	if err := l.CheckViewQuery(viewUpdate.Query); err != nil {
+		log.Println(err)
+		renderSavedViews(w, r, l, Alert{Message: err.Error(), Class: AlertClassError})
+		return
+	}
+	recordsAffected, err := u.UpdateSavedView(viewUpdate, signedInUserID(r))
+	if err != nil {
+		log.Println(err)
+		renderSavedViews(w, r, l, Alert{Message: err.Error(), Class: AlertClassError})
+		return
+	}
+	log.Printf("Updated Saved View id: %d. Records affected: %d\n", viewUpdate.ID, recordsAffected)
+	renderSavedViews(w, r, l, Alert{Message: fmt.Sprintf("%s was saved.", viewUpdate.Name), Class: AlertClassSuccess})
+}
+
+func postDeleteSavedView(s remover.Service) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		ID, err := strconv.Atoi(p.ByName("id"))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("%s is not a valid saved view ID, it must be a number.", p.ByName("id")),
+				http.StatusBadRequest)
+			return
+		}
+		recordsAffected, err := s.RemoveSavedView(int64(ID), signedInUserID(r))
+		if err != nil {
+			log.Println(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		log.Printf("Removed Saved View id: %d. Records affected: %d\n", ID, recordsAffected)
+		http.Redirect(w, r, "/views", http.StatusSeeOther)
+	}
+}
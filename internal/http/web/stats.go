@@ -0,0 +1,155 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/kelvinatorr/restaurant-tracker/internal/lister"
+)
+
+// statsBar is a bar in a chart on the stats page. Percent is how long it is compared to the longest bar in its chart.
+type statsBar struct {
+	Label   string
+	Value   int64
+	Percent int64
+}
+
+// statsRange is a link to the stats of a preset date range.
+type statsRange struct {
+	Label    string
+	URL      string
+	Selected bool
+}
+
+// userRatingBars is the chart of how a user's ratings are spread out.
+type userRatingBars struct {
+	lister.UserRatingStats
+	Bars []statsBar
+}
+
+func getStats(l lister.Service) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		v := newView("base", "./web/template/stats.html")
+
+		stats, err := l.GetStats(r.URL.Query())
+		if err != nil {
+			log.Println(err.Error())
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		monthBars := make([]statsBar, len(stats.VisitsByMonth))
+		for i, m := range stats.VisitsByMonth {
+			monthBars[i] = statsBar{Label: m.Period, Value: m.Visits}
+		}
+		yearBars := make([]statsBar, len(stats.VisitsByYear))
+		for i, y := range stats.VisitsByYear {
+			yearBars[i] = statsBar{Label: y.Period, Value: y.Visits}
+		}
+		userBars := make([]userRatingBars, len(stats.UserRatings))
+		for i, u := range stats.UserRatings {
+			userBars[i].UserRatingStats = u
+			for rating, count := range u.Counts {
+				userBars[i].Bars = append(userBars[i].Bars, statsBar{Label: fmt.Sprint(rating + 1), Value: count})
+			}
+			setBarPercents(userBars[i].Bars)
+		}
+		setBarPercents(monthBars)
+		setBarPercents(yearBars)
+
+		q := r.URL.Query()
+		jsonURL := "/stats.json"
+		if len(q) > 0 {
+			jsonURL += "?" + q.Encode()
+		}
+
+		data := Data{}
+		data.Head = Head{"Stats"}
+		data.Yield = struct {
+			Stats     lister.Stats
+			Ranges    []statsRange
+			MonthBars []statsBar
+			YearBars  []statsBar
+			UserBars  []userRatingBars
+			// NewRestaurantPercent is Stats.NewRestaurantRate as a whole percent.
+			NewRestaurantPercent int64
+			JSONURL              string
+		}{
+			stats,
+			getStatsRanges(stats.From, stats.To, time.Now().UTC()),
+			monthBars,
+			yearBars,
+			userBars,
+			int64(stats.NewRestaurantRate*100 + 0.5),
+			jsonURL,
+		}
+		v.render(w, r, data)
+	}
+}
+
+// getStatsJSON returns the same stats as the stats page as JSON for charts.
+func getStatsJSON(l lister.Service) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		stats, err := l.GetStats(r.URL.Query())
+		if err != nil {
+			log.Println(err.Error())
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(stats)
+	}
+}
+
+// setBarPercents sets how long each bar is compared to the longest one.
+func setBarPercents(bars []statsBar) {
+	var max int64
+	for _, b := range bars {
+		if b.Value > max {
+			max = b.Value
+		}
+	}
+	if max == 0 {
+		return
+	}
+	for i := range bars {
+		bars[i].Percent = bars[i].Value * 100 / max
+	}
+}
+
+// getStatsRanges returns the preset date ranges of the stats page. The one that is from to to is selected.
+func getStatsRanges(from string, to string, now time.Time) []statsRange {
+	dateFormat := "2006-01-02"
+	today := now.Format(dateFormat)
+	presets := []struct {
+		label string
+		from  string
+		to    string
+	}{
+		{"All Time", "", ""},
+		{"This Year", fmt.Sprintf("%d-01-01", now.Year()), today},
+		{"Last 12 Months", now.AddDate(-1, 0, 1).Format(dateFormat), today},
+		{"Last Year", fmt.Sprintf("%d-01-01", now.Year()-1), fmt.Sprintf("%d-12-31", now.Year()-1)},
+	}
+	ranges := make([]statsRange, len(presets))
+	for i, p := range presets {
+		qp := url.Values{}
+		if p.from != "" {
+			qp.Set("from", p.from)
+		}
+		if p.to != "" {
+			qp.Set("to", p.to)
+		}
+		u := "/stats"
+		if len(qp) > 0 {
+			u += "?" + qp.Encode()
+		}
+		ranges[i] = statsRange{Label: p.label, URL: u, Selected: p.from == from && p.to == to}
+	}
+	return ranges
+}
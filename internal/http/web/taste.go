@@ -0,0 +1,80 @@
+package web
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/kelvinatorr/restaurant-tracker/internal/lister"
+)
+
+// getTaste compares the ratings of the users in the user1 and user2 query params. user1 is the signed in user and user2
+// is the first other user unless they are given.
+func getTaste(l lister.Service) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		v := newView("base", "./web/template/taste.html")
+
+		users, err := l.GetUsers()
+		if err != nil {
+			log.Println(err.Error())
+			http.Error(w, "There was a problem processing your request", http.StatusInternalServerError)
+			return
+		}
+
+		var userIDs [2]int64
+		for i, param := range []string{"user1", "user2"} {
+			value := r.URL.Query().Get(param)
+			if value == "" {
+				continue
+			}
+			ID, err := strconv.Atoi(value)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("%s is not a valid user ID, it must be a number.", value),
+					http.StatusBadRequest)
+				return
+			}
+			userIDs[i] = int64(ID)
+		}
+		if userIDs[0] == 0 {
+			userIDs[0] = signedInUserID(r)
+		}
+		if userIDs[1] == 0 {
+			for _, u := range users {
+				if u.ID != userIDs[0] {
+					userIDs[1] = u.ID
+					break
+				}
+			}
+		}
+
+		data := Data{}
+		data.Head = Head{"Compare Tastes"}
+		var comparison lister.TasteComparison
+		// A household of one has no one to compare with.
+		if userIDs[1] != 0 {
+			comparison, err = l.CompareTastes(userIDs[0], userIDs[1])
+			if err != nil {
+				log.Println(err.Error())
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		data.Yield = struct {
+			Users      []lister.User
+			User1ID    int64
+			User2ID    int64
+			Comparison lister.TasteComparison
+			// AgreementPercent is Comparison.AgreementRate as a whole percent.
+			AgreementPercent int64
+		}{
+			users,
+			userIDs[0],
+			userIDs[1],
+			comparison,
+			int64(comparison.AgreementRate*100 + 0.5),
+		}
+		v.render(w, r, data)
+	}
+}
@@ -0,0 +1,76 @@
+package web
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/kelvinatorr/restaurant-tracker/internal/lister"
+	"github.com/kelvinatorr/restaurant-tracker/internal/remover"
+)
+
+func getTrash(l lister.Service) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		v := newView("base", "./web/template/trash.html")
+
+		trash, err := l.GetTrash()
+		if err != nil {
+			log.Println(err.Error())
+			http.Error(w, "There was a problem processing your request", http.StatusInternalServerError)
+			return
+		}
+
+		data := Data{}
+		data.Head = Head{"Trash"}
+		data.Yield = struct {
+			Heading string
+			Text    string
+			Trash   lister.Trash
+		}{
+			"Trash",
+			"Deleted restaurants and visits stay here until they are purged. Restore them to bring them back.",
+			trash,
+		}
+		v.render(w, r, data)
+	}
+}
+
+func postRestoreRestaurant(s remover.Service) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		ID, err := strconv.Atoi(p.ByName("id"))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("%s is not a valid restaurant ID, it must be a number.", p.ByName("id")),
+				http.StatusBadRequest)
+			return
+		}
+		recordsAffected, err := s.RestoreRestaurant(int64(ID), signedInUserID(r))
+		if err != nil {
+			log.Println(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		log.Printf("Restored Restaurant id: %d. Records affected: %d\n", ID, recordsAffected)
+		http.Redirect(w, r, "/trash", http.StatusSeeOther)
+	}
+}
+
+func postRestoreVisit(s remover.Service) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		ID, err := strconv.Atoi(p.ByName("id"))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("%s is not a valid visit ID, it must be a number.", p.ByName("id")),
+				http.StatusBadRequest)
+			return
+		}
+		recordsAffected, err := s.RestoreVisit(int64(ID), signedInUserID(r))
+		if err != nil {
+			log.Println(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		log.Printf("Restored Visit id: %d. Records affected: %d\n", ID, recordsAffected)
+		http.Redirect(w, r, "/trash", http.StatusSeeOther)
+	}
+}
@@ -62,6 +62,7 @@ func (v *view) render(w http.ResponseWriter, r *http.Request, data interface{})
 	if ok {
 		viewData.User.ID = user.ID
 		viewData.User.FirstName = user.FirstName
+		viewData.User.IsAdmin = user.IsAdmin
 	}
 
 	csrfField := csrf.TemplateField(r)
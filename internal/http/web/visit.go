@@ -41,25 +41,83 @@ func getVisits(l lister.Service) httprouter.Handle {
 			return
 		}
 
-		v := newView("base", "./web/template/visits.html")
-
-		data := Data{}
+		renderVisits(w, r, l, restaurant, visits)
+	}
+}
 
-		data.Head = Head{fmt.Sprintf("%s Visits", restaurant.Name)}
-		data.Yield = struct {
-			Heading      string
-			RestaurantID int64
-			Visits       []lister.Visit
-		}{
-			fmt.Sprintf("%s", restaurant.Name),
-			restaurant.ID,
-			visits,
+// getAllVisits lists the visits to every restaurant.
+func getAllVisits(l lister.Service) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		visits, err := l.GetVisits(r.URL.Query())
+		if err != nil {
+			log.Println(err.Error())
+			http.Error(w, "There was a problem processing your request", http.StatusBadRequest)
+			return
 		}
 
-		v.render(w, r, data)
+		renderVisits(w, r, l, lister.Restaurant{}, visits)
 	}
 }
 
+// renderVisits renders a page of visits. The restaurant's ID is 0 when the visits are to every restaurant.
+func renderVisits(w http.ResponseWriter, r *http.Request, l lister.Service, restaurant lister.Restaurant,
+	visits lister.VisitPage) {
+	users, err := l.GetUsers()
+	if err != nil {
+		log.Println(err.Error())
+		http.Error(w, "There was a problem processing your request", http.StatusInternalServerError)
+		return
+	}
+
+	v := newView("base", "./web/template/visits.html")
+
+	data := Data{}
+
+	heading := restaurant.Name
+	path := fmt.Sprintf("/r/%d/visits", restaurant.ID)
+	if restaurant.ID == 0 {
+		heading = "All"
+		path = "/visits"
+	}
+	data.Head = Head{fmt.Sprintf("%s Visits", heading)}
+	// The filter form is filled in with the filter[] params it submits.
+	queryParams := r.URL.Query()
+	prevURL, nextURL := pageURLs(r.URL, visits.Page)
+	data.Yield = struct {
+		Heading      string
+		RestaurantID int64
+		Path         string
+		Visits       []lister.Visit
+		Page         lister.Page
+		PrevURL      string
+		NextURL      string
+		Users        []lister.User
+		DateFrom     string
+		DateTo       string
+		UserID       string
+		RatingFrom   string
+		RatingTo     string
+		HasNote      string
+	}{
+		heading,
+		restaurant.ID,
+		path,
+		visits.Visits,
+		visits.Page,
+		prevURL,
+		nextURL,
+		users,
+		queryParams.Get("filter[date|gteq]"),
+		queryParams.Get("filter[date|lteq]"),
+		queryParams.Get("filter[user|eq]"),
+		queryParams.Get("filter[rating|gteq]"),
+		queryParams.Get("filter[rating|lteq]"),
+		queryParams.Get("filter[has_note|eq]"),
+	}
+
+	v.render(w, r, data)
+}
+
 func getVisit(l lister.Service) httprouter.Handle {
 	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
 		resID, err := strconv.Atoi(p.ByName("resid"))
@@ -118,7 +176,7 @@ func updateVisit(u updater.Service, l lister.Service, w http.ResponseWriter, r *
 		return
 	}
 
-	recordsAffected, err := u.UpdateVisit(visitUpdate)
+	recordsAffected, err := u.UpdateVisit(visitUpdate, signedInUserID(r))
 	if err != nil {
 		updateErrorMsg := err.Error()
 		log.Println(updateErrorMsg)
@@ -130,7 +188,12 @@ func updateVisit(u updater.Service, l lister.Service, w http.ResponseWriter, r *
 			Note:          visitUpdate.Note,
 		}
 		for _, vu := range visitUpdate.VisitUsers {
-			lvu := lister.VisitUser{ID: vu.ID, User: l.GetUserByID(vu.UserID), Rating: vu.Rating}
+			// A user that can't be found is shown blank so the rest of the form can still be corrected.
+			user, err := l.GetUserByID(vu.UserID)
+			if err != nil {
+				log.Println(err)
+			}
+			lvu := lister.VisitUser{ID: vu.ID, User: user, Rating: vu.Rating}
 			visit.VisitUsers = append(visit.VisitUsers, lvu)
 		}
 
@@ -167,7 +230,7 @@ func addVisit(a adder.Service, l lister.Service, w http.ResponseWriter, r *http.
 		return
 	}
 
-	newVisitID, err := a.AddVisit(visitNew)
+	newVisitID, err := a.AddVisit(visitNew, signedInUserID(r))
 	if err != nil {
 		errorMsg := err.Error()
 		log.Println(errorMsg)
@@ -185,7 +248,12 @@ func addVisit(a adder.Service, l lister.Service, w http.ResponseWriter, r *http.
 			Note:          visitNew.Note,
 		}
 		for _, vu := range visitNew.VisitUsers {
-			lvu := lister.VisitUser{ID: 0, User: l.GetUserByID(vu.UserID), Rating: vu.Rating}
+			// A user that can't be found is shown blank so the rest of the form can still be corrected.
+			user, err := l.GetUserByID(vu.UserID)
+			if err != nil {
+				log.Println(err)
+			}
+			lvu := lister.VisitUser{ID: 0, User: user, Rating: vu.Rating}
 			visit.VisitUsers = append(visit.VisitUsers, lvu)
 		}
 
@@ -289,7 +357,12 @@ func postDeleteVisit(s remover.Service) httprouter.Handle {
 
 		log.Printf("Confirmed request to remove visit to %s on %s with ID: %d", deleteConfirm.RestaurantName,
 			deleteConfirm.VisitDateTime, ID)
-		s.RemoveVisit(remover.Visit{ID: int64(ID)})
+		if _, err := s.RemoveVisit(remover.Visit{ID: int64(ID), RestaurantID: int64(deleteConfirm.RestaurantID)},
+			signedInUserID(r)); err != nil {
+			log.Println(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
 		// Redirect to the list of other visits.
 		http.Redirect(w, r, fmt.Sprintf("/r/%d/visits", deleteConfirm.RestaurantID), http.StatusSeeOther)
 	}
@@ -315,7 +388,13 @@ func renderVisit(w http.ResponseWriter, r *http.Request, restaurant lister.Resta
 			VisitDateTime: "",
 			Note:          "",
 		}
-		for _, user := range l.GetUsers() {
+		users, err := l.GetUsers()
+		if err != nil {
+			log.Println(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		for _, user := range users {
 			lvu := lister.VisitUser{ID: 0, User: user, Rating: 0}
 			visit.VisitUsers = append(visit.VisitUsers, lvu)
 		}
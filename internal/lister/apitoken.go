@@ -0,0 +1,26 @@
+package lister
+
+import "time"
+
+// APIToken is a personal access token without the token itself, which is only shown when it is made.
+type APIToken struct {
+	ID       int64  `json:"id"`
+	UserID   int64  `json:"user_id"`
+	Name     string `json:"name"`
+	ReadOnly bool   `json:"read_only"`
+	// CreatedAt, LastUsedAt and ExpiresAt are RFC3339 UTC. LastUsedAt is empty until it is used and ExpiresAt is
+	// empty if it never expires.
+	CreatedAt  string `json:"created_at"`
+	LastUsedAt string `json:"last_used_at"`
+	ExpiresAt  string `json:"expires_at"`
+}
+
+// GetAPITokens returns the user's API tokens, newest first.
+func (s service) GetAPITokens(userID int64) ([]APIToken, error) {
+	return s.r.GetAPITokens(userID)
+}
+
+// Expired is true once the token's expiry has passed.
+func (t APIToken) Expired() bool {
+	return t.ExpiresAt != "" && t.ExpiresAt <= time.Now().UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,228 @@
+package lister
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// ExportVersion is the version of the Export format. It changes when an export can't be read the way older ones were.
+const ExportVersion = 1
+
+// Export is all of the restaurants, visits and users, without their passwords, so the data can be kept or moved
+// somewhere else. Restaurants and visits in the trash aren't in it.
+type Export struct {
+	Version     int                `json:"version"`
+	ExportedAt  string             `json:"exported_at"`
+	Users       []User             `json:"users"`
+	Restaurants []ExportRestaurant `json:"restaurants"`
+}
+
+// ExportRestaurant is a restaurant with its city, Google Maps place and visits.
+type ExportRestaurant struct {
+	ID             int64   `json:"id"`
+	Name           string  `json:"name"`
+	Cuisine        string  `json:"cuisine"`
+	BusinessStatus int     `json:"business_status"`
+	Note           string  `json:"note"`
+	Address        string  `json:"address"`
+	Zipcode        string  `json:"zipcode"`
+	City           string  `json:"city"`
+	State          string  `json:"state"`
+	Latitude       float32 `json:"latitude"`
+	Longitude      float32 `json:"longitude"`
+	// GmapsPlace is nil if the restaurant isn't linked to Google Maps.
+	GmapsPlace *ExportGmapsPlace `json:"gmaps_place"`
+	Visits     []ExportVisit     `json:"visits"`
+}
+
+// ExportGmapsPlace is the Google Maps place of a restaurant.
+type ExportGmapsPlace struct {
+	PlaceID              string  `json:"place_id"`
+	LastUpdated          string  `json:"last_updated"`
+	BusinessStatus       string  `json:"business_status"`
+	FormattedPhoneNumber string  `json:"formatted_phone_number"`
+	Name                 string  `json:"name"`
+	PriceLevel           int     `json:"price_level"`
+	Rating               float32 `json:"rating"`
+	URL                  string  `json:"url"`
+	UserRatingsTotal     int     `json:"user_ratings_total"`
+	UTCOffset            int     `json:"utc_offset"`
+	Website              string  `json:"website"`
+}
+
+// ExportVisit is a visit and the users who went.
+type ExportVisit struct {
+	ID            int64          `json:"id"`
+	VisitDateTime string         `json:"visit_datetime"`
+	Note          string         `json:"note"`
+	Ratings       []ExportRating `json:"ratings"`
+}
+
+// ExportRating is a user at a visit. Rating is 0 if they went but didn't rate it.
+type ExportRating struct {
+	UserID int64 `json:"user_id"`
+	Rating int64 `json:"rating"`
+}
+
+// Export returns everything that isn't in the trash. Restaurants are in the order they were added and their visits
+// are oldest first.
+func (s service) Export() (Export, error) {
+	e := Export{Version: ExportVersion, ExportedAt: time.Now().UTC().Format(time.RFC3339)}
+	var err error
+	if e.Users, err = s.r.GetUsers(); err != nil {
+		return e, err
+	}
+	restaurants, err := s.r.GetRestaurants(nil, nil, PageOperation{})
+	if err != nil {
+		return e, err
+	}
+	visits, err := s.r.GetVisits(nil, nil, PageOperation{})
+	if err != nil {
+		return e, err
+	}
+	sort.Slice(restaurants, func(i, j int) bool { return restaurants[i].ID < restaurants[j].ID })
+	sort.Slice(visits, func(i, j int) bool {
+		if visits[i].VisitDateTime != visits[j].VisitDateTime {
+			return visits[i].VisitDateTime < visits[j].VisitDateTime
+		}
+		return visits[i].ID < visits[j].ID
+	})
+
+	visitsByRestaurant := make(map[int64][]ExportVisit)
+	for _, v := range visits {
+		visitUsers, err := s.r.GetVisitUsersByVisitID(v.ID)
+		if err != nil {
+			return e, err
+		}
+		ratings := []ExportRating{}
+		for _, vu := range visitUsers {
+			ratings = append(ratings, ExportRating{UserID: vu.User.ID, Rating: vu.Rating})
+		}
+		sort.Slice(ratings, func(i, j int) bool { return ratings[i].UserID < ratings[j].UserID })
+		visitsByRestaurant[v.RestaurantID] = append(visitsByRestaurant[v.RestaurantID], ExportVisit{
+			ID:            v.ID,
+			VisitDateTime: v.VisitDateTime,
+			Note:          v.Note,
+			Ratings:       ratings,
+		})
+	}
+
+	e.Restaurants = []ExportRestaurant{}
+	for _, r := range restaurants {
+		er := ExportRestaurant{
+			ID:             r.ID,
+			Name:           r.Name,
+			Cuisine:        r.Cuisine,
+			BusinessStatus: r.BusinessStatus,
+			Note:           r.Note,
+			Address:        r.Address,
+			Zipcode:        r.Zipcode,
+			City:           r.CityState.Name,
+			State:          r.CityState.State,
+			Latitude:       r.Latitude,
+			Longitude:      r.Longitude,
+			Visits:         visitsByRestaurant[r.ID],
+		}
+		if er.Visits == nil {
+			er.Visits = []ExportVisit{}
+		}
+		if r.GmapsPlace.PlaceID != "" {
+			er.GmapsPlace = &ExportGmapsPlace{
+				PlaceID:              r.GmapsPlace.PlaceID,
+				LastUpdated:          r.GmapsPlace.LastUpdated,
+				BusinessStatus:       r.GmapsPlace.BusinessStatus,
+				FormattedPhoneNumber: r.GmapsPlace.FormattedPhoneNumber,
+				Name:                 r.GmapsPlace.Name,
+				PriceLevel:           r.GmapsPlace.PriceLevel,
+				Rating:               r.GmapsPlace.Rating,
+				URL:                  r.GmapsPlace.URL,
+				UserRatingsTotal:     r.GmapsPlace.UserRatingsTotal,
+				UTCOffset:            r.GmapsPlace.UTCOffset,
+				Website:              r.GmapsPlace.Website,
+			}
+		}
+		e.Restaurants = append(e.Restaurants, er)
+	}
+	if e.Users == nil {
+		e.Users = []User{}
+	}
+	return e, nil
+}
+
+// WriteJSON writes the export as one JSON document, which is what an import reads.
+func (e Export) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(e)
+}
+
+// WriteCSVZip writes the export as a zip of a CSV per table: users.csv, restaurants.csv with their city and Google
+// Maps place, visits.csv and visit_ratings.csv. The rows refer to each other by id.
+func (e Export) WriteCSVZip(w io.Writer) error {
+	users := [][]string{{"id", "first_name", "last_name", "email", "is_admin"}}
+	for _, u := range e.Users {
+		users = append(users, []string{itoa(u.ID), u.FirstName, u.LastName, u.Email, strconv.FormatBool(u.IsAdmin)})
+	}
+	restaurants := [][]string{{"id", "name", "cuisine", "business_status", "note", "address", "zipcode", "city",
+		"state", "latitude", "longitude", "gmaps_place_id", "gmaps_last_updated", "gmaps_business_status",
+		"gmaps_formatted_phone_number", "gmaps_name", "gmaps_price_level", "gmaps_rating", "gmaps_url",
+		"gmaps_user_ratings_total", "gmaps_utc_offset", "gmaps_website"}}
+	visits := [][]string{{"id", "restaurant_id", "visit_datetime", "note"}}
+	ratings := [][]string{{"visit_id", "user_id", "rating"}}
+	for _, r := range e.Restaurants {
+		row := []string{itoa(r.ID), r.Name, r.Cuisine, strconv.Itoa(r.BusinessStatus), r.Note, r.Address, r.Zipcode,
+			r.City, r.State, ftoa(r.Latitude), ftoa(r.Longitude)}
+		if g := r.GmapsPlace; g != nil {
+			row = append(row, g.PlaceID, g.LastUpdated, g.BusinessStatus, g.FormattedPhoneNumber, g.Name,
+				strconv.Itoa(g.PriceLevel), ftoa(g.Rating), g.URL, strconv.Itoa(g.UserRatingsTotal),
+				strconv.Itoa(g.UTCOffset), g.Website)
+		} else {
+			row = append(row, make([]string, 11)...)
+		}
+		restaurants = append(restaurants, row)
+		for _, v := range r.Visits {
+			visits = append(visits, []string{itoa(v.ID), itoa(r.ID), v.VisitDateTime, v.Note})
+			for _, vr := range v.Ratings {
+				rating := ""
+				if vr.Rating != 0 {
+					rating = itoa(vr.Rating)
+				}
+				ratings = append(ratings, []string{itoa(v.ID), itoa(vr.UserID), rating})
+			}
+		}
+	}
+
+	zw := zip.NewWriter(w)
+	for _, f := range []struct {
+		name string
+		rows [][]string
+	}{
+		{"users.csv", users},
+		{"restaurants.csv", restaurants},
+		{"visits.csv", visits},
+		{"visit_ratings.csv", ratings},
+	} {
+		fw, err := zw.Create(f.name)
+		if err != nil {
+			return err
+		}
+		cw := csv.NewWriter(fw)
+		if err := cw.WriteAll(f.rows); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func itoa(i int64) string {
+	return strconv.FormatInt(i, 10)
+}
+
+func ftoa(f float32) string {
+	return strconv.FormatFloat(float64(f), 'f', -1, 32)
+}
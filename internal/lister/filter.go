@@ -3,7 +3,10 @@ package lister
 import (
 	"fmt"
 	"net/url"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type FilterOption struct {
@@ -17,22 +20,71 @@ type FilterOptions struct {
 	State   []FilterOption
 }
 
+// The types of a Field. The type decides which filter operators can be used on a field and what values it can be
+// compared to. Repositories cast filter values to the matching SQL type.
+const (
+	FieldText = "TEXT"
+	FieldReal = "REAL"
+	FieldInt  = "INT"
+	// FieldDate is TEXT with an RFC3339 date time in it that is filtered by the day, e.g. 2021-06-30.
+	FieldDate = "DATE"
+	// FieldIDSet is a set of ids, e.g. the users at a visit. It equals an id when the id is in the set. Repositories
+	// compile its name to a query of the ids in the set.
+	FieldIDSet = "IDSET"
+	// FieldGeo is a place with a latitude and longitude. It can only be filtered with near, whose values are the
+	// latitude, longitude and radius in km of a circle, e.g. filter[location|near]=37.77,-122.42,2.
+	FieldGeo = "GEO"
+)
+
+// FilterOperation compares a field to a value. The in, between and near operators use Values instead of Value, between
+// has the lowest value first.
 type FilterOperation struct {
 	Field     string
 	FieldType string
 	Operator  string
 	Value     string
+	Values    []string
 }
 
 func getAllowedOperators() map[string]string {
 	return map[string]string{
-		"eq":   "=",
-		"lt":   "<",
-		"gt":   ">",
-		"lteq": "<=",
-		"gteq": ">=",
-		"is":   "is",
-		"isnt": "is not",
+		"eq":       "=",
+		"neq":      "!=",
+		"lt":       "<",
+		"gt":       ">",
+		"lteq":     "<=",
+		"gteq":     ">=",
+		"is":       "is",
+		"isnt":     "is not",
+		"contains": "contains",
+		"starts":   "starts with",
+		"in":       "in",
+		"between":  "between",
+		"near":     "near",
+	}
+}
+
+// getOperatorsByType returns the filter operators that can be used on each type of field.
+func getOperatorsByType() map[string]map[string]bool {
+	compare := []string{"eq", "neq", "lt", "gt", "lteq", "gteq", "is", "isnt"}
+	allowed := func(extra ...string) map[string]bool {
+		result := make(map[string]bool)
+		for _, o := range append(compare, extra...) {
+			result[o] = true
+		}
+		return result
+	}
+	dateOperators := allowed("between")
+	// != of a day would have to match every time that isn't in it which the date operators can't express.
+	delete(dateOperators, "neq")
+	return map[string]map[string]bool{
+		FieldText: allowed("contains", "starts", "in"),
+		FieldReal: allowed("in", "between"),
+		FieldInt:  allowed("in", "between"),
+		FieldDate: dateOperators,
+		// in matches a set that has any of the ids in it.
+		FieldIDSet: map[string]bool{"eq": true, "neq": true, "in": true},
+		FieldGeo:   map[string]bool{"near": true},
 	}
 }
 
@@ -40,12 +92,40 @@ func (s service) getAllowedFilterFields(object string) (map[string]Field, error)
 	switch object {
 	case "restaurant":
 		return s.r.RestaurantFilterFields(), nil
+	case "visit":
+		return s.r.VisitFilterFields(), nil
 	default:
 		return make(map[string]Field), fmt.Errorf("Unknown filter object %s", object)
 	}
 
 }
 
+// FilterField is a field that can be filtered on and the operators that can be used on it.
+type FilterField struct {
+	Name      string   `json:"name"`
+	Type      string   `json:"type"`
+	Operators []string `json:"operators"`
+}
+
+// GetFilterFields returns the fields a restaurant or visit can be filtered on, ordered by name.
+func (s service) GetFilterFields(object string) ([]FilterField, error) {
+	allowedFields, err := s.getAllowedFilterFields(object)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]FilterField, 0, len(allowedFields))
+	for name, f := range allowedFields {
+		var operators []string
+		for o := range getOperatorsByType()[f.Type] {
+			operators = append(operators, o)
+		}
+		sort.Strings(operators)
+		result = append(result, FilterField{Name: name, Type: f.Type, Operators: operators})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, nil
+}
+
 func (s service) checkFilter(object string, filterRequested url.Values) ([]FilterOperation, error) {
 
 	var result []FilterOperation
@@ -59,11 +139,10 @@ func (s service) checkFilter(object string, filterRequested url.Values) ([]Filte
 	if err != nil {
 		return result, err
 	}
-	allowedOperators := getAllowedOperators()
 
-	for k, v := range filterRequested {
+	for _, k := range sortedKeys(filterRequested) {
 
-		filterOp, err := parseFilterArg(k, v)
+		filterOp, err := parseFilterArg(k, filterRequested[k])
 		if err != nil {
 			return result, err
 		} else if filterOp.Field == "" && filterOp.Operator == "" {
@@ -71,25 +150,159 @@ func (s service) checkFilter(object string, filterRequested url.Values) ([]Filte
 			continue
 		}
 
-		// Check and sanitize the filter params
-		if f, check := allowedFields[filterOp.Field]; check {
-			filterOp.Field = f.Name
-			if o, check := allowedOperators[filterOp.Operator]; check {
-				filterOp.FieldType = f.Type
-				filterOp.Operator = o
-				result = append(result, filterOp)
-			} else {
-				err := fmt.Errorf("Bad filter operator: %s", filterOp.Operator)
-				return result, err
-			}
-		} else {
-			err := fmt.Errorf("%s is not a valid filter field", filterOp.Field)
+		filterOp, err = checkFilterOp(filterOp, allowedFields)
+		if err != nil {
 			return result, err
 		}
+		result = append(result, filterOp)
 	}
 	return result, nil
 }
 
+// checkFilterOp checks and sanitizes a filter operation from the user. The field becomes the repository field and the
+// operator becomes the SQL operator.
+func checkFilterOp(filterOp FilterOperation, allowedFields map[string]Field) (FilterOperation, error) {
+	f, check := allowedFields[filterOp.Field]
+	if !check {
+		return filterOp, fmt.Errorf("%s is not a valid filter field", filterOp.Field)
+	}
+	o, check := getAllowedOperators()[filterOp.Operator]
+	if !check {
+		return filterOp, fmt.Errorf("Bad filter operator: %s", filterOp.Operator)
+	}
+	if !getOperatorsByType()[f.Type][filterOp.Operator] {
+		return filterOp, fmt.Errorf("%s can't be used to filter %s", filterOp.Operator, filterOp.Field)
+	}
+	if err := checkFilterValues(filterOp, f.Type); err != nil {
+		return filterOp, err
+	}
+	if f.Type == FieldDate {
+		filterOp = dateFilterOp(filterOp)
+		o = getAllowedOperators()[filterOp.Operator]
+	}
+	filterOp.Field = f.Name
+	filterOp.FieldType = f.Type
+	filterOp.Operator = o
+	return filterOp, nil
+}
+
+// checkFilterValues checks that the values of a filter operation can be compared to a field of the given type.
+func checkFilterValues(filterOp FilterOperation, fieldType string) error {
+	values := []string{filterOp.Value}
+	switch filterOp.Operator {
+	case "is", "isnt":
+		// NULL or a value, like the = and != operators.
+		if filterOp.Value == "NULL" {
+			return nil
+		}
+		if fieldType == FieldDate && filterOp.Operator == "isnt" {
+			return fmt.Errorf("isnt can only compare %s to NULL", filterOp.Field)
+		}
+	case "in":
+		values = filterOp.Values
+		if len(values) == 0 {
+			return fmt.Errorf("in needs at least one value to filter %s", filterOp.Field)
+		}
+		if len(values) > maxFilterValues {
+			return fmt.Errorf("in can have up to %d values to filter %s", maxFilterValues, filterOp.Field)
+		}
+	case "between":
+		values = filterOp.Values
+		if len(values) != 2 {
+			return fmt.Errorf("between needs two values to filter %s", filterOp.Field)
+		}
+	case "near":
+		_, err := NearFilter(filterOp)
+		return err
+	case "contains", "starts":
+		if filterOp.Value == "" {
+			return fmt.Errorf("%s needs a value to filter %s", filterOp.Operator, filterOp.Field)
+		}
+	}
+
+	for _, v := range values {
+		var err error
+		switch fieldType {
+		case FieldReal:
+			_, err = strconv.ParseFloat(v, 64)
+		case FieldInt, FieldIDSet:
+			_, err = strconv.ParseInt(v, 10, 64)
+		case FieldDate:
+			_, err = time.Parse(filterDateFormat, v)
+		}
+		if err != nil {
+			return fmt.Errorf("%s is not a valid value to filter %s", v, filterOp.Field)
+		}
+	}
+	return nil
+}
+
+// filterDateFormat is how the values for a FieldDate are written.
+const filterDateFormat = "2006-01-02"
+
+// maxFilterValues is the most values an in filter can have.
+const maxFilterValues = 50
+
+// dateFilterOp turns a filter on the day of a FieldDate into one on its RFC3339 text. A day starts with its date, e.g.
+// 2021-06-30, which sorts before every time in it, and ends with its last second, 2021-06-30T23:59:59Z.
+func dateFilterOp(filterOp FilterOperation) FilterOperation {
+	dayEnd := func(day string) string {
+		return day + "T23:59:59Z"
+	}
+	switch filterOp.Operator {
+	case "eq", "is":
+		if filterOp.Value == "NULL" {
+			break
+		}
+		filterOp.Operator = "between"
+		filterOp.Values = []string{filterOp.Value, dayEnd(filterOp.Value)}
+		filterOp.Value = ""
+	case "gt":
+		filterOp.Value = dayEnd(filterOp.Value)
+	case "lteq":
+		filterOp.Value = dayEnd(filterOp.Value)
+	case "between":
+		filterOp.Values = []string{filterOp.Values[0], dayEnd(filterOp.Values[1])}
+	}
+	return filterOp
+}
+
+// getFilter returns everything the filter[] params and the filter expression ask for ANDed together, or nil if there
+// are no filters.
+func (s service) getFilter(object string, qp url.Values) (FilterExpr, error) {
+	fops, err := s.checkFilter(object, qp)
+	if err != nil {
+		return nil, err
+	}
+	var result FilterAnd
+	for _, fo := range fops {
+		result = append(result, fo)
+	}
+
+	expr, err := parseFilterExpr(qp.Get(filterExprParam))
+	if err != nil {
+		return nil, err
+	}
+	if expr != nil {
+		allowedFields, err := s.getAllowedFilterFields(object)
+		if err != nil {
+			return nil, err
+		}
+		expr, err = checkFilterExpr(expr, allowedFields)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, expr)
+	}
+
+	if len(result) == 0 {
+		return nil, nil
+	}
+	return result, nil
+}
+
+// GetFilterParam returns the filter on the given field from the filter[] params, or else the first comparison on it in
+// the filter expression. The operator is the name used in the filter[] params.
 func (s service) GetFilterParam(object string, filterRequested url.Values) FilterOperation {
 	var result FilterOperation
 
@@ -103,6 +316,23 @@ func (s service) GetFilterParam(object string, filterRequested url.Values) Filte
 			return result
 		}
 	}
+
+	expr, _ := parseFilterExpr(filterRequested.Get(filterExprParam)) // ignore bad filter expressions
+	if f, ok := findFilterOp(expr, object); ok {
+		result = f
+	}
+	return result
+}
+
+// GetFilterParams returns every filter[] param on the given field in the order of their keys.
+func (s service) GetFilterParams(object string, filterRequested url.Values) []FilterOperation {
+	var result []FilterOperation
+	for _, k := range sortedKeys(filterRequested) {
+		f, _ := parseFilterArg(k, filterRequested[k]) // ignore bad filter arguments
+		if f.Field == object {
+			result = append(result, f)
+		}
+	}
 	return result
 }
 
@@ -110,7 +340,8 @@ func (s service) GetFilterParam(object string, filterRequested url.Values) Filte
 func parseFilterArg(keyArg string, valueArg []string) (FilterOperation, error) {
 	var result FilterOperation
 
-	if keyArg[:6] != "filter" {
+	// The filter param without brackets is a filter expression.
+	if !strings.HasPrefix(keyArg, "filter") || keyArg == filterExprParam {
 		// return an empty string array but no error
 		return result, nil
 	}
@@ -123,5 +354,11 @@ func parseFilterArg(keyArg string, valueArg []string) (FilterOperation, error) {
 	result.Field = fkey[0]
 	result.Operator = fkey[1]
 	result.Value = valueArg[0]
+	if result.Operator == "in" || result.Operator == "between" || result.Operator == "near" {
+		// The values are separated by commas, e.g. filter[cuisine|in]=Thai,Vietnamese
+		for _, v := range strings.Split(result.Value, ",") {
+			result.Values = append(result.Values, strings.TrimSpace(v))
+		}
+	}
 	return result, nil
 }
@@ -0,0 +1,250 @@
+package lister
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCheckFilterOp(t *testing.T) {
+	allowedFields := map[string]Field{
+		"name":       {Name: "res.name", Type: FieldText},
+		"avg_rating": {Name: "avg_rating", Type: FieldReal},
+		"attendees":  {Name: "attendees", Type: FieldInt},
+		"date":       {Name: "visit_datetime", Type: FieldDate},
+		"user":       {Name: "user_ids", Type: FieldIDSet},
+		"location":   {Name: "location", Type: FieldGeo},
+	}
+	tests := []struct {
+		name    string
+		op      FilterOperation
+		want    FilterOperation
+		wantErr string
+	}{
+		{
+			name: "text contains",
+			op:   FilterOperation{Field: "name", Operator: "contains", Value: "pho"},
+			want: FilterOperation{Field: "res.name", FieldType: FieldText, Operator: "contains", Value: "pho"},
+		},
+		{
+			name: "real in",
+			op:   FilterOperation{Field: "avg_rating", Operator: "in", Values: []string{"4", "4.5"}},
+			want: FilterOperation{Field: "avg_rating", FieldType: FieldReal, Operator: "in",
+				Values: []string{"4", "4.5"}},
+		},
+		{
+			name: "int between",
+			op:   FilterOperation{Field: "attendees", Operator: "between", Values: []string{"2", "4"}},
+			want: FilterOperation{Field: "attendees", FieldType: FieldInt, Operator: "between",
+				Values: []string{"2", "4"}},
+		},
+		{
+			name: "id set eq",
+			op:   FilterOperation{Field: "user", Operator: "eq", Value: "2"},
+			want: FilterOperation{Field: "user_ids", FieldType: FieldIDSet, Operator: "=", Value: "2"},
+		},
+		{
+			name: "date eq is the whole day",
+			op:   FilterOperation{Field: "date", Operator: "eq", Value: "2021-06-30"},
+			want: FilterOperation{Field: "visit_datetime", FieldType: FieldDate, Operator: "between",
+				Values: []string{"2021-06-30", "2021-06-30T23:59:59Z"}},
+		},
+		{
+			name: "is NULL",
+			op:   FilterOperation{Field: "date", Operator: "is", Value: "NULL"},
+			want: FilterOperation{Field: "visit_datetime", FieldType: FieldDate, Operator: "is", Value: "NULL"},
+		},
+		{
+			name: "near",
+			op:   FilterOperation{Field: "location", Operator: "near", Values: []string{"37.77", "-122.42", "2"}},
+			want: FilterOperation{Field: "location", FieldType: FieldGeo, Operator: "near",
+				Values: []string{"37.77", "-122.42", "2"}},
+		},
+		{
+			name:    "unknown field",
+			op:      FilterOperation{Field: "bogus", Operator: "eq", Value: "1"},
+			wantErr: "bogus is not a valid filter field",
+		},
+		{
+			name:    "unknown operator",
+			op:      FilterOperation{Field: "name", Operator: "like", Value: "1"},
+			wantErr: "Bad filter operator: like",
+		},
+		{
+			name:    "operator not allowed on the type",
+			op:      FilterOperation{Field: "avg_rating", Operator: "contains", Value: "4"},
+			wantErr: "contains can't be used to filter avg_rating",
+		},
+		{
+			name:    "neq on a date",
+			op:      FilterOperation{Field: "date", Operator: "neq", Value: "2021-06-30"},
+			wantErr: "neq can't be used to filter date",
+		},
+		{
+			name:    "eq on a place",
+			op:      FilterOperation{Field: "location", Operator: "eq", Value: "1"},
+			wantErr: "eq can't be used to filter location",
+		},
+		{
+			name:    "bad value",
+			op:      FilterOperation{Field: "attendees", Operator: "gt", Value: "two"},
+			wantErr: "two is not a valid value to filter attendees",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := checkFilterOp(tc.op, allowedFields)
+			if tc.wantErr != "" {
+				if err == nil || err.Error() != tc.wantErr {
+					t.Fatalf("got error %v, want %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %#v, want %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCheckFilterValues(t *testing.T) {
+	tooMany := make([]string, maxFilterValues+1)
+	for i := range tooMany {
+		tooMany[i] = "1"
+	}
+	tests := []struct {
+		name      string
+		op        FilterOperation
+		fieldType string
+		wantErr   string
+	}{
+		{"text eq", FilterOperation{Field: "f", Operator: "eq", Value: "anything"}, FieldText, ""},
+		{"real", FilterOperation{Field: "f", Operator: "gt", Value: "4.5"}, FieldReal, ""},
+		{"bad real", FilterOperation{Field: "f", Operator: "gt", Value: "4,5"}, FieldReal,
+			"4,5 is not a valid value to filter f"},
+		{"int", FilterOperation{Field: "f", Operator: "lt", Value: "3"}, FieldInt, ""},
+		{"real for an int", FilterOperation{Field: "f", Operator: "lt", Value: "3.5"}, FieldInt,
+			"3.5 is not a valid value to filter f"},
+		{"id", FilterOperation{Field: "f", Operator: "eq", Value: "x"}, FieldIDSet,
+			"x is not a valid value to filter f"},
+		{"date", FilterOperation{Field: "f", Operator: "gteq", Value: "2021-06-30"}, FieldDate, ""},
+		{"date with a time", FilterOperation{Field: "f", Operator: "gteq", Value: "2021-06-30T12:00:00Z"}, FieldDate,
+			"2021-06-30T12:00:00Z is not a valid value to filter f"},
+		{"is NULL", FilterOperation{Field: "f", Operator: "is", Value: "NULL"}, FieldInt, ""},
+		{"is a value", FilterOperation{Field: "f", Operator: "is", Value: "x"}, FieldInt,
+			"x is not a valid value to filter f"},
+		{"isnt a date", FilterOperation{Field: "f", Operator: "isnt", Value: "2021-06-30"}, FieldDate,
+			"isnt can only compare f to NULL"},
+		{"in", FilterOperation{Field: "f", Operator: "in", Values: []string{"1", "2"}}, FieldInt, ""},
+		{"in without values", FilterOperation{Field: "f", Operator: "in"}, FieldText,
+			"in needs at least one value to filter f"},
+		{"in with too many values", FilterOperation{Field: "f", Operator: "in", Values: tooMany}, FieldInt,
+			"in can have up to 50 values to filter f"},
+		{"in with a bad value", FilterOperation{Field: "f", Operator: "in", Values: []string{"1", "b"}}, FieldInt,
+			"b is not a valid value to filter f"},
+		{"between", FilterOperation{Field: "f", Operator: "between", Values: []string{"1", "2"}}, FieldReal, ""},
+		{"between one value", FilterOperation{Field: "f", Operator: "between", Values: []string{"1"}}, FieldReal,
+			"between needs two values to filter f"},
+		{"between a bad date", FilterOperation{Field: "f", Operator: "between",
+			Values: []string{"2021-06-01", "June"}}, FieldDate, "June is not a valid value to filter f"},
+		{"contains nothing", FilterOperation{Field: "f", Operator: "contains"}, FieldText,
+			"contains needs a value to filter f"},
+		{"starts with nothing", FilterOperation{Field: "f", Operator: "starts"}, FieldText,
+			"starts needs a value to filter f"},
+		{"near", FilterOperation{Field: "f", Operator: "near", Values: []string{"1", "2", "3"}}, FieldGeo, ""},
+		{"near without km", FilterOperation{Field: "f", Operator: "near", Values: []string{"1", "2"}}, FieldGeo,
+			"near needs a latitude, longitude and km to filter f"},
+		{"near a bad latitude", FilterOperation{Field: "f", Operator: "near", Values: []string{"91", "2", "3"}},
+			FieldGeo, "The latitude to filter f must be between -90 and 90"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkFilterValues(tc.op, tc.fieldType)
+			if tc.wantErr == "" && err != nil {
+				t.Fatalf("got error %v, want none", err)
+			}
+			if tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)) {
+				t.Fatalf("got error %v, want %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestDateFilterOp(t *testing.T) {
+	const day = "2021-06-30"
+	const dayEnd = "2021-06-30T23:59:59Z"
+	tests := []struct {
+		name string
+		op   FilterOperation
+		want FilterOperation
+	}{
+		{"eq is from the start to the end of the day", FilterOperation{Operator: "eq", Value: day},
+			FilterOperation{Operator: "between", Values: []string{day, dayEnd}}},
+		{"is a day is the same as eq", FilterOperation{Operator: "is", Value: day},
+			FilterOperation{Operator: "between", Values: []string{day, dayEnd}}},
+		{"is NULL is unchanged", FilterOperation{Operator: "is", Value: "NULL"},
+			FilterOperation{Operator: "is", Value: "NULL"}},
+		{"gt is after the end of the day", FilterOperation{Operator: "gt", Value: day},
+			FilterOperation{Operator: "gt", Value: dayEnd}},
+		{"gteq is from the start of the day", FilterOperation{Operator: "gteq", Value: day},
+			FilterOperation{Operator: "gteq", Value: day}},
+		{"lt is before the start of the day", FilterOperation{Operator: "lt", Value: day},
+			FilterOperation{Operator: "lt", Value: day}},
+		{"lteq is up to the end of the day", FilterOperation{Operator: "lteq", Value: day},
+			FilterOperation{Operator: "lteq", Value: dayEnd}},
+		{"between ends at the end of the last day",
+			FilterOperation{Operator: "between", Values: []string{"2021-06-01", day}},
+			FilterOperation{Operator: "between", Values: []string{"2021-06-01", dayEnd}}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := dateFilterOp(tc.op)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %#v, want %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDateFilterOpBounds(t *testing.T) {
+	// The RFC3339 times of the visits are compared as text, so a bound must sort on the right side of every time in
+	// its day.
+	times := []string{"2021-06-29T23:59:59Z", "2021-06-30T00:00:00Z", "2021-06-30T12:30:00Z", "2021-06-30T23:59:59Z",
+		"2021-07-01T00:00:00Z"}
+	inDay := map[string]bool{"2021-06-30T00:00:00Z": true, "2021-06-30T12:30:00Z": true, "2021-06-30T23:59:59Z": true}
+	compare := map[string]func(string, string) bool{
+		"lt":   func(a string, b string) bool { return a < b },
+		"gt":   func(a string, b string) bool { return a > b },
+		"lteq": func(a string, b string) bool { return a <= b },
+		"gteq": func(a string, b string) bool { return a >= b },
+	}
+	tests := []struct {
+		operator string
+		want     func(tm string) bool
+	}{
+		{"eq", func(tm string) bool { return inDay[tm] }},
+		{"lt", func(tm string) bool { return tm < "2021-06-30" }},
+		{"gt", func(tm string) bool { return tm >= "2021-07-01" }},
+		{"lteq", func(tm string) bool { return tm < "2021-07-01" }},
+		{"gteq", func(tm string) bool { return tm >= "2021-06-30" }},
+	}
+	for _, tc := range tests {
+		t.Run(tc.operator, func(t *testing.T) {
+			fo := dateFilterOp(FilterOperation{Operator: tc.operator, Value: "2021-06-30"})
+			for _, tm := range times {
+				var got bool
+				if fo.Operator == "between" {
+					got = tm >= fo.Values[0] && tm <= fo.Values[1]
+				} else {
+					got = compare[fo.Operator](tm, fo.Value)
+				}
+				if got != tc.want(tm) {
+					t.Errorf("%s 2021-06-30 matched %s: %t, want %t", tc.operator, tm, got, tc.want(tm))
+				}
+			}
+		})
+	}
+}
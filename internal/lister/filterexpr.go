@@ -0,0 +1,441 @@
+package lister
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FilterExpr is a node of a parsed filter expression. It is a FilterOperation, FilterAnd, FilterOr or FilterNot.
+type FilterExpr interface {
+	isFilterExpr()
+}
+
+// FilterAnd is true when every expression in it is true.
+type FilterAnd []FilterExpr
+
+// FilterOr is true when any expression in it is true.
+type FilterOr []FilterExpr
+
+// FilterNot is true when Expr is false.
+type FilterNot struct {
+	Expr FilterExpr
+}
+
+func (FilterOperation) isFilterExpr() {}
+func (FilterAnd) isFilterExpr()       {}
+func (FilterOr) isFilterExpr()        {}
+func (FilterNot) isFilterExpr()       {}
+
+// filterExprParam is the query param with a filter expression, e.g.
+// filter=(cuisine:Thai OR cuisine:Lao) AND avg_rating>=4
+const filterExprParam = "filter"
+
+// maxFilterExprLength is the longest filter expression that is parsed.
+const maxFilterExprLength = 1000
+
+// maxFilterExprComparisons is the most comparisons a filter expression can have.
+const maxFilterExprComparisons = 30
+
+// maxFilterExprDepth is the deepest parentheses and NOTs can be nested.
+const maxFilterExprDepth = 10
+
+// filterExprOperators maps the comparison operators of a filter expression to the operators of the filter[] params.
+var filterExprOperators = map[string]string{
+	":":  "eq",
+	"=":  "eq",
+	"!=": "neq",
+	"<":  "lt",
+	">":  "gt",
+	"<=": "lteq",
+	">=": "gteq",
+}
+
+type filterTokenKind int
+
+const (
+	filterTokenEnd filterTokenKind = iota
+	filterTokenWord
+	filterTokenString
+	filterTokenOperator
+	filterTokenOpen
+	filterTokenClose
+	filterTokenComma
+)
+
+type filterToken struct {
+	kind  filterTokenKind
+	text  string
+	start int
+}
+
+// isKeyword returns true if the token is the given keyword. Keywords aren't case sensitive and can't be quoted.
+func (t filterToken) isKeyword(keyword string) bool {
+	return t.kind == filterTokenWord && strings.EqualFold(t.text, keyword)
+}
+
+// tokenizeFilterExpr splits a filter expression into words, quoted strings, operators, parentheses and commas.
+func tokenizeFilterExpr(expr string) ([]filterToken, error) {
+	var tokens []filterToken
+	i := 0
+	for i < len(expr) {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, filterToken{filterTokenOpen, "(", i})
+			i++
+		case c == ')':
+			tokens = append(tokens, filterToken{filterTokenClose, ")", i})
+			i++
+		case c == ',':
+			tokens = append(tokens, filterToken{filterTokenComma, ",", i})
+			i++
+		case c == ':' || c == '=':
+			tokens = append(tokens, filterToken{filterTokenOperator, string(c), i})
+			i++
+		case c == '!' || c == '<' || c == '>':
+			op := string(c)
+			if i+1 < len(expr) && expr[i+1] == '=' {
+				op += "="
+			}
+			if op == "!" {
+				return tokens, fmt.Errorf("Bad filter expression at %d: ! must be followed by =", i+1)
+			}
+			tokens = append(tokens, filterToken{filterTokenOperator, op, i})
+			i += len(op)
+		case c == '"':
+			// A quoted string can have anything in it. \" is a quote and \\ is a backslash.
+			var b strings.Builder
+			start := i
+			i++
+			closed := false
+			for i < len(expr) {
+				if expr[i] == '\\' && i+1 < len(expr) {
+					b.WriteByte(expr[i+1])
+					i += 2
+					continue
+				}
+				if expr[i] == '"' {
+					closed = true
+					i++
+					break
+				}
+				b.WriteByte(expr[i])
+				i++
+			}
+			if !closed {
+				return tokens, fmt.Errorf("Bad filter expression at %d: the quote is never closed", start+1)
+			}
+			tokens = append(tokens, filterToken{filterTokenString, b.String(), start})
+		default:
+			start := i
+			for i < len(expr) && !strings.ContainsRune(" \t\n\r(),:=!<>\"", rune(expr[i])) {
+				i++
+			}
+			tokens = append(tokens, filterToken{filterTokenWord, expr[start:i], start})
+		}
+	}
+	return append(tokens, filterToken{filterTokenEnd, "", len(expr)}), nil
+}
+
+// filterParser is a recursive descent parser for filter expressions. AND binds tighter than OR, and two comparisons
+// next to each other are ANDed. The comparisons it returns are not checked yet, their Field and Operator are what the
+// user typed with the operator translated to the name used in the filter[] params.
+type filterParser struct {
+	tokens      []filterToken
+	pos         int
+	depth       int
+	comparisons int
+}
+
+// parseFilterExpr parses a filter expression without checking its fields.
+func parseFilterExpr(expr string) (FilterExpr, error) {
+	if len(expr) > maxFilterExprLength {
+		return nil, fmt.Errorf("The filter expression is too long, it can be up to %d characters", maxFilterExprLength)
+	}
+	tokens, err := tokenizeFilterExpr(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := filterParser{tokens: tokens}
+	if p.peek().kind == filterTokenEnd {
+		return nil, nil
+	}
+	result, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != filterTokenEnd {
+		return nil, p.errorf(t, "unexpected %s", t.text)
+	}
+	return result, nil
+}
+
+func (p *filterParser) peek() filterToken {
+	return p.tokens[p.pos]
+}
+
+func (p *filterParser) next() filterToken {
+	t := p.tokens[p.pos]
+	if t.kind != filterTokenEnd {
+		p.pos++
+	}
+	return t
+}
+
+func (p *filterParser) errorf(t filterToken, format string, args ...interface{}) error {
+	if t.kind == filterTokenEnd {
+		return fmt.Errorf("Bad filter expression at the end: "+format, args...)
+	}
+	return fmt.Errorf("Bad filter expression at %d: "+format, append([]interface{}{t.start + 1}, args...)...)
+}
+
+func (p *filterParser) parseOr() (FilterExpr, error) {
+	first, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	result := FilterOr{first}
+	for p.peek().isKeyword("OR") {
+		p.next()
+		e, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, e)
+	}
+	if len(result) == 1 {
+		return first, nil
+	}
+	return result, nil
+}
+
+func (p *filterParser) parseAnd() (FilterExpr, error) {
+	first, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	result := FilterAnd{first}
+	for {
+		t := p.peek()
+		if t.isKeyword("AND") {
+			p.next()
+		} else if t.kind == filterTokenEnd || t.kind == filterTokenClose || t.isKeyword("OR") {
+			break
+		}
+		e, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, e)
+	}
+	if len(result) == 1 {
+		return first, nil
+	}
+	return result, nil
+}
+
+func (p *filterParser) parseNot() (FilterExpr, error) {
+	t := p.peek()
+	if !t.isKeyword("NOT") && t.kind != filterTokenOpen {
+		return p.parseComparison()
+	}
+	p.depth++
+	if p.depth > maxFilterExprDepth {
+		return nil, p.errorf(t, "it can only be nested %d deep", maxFilterExprDepth)
+	}
+	defer func() { p.depth-- }()
+
+	p.next()
+	if t.kind == filterTokenOpen {
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if c := p.next(); c.kind != filterTokenClose {
+			return nil, p.errorf(c, "expected )")
+		}
+		return e, nil
+	}
+	e, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	return FilterNot{Expr: e}, nil
+}
+
+// parseComparison parses a field compared to a value with an operator, or with one of the CONTAINS, STARTS [WITH],
+// IN (a, b, ...), BETWEEN a AND b and NEAR (lat, lng, km) keywords.
+func (p *filterParser) parseComparison() (FilterExpr, error) {
+	var fo FilterOperation
+	field := p.next()
+	if field.kind != filterTokenWord || field.isKeyword("AND") || field.isKeyword("OR") {
+		return nil, p.errorf(field, "expected a field name")
+	}
+	p.comparisons++
+	if p.comparisons > maxFilterExprComparisons {
+		return nil, p.errorf(field, "it can only have %d comparisons", maxFilterExprComparisons)
+	}
+	fo.Field = field.text
+
+	op := p.next()
+	switch {
+	case op.kind == filterTokenOperator:
+		value, err := p.parseValue(field.text + op.text)
+		if err != nil {
+			return nil, err
+		}
+		fo.Operator = filterExprOperators[op.text]
+		fo.Value = value.text
+		// An unquoted NULL matches a missing value like the is and isnt filter[] operators.
+		if value.kind == filterTokenWord && strings.EqualFold(value.text, "NULL") {
+			fo.Value = "NULL"
+			switch fo.Operator {
+			case "eq":
+				fo.Operator = "is"
+			case "neq":
+				fo.Operator = "isnt"
+			default:
+				return nil, p.errorf(op, "NULL can only be compared with :, = or !=")
+			}
+		}
+	case op.isKeyword("CONTAINS"):
+		value, err := p.parseValue(field.text + " CONTAINS")
+		if err != nil {
+			return nil, err
+		}
+		fo.Operator = "contains"
+		fo.Value = value.text
+	case op.isKeyword("STARTS"):
+		if p.peek().isKeyword("WITH") {
+			p.next()
+		}
+		value, err := p.parseValue(field.text + " STARTS WITH")
+		if err != nil {
+			return nil, err
+		}
+		fo.Operator = "starts"
+		fo.Value = value.text
+	case op.isKeyword("IN"):
+		values, err := p.parseValueList(field.text + " IN")
+		if err != nil {
+			return nil, err
+		}
+		fo.Operator = "in"
+		fo.Values = values
+	case op.isKeyword("NEAR"):
+		values, err := p.parseValueList(field.text + " NEAR")
+		if err != nil {
+			return nil, err
+		}
+		fo.Operator = "near"
+		fo.Values = values
+	case op.isKeyword("BETWEEN"):
+		low, err := p.parseValue(field.text + " BETWEEN")
+		if err != nil {
+			return nil, err
+		}
+		if t := p.next(); !t.isKeyword("AND") {
+			return nil, p.errorf(t, "expected AND after %s BETWEEN %s", field.text, low.text)
+		}
+		high, err := p.parseValue(field.text + " BETWEEN " + low.text + " AND")
+		if err != nil {
+			return nil, err
+		}
+		fo.Operator = "between"
+		fo.Values = []string{low.text, high.text}
+	default:
+		return nil, p.errorf(op, "expected an operator after %s", field.text)
+	}
+	return fo, nil
+}
+
+// parseValueList parses values separated by commas in parentheses. after is what comes before it for the error
+// messages.
+func (p *filterParser) parseValueList(after string) ([]string, error) {
+	if t := p.next(); t.kind != filterTokenOpen {
+		return nil, p.errorf(t, "expected ( after %s", after)
+	}
+	var values []string
+	for {
+		value, err := p.parseValue(after)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value.text)
+		t := p.next()
+		if t.kind == filterTokenClose {
+			return values, nil
+		}
+		if t.kind != filterTokenComma {
+			return nil, p.errorf(t, "expected , or )")
+		}
+	}
+}
+
+// parseValue parses a word or a quoted string. after is what comes before it for the error message.
+func (p *filterParser) parseValue(after string) (filterToken, error) {
+	value := p.next()
+	if value.kind != filterTokenWord && value.kind != filterTokenString {
+		return value, p.errorf(value, "expected a value after %s", after)
+	}
+	return value, nil
+}
+
+// checkFilterExpr checks every comparison of a parsed filter expression like the filter[] params are checked.
+func checkFilterExpr(expr FilterExpr, allowedFields map[string]Field) (FilterExpr, error) {
+	switch e := expr.(type) {
+	case FilterOperation:
+		return checkFilterOp(e, allowedFields)
+	case FilterAnd:
+		result := make(FilterAnd, len(e))
+		for i, c := range e {
+			checked, err := checkFilterExpr(c, allowedFields)
+			if err != nil {
+				return nil, err
+			}
+			result[i] = checked
+		}
+		return result, nil
+	case FilterOr:
+		result := make(FilterOr, len(e))
+		for i, c := range e {
+			checked, err := checkFilterExpr(c, allowedFields)
+			if err != nil {
+				return nil, err
+			}
+			result[i] = checked
+		}
+		return result, nil
+	case FilterNot:
+		checked, err := checkFilterExpr(e.Expr, allowedFields)
+		if err != nil {
+			return nil, err
+		}
+		return FilterNot{Expr: checked}, nil
+	default:
+		return nil, fmt.Errorf("Unknown filter expression %T", expr)
+	}
+}
+
+// findFilterOp returns the first comparison on field in a filter expression.
+func findFilterOp(expr FilterExpr, field string) (FilterOperation, bool) {
+	var children []FilterExpr
+	switch e := expr.(type) {
+	case FilterOperation:
+		return e, e.Field == field
+	case FilterAnd:
+		children = e
+	case FilterOr:
+		children = e
+	case FilterNot:
+		children = []FilterExpr{e.Expr}
+	}
+	for _, c := range children {
+		if fo, ok := findFilterOp(c, field); ok {
+			return fo, true
+		}
+	}
+	return FilterOperation{}, false
+}
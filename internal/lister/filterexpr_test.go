@@ -0,0 +1,136 @@
+package lister
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseFilterExpr(t *testing.T) {
+	eq := func(field string, value string) FilterOperation {
+		return FilterOperation{Field: field, Operator: "eq", Value: value}
+	}
+	tests := []struct {
+		name string
+		expr string
+		want FilterExpr
+	}{
+		{"empty", "  ", nil},
+		{"one comparison", "cuisine:Thai", eq("cuisine", "Thai")},
+		{"operators", "a=1 b!=2 c<3 d>4 e<=5 f>=6", FilterAnd{
+			eq("a", "1"),
+			FilterOperation{Field: "b", Operator: "neq", Value: "2"},
+			FilterOperation{Field: "c", Operator: "lt", Value: "3"},
+			FilterOperation{Field: "d", Operator: "gt", Value: "4"},
+			FilterOperation{Field: "e", Operator: "lteq", Value: "5"},
+			FilterOperation{Field: "f", Operator: "gteq", Value: "6"},
+		}},
+		{"AND binds tighter than OR", "a:1 OR b:2 AND c:3", FilterOr{
+			eq("a", "1"),
+			FilterAnd{eq("b", "2"), eq("c", "3")},
+		}},
+		{"parentheses", "(a:1 OR b:2) AND c:3", FilterAnd{
+			FilterOr{eq("a", "1"), eq("b", "2")},
+			eq("c", "3"),
+		}},
+		{"keywords aren't case sensitive", "a:1 or not b:2", FilterOr{
+			eq("a", "1"),
+			FilterNot{Expr: eq("b", "2")},
+		}},
+		{"NOT of a group", "NOT (a:1 AND b:2)", FilterNot{Expr: FilterAnd{eq("a", "1"), eq("b", "2")}}},
+		{"quoted string", `name:"Joe's \"Pho\" AND more"`, eq("name", `Joe's "Pho" AND more`)},
+		{"NULL", "note:NULL city!=null", FilterAnd{
+			FilterOperation{Field: "note", Operator: "is", Value: "NULL"},
+			FilterOperation{Field: "city", Operator: "isnt", Value: "NULL"},
+		}},
+		{"quoted NULL is a value", `note:"NULL"`, eq("note", "NULL")},
+		{"CONTAINS", `name CONTAINS "pho"`, FilterOperation{Field: "name", Operator: "contains", Value: "pho"}},
+		{"STARTS WITH", "name STARTS WITH Ph", FilterOperation{Field: "name", Operator: "starts", Value: "Ph"}},
+		{"STARTS", "name STARTS Ph", FilterOperation{Field: "name", Operator: "starts", Value: "Ph"}},
+		{"IN", `cuisine IN (Thai, "Lao")`, FilterOperation{Field: "cuisine", Operator: "in",
+			Values: []string{"Thai", "Lao"}}},
+		{"BETWEEN", "avg_rating BETWEEN 3 AND 4.5 AND city:Seattle", FilterAnd{
+			FilterOperation{Field: "avg_rating", Operator: "between", Values: []string{"3", "4.5"}},
+			eq("city", "Seattle"),
+		}},
+		{"NEAR", "location NEAR (37.77, -122.42, 2)", FilterOperation{Field: "location", Operator: "near",
+			Values: []string{"37.77", "-122.42", "2"}}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseFilterExpr(tc.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %#v, want %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseFilterExprErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    string
+		wantErr string
+	}{
+		{"lone !", "a!1", "at 2: ! must be followed by ="},
+		{"unclosed quote", `name:"Pho`, `at 6: the quote is never closed`},
+		{"missing value", "a:", "at the end: expected a value after a:"},
+		{"missing operator", "a 1", "at 3: expected an operator after a"},
+		{"missing field", "AND a:1", "at 1: expected a field name"},
+		{"unclosed parenthesis", "(a:1", "at the end: expected )"},
+		{"extra parenthesis", "a:1)", "at 4: unexpected )"},
+		{"NULL with <", "a<NULL", "at 2: NULL can only be compared with :, = or !="},
+		{"BETWEEN without AND", "a BETWEEN 1 2", "at 13: expected AND after a BETWEEN 1"},
+		{"IN without parentheses", "a IN 1", "at 6: expected ( after a IN"},
+		{"IN without comma", "a IN (1 2)", "at 9: expected , or )"},
+		{"too long", "a:" + strings.Repeat("x", maxFilterExprLength), "too long"},
+		{"too deep", strings.Repeat("(", maxFilterExprDepth+1) + "a:1" + strings.Repeat(")", maxFilterExprDepth+1),
+			"nested 10 deep"},
+		{"too many comparisons", strings.Repeat("a:1 ", maxFilterExprComparisons+1), "30 comparisons"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := parseFilterExpr(tc.expr)
+			if err == nil {
+				t.Fatalf("got no error, want %q", tc.wantErr)
+			}
+			if !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("got %q, want it to contain %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckFilterExpr(t *testing.T) {
+	allowedFields := map[string]Field{
+		"cuisine":    {Name: "cuisine", Type: FieldText},
+		"avg_rating": {Name: "avg_rating", Type: FieldReal},
+	}
+	expr, err := parseFilterExpr("NOT cuisine:Thai OR avg_rating>=4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := checkFilterExpr(expr, allowedFields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := FilterOr{
+		FilterNot{Expr: FilterOperation{Field: "cuisine", FieldType: FieldText, Operator: "=", Value: "Thai"}},
+		FilterOperation{Field: "avg_rating", FieldType: FieldReal, Operator: ">=", Value: "4"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+
+	expr, err = parseFilterExpr("cuisine:Thai AND (bogus:1 OR avg_rating>=4)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := checkFilterExpr(expr, allowedFields); err == nil ||
+		err.Error() != "bogus is not a valid filter field" {
+		t.Errorf("got %v, want an error for the bogus field", err)
+	}
+}
@@ -0,0 +1,102 @@
+package lister
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// EarthRadiusKm is the mean radius of the earth that distances are worked out with.
+const EarthRadiusKm = 6371.0
+
+// maxNearKm is the largest radius a near filter can have, about half way around the earth.
+const maxNearKm = 20000
+
+// GeoPoint is a place on the earth in degrees.
+type GeoPoint struct {
+	Lat float64
+	Lng float64
+}
+
+// Near is the circle a near filter keeps the restaurants in.
+type Near struct {
+	Origin GeoPoint
+	Km     float64
+}
+
+// DistanceKm returns the great circle distance between two points with the haversine formula.
+func DistanceKm(a GeoPoint, b GeoPoint) float64 {
+	rad := math.Pi / 180
+	dLat := (b.Lat - a.Lat) * rad
+	dLng := (b.Lng - a.Lng) * rad
+	h := math.Pow(math.Sin(dLat/2), 2) + math.Cos(a.Lat*rad)*math.Cos(b.Lat*rad)*math.Pow(math.Sin(dLng/2), 2)
+	// Rounding can push h a little over 1 for points on opposite sides of the earth.
+	return 2 * EarthRadiusKm * math.Asin(math.Sqrt(math.Min(1, h)))
+}
+
+// NearFilter returns the circle of a checked near filter operation, whose values are the latitude, longitude and
+// radius in km.
+func NearFilter(fo FilterOperation) (Near, error) {
+	if len(fo.Values) != 3 {
+		return Near{}, fmt.Errorf("near needs a latitude, longitude and km to filter %s", fo.Field)
+	}
+	var v [3]float64
+	for i, s := range fo.Values {
+		f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return Near{}, fmt.Errorf("%s is not a valid value to filter %s", s, fo.Field)
+		}
+		v[i] = f
+	}
+	n := Near{Origin: GeoPoint{Lat: v[0], Lng: v[1]}, Km: v[2]}
+	if n.Origin.Lat < -90 || n.Origin.Lat > 90 {
+		return n, fmt.Errorf("The latitude to filter %s must be between -90 and 90", fo.Field)
+	}
+	if n.Origin.Lng < -180 || n.Origin.Lng > 180 {
+		return n, fmt.Errorf("The longitude to filter %s must be between -180 and 180", fo.Field)
+	}
+	if n.Km <= 0 || n.Km > maxNearKm {
+		return n, fmt.Errorf("The km to filter %s must be more than 0 and at most %d", fo.Field, maxNearKm)
+	}
+	return n, nil
+}
+
+// BoundingBox returns latitude and longitude ranges that hold the whole circle so a repository can use an index to
+// skip most of the rows before working out their distance. The longitude range is all of it when the circle goes over
+// a pole or the 180th meridian.
+func (n Near) BoundingBox() (minLat float64, maxLat float64, minLng float64, maxLng float64) {
+	dLat := n.Km / EarthRadiusKm * 180 / math.Pi
+	minLat, maxLat = n.Origin.Lat-dLat, n.Origin.Lat+dLat
+	if minLat <= -90 || maxLat >= 90 {
+		return math.Max(minLat, -90), math.Min(maxLat, 90), -180, 180
+	}
+	// The widest part of the circle in degrees of longitude is at the latitude furthest from the equator.
+	dLng := math.Asin(math.Min(1, math.Sin(n.Km/EarthRadiusKm)/math.Cos(n.Origin.Lat*math.Pi/180))) * 180 / math.Pi
+	minLng, maxLng = n.Origin.Lng-dLng, n.Origin.Lng+dLng
+	if minLng < -180 || maxLng > 180 {
+		return minLat, maxLat, -180, 180
+	}
+	return minLat, maxLat, minLng, maxLng
+}
+
+// FilterOrigin returns the origin of the first near filter that every row of a checked filter has to match, which is
+// what distances are measured from.
+func FilterOrigin(expr FilterExpr) (GeoPoint, bool) {
+	switch e := expr.(type) {
+	case FilterOperation:
+		if e.FieldType != FieldGeo {
+			return GeoPoint{}, false
+		}
+		n, err := NearFilter(e)
+		return n.Origin, err == nil
+	case FilterAnd:
+		for _, c := range e {
+			if origin, ok := FilterOrigin(c); ok {
+				return origin, true
+			}
+		}
+	}
+	// The rows of an OR or NOT don't all have to be near anything.
+	return GeoPoint{}, false
+}
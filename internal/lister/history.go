@@ -0,0 +1,90 @@
+package lister
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// AuditEntry is one change in the audit log.
+type AuditEntry struct {
+	ID     int64 `json:"id"`
+	UserID int64 `json:"user_id"`
+	// UserName is the name of the user who made the change. It is empty if no user did.
+	UserName     string          `json:"user_name"`
+	EntityType   string          `json:"entity_type"`
+	EntityID     int64           `json:"entity_id"`
+	RestaurantID int64           `json:"restaurant_id"`
+	Action       string          `json:"action"`
+	CreatedAt    string          `json:"created_at"`
+	Diff         json.RawMessage `json:"diff"`
+	// Changes is Diff sorted by field for showing in a page.
+	Changes []FieldChange `json:"-"`
+}
+
+// FieldChange is the value of a field before and after a change formatted for showing in a page.
+type FieldChange struct {
+	Field  string
+	Before string
+	After  string
+}
+
+// GetHistory returns the changes to an entity, newest first. The history of a restaurant includes the changes to its
+// visits.
+func (s service) GetHistory(entityType string, id int64) ([]AuditEntry, error) {
+	var entries []AuditEntry
+	var err error
+	if entityType == "restaurant" {
+		entries, err = s.r.GetRestaurantAuditLog(id)
+	} else {
+		entries, err = s.r.GetAuditLog(entityType, id)
+	}
+	if err != nil {
+		return entries, err
+	}
+	for i, e := range entries {
+		changes, err := parseDiff(e.Diff)
+		if err != nil {
+			return entries, err
+		}
+		entries[i].Changes = changes
+		createdAt, err := time.Parse(time.RFC3339, e.CreatedAt)
+		if err != nil {
+			return entries, err
+		}
+		entries[i].CreatedAt = createdAt.Format("2006-01-02 15:04 MST")
+	}
+	return entries, nil
+}
+
+// parseDiff turns an audit.Diff into FieldChanges sorted by field. Values that were missing or null are empty.
+func parseDiff(diff json.RawMessage) ([]FieldChange, error) {
+	var changes []FieldChange
+	var parsed map[string]struct {
+		Before interface{} `json:"before"`
+		After  interface{} `json:"after"`
+	}
+	if err := json.Unmarshal(diff, &parsed); err != nil {
+		return changes, err
+	}
+	for field, c := range parsed {
+		changes = append(changes, FieldChange{Field: field, Before: formatValue(c.Before), After: formatValue(c.After)})
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes, nil
+}
+
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64, bool:
+		return fmt.Sprint(v)
+	default:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+}
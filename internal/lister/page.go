@@ -0,0 +1,253 @@
+package lister
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// defaultPageSize is how many rows a page has when the limit query param isn't given.
+const defaultPageSize = 50
+
+// maxPageSize is the most rows a page can have.
+const maxPageSize = 200
+
+// PageOperation asks a repository for one page of rows.
+type PageOperation struct {
+	// Limit is the most rows to return.
+	Limit int
+	// After holds the values of the sort fields of the row just before the page, one per sort operation. Only rows
+	// that sort after it are returned. It is nil for the first page.
+	After []interface{}
+}
+
+// Page describes where a page is in the whole list.
+type Page struct {
+	// Total is the number of rows on all the pages together.
+	Total int64 `json:"total"`
+	Limit int   `json:"limit"`
+	// Next and Prev are the cursors for the after and before query params of the next and previous pages. They are
+	// empty when there is no such page.
+	Next string `json:"next"`
+	Prev string `json:"prev"`
+}
+
+// RestaurantPage is one page of restaurants.
+type RestaurantPage struct {
+	Restaurants []Restaurant `json:"restaurants"`
+	Page        Page         `json:"page"`
+}
+
+// VisitPage is one page of visits.
+type VisitPage struct {
+	Visits []Visit `json:"visits"`
+	Page   Page    `json:"page"`
+}
+
+// cursor is what is encoded in the after and before query params. Sort is the sort it was made for so a cursor isn't
+// used with a different sort, and Values are the values of the sort fields of the row it points at.
+type cursor struct {
+	Sort   string        `json:"s"`
+	Values []interface{} `json:"v"`
+}
+
+// pageRequest is a page that was asked for in the query params.
+type pageRequest struct {
+	limit  int
+	cursor *cursor
+	// backward is true when the rows before the cursor were asked for.
+	backward bool
+}
+
+// parsePageArgs parses the limit, after and before query params for the given sort operations.
+func parsePageArgs(qp url.Values, sortOps []SortOperation) (pageRequest, error) {
+	pr := pageRequest{limit: defaultPageSize}
+	if l := qp.Get("limit"); l != "" {
+		limit, err := strconv.Atoi(l)
+		if err != nil || limit < 1 {
+			return pr, fmt.Errorf("Bad page limit: %s", l)
+		}
+		if limit > maxPageSize {
+			limit = maxPageSize
+		}
+		pr.limit = limit
+	}
+
+	after, before := qp.Get("after"), qp.Get("before")
+	if after != "" && before != "" {
+		return pr, fmt.Errorf("Only one of after and before can be given")
+	}
+	token := after
+	if before != "" {
+		token = before
+		pr.backward = true
+	}
+	if token == "" {
+		return pr, nil
+	}
+	c, err := decodeCursor(token)
+	if err != nil {
+		return pr, err
+	}
+	if c.Sort != sortSignature(sortOps) || len(c.Values) != len(sortOps) {
+		return pr, fmt.Errorf("The page cursor is for a different sort, go back to the first page")
+	}
+	pr.cursor = &c
+	return pr, nil
+}
+
+// withTiebreaker returns the sort operations with the id field added last, unless it is already there, so every row
+// has a unique place in the order.
+func withTiebreaker(sortOps []SortOperation, idField string) []SortOperation {
+	for _, so := range sortOps {
+		if so.Field == idField {
+			return sortOps
+		}
+	}
+	result := make([]SortOperation, len(sortOps), len(sortOps)+1)
+	copy(result, sortOps)
+	return append(result, SortOperation{Name: "id", Field: idField, Direction: "asc"})
+}
+
+// reverseSort returns the sort operations with every direction flipped. Reading the list in the reverse order is how
+// the page before a cursor is found.
+func reverseSort(sortOps []SortOperation) []SortOperation {
+	result := make([]SortOperation, len(sortOps))
+	for i, so := range sortOps {
+		so.Direction = map[string]string{"asc": "desc", "desc": "asc"}[so.Direction]
+		result[i] = so
+	}
+	return result
+}
+
+// sortSignature identifies a sort by the names and directions of its fields.
+func sortSignature(sortOps []SortOperation) string {
+	parts := make([]string, len(sortOps))
+	for i, so := range sortOps {
+		parts[i] = so.Name + ":" + so.Direction
+	}
+	return strings.Join(parts, ",")
+}
+
+func encodeCursor(sortOps []SortOperation, values []interface{}) string {
+	b, _ := json.Marshal(cursor{Sort: sortSignature(sortOps), Values: values})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(token string) (cursor, error) {
+	var c cursor
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return c, fmt.Errorf("Bad page cursor")
+	}
+	if err := json.Unmarshal(b, &c); err != nil {
+		return c, fmt.Errorf("Bad page cursor")
+	}
+	return c, nil
+}
+
+// getPage runs a page request. n is the number of rows fetched, which must be up to limit + 1 rows in the order of the
+// sort operations used for the query. values returns the sort values of a fetched row and reverse reverses the fetched
+// rows. It returns the bounds of the rows that belong on the page and the page's cursors.
+func (pr pageRequest) getPage(sortOps []SortOperation, n int, values func(i int) []interface{},
+	reverse func()) (int, int, Page) {
+	p := Page{Limit: pr.limit}
+	more := n > pr.limit
+	lo, hi := 0, n
+	if pr.backward {
+		// The rows were fetched nearest the cursor first so the extra row is first after reversing.
+		reverse()
+		if more {
+			lo = n - pr.limit
+		}
+	} else if more {
+		hi = pr.limit
+	}
+	if lo == hi {
+		return lo, hi, p
+	}
+
+	hasPrev := pr.cursor != nil && !pr.backward || pr.backward && more
+	hasNext := pr.cursor != nil && pr.backward || !pr.backward && more
+	if hasPrev {
+		p.Prev = encodeCursor(sortOps, values(lo))
+	}
+	if hasNext {
+		p.Next = encodeCursor(sortOps, values(hi-1))
+	}
+	return lo, hi, p
+}
+
+// queryPage returns the page operation for the repository and the sort operations to query with.
+func (pr pageRequest) queryPage(sortOps []SortOperation) (PageOperation, []SortOperation) {
+	po := PageOperation{Limit: pr.limit + 1}
+	if pr.cursor != nil {
+		po.After = pr.cursor.Values
+	}
+	if pr.backward {
+		return po, reverseSort(sortOps)
+	}
+	return po, sortOps
+}
+
+// restaurantSortValues returns the values of the sort fields for a restaurant as it came from the repository.
+func restaurantSortValues(r Restaurant, sortOps []SortOperation) []interface{} {
+	values := make([]interface{}, len(sortOps))
+	for i, so := range sortOps {
+		switch so.Name {
+		case "name":
+			values[i] = r.Name
+		case "cuisine":
+			values[i] = r.Cuisine
+		case "city":
+			values[i] = r.CityState.Name
+		case "state":
+			values[i] = r.CityState.State
+		case "last_visit":
+			values[i] = r.LastVisitDatetime
+		case "avg_rating":
+			// Undo the float32 so the value is the same as the repository's round(avg(rating), 1).
+			values[i] = math.Round(float64(r.AvgRating)*10) / 10
+		case "distance":
+			values[i] = r.Distance
+		case "price_level":
+			values[i] = r.GmapsPlace.PriceLevel
+		case "gmaps_rating":
+			// Undo the float32 so the value is the same as the repository's rating rounded to 1 decimal place.
+			values[i] = math.Round(float64(r.GmapsPlace.Rating)*10) / 10
+		case "gmaps_ratings_total":
+			values[i] = r.GmapsPlace.UserRatingsTotal
+		case "gmaps_business_status":
+			values[i] = r.GmapsPlace.BusinessStatus
+		case "rating_diff":
+			values[i] = r.RatingDiff
+		case "id":
+			values[i] = r.ID
+		}
+	}
+	return values
+}
+
+// visitSortValues returns the values of the sort fields for a visit as it came from the repository.
+func visitSortValues(v Visit, sortOps []SortOperation) []interface{} {
+	values := make([]interface{}, len(sortOps))
+	for i, so := range sortOps {
+		switch so.Name {
+		case "date":
+			values[i] = v.VisitDateTime
+		case "rating":
+			values[i] = math.Round(float64(v.AvgRating)*10) / 10
+		case "attendees":
+			values[i] = v.Attendees
+		case "restaurant":
+			values[i] = v.RestaurantName
+		case "id":
+			values[i] = v.ID
+		}
+	}
+	return values
+}
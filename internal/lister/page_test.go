@@ -0,0 +1,224 @@
+package lister
+
+import (
+	"encoding/base64"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestParsePageArgs(t *testing.T) {
+	sortOps := []SortOperation{{Name: "name", Field: "res.name", Direction: "asc"},
+		{Name: "id", Field: "res.id", Direction: "asc"}}
+	otherSortOps := []SortOperation{{Name: "name", Field: "res.name", Direction: "desc"},
+		{Name: "id", Field: "res.id", Direction: "asc"}}
+	token := encodeCursor(sortOps, []interface{}{"Pho Bac", 5})
+	tests := []struct {
+		name    string
+		qp      url.Values
+		want    pageRequest
+		wantErr string
+	}{
+		{
+			name: "first page",
+			qp:   url.Values{},
+			want: pageRequest{limit: defaultPageSize},
+		},
+		{
+			name: "limit",
+			qp:   url.Values{"limit": {"10"}},
+			want: pageRequest{limit: 10},
+		},
+		{
+			name: "limit over the max",
+			qp:   url.Values{"limit": {"1000"}},
+			want: pageRequest{limit: maxPageSize},
+		},
+		{
+			name: "after",
+			qp:   url.Values{"limit": {"2"}, "after": {token}},
+			want: pageRequest{limit: 2, cursor: &cursor{Sort: "name:asc,id:asc",
+				Values: []interface{}{"Pho Bac", float64(5)}}},
+		},
+		{
+			name: "before",
+			qp:   url.Values{"before": {token}},
+			want: pageRequest{limit: defaultPageSize, cursor: &cursor{Sort: "name:asc,id:asc",
+				Values: []interface{}{"Pho Bac", float64(5)}}, backward: true},
+		},
+		{
+			name:    "zero limit",
+			qp:      url.Values{"limit": {"0"}},
+			wantErr: "Bad page limit: 0",
+		},
+		{
+			name:    "limit that isn't a number",
+			qp:      url.Values{"limit": {"ten"}},
+			wantErr: "Bad page limit: ten",
+		},
+		{
+			name:    "after and before",
+			qp:      url.Values{"after": {token}, "before": {token}},
+			wantErr: "Only one of after and before can be given",
+		},
+		{
+			name:    "bad base64",
+			qp:      url.Values{"after": {"not base64!"}},
+			wantErr: "Bad page cursor",
+		},
+		{
+			name:    "base64 that isn't a cursor",
+			qp:      url.Values{"after": {base64.RawURLEncoding.EncodeToString([]byte("[1, 2]"))}},
+			wantErr: "Bad page cursor",
+		},
+		{
+			name:    "cursor for a different sort",
+			qp:      url.Values{"after": {encodeCursor(otherSortOps, []interface{}{"Pho Bac", 5})}},
+			wantErr: "The page cursor is for a different sort, go back to the first page",
+		},
+		{
+			name:    "cursor with too few values",
+			qp:      url.Values{"before": {encodeCursor(sortOps, []interface{}{"Pho Bac"})}},
+			wantErr: "The page cursor is for a different sort, go back to the first page",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parsePageArgs(tc.qp, sortOps)
+			if tc.wantErr != "" {
+				if err == nil || err.Error() != tc.wantErr {
+					t.Fatalf("got error %v, want %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestQueryPage(t *testing.T) {
+	sortOps := []SortOperation{{Name: "name", Field: "res.name", Direction: "asc"},
+		{Name: "id", Field: "res.id", Direction: "desc"}}
+	c := &cursor{Values: []interface{}{"Pho Bac", 5}}
+
+	po, querySortOps := pageRequest{limit: 2}.queryPage(sortOps)
+	if !reflect.DeepEqual(po, PageOperation{Limit: 3}) || !reflect.DeepEqual(querySortOps, sortOps) {
+		t.Errorf("first page: got %+v %v", po, querySortOps)
+	}
+
+	po, querySortOps = pageRequest{limit: 2, cursor: c}.queryPage(sortOps)
+	if !reflect.DeepEqual(po, PageOperation{Limit: 3, After: c.Values}) || !reflect.DeepEqual(querySortOps, sortOps) {
+		t.Errorf("after: got %+v %v", po, querySortOps)
+	}
+
+	// The rows before a cursor are the rows after it in the reverse order.
+	po, querySortOps = pageRequest{limit: 2, cursor: c, backward: true}.queryPage(sortOps)
+	wantSortOps := []SortOperation{{Name: "name", Field: "res.name", Direction: "desc"},
+		{Name: "id", Field: "res.id", Direction: "asc"}}
+	if !reflect.DeepEqual(po, PageOperation{Limit: 3, After: c.Values}) ||
+		!reflect.DeepEqual(querySortOps, wantSortOps) {
+		t.Errorf("before: got %+v %v", po, querySortOps)
+	}
+}
+
+func TestGetPage(t *testing.T) {
+	sortOps := []SortOperation{{Name: "id", Field: "res.id", Direction: "asc"}}
+	at := func(id int) string {
+		return encodeCursor(sortOps, []interface{}{id})
+	}
+	c := &cursor{Sort: "id:asc", Values: []interface{}{float64(10)}}
+	tests := []struct {
+		name string
+		pr   pageRequest
+		// rows are the ids the repository returned, in the order it was queried in.
+		rows     []int
+		wantRows []int
+		wantPrev string
+		wantNext string
+	}{
+		{
+			name:     "first page with more after it",
+			pr:       pageRequest{limit: 2},
+			rows:     []int{1, 2, 3},
+			wantRows: []int{1, 2},
+			wantNext: at(2),
+		},
+		{
+			name:     "only page",
+			pr:       pageRequest{limit: 2},
+			rows:     []int{1, 2},
+			wantRows: []int{1, 2},
+		},
+		{
+			name:     "empty list",
+			pr:       pageRequest{limit: 2},
+			rows:     []int{},
+			wantRows: []int{},
+		},
+		{
+			name:     "after with more after it",
+			pr:       pageRequest{limit: 2, cursor: c},
+			rows:     []int{11, 12, 13},
+			wantRows: []int{11, 12},
+			wantPrev: at(11),
+			wantNext: at(12),
+		},
+		{
+			name:     "after the last page",
+			pr:       pageRequest{limit: 2, cursor: c},
+			rows:     []int{11},
+			wantRows: []int{11},
+			wantPrev: at(11),
+		},
+		{
+			name:     "after the last row",
+			pr:       pageRequest{limit: 2, cursor: c},
+			rows:     []int{},
+			wantRows: []int{},
+		},
+		{
+			name:     "before with more before it",
+			pr:       pageRequest{limit: 2, cursor: c, backward: true},
+			rows:     []int{9, 8, 7},
+			wantRows: []int{8, 9},
+			wantPrev: at(8),
+			wantNext: at(9),
+		},
+		{
+			name:     "before the first page",
+			pr:       pageRequest{limit: 2, cursor: c, backward: true},
+			rows:     []int{9, 8},
+			wantRows: []int{8, 9},
+			wantNext: at(9),
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rows := append([]int{}, tc.rows...)
+			lo, hi, p := tc.pr.getPage(sortOps, len(rows), func(i int) []interface{} {
+				return []interface{}{rows[i]}
+			}, func() {
+				for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+					rows[i], rows[j] = rows[j], rows[i]
+				}
+			})
+			if got := rows[lo:hi]; !reflect.DeepEqual(got, tc.wantRows) {
+				t.Errorf("got rows %v, want %v", got, tc.wantRows)
+			}
+			if p.Limit != tc.pr.limit {
+				t.Errorf("got limit %d, want %d", p.Limit, tc.pr.limit)
+			}
+			if p.Prev != tc.wantPrev {
+				t.Errorf("got prev %q, want %q", p.Prev, tc.wantPrev)
+			}
+			if p.Next != tc.wantNext {
+				t.Errorf("got next %q, want %q", p.Next, tc.wantNext)
+			}
+		})
+	}
+}
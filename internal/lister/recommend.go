@@ -0,0 +1,280 @@
+package lister
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The most points each part of a recommendation's score can get. A restaurant that is the best on every part scores
+// 100, or 85 when there is no near param.
+const (
+	ratingPoints    = 45
+	lastVisitPoints = 25
+	varietyPoints   = 15
+	distancePoints  = 15
+)
+
+// poorRating is the highest average rating an attendee can give a restaurant and still rate it poorly, which rules it
+// out.
+const poorRating = 2
+
+// unratedRating is the rating a restaurant nobody has rated is scored as, the middle of 1 to 5.
+const unratedRating = 3
+
+// staleDays is how long since the last visit it takes for a restaurant to get all the last visit points.
+const staleDays = 180
+
+// recentVisits is how many of the latest visits the variety of cuisines is measured against.
+const recentVisits = 10
+
+// defaultRecommendations and maxRecommendations are how many recommendations there are by default and at most.
+const (
+	defaultRecommendations = 10
+	maxRecommendations     = 50
+)
+
+// ScoreReason is why a recommendation got some of its points.
+type ScoreReason struct {
+	// Factor is rating, last_visit, variety or distance.
+	Factor string  `json:"factor"`
+	Points float64 `json:"points"`
+	Max    float64 `json:"max"`
+	Detail string  `json:"detail"`
+}
+
+// Recommendation is a restaurant to go to and the reasons for its score.
+type Recommendation struct {
+	RestaurantID int64     `json:"restaurant_id"`
+	Name         string    `json:"name"`
+	Cuisine      string    `json:"cuisine"`
+	CityState    CityState `json:"city_state"`
+	// LastVisit is the date of the last visit, or empty if nobody has been.
+	LastVisit string `json:"last_visit"`
+	// Distance is how many km the restaurant is from the near param, or 0 without one.
+	Distance float64       `json:"distance"`
+	Score    float64       `json:"score"`
+	Reasons  []ScoreReason `json:"reasons"`
+}
+
+// ExcludedRestaurant is an open restaurant that was left out of the recommendations because an attendee rated it
+// poorly.
+type ExcludedRestaurant struct {
+	RestaurantID int64  `json:"restaurant_id"`
+	Name         string `json:"name"`
+	Reason       string `json:"reason"`
+}
+
+// Recommendations are the restaurants the attendees should go to, the best first.
+type Recommendations struct {
+	Attendees       []User               `json:"attendees"`
+	Recommendations []Recommendation     `json:"recommendations"`
+	Excluded        []ExcludedRestaurant `json:"excluded"`
+}
+
+// Recommend ranks the restaurants that are still open for the users in the users query param, a comma separated list
+// of ids. Each restaurant is scored on the attendees' ratings of it, how long it has been since anyone went, how
+// different its cuisine is from the latest visits and, when the near query param is given as lat,lng,km, how close it
+// is. Restaurants further than km away are left out, and so are the ones an attendee rated 2 or less on average. The
+// limit query param is how many to return.
+func (s service) Recommend(qp url.Values) (Recommendations, error) {
+	recs := Recommendations{Attendees: []User{}, Recommendations: []Recommendation{}, Excluded: []ExcludedRestaurant{}}
+	limit := defaultRecommendations
+	if l := qp.Get("limit"); l != "" {
+		var err error
+		limit, err = strconv.Atoi(l)
+		if err != nil || limit < 1 || limit > maxRecommendations {
+			return recs, fmt.Errorf("limit must be a number from 1 to %d", maxRecommendations)
+		}
+	}
+
+	// ratings has each attendee's average rating by restaurant id.
+	var ratings []map[int64]float64
+	seen := make(map[int64]bool)
+	for _, id := range strings.Split(qp.Get("users"), ",") {
+		id = strings.TrimSpace(id)
+		if id == "" {
+			continue
+		}
+		userID, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			return recs, fmt.Errorf("%s is not a valid user ID, it must be a number", id)
+		}
+		if seen[userID] {
+			continue
+		}
+		seen[userID] = true
+		u, err := s.GetUserByID(userID)
+		if err != nil {
+			return recs, err
+		}
+		userRatings, err := s.r.GetUserRestaurantRatings(userID)
+		if err != nil {
+			return recs, err
+		}
+		byRestaurant := make(map[int64]float64)
+		for _, r := range userRatings {
+			byRestaurant[r.RestaurantID] = r.AvgRating
+		}
+		recs.Attendees = append(recs.Attendees, u)
+		ratings = append(ratings, byRestaurant)
+	}
+	if len(recs.Attendees) == 0 {
+		return recs, fmt.Errorf("Pick who is going with the users param, e.g. users=1,2")
+	}
+
+	openOp, err := checkFilterOp(FilterOperation{Field: "business_status", Operator: "eq", Value: "1"},
+		s.r.RestaurantFilterFields())
+	if err != nil {
+		return recs, err
+	}
+	var filter FilterExpr = openOp
+	var near Near
+	if n := qp.Get("near"); n != "" {
+		nearOp, err := checkFilterOp(FilterOperation{Field: "location", Operator: "near", Values: strings.Split(n, ",")},
+			s.r.RestaurantFilterFields())
+		if err != nil {
+			return recs, err
+		}
+		if near, err = NearFilter(nearOp); err != nil {
+			return recs, err
+		}
+		filter = FilterAnd{filter, nearOp}
+	}
+	sops := withTiebreaker(nil, s.r.RestaurantSortFields()["id"])
+	restaurants, err := s.r.GetRestaurants(sops, filter, PageOperation{})
+	if err != nil {
+		return recs, err
+	}
+
+	visits, err := s.r.GetStatsVisits()
+	if err != nil {
+		return recs, err
+	}
+	if len(visits) > recentVisits {
+		visits = visits[len(visits)-recentVisits:]
+	}
+	recentCuisines := make(map[string]int)
+	for _, v := range visits {
+		recentCuisines[v.Cuisine]++
+	}
+
+	now := time.Now().UTC()
+	for _, r := range restaurants {
+		if excluded, ok := poorlyRated(r, recs.Attendees, ratings); ok {
+			recs.Excluded = append(recs.Excluded, excluded)
+			continue
+		}
+		rec := Recommendation{RestaurantID: r.ID, Name: r.Name, Cuisine: r.Cuisine, CityState: r.CityState}
+		rec.Reasons = append(rec.Reasons, ratingReason(r, ratings))
+		visitReason, err := lastVisitReason(r, now)
+		if err != nil {
+			return recs, err
+		}
+		if r.LastVisitDatetime != "" {
+			rec.LastVisit = r.LastVisitDatetime[:len(statsDateFormat)]
+		}
+		rec.Reasons = append(rec.Reasons, visitReason, varietyReason(r.Cuisine, recentCuisines, len(visits)))
+		if near.Km != 0 {
+			rec.Distance = r.Distance
+			rec.Reasons = append(rec.Reasons, ScoreReason{
+				Factor: "distance",
+				Points: distancePoints * math.Max(0, 1-r.Distance/near.Km),
+				Max:    distancePoints,
+				Detail: fmt.Sprintf("%.1f km away", r.Distance),
+			})
+		}
+		for i, reason := range rec.Reasons {
+			rec.Reasons[i].Points = roundRating(reason.Points)
+			rec.Score += reason.Points
+		}
+		rec.Score = roundRating(rec.Score)
+		recs.Recommendations = append(recs.Recommendations, rec)
+	}
+
+	sort.SliceStable(recs.Recommendations, func(i, j int) bool {
+		return recs.Recommendations[i].Score > recs.Recommendations[j].Score
+	})
+	if len(recs.Recommendations) > limit {
+		recs.Recommendations = recs.Recommendations[:limit]
+	}
+	return recs, nil
+}
+
+// poorlyRated returns why a restaurant is left out if one of the attendees rated it poorly.
+func poorlyRated(r Restaurant, attendees []User, ratings []map[int64]float64) (ExcludedRestaurant, bool) {
+	for i, userRatings := range ratings {
+		if rating, ok := userRatings[r.ID]; ok && rating <= poorRating {
+			return ExcludedRestaurant{
+				RestaurantID: r.ID,
+				Name:         r.Name,
+				Reason:       fmt.Sprintf("%s rated it %.1f", attendees[i].FirstName, rating),
+			}, true
+		}
+	}
+	return ExcludedRestaurant{}, false
+}
+
+// ratingReason scores a restaurant on the average of the attendees' ratings of it. When none of them have rated it the
+// average of everyone's ratings is used instead, and a restaurant nobody has rated is scored as a 3.
+func ratingReason(r Restaurant, ratings []map[int64]float64) ScoreReason {
+	var sum float64
+	var n int
+	for _, userRatings := range ratings {
+		if rating, ok := userRatings[r.ID]; ok {
+			sum += rating
+			n++
+		}
+	}
+	rating := float64(unratedRating)
+	detail := "Nobody has rated it yet"
+	if n > 0 {
+		rating = sum / float64(n)
+		detail = fmt.Sprintf("%d of you rated it %.1f on average", n, rating)
+		if len(ratings) == 1 {
+			detail = fmt.Sprintf("You rated it %.1f on average", rating)
+		}
+	} else if r.AvgRating > 0 {
+		rating = float64(r.AvgRating)
+		detail = fmt.Sprintf("None of you have rated it, everyone else rated it %.1f", rating)
+	}
+	return ScoreReason{Factor: "rating", Points: ratingPoints * (rating - 1) / 4, Max: ratingPoints, Detail: detail}
+}
+
+// lastVisitReason scores a restaurant on how long it has been since the last visit. It gets all the points if nobody
+// has been there for 180 days or ever.
+func lastVisitReason(r Restaurant, now time.Time) (ScoreReason, error) {
+	reason := ScoreReason{Factor: "last_visit", Points: lastVisitPoints, Max: lastVisitPoints}
+	if r.LastVisitDatetime == "" {
+		reason.Detail = "Nobody has been yet"
+		return reason, nil
+	}
+	lastVisit, err := time.Parse(time.RFC3339, r.LastVisitDatetime)
+	if err != nil {
+		return reason, err
+	}
+	days := int64(now.Sub(lastVisit).Hours() / 24)
+	reason.Points = lastVisitPoints * math.Min(1, math.Max(0, float64(days))/staleDays)
+	reason.Detail = fmt.Sprintf("Last visit was %d days ago", days)
+	return reason, nil
+}
+
+// varietyReason scores a restaurant on how few of the latest visits were to the same cuisine.
+func varietyReason(cuisine string, recentCuisines map[string]int, visits int) ScoreReason {
+	reason := ScoreReason{Factor: "variety", Points: varietyPoints, Max: varietyPoints}
+	count := recentCuisines[cuisine]
+	if count == 0 {
+		reason.Detail = fmt.Sprintf("None of the last %d visits were %s", visits, cuisine)
+		return reason
+	}
+	reason.Points = varietyPoints * (1 - float64(count)/float64(visits))
+	reason.Detail = fmt.Sprintf("%d of the last %d visits were %s", count, visits, cuisine)
+	if count == 1 {
+		reason.Detail = fmt.Sprintf("1 of the last %d visits was %s", visits, cuisine)
+	}
+	return reason
+}
@@ -15,7 +15,12 @@ type Restaurant struct {
 	AvgRating         float32         `json:"avg_rating"`
 	AvgUserRatings    []AvgUserRating `json:"avg_user_ratings"`
 	LastVisitDatetime string          `json:"last_visit_datetime"`
-	SearchValue       string          `json:"search_value"`
+	// RatingDiff is AvgRating minus the Google Maps rating, or 0 if the restaurant doesn't have both.
+	RatingDiff float64 `json:"rating_diff"`
+	// Distance is how many km the restaurant is from the origin of the near filter it was listed with, or 0 without
+	// one.
+	Distance    float64 `json:"distance"`
+	SearchValue string  `json:"search_value"`
 }
 
 type CityState struct {
@@ -0,0 +1,98 @@
+package lister
+
+import (
+	"strings"
+	"unicode"
+)
+
+// SearchMatchStart and SearchMatchEnd surround the matching words in the snippet a repository returns. They are control
+// characters so they can't clash with anything a user typed.
+const (
+	SearchMatchStart = "\x02"
+	SearchMatchEnd   = "\x03"
+)
+
+// searchResultLimit is the most results a search returns.
+const searchResultLimit = 50
+
+// maxSearchTerms is the most words of a query that are searched for. The rest are ignored.
+const maxSearchTerms = 10
+
+// SearchMatch is a restaurant found by a repository search.
+type SearchMatch struct {
+	RestaurantID int64
+	// Snippet is the text around the best match with the matching words between SearchMatchStart and SearchMatchEnd.
+	Snippet string
+	// Rank is how relevant the match is, higher is more relevant.
+	Rank float64
+}
+
+// SearchResult is a restaurant that matched a search.
+type SearchResult struct {
+	Restaurant Restaurant
+	Snippet    []SnippetPart
+}
+
+// SnippetPart is part of a search result snippet. Match is true if Text matched the search.
+type SnippetPart struct {
+	Text  string
+	Match bool
+}
+
+// Search returns the restaurants whose name, cuisine, note, city or visit notes contain every word in query, most
+// relevant first. Words match as prefixes so "tac" finds "tacos".
+func (s service) Search(query string) ([]SearchResult, error) {
+	var results []SearchResult
+	terms := parseSearchTerms(query)
+	if len(terms) == 0 {
+		return results, nil
+	}
+
+	matches, err := s.r.SearchRestaurants(terms, searchResultLimit)
+	if err != nil {
+		return results, err
+	}
+	for _, m := range matches {
+		r, err := s.GetRestaurant(m.RestaurantID)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, SearchResult{Restaurant: r, Snippet: parseSnippet(m.Snippet)})
+	}
+	return results, nil
+}
+
+// parseSearchTerms splits query into lower case words made of letters and numbers. Everything else is dropped so the
+// terms are safe to use in any backend's full-text query syntax.
+func parseSearchTerms(query string) []string {
+	terms := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	if len(terms) > maxSearchTerms {
+		terms = terms[:maxSearchTerms]
+	}
+	return terms
+}
+
+// parseSnippet splits a snippet from the repository into the parts that did and didn't match.
+func parseSnippet(snippet string) []SnippetPart {
+	var parts []SnippetPart
+	for snippet != "" {
+		start := strings.Index(snippet, SearchMatchStart)
+		if start == -1 {
+			parts = append(parts, SnippetPart{Text: snippet})
+			break
+		}
+		if start > 0 {
+			parts = append(parts, SnippetPart{Text: snippet[:start]})
+		}
+		snippet = snippet[start+len(SearchMatchStart):]
+		end := strings.Index(snippet, SearchMatchEnd)
+		if end == -1 {
+			end = len(snippet)
+		}
+		parts = append(parts, SnippetPart{Text: snippet[:end], Match: true})
+		snippet = strings.TrimPrefix(snippet[end:], SearchMatchEnd)
+	}
+	return parts
+}
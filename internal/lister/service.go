@@ -3,7 +3,10 @@ package lister
 import (
 	"fmt"
 	"net/url"
+	"strconv"
 	"time"
+
+	"github.com/kelvinatorr/restaurant-tracker/internal/storage"
 )
 
 // ErrDoesNotExist is used when a resturant does not exist in the repository
@@ -11,7 +14,7 @@ type ErrDoesNotExist struct {
 	msg string
 }
 
-// Field is a repository field
+// Field is a repository field. Type is one of FieldText, FieldReal, FieldInt, FieldDate, FieldIDSet or FieldGeo.
 type Field struct {
 	Name string
 	Type string
@@ -24,34 +27,75 @@ func (m *ErrDoesNotExist) Error() string {
 // Service provides listing operations.
 type Service interface {
 	GetRestaurant(int64) (Restaurant, error)
-	GetRestaurants(url.Values) ([]Restaurant, error)
+	GetRestaurants(url.Values) (RestaurantPage, error)
 	GetVisit(int64, int64) (Visit, error)
-	GetVisitsByRestaurantID(int64, url.Values) ([]Visit, error)
-	GetUserCount() int64
-	GetUserByID(int64) User
-	GetFilterOptions(url.Values) FilterOptions
+	GetVisitsByRestaurantID(int64, url.Values) (VisitPage, error)
+	GetVisits(url.Values) (VisitPage, error)
+	GetUserCount() (int64, error)
+	GetUserByID(int64) (User, error)
+	GetFilterOptions(url.Values) (FilterOptions, error)
 	GetFilterParam(string, url.Values) FilterOperation
+	GetFilterParams(string, url.Values) []FilterOperation
 	GetSortParam(string, url.Values) SortOperation
-	GetUsers() []User
-	GetDistinct(string, string) []string
+	GetSortParams(url.Values) []SortOperation
+	GetFilterFields(object string) ([]FilterField, error)
+	GetSortFields(object string) ([]string, error)
+	GetUsers() ([]User, error)
+	GetDistinct(string, string) ([]string, error)
+	Search(string) ([]SearchResult, error)
+	GetTrash() (Trash, error)
+	GetHistory(entityType string, id int64) ([]AuditEntry, error)
+	GetSavedViews(userID int64) ([]SavedView, error)
+	GetSavedView(id int64, userID int64) (SavedView, error)
+	GetDefaultSavedView(userID int64) (SavedView, error)
+	CheckViewQuery(query string) error
+	GetAPITokens(userID int64) ([]APIToken, error)
+	GetStats(url.Values) (Stats, error)
+	CompareTastes(user1ID int64, user2ID int64) (TasteComparison, error)
+	Recommend(url.Values) (Recommendations, error)
+	Export() (Export, error)
 }
 
 // Repository provides access to restaurant repository.
 type Repository interface {
 	// GetRestaurant gets a given restaurant to the repository.
-	GetRestaurant(int64) Restaurant
-	GetRestaurants([]SortOperation, []FilterOperation) []Restaurant
-	GetVisit(int64, int64) Visit
-	GetVisitUsersByVisitID(int64) []VisitUser
-	GetVisitsByRestaurantID(int64, []SortOperation) []Visit
-	GetUserCount() int64
-	GetUser(int64) User
-	GetRestaurantAvgRatingByUser(int64) []AvgUserRating
-	GetDistinct(string, string) []string
+	GetRestaurant(int64) (Restaurant, error)
+	// GetRestaurants returns the restaurants that match the filter sorted by the sort operations, up to the page limit.
+	// The filter is nil when every restaurant is wanted.
+	GetRestaurants([]SortOperation, FilterExpr, PageOperation) ([]Restaurant, error)
+	CountRestaurants(FilterExpr) (int64, error)
+	GetVisit(int64, int64) (Visit, error)
+	GetVisitUsersByVisitID(int64) ([]VisitUser, error)
+	// GetVisits returns the visits that match the filter sorted by the sort operations, up to the page limit. The
+	// filter is nil when every visit is wanted.
+	GetVisits([]SortOperation, FilterExpr, PageOperation) ([]Visit, error)
+	CountVisits(FilterExpr) (int64, error)
+	GetUserCount() (int64, error)
+	GetUser(int64) (User, error)
+	GetRestaurantAvgRatingByUser(int64) ([]AvgUserRating, error)
+	GetDistinct(string, string) ([]string, error)
 	RestaurantSortFields() map[string]string
 	RestaurantFilterFields() map[string]Field
 	VisitSortFields() map[string]string
-	GetUsers() []User
+	VisitFilterFields() map[string]Field
+	GetUsers() ([]User, error)
+	// SearchRestaurants returns up to limit restaurants that contain every term as a word prefix, most relevant first.
+	SearchRestaurants(terms []string, limit int) ([]SearchMatch, error)
+	GetTrashedRestaurants() ([]TrashedRestaurant, error)
+	GetTrashedVisits() ([]TrashedVisit, error)
+	GetAuditLog(entityType string, entityID int64) ([]AuditEntry, error)
+	GetRestaurantAuditLog(restaurantID int64) ([]AuditEntry, error)
+	// GetSavedViews returns the views the user owns and the ones other users share, ordered by name.
+	GetSavedViews(userID int64) ([]SavedView, error)
+	GetSavedView(int64) (SavedView, error)
+	// GetAPITokens returns the user's API tokens, newest first.
+	GetAPITokens(userID int64) ([]APIToken, error)
+	// GetStatsVisits returns the visits that aren't in the trash, oldest first.
+	GetStatsVisits() ([]StatsVisit, error)
+	// GetStatsRatings returns the ratings of the visits that aren't in the trash.
+	GetStatsRatings() ([]StatsRating, error)
+	// GetUserRestaurantRatings returns the user's average rating of each restaurant they rated, by restaurant id.
+	GetUserRestaurantRatings(userID int64) ([]UserRestaurantRating, error)
 }
 
 type service struct {
@@ -60,10 +104,11 @@ type service struct {
 
 // GetRestaurant returns a restaurant with the given id
 func (s service) GetRestaurant(id int64) (Restaurant, error) {
-	var err error
-	r := s.r.GetRestaurant(id)
-	if r.ID == 0 {
-		err = &ErrDoesNotExist{fmt.Sprintf("No restaurant with id: %d", id)}
+	r, err := s.r.GetRestaurant(id)
+	if storage.IsNotFound(err) {
+		return r, &ErrDoesNotExist{fmt.Sprintf("No restaurant with id: %d", id)}
+	} else if err != nil {
+		return r, err
 	}
 
 	dateFormat := "2006-01-02"
@@ -85,28 +130,64 @@ func (s service) GetRestaurant(id int64) (Restaurant, error) {
 	return r, err
 }
 
-// GetRestaurants returns all the restaurants in the storage
-func (s service) GetRestaurants(qp url.Values) ([]Restaurant, error) {
-	var rs []Restaurant
+// GetRestaurants returns a page of the restaurants in the storage. The limit, after and before query params pick the
+// page.
+func (s service) GetRestaurants(qp url.Values) (RestaurantPage, error) {
+	var rp RestaurantPage
 	sops, err := s.checkSort("restaurant", qp)
 	if err != nil {
-		return rs, err
+		return rp, err
 	}
+	sops = withTiebreaker(sops, s.r.RestaurantSortFields()["id"])
 
-	fops, err := s.checkFilter("restaurant", qp)
+	filter, err := s.getFilter("restaurant", qp)
+	if err != nil {
+		return rp, err
+	}
+	if _, ok := FilterOrigin(filter); !ok {
+		for _, so := range sops {
+			if so.Name == "distance" {
+				return rp, fmt.Errorf("Sorting by distance needs a location near filter to measure it from, e.g. " +
+					"filter[location|near]=37.77,-122.42,2")
+			}
+		}
+	}
+
+	pr, err := parsePageArgs(qp, sops)
+	if err != nil {
+		return rp, err
+	}
+	po, querySops := pr.queryPage(sops)
+	rs, err := s.r.GetRestaurants(querySops, filter, po)
+	if err != nil {
+		return rp, err
+	}
+	lo, hi, page := pr.getPage(sops, len(rs), func(i int) []interface{} {
+		return restaurantSortValues(rs[i], sops)
+	}, func() {
+		for i, j := 0, len(rs)-1; i < j; i, j = i+1, j-1 {
+			rs[i], rs[j] = rs[j], rs[i]
+		}
+	})
+	// Leave out the extra row that was only fetched to see if there is another page.
+	rs = rs[lo:hi]
+	page.Total, err = s.r.CountRestaurants(filter)
 	if err != nil {
-		return rs, err
+		return rp, err
 	}
+	rp.Page = page
 
-	rs = s.r.GetRestaurants(sops, fops)
 	for i, r := range rs {
 		// // Get ratings for each restaurant
-		rs[i].AvgUserRatings = s.r.GetRestaurantAvgRatingByUser(r.ID)
+		rs[i].AvgUserRatings, err = s.r.GetRestaurantAvgRatingByUser(r.ID)
+		if err != nil {
+			return rp, err
+		}
 		var lastVisitHumanDate string = ""
 		if r.LastVisitDatetime != "" {
 			lastVisitDate, err := time.Parse(time.RFC3339, r.LastVisitDatetime)
 			if err != nil {
-				return rs, err
+				return rp, err
 			}
 			lastVisitHumanDate = lastVisitDate.Format("January 2, 2006")
 
@@ -117,19 +198,23 @@ func (s service) GetRestaurants(qp url.Values) ([]Restaurant, error) {
 		rs[i].SearchValue = fmt.Sprintf("%s|%s|%s|%s|%s|%s|%s", r.Name, r.Cuisine, r.CityState.Name, r.CityState.State,
 			r.Note, r.LastVisitDatetime, lastVisitHumanDate)
 	}
-	return rs, nil
+	rp.Restaurants = rs
+	return rp, nil
 }
 
 // GetVisit returns a visit with the given id and restaurant id
 func (s service) GetVisit(id int64, resID int64) (Visit, error) {
-	var err error
-	v := s.r.GetVisit(id, resID)
-	if v.ID == 0 {
+	v, err := s.r.GetVisit(id, resID)
+	if storage.IsNotFound(err) {
 		err = &ErrDoesNotExist{fmt.Sprintf("No visit with id: %d for restaurant: %d", id, resID)}
 		return v, err
-	} else {
-		// Get the users who were in this visit.
-		v.VisitUsers = s.r.GetVisitUsersByVisitID(v.ID)
+	} else if err != nil {
+		return v, err
+	}
+	// Get the users who were in this visit.
+	v.VisitUsers, err = s.r.GetVisitUsersByVisitID(v.ID)
+	if err != nil {
+		return v, err
 	}
 	dateFormat := "2006-01-02"
 	visitDateTime, err := time.Parse(time.RFC3339, v.VisitDateTime)
@@ -141,60 +226,153 @@ func (s service) GetVisit(id int64, resID int64) (Visit, error) {
 	return v, err
 }
 
-func (s service) GetVisitsByRestaurantID(restaurantID int64, qp url.Values) ([]Visit, error) {
-	var allVisits []Visit
+// GetVisitsByRestaurantID returns a page of a restaurant's visits, newest first unless another sort is asked for. The
+// limit, after and before query params pick the page.
+func (s service) GetVisitsByRestaurantID(restaurantID int64, qp url.Values) (VisitPage, error) {
+	restaurantFilter, err := checkFilterOp(FilterOperation{
+		Field:    "restaurant_id",
+		Operator: "eq",
+		Value:    strconv.FormatInt(restaurantID, 10),
+	}, s.r.VisitFilterFields())
+	if err != nil {
+		return VisitPage{}, err
+	}
+	return s.getVisits(qp, restaurantFilter)
+}
+
+// GetVisits returns a page of the visits to every restaurant, newest first unless another sort is asked for. The
+// limit, after and before query params pick the page.
+func (s service) GetVisits(qp url.Values) (VisitPage, error) {
+	return s.getVisits(qp, nil)
+}
+
+// getVisits returns a page of the visits that match both the filter in the query params and the given filter, which
+// can be nil.
+func (s service) getVisits(qp url.Values, filter FilterExpr) (VisitPage, error) {
+	var vp VisitPage
 	sops, err := s.checkSort("visit", qp)
 	if err != nil {
-		return allVisits, err
+		return vp, err
 	}
+	if len(sops) == 0 {
+		// By default sort by this
+		sops = []SortOperation{{Name: "date", Field: s.r.VisitSortFields()["date"], Direction: "desc"}}
+	}
+	sops = withTiebreaker(sops, s.r.VisitSortFields()["id"])
+
+	qpFilter, err := s.getFilter("visit", qp)
+	if err != nil {
+		return vp, err
+	}
+	if filter == nil {
+		filter = qpFilter
+	} else if qpFilter != nil {
+		filter = FilterAnd{filter, qpFilter}
+	}
+
+	pr, err := parsePageArgs(qp, sops)
+	if err != nil {
+		return vp, err
+	}
+	po, querySops := pr.queryPage(sops)
+	allVisits, err := s.r.GetVisits(querySops, filter, po)
+	if err != nil {
+		return vp, err
+	}
+	lo, hi, page := pr.getPage(sops, len(allVisits), func(i int) []interface{} {
+		return visitSortValues(allVisits[i], sops)
+	}, func() {
+		for i, j := 0, len(allVisits)-1; i < j; i, j = i+1, j-1 {
+			allVisits[i], allVisits[j] = allVisits[j], allVisits[i]
+		}
+	})
+	// Leave out the extra row that was only fetched to see if there is another page.
+	allVisits = allVisits[lo:hi]
+	page.Total, err = s.r.CountVisits(filter)
+	if err != nil {
+		return vp, err
+	}
+	vp.Page = page
 
-	allVisits = s.r.GetVisitsByRestaurantID(restaurantID, sops)
 	for i, v := range allVisits {
 		// For each visit get the users who were there and their rating.
-		allVisits[i].VisitUsers = s.r.GetVisitUsersByVisitID(v.ID)
+		allVisits[i].VisitUsers, err = s.r.GetVisitUsersByVisitID(v.ID)
+		if err != nil {
+			return vp, err
+		}
 
 		// Format the last visit to just the date
 		visitDateTime, err := time.Parse(time.RFC3339, v.VisitDateTime)
 		if err != nil {
-			return allVisits, err
+			return vp, err
 		}
 		allVisits[i].VisitDateTime = visitDateTime.Format("2006-01-02")
 	}
-	return allVisits, nil
+	vp.Visits = allVisits
+	return vp, nil
 }
 
 // GetUserCount returns the number of users in the repository.
-func (s service) GetUserCount() int64 {
+func (s service) GetUserCount() (int64, error) {
 	return s.r.GetUserCount()
 }
 
 // GetUserByID returns the User for a given id
-func (s service) GetUserByID(id int64) User {
-	return s.r.GetUser(id)
+func (s service) GetUserByID(id int64) (User, error) {
+	u, err := s.r.GetUser(id)
+	if storage.IsNotFound(err) {
+		return u, &ErrDoesNotExist{fmt.Sprintf("No user with id: %d", id)}
+	}
+	return u, err
 }
 
 // GetFilterOptions returns a Filter
-func (s service) GetFilterOptions(qp url.Values) FilterOptions {
-	return FilterOptions{
-		Cuisine: generateFilterOptions(s.r.GetDistinct("cuisine", "restaurant"), s.GetFilterParam("cuisine", qp).Value),
-		City:    generateFilterOptions(s.r.GetDistinct("name", "city"), s.GetFilterParam("city", qp).Value),
-		State:   generateFilterOptions(s.r.GetDistinct("state", "city"), s.GetFilterParam("state", qp).Value),
+func (s service) GetFilterOptions(qp url.Values) (FilterOptions, error) {
+	var fo FilterOptions
+	cuisines, err := s.r.GetDistinct("cuisine", "restaurant")
+	if err != nil {
+		return fo, err
 	}
+	cities, err := s.r.GetDistinct("name", "city")
+	if err != nil {
+		return fo, err
+	}
+	states, err := s.r.GetDistinct("state", "city")
+	if err != nil {
+		return fo, err
+	}
+	fo.Cuisine = generateFilterOptions(cuisines, selectedValues(s.GetFilterParam("cuisine", qp)))
+	fo.City = generateFilterOptions(cities, selectedValues(s.GetFilterParam("city", qp)))
+	fo.State = generateFilterOptions(states, selectedValues(s.GetFilterParam("state", qp)))
+	return fo, nil
+}
+
+// selectedValues returns the values a filter param selects.
+func selectedValues(f FilterOperation) []string {
+	if f.Operator == "in" {
+		return f.Values
+	}
+	return []string{f.Value}
 }
 
 // GetUsers gets all the users in storage
-func (s service) GetUsers() []User {
+func (s service) GetUsers() ([]User, error) {
 	return s.r.GetUsers()
 }
 
-func (s service) GetDistinct(field string, obj string) []string {
+func (s service) GetDistinct(field string, obj string) ([]string, error) {
 	return s.r.GetDistinct(field, obj)
 }
 
-func generateFilterOptions(distinctSlice []string, selectedValue string) []FilterOption {
+func generateFilterOptions(distinctSlice []string, selectedValues []string) []FilterOption {
 	var cuisine []FilterOption
 	for _, o := range distinctSlice {
-		selected := o == selectedValue
+		selected := false
+		for _, v := range selectedValues {
+			if o == v {
+				selected = true
+			}
+		}
 		cuisine = append(cuisine, FilterOption{Value: o, Selected: selected})
 	}
 	return cuisine
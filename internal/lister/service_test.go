@@ -0,0 +1,178 @@
+package lister_test
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/kelvinatorr/restaurant-tracker/internal/adder"
+	"github.com/kelvinatorr/restaurant-tracker/internal/lister"
+	"github.com/kelvinatorr/restaurant-tracker/internal/mapper"
+	"github.com/kelvinatorr/restaurant-tracker/internal/storage/memory"
+)
+
+// newService returns a lister for an in-memory storage with five restaurants. Their ids are in the order they are
+// added, so restaurants 1 and 5 tie on both cuisine and city.
+func newService(t *testing.T) lister.Service {
+	t.Helper()
+	s := memory.NewStorage()
+	a := adder.NewService(s.Adder(), mapper.NewService(""))
+	userID, err := a.AddUser(adder.User{FirstName: "Alex", LastName: "Rivera", Email: "alex@example.com",
+		Password: "pw", RepeatPassword: "pw"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range []struct{ name, cuisine, city, state string }{
+		{"Pho Saigon", "Vietnamese", "Seattle", "WA"},
+		{"Thai Tom", "Thai", "Seattle", "WA"},
+		{"Bangkok House", "Thai", "Portland", "OR"},
+		{"Lao Table", "Lao", "Portland", "OR"},
+		{"Pho Bac", "Vietnamese", "Seattle", "WA"},
+	} {
+		_, err := a.AddRestaurant(adder.Restaurant{Name: r.name, Cuisine: r.cuisine,
+			CityState: adder.CityState{Name: r.city, State: r.state}}, userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return lister.NewService(s)
+}
+
+func restaurantIDs(rp lister.RestaurantPage) []int64 {
+	ids := make([]int64, len(rp.Restaurants))
+	for i, r := range rp.Restaurants {
+		ids[i] = r.ID
+	}
+	return ids
+}
+
+func TestGetRestaurantsSort(t *testing.T) {
+	tests := []struct {
+		name    string
+		qp      url.Values
+		wantIDs []int64
+		wantErr string
+	}{
+		{
+			name:    "no sort is by id",
+			qp:      url.Values{},
+			wantIDs: []int64{1, 2, 3, 4, 5},
+		},
+		{
+			name:    "fields in the order of the sort spec with ties by id",
+			qp:      url.Values{"sort": {"cuisine,city:desc"}},
+			wantIDs: []int64{4, 2, 3, 1, 5},
+		},
+		{
+			name:    "the order of the fields matters",
+			qp:      url.Values{"sort": {"city:desc,cuisine"}},
+			wantIDs: []int64{2, 1, 5, 4, 3},
+		},
+		{
+			name:    "ties are by id ascending when the other fields are descending",
+			qp:      url.Values{"sort": {"cuisine:desc"}},
+			wantIDs: []int64{1, 5, 2, 3, 4},
+		},
+		{
+			name:    "id in the sort replaces the tiebreaker",
+			qp:      url.Values{"sort": {"cuisine:desc,id:desc"}},
+			wantIDs: []int64{5, 1, 3, 2, 4},
+		},
+		{
+			name:    "sort[] params after the sort spec",
+			qp:      url.Values{"sort": {"city"}, "sort[name]": {"desc"}},
+			wantIDs: []int64{4, 3, 2, 1, 5},
+		},
+		{
+			name:    "unknown field",
+			qp:      url.Values{"sort": {"bogus"}},
+			wantErr: "bogus is not a valid sort field",
+		},
+		{
+			name:    "unknown direction",
+			qp:      url.Values{"sort": {"name:up"}},
+			wantErr: "Bad sort direction",
+		},
+	}
+	l := newService(t)
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rp, err := l.GetRestaurants(tc.qp)
+			if tc.wantErr != "" {
+				if err == nil || err.Error() != tc.wantErr {
+					t.Fatalf("got error %v, want %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := restaurantIDs(rp); !reflect.DeepEqual(got, tc.wantIDs) {
+				t.Errorf("got restaurants %v, want %v", got, tc.wantIDs)
+			}
+		})
+	}
+}
+
+func TestGetRestaurantsPages(t *testing.T) {
+	l := newService(t)
+	qp := func(cursorParam string, token string) url.Values {
+		v := url.Values{"sort": {"cuisine:desc"}, "limit": {"2"}}
+		if cursorParam != "" {
+			v.Set(cursorParam, token)
+		}
+		return v
+	}
+
+	// Go forward through the pages with the next cursors, then back with the prev cursors.
+	wantPages := [][]int64{{1, 5}, {2, 3}, {4}}
+	var pages []lister.RestaurantPage
+	rp, err := l.GetRestaurants(qp("", ""))
+	for err == nil {
+		pages = append(pages, rp)
+		if rp.Page.Next == "" {
+			break
+		}
+		rp, err = l.GetRestaurants(qp("after", rp.Page.Next))
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) != len(wantPages) {
+		t.Fatalf("got %d pages, want %d", len(pages), len(wantPages))
+	}
+	for i, p := range pages {
+		if got := restaurantIDs(p); !reflect.DeepEqual(got, wantPages[i]) {
+			t.Errorf("page %d: got restaurants %v, want %v", i+1, got, wantPages[i])
+		}
+		if p.Page.Total != 5 {
+			t.Errorf("page %d: got total %d, want 5", i+1, p.Page.Total)
+		}
+		if hasPrev := p.Page.Prev != ""; hasPrev != (i > 0) {
+			t.Errorf("page %d: got a prev cursor: %t", i+1, hasPrev)
+		}
+	}
+
+	for i := len(pages) - 1; i > 0; i-- {
+		rp, err := l.GetRestaurants(qp("before", pages[i].Page.Prev))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := restaurantIDs(rp); !reflect.DeepEqual(got, wantPages[i-1]) {
+			t.Errorf("before page %d: got restaurants %v, want %v", i+1, got, wantPages[i-1])
+		}
+		if rp.Page.Next != pages[i-1].Page.Next || rp.Page.Prev != pages[i-1].Page.Prev {
+			t.Errorf("before page %d: got cursors %+v, want %+v", i+1, rp.Page, pages[i-1].Page)
+		}
+	}
+
+	// A cursor only works with the sort it was made for.
+	other := url.Values{"sort": {"cuisine"}, "after": {pages[0].Page.Next}}
+	if _, err := l.GetRestaurants(other); err == nil ||
+		err.Error() != "The page cursor is for a different sort, go back to the first page" {
+		t.Errorf("got error %v for a cursor of a different sort", err)
+	}
+	if _, err := l.GetRestaurants(qp("after", "%%%")); err == nil || err.Error() != "Bad page cursor" {
+		t.Errorf("got error %v for a bad cursor", err)
+	}
+}
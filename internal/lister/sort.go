@@ -4,10 +4,13 @@ import (
 	"fmt"
 	"log"
 	"net/url"
+	"sort"
 	"strings"
 )
 
 type SortOperation struct {
+	// Name is the field's name in the sort query param and Field is the repository field it is sorted by.
+	Name      string
 	Field     string
 	Direction string
 }
@@ -28,13 +31,34 @@ func (s service) getAllowedSortFields(object string) (map[string]string, error)
 
 }
 
+// GetSortFields returns the names of the fields a restaurant or visit can be sorted by, in order.
+func (s service) GetSortFields(object string) ([]string, error) {
+	allowedSortFields, err := s.getAllowedSortFields(object)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]string, 0, len(allowedSortFields))
+	for name := range allowedSortFields {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// sortSpecParam is the query param that holds an ordered sort, e.g. sort=avg_rating:desc,last_visit:asc.
+const sortSpecParam = "sort"
+
 // checkSort checks that the sort params from the user are valid and prevents sql injections
 func (s service) checkSort(object string, sortRequested url.Values) ([]SortOperation, error) {
 
 	var result []SortOperation
 
+	sortOps, err := parseSortParams(sortRequested)
+	if err != nil {
+		return result, err
+	}
 	// If there is nothing to sort then just return
-	if len(sortRequested) == 0 {
+	if len(sortOps) == 0 {
 		return result, nil
 	}
 
@@ -44,17 +68,10 @@ func (s service) checkSort(object string, sortRequested url.Values) ([]SortOpera
 	}
 	allowedDirections := getAllowedDirections()
 
-	for k, v := range sortRequested {
-
-		sortOp := parseSortArg(k, v)
-		if sortOp.Field == "" && sortOp.Direction == "" {
-			// skip this since it isn't a sort query param. Use && instead of || so the user sees an error on a badly formed sort
-			// query
-			continue
-		}
-
+	for _, sortOp := range sortOps {
 		if sf, check := allowedSortFields[sortOp.Field]; check {
 			if _, check := allowedDirections[sortOp.Direction]; check {
+				sortOp.Name = sortOp.Field
 				sortOp.Field = sf
 				result = append(result, sortOp)
 			} else {
@@ -70,27 +87,78 @@ func (s service) checkSort(object string, sortRequested url.Values) ([]SortOpera
 	return result, nil
 }
 
+// GetSortParam returns how the given field is sorted in the query params. It is empty if the field isn't sorted.
 func (s service) GetSortParam(object string, sortRequested url.Values) SortOperation {
 	var result SortOperation
 
-	for k, v := range sortRequested {
-		s := parseSortArg(k, v)
-		if s.Field == "" && s.Direction == "" {
-			// skip this since it isn't a sort query param
-			continue
-		} else if s.Field == object {
-			result = s
+	sortOps, _ := parseSortParams(sortRequested)
+	for _, so := range sortOps {
+		if so.Field == object {
+			result = so
 			return result
 		}
 	}
 	return result
 }
 
+// GetSortParams returns the sort keys in the query params in the order they are sorted by. The fields are named as
+// they are in the query params and are not checked.
+func (s service) GetSortParams(sortRequested url.Values) []SortOperation {
+	sortOps, _ := parseSortParams(sortRequested)
+	return sortOps
+}
+
+// parseSortParams parses the sort query params in the order they are sorted by. The keys of the sort param come
+// first, then the sort[]= params in a set order so the same query always sorts the same way. A sort[]= param for a
+// field that is already in the sort param is skipped so a default sort can be added after the user's. It does not
+// sanitize them.
+func parseSortParams(qp url.Values) ([]SortOperation, error) {
+	var result []SortOperation
+	seen := make(map[string]bool)
+
+	if spec := qp.Get(sortSpecParam); spec != "" {
+		for _, key := range strings.Split(spec, ",") {
+			key = strings.TrimSpace(key)
+			if key == "" {
+				continue
+			}
+			parts := strings.Split(key, ":")
+			if len(parts) > 2 {
+				return result, fmt.Errorf("Bad sort key %s", key)
+			}
+			so := SortOperation{Field: strings.TrimSpace(parts[0]), Direction: "asc"}
+			if len(parts) == 2 {
+				so.Direction = strings.ToLower(strings.TrimSpace(parts[1]))
+			}
+			if seen[so.Field] {
+				return result, fmt.Errorf("%s is sorted by more than once", so.Field)
+			}
+			seen[so.Field] = true
+			result = append(result, so)
+		}
+	}
+
+	for _, k := range sortedKeys(qp) {
+		so := parseSortArg(k, qp[k])
+		if so.Field == "" && so.Direction == "" {
+			// skip this since it isn't a sort query param. Use && instead of || so the user sees an error on a badly
+			// formed sort query
+			continue
+		}
+		if seen[so.Field] {
+			continue
+		}
+		seen[so.Field] = true
+		result = append(result, so)
+	}
+	return result, nil
+}
+
 // parseSortArg just parses the sort[]= query param. It does not sanitize it.
 func parseSortArg(keyArg string, valueArg []string) SortOperation {
 	var result SortOperation
 
-	if keyArg[:4] != "sort" {
+	if !strings.HasPrefix(keyArg, sortSpecParam) || keyArg == sortSpecParam {
 		// return an empty string object
 		return result
 	}
@@ -99,3 +167,13 @@ func parseSortArg(keyArg string, valueArg []string) SortOperation {
 	result.Direction = valueArg[0]
 	return result
 }
+
+// sortedKeys returns the keys of the query params in order.
+func sortedKeys(qp url.Values) []string {
+	keys := make([]string, 0, len(qp))
+	for k := range qp {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
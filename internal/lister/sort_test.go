@@ -0,0 +1,95 @@
+package lister
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestParseSortParams(t *testing.T) {
+	tests := []struct {
+		name    string
+		qp      url.Values
+		want    []SortOperation
+		wantErr string
+	}{
+		{
+			name: "none",
+			qp:   url.Values{"filter[name|eq]": {"Pho"}},
+		},
+		{
+			name: "sort spec in order",
+			qp:   url.Values{"sort": {"avg_rating:desc, last_visit ,name:ASC"}},
+			want: []SortOperation{{Field: "avg_rating", Direction: "desc"}, {Field: "last_visit", Direction: "asc"},
+				{Field: "name", Direction: "asc"}},
+		},
+		{
+			name: "sort[] params by key",
+			qp:   url.Values{"sort[name]": {"desc"}, "sort[city]": {"asc"}},
+			want: []SortOperation{{Field: "city", Direction: "asc"}, {Field: "name", Direction: "desc"}},
+		},
+		{
+			name: "sort spec before sort[] params and a field is only sorted once",
+			qp:   url.Values{"sort": {"name:desc"}, "sort[name]": {"asc"}, "sort[cuisine]": {"asc"}},
+			want: []SortOperation{{Field: "name", Direction: "desc"}, {Field: "cuisine", Direction: "asc"}},
+		},
+		{
+			name:    "field twice in the sort spec",
+			qp:      url.Values{"sort": {"name,cuisine,name:desc"}},
+			wantErr: "name is sorted by more than once",
+		},
+		{
+			name:    "too many colons",
+			qp:      url.Values{"sort": {"name:asc:desc"}},
+			wantErr: "Bad sort key name:asc:desc",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseSortParams(tc.qp)
+			if tc.wantErr != "" {
+				if err == nil || err.Error() != tc.wantErr {
+					t.Fatalf("got error %v, want %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestWithTiebreaker(t *testing.T) {
+	id := SortOperation{Name: "id", Field: "res.id", Direction: "asc"}
+	name := SortOperation{Name: "name", Field: "res.name", Direction: "desc"}
+	idDesc := SortOperation{Name: "id", Field: "res.id", Direction: "desc"}
+	tests := []struct {
+		name    string
+		sortOps []SortOperation
+		want    []SortOperation
+	}{
+		{"no sort", nil, []SortOperation{id}},
+		{"id is added last", []SortOperation{name}, []SortOperation{name, id}},
+		{"id already in the sort", []SortOperation{idDesc, name}, []SortOperation{idDesc, name}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := withTiebreaker(tc.sortOps, "res.id")
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+
+	// The tiebreaker is appended to a copy so it doesn't change the caller's sort.
+	sortOps := make([]SortOperation, 1, 2)
+	sortOps[0] = name
+	withTiebreaker(sortOps, "res.id")
+	if got := sortOps[:2][1]; got != (SortOperation{}) {
+		t.Errorf("withTiebreaker wrote %v past the end of the sort operations", got)
+	}
+}
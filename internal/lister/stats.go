@@ -0,0 +1,399 @@
+package lister
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"time"
+)
+
+// favoriteRating is the lowest average rating a restaurant can have and still be a favorite.
+const favoriteRating = 4
+
+// statsListSize is how many restaurants the most visited and unvisited favorites lists have.
+const statsListSize = 10
+
+// statsDateFormat is the format of the from and to query params and of the dates in Stats.
+const statsDateFormat = "2006-01-02"
+
+// StatsVisit is a visit with the restaurant fields the statistics group it by.
+type StatsVisit struct {
+	ID             int64
+	RestaurantID   int64
+	RestaurantName string
+	Cuisine        string
+	City           string
+	State          string
+	VisitDateTime  string
+}
+
+// StatsRating is a user's rating of a visit.
+type StatsRating struct {
+	VisitID int64
+	UserID  int64
+	Rating  int64
+}
+
+// PeriodCount is how many visits there were in a month or a year and how many of them were to a restaurant for the
+// first time.
+type PeriodCount struct {
+	// Period is 2006 for a year or 2006-01 for a month.
+	Period         string `json:"period"`
+	Visits         int64  `json:"visits"`
+	NewRestaurants int64  `json:"new_restaurants"`
+}
+
+// GroupStats is the visits and ratings of the restaurants with the same cuisine or city.
+type GroupStats struct {
+	Name        string `json:"name"`
+	Visits      int64  `json:"visits"`
+	Restaurants int64  `json:"restaurants"`
+	Ratings     int64  `json:"ratings"`
+	// AvgRating is the average of the ratings, or 0 if there aren't any.
+	AvgRating float64 `json:"avg_rating"`
+}
+
+// UserRatingStats is how a user's ratings are spread out.
+type UserRatingStats struct {
+	UserID    int64  `json:"user_id"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	// Counts has the number of ratings of 1 to 5 in that order.
+	Counts    [5]int64 `json:"counts"`
+	Ratings   int64    `json:"ratings"`
+	AvgRating float64  `json:"avg_rating"`
+}
+
+// RestaurantStats is a restaurant in the most visited or unvisited favorites lists.
+type RestaurantStats struct {
+	ID        int64   `json:"id"`
+	Name      string  `json:"name"`
+	Visits    int64   `json:"visits"`
+	AvgRating float64 `json:"avg_rating"`
+	LastVisit string  `json:"last_visit"`
+	// DaysSinceVisit is how many days there are between the last visit and the end of the range.
+	DaysSinceVisit int64 `json:"days_since_visit"`
+}
+
+// Stats are the statistics of the visits between From and To, which are inclusive and empty when the range is open.
+type Stats struct {
+	From        string `json:"from"`
+	To          string `json:"to"`
+	Visits      int64  `json:"visits"`
+	Restaurants int64  `json:"restaurants"`
+	// NewRestaurants is how many restaurants were visited for the first time and NewRestaurantRate is that as a
+	// fraction of Visits.
+	NewRestaurants    int64             `json:"new_restaurants"`
+	NewRestaurantRate float64           `json:"new_restaurant_rate"`
+	VisitsByMonth     []PeriodCount     `json:"visits_by_month"`
+	VisitsByYear      []PeriodCount     `json:"visits_by_year"`
+	Cuisines          []GroupStats      `json:"cuisines"`
+	Cities            []GroupStats      `json:"cities"`
+	UserRatings       []UserRatingStats `json:"user_ratings"`
+	MostVisited       []RestaurantStats `json:"most_visited"`
+	// UnvisitedFavorites are the restaurants with an average rating of at least 4 up to To, the longest unvisited
+	// first. It isn't limited by From because the last visit could be before it.
+	UnvisitedFavorites []RestaurantStats `json:"unvisited_favorites"`
+}
+
+// statsGroup adds up the visits and ratings of a GroupStats.
+type statsGroup struct {
+	visits      int64
+	restaurants map[int64]bool
+	ratings     int64
+	ratingSum   int64
+}
+
+func (g *statsGroup) add(v StatsVisit, ratings []StatsRating) {
+	if g.restaurants == nil {
+		g.restaurants = make(map[int64]bool)
+	}
+	g.visits++
+	g.restaurants[v.RestaurantID] = true
+	for _, r := range ratings {
+		g.ratings++
+		g.ratingSum += r.Rating
+	}
+}
+
+// GetStats returns the statistics of the visits between the from and to query params, which are dates like
+// 2006-01-02. Either can be left out to not limit that end of the range.
+func (s service) GetStats(qp url.Values) (Stats, error) {
+	// The lists are empty rather than nil so they are [] in JSON.
+	st := Stats{
+		VisitsByMonth:      []PeriodCount{},
+		VisitsByYear:       []PeriodCount{},
+		Cuisines:           []GroupStats{},
+		Cities:             []GroupStats{},
+		UserRatings:        []UserRatingStats{},
+		MostVisited:        []RestaurantStats{},
+		UnvisitedFavorites: []RestaurantStats{},
+	}
+	from, err := parseStatsDate("from", qp.Get("from"))
+	if err != nil {
+		return st, err
+	}
+	to, err := parseStatsDate("to", qp.Get("to"))
+	if err != nil {
+		return st, err
+	}
+	if from != "" && to != "" && from > to {
+		return st, fmt.Errorf("The from date %s is after the to date %s", from, to)
+	}
+	st.From = from
+	st.To = to
+
+	visits, err := s.r.GetStatsVisits()
+	if err != nil {
+		return st, err
+	}
+	allRatings, err := s.r.GetStatsRatings()
+	if err != nil {
+		return st, err
+	}
+	users, err := s.r.GetUsers()
+	if err != nil {
+		return st, err
+	}
+	ratings := make(map[int64][]StatsRating)
+	for _, r := range allRatings {
+		ratings[r.VisitID] = append(ratings[r.VisitID], r)
+	}
+
+	// The visits are oldest first so the first one to a restaurant is its first visit ever, even when it is before
+	// the range.
+	firstVisit := make(map[int64]int64)
+	months := make(map[string]*PeriodCount)
+	years := make(map[string]*PeriodCount)
+	cuisines := make(map[string]*statsGroup)
+	cities := make(map[string]*statsGroup)
+	restaurants := make(map[int64]*RestaurantStats)
+	restaurantRatings := make(map[int64]*statsGroup)
+	userRatings := make(map[int64]*UserRatingStats)
+	for _, u := range users {
+		userRatings[u.ID] = &UserRatingStats{UserID: u.ID, FirstName: u.FirstName, LastName: u.LastName}
+	}
+	// favorites adds up the ratings and last visits up to the end of the range, whatever its start.
+	favorites := make(map[int64]*RestaurantStats)
+	favoriteRatings := make(map[int64]*statsGroup)
+	var firstMonth, lastMonth string
+	for _, v := range visits {
+		if _, ok := firstVisit[v.RestaurantID]; !ok {
+			firstVisit[v.RestaurantID] = v.ID
+		}
+		date := v.VisitDateTime[:len(statsDateFormat)]
+		if to != "" && date > to {
+			continue
+		}
+		if favorites[v.RestaurantID] == nil {
+			favorites[v.RestaurantID] = &RestaurantStats{ID: v.RestaurantID, Name: v.RestaurantName}
+			favoriteRatings[v.RestaurantID] = &statsGroup{}
+		}
+		favorites[v.RestaurantID].Visits++
+		favorites[v.RestaurantID].LastVisit = date
+		favoriteRatings[v.RestaurantID].add(v, ratings[v.ID])
+		if from != "" && date < from {
+			continue
+		}
+
+		month := date[:len("2006-01")]
+		if firstMonth == "" {
+			firstMonth = month
+		}
+		lastMonth = month
+		isNew := firstVisit[v.RestaurantID] == v.ID
+		for _, p := range []struct {
+			counts map[string]*PeriodCount
+			period string
+		}{{months, month}, {years, date[:len("2006")]}} {
+			if p.counts[p.period] == nil {
+				p.counts[p.period] = &PeriodCount{Period: p.period}
+			}
+			p.counts[p.period].Visits++
+			if isNew {
+				p.counts[p.period].NewRestaurants++
+			}
+		}
+		st.Visits++
+		if isNew {
+			st.NewRestaurants++
+		}
+
+		for _, g := range []struct {
+			groups map[string]*statsGroup
+			name   string
+		}{{cuisines, v.Cuisine}, {cities, fmt.Sprintf("%s, %s", v.City, v.State)}} {
+			if g.groups[g.name] == nil {
+				g.groups[g.name] = &statsGroup{}
+			}
+			g.groups[g.name].add(v, ratings[v.ID])
+		}
+
+		if restaurants[v.RestaurantID] == nil {
+			restaurants[v.RestaurantID] = &RestaurantStats{ID: v.RestaurantID, Name: v.RestaurantName}
+			restaurantRatings[v.RestaurantID] = &statsGroup{}
+		}
+		restaurants[v.RestaurantID].Visits++
+		restaurants[v.RestaurantID].LastVisit = date
+		restaurantRatings[v.RestaurantID].add(v, ratings[v.ID])
+
+		for _, r := range ratings[v.ID] {
+			ur := userRatings[r.UserID]
+			if ur == nil || r.Rating < 1 || r.Rating > int64(len(ur.Counts)) {
+				continue
+			}
+			ur.Counts[r.Rating-1]++
+			ur.Ratings++
+			ur.AvgRating += float64(r.Rating)
+		}
+	}
+	if st.Visits > 0 {
+		st.NewRestaurantRate = float64(st.NewRestaurants) / float64(st.Visits)
+	}
+	st.Restaurants = int64(len(restaurants))
+
+	// Every month and year in the range is listed, even the ones without visits, so they can be charted as is.
+	if from != "" {
+		firstMonth = from[:len("2006-01")]
+	}
+	if to != "" {
+		lastMonth = to[:len("2006-01")]
+	}
+	if firstMonth != "" {
+		start, err := time.Parse("2006-01", firstMonth)
+		if err != nil {
+			return st, err
+		}
+		for m := start; m.Format("2006-01") <= lastMonth; m = m.AddDate(0, 1, 0) {
+			st.VisitsByMonth = append(st.VisitsByMonth, periodCount(months, m.Format("2006-01")))
+			if m == start || m.Month() == time.January {
+				st.VisitsByYear = append(st.VisitsByYear, periodCount(years, m.Format("2006")))
+			}
+		}
+	}
+
+	st.Cuisines = groupStats(cuisines)
+	st.Cities = groupStats(cities)
+
+	for _, u := range users {
+		ur := userRatings[u.ID]
+		if ur.Ratings > 0 {
+			ur.AvgRating = roundRating(ur.AvgRating / float64(ur.Ratings))
+		}
+		st.UserRatings = append(st.UserRatings, *ur)
+	}
+
+	end := time.Now().UTC()
+	if to != "" {
+		end, err = time.Parse(statsDateFormat, to)
+		if err != nil {
+			return st, err
+		}
+	}
+	for id, r := range restaurants {
+		r.AvgRating = restaurantRatings[id].avgRating()
+		st.MostVisited = append(st.MostVisited, *r)
+	}
+	sort.Slice(st.MostVisited, func(i, j int) bool {
+		a, b := st.MostVisited[i], st.MostVisited[j]
+		if a.Visits != b.Visits {
+			return a.Visits > b.Visits
+		}
+		return a.Name < b.Name || a.Name == b.Name && a.ID < b.ID
+	})
+	for i := range st.MostVisited {
+		st.MostVisited[i].DaysSinceVisit, err = daysSince(st.MostVisited[i].LastVisit, end)
+		if err != nil {
+			return st, err
+		}
+	}
+	if len(st.MostVisited) > statsListSize {
+		st.MostVisited = st.MostVisited[:statsListSize]
+	}
+
+	for id, r := range favorites {
+		r.AvgRating = favoriteRatings[id].avgRating()
+		if r.AvgRating < favoriteRating {
+			continue
+		}
+		r.DaysSinceVisit, err = daysSince(r.LastVisit, end)
+		if err != nil {
+			return st, err
+		}
+		st.UnvisitedFavorites = append(st.UnvisitedFavorites, *r)
+	}
+	sort.Slice(st.UnvisitedFavorites, func(i, j int) bool {
+		a, b := st.UnvisitedFavorites[i], st.UnvisitedFavorites[j]
+		if a.LastVisit != b.LastVisit {
+			return a.LastVisit < b.LastVisit
+		}
+		return a.Name < b.Name || a.Name == b.Name && a.ID < b.ID
+	})
+	if len(st.UnvisitedFavorites) > statsListSize {
+		st.UnvisitedFavorites = st.UnvisitedFavorites[:statsListSize]
+	}
+	return st, nil
+}
+
+// parseStatsDate checks that a from or to query param is a date like 2006-01-02. It can be empty.
+func parseStatsDate(param string, value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	d, err := time.Parse(statsDateFormat, value)
+	if err != nil {
+		return "", fmt.Errorf("The %s date %s is not valid, it must look like 2006-01-02", param, value)
+	}
+	return d.Format(statsDateFormat), nil
+}
+
+// periodCount returns the count of a period, which is all 0 if there weren't any visits in it.
+func periodCount(counts map[string]*PeriodCount, period string) PeriodCount {
+	if c, ok := counts[period]; ok {
+		return *c
+	}
+	return PeriodCount{Period: period}
+}
+
+// groupStats returns the groups with the most visits first.
+func groupStats(groups map[string]*statsGroup) []GroupStats {
+	gs := []GroupStats{}
+	for name, g := range groups {
+		gs = append(gs, GroupStats{
+			Name:        name,
+			Visits:      g.visits,
+			Restaurants: int64(len(g.restaurants)),
+			Ratings:     g.ratings,
+			AvgRating:   g.avgRating(),
+		})
+	}
+	sort.Slice(gs, func(i, j int) bool {
+		if gs[i].Visits != gs[j].Visits {
+			return gs[i].Visits > gs[j].Visits
+		}
+		return gs[i].Name < gs[j].Name
+	})
+	return gs
+}
+
+func (g *statsGroup) avgRating() float64 {
+	if g.ratings == 0 {
+		return 0
+	}
+	return roundRating(float64(g.ratingSum) / float64(g.ratings))
+}
+
+// roundRating rounds an average rating to 1 decimal place like the repository does.
+func roundRating(r float64) float64 {
+	return math.Round(r*10) / 10
+}
+
+// daysSince returns how many whole days there are from a date like 2006-01-02 to end.
+func daysSince(date string, end time.Time) (int64, error) {
+	d, err := time.Parse(statsDateFormat, date)
+	if err != nil {
+		return 0, err
+	}
+	return int64(end.Sub(d).Hours() / 24), nil
+}
@@ -0,0 +1,178 @@
+package lister
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// agreeWithin is the most two users' ratings of a restaurant can be apart and still agree.
+const agreeWithin = 0.5
+
+// disagreementsSize is how many of the biggest disagreements a TasteComparison has.
+const disagreementsSize = 10
+
+// UserRestaurantRating is a user's average rating of a restaurant. RestaurantAvgRating is the average of every user's
+// ratings of it, which the user's bias is measured against.
+type UserRestaurantRating struct {
+	RestaurantID        int64
+	RestaurantName      string
+	AvgRating           float64
+	Ratings             int64
+	RestaurantAvgRating float64
+}
+
+// UserTaste is how a user rates restaurants.
+type UserTaste struct {
+	User User `json:"user"`
+	// Restaurants is how many restaurants the user rated and AvgRating is the average of their average ratings.
+	Restaurants int64   `json:"restaurants"`
+	AvgRating   float64 `json:"avg_rating"`
+	// Bias is how much higher the user rates restaurants than everyone does on average. It is negative when they are
+	// harsher than everyone else.
+	Bias float64 `json:"bias"`
+}
+
+// RatingDisagreement is a restaurant two users rated differently. Diff is the first user's rating minus the second's.
+type RatingDisagreement struct {
+	RestaurantID   int64   `json:"restaurant_id"`
+	RestaurantName string  `json:"restaurant_name"`
+	Rating1        float64 `json:"rating1"`
+	Rating2        float64 `json:"rating2"`
+	Diff           float64 `json:"diff"`
+}
+
+// TasteComparison compares the ratings of two users on the restaurants they both rated.
+type TasteComparison struct {
+	User1 UserTaste `json:"user1"`
+	User2 UserTaste `json:"user2"`
+	// SharedRestaurants is how many restaurants both users rated.
+	SharedRestaurants int64 `json:"shared_restaurants"`
+	// Correlation is the Pearson correlation of the users' ratings of the shared restaurants, from -1 to 1.
+	// HasCorrelation is false when there aren't enough shared restaurants to work it out or one of the users gave
+	// them all the same rating.
+	Correlation    float64 `json:"correlation"`
+	HasCorrelation bool    `json:"has_correlation"`
+	// AgreementRate is the fraction of the shared restaurants the users rated within half a star of each other.
+	AgreementRate float64 `json:"agreement_rate"`
+	// MeanDiff is the average of the first user's rating minus the second's over the shared restaurants.
+	MeanDiff float64 `json:"mean_diff"`
+	// Disagreements are the shared restaurants the users rated differently, the biggest difference first.
+	Disagreements []RatingDisagreement `json:"disagreements"`
+}
+
+// CompareTastes compares how two different users rate the restaurants they both rated.
+func (s service) CompareTastes(user1ID int64, user2ID int64) (TasteComparison, error) {
+	tc := TasteComparison{Disagreements: []RatingDisagreement{}}
+	if user1ID == user2ID {
+		return tc, fmt.Errorf("Pick two different users to compare")
+	}
+	var ratings [2][]UserRestaurantRating
+	for i, id := range []int64{user1ID, user2ID} {
+		u, err := s.GetUserByID(id)
+		if err != nil {
+			return tc, err
+		}
+		ratings[i], err = s.r.GetUserRestaurantRatings(id)
+		if err != nil {
+			return tc, err
+		}
+		taste := userTaste(u, ratings[i])
+		if i == 0 {
+			tc.User1 = taste
+		} else {
+			tc.User2 = taste
+		}
+	}
+
+	rating2 := make(map[int64]float64)
+	for _, r := range ratings[1] {
+		rating2[r.RestaurantID] = r.AvgRating
+	}
+	var xs, ys []float64
+	var agree int64
+	var diffSum float64
+	for _, r := range ratings[0] {
+		y, ok := rating2[r.RestaurantID]
+		if !ok {
+			continue
+		}
+		xs = append(xs, r.AvgRating)
+		ys = append(ys, y)
+		diff := r.AvgRating - y
+		diffSum += diff
+		if math.Abs(diff) <= agreeWithin {
+			agree++
+		}
+		if roundRating(diff) != 0 {
+			tc.Disagreements = append(tc.Disagreements, RatingDisagreement{
+				RestaurantID:   r.RestaurantID,
+				RestaurantName: r.RestaurantName,
+				Rating1:        roundRating(r.AvgRating),
+				Rating2:        roundRating(y),
+				Diff:           roundRating(diff),
+			})
+		}
+	}
+	tc.SharedRestaurants = int64(len(xs))
+	if tc.SharedRestaurants > 0 {
+		tc.AgreementRate = float64(agree) / float64(tc.SharedRestaurants)
+		tc.MeanDiff = roundRating(diffSum / float64(tc.SharedRestaurants))
+	}
+	tc.Correlation, tc.HasCorrelation = pearson(xs, ys)
+
+	sort.SliceStable(tc.Disagreements, func(i, j int) bool {
+		a, b := math.Abs(tc.Disagreements[i].Diff), math.Abs(tc.Disagreements[j].Diff)
+		if a != b {
+			return a > b
+		}
+		return tc.Disagreements[i].RestaurantName < tc.Disagreements[j].RestaurantName
+	})
+	if len(tc.Disagreements) > disagreementsSize {
+		tc.Disagreements = tc.Disagreements[:disagreementsSize]
+	}
+	return tc, nil
+}
+
+// userTaste works out a user's average rating and bias from their ratings of each restaurant.
+func userTaste(u User, ratings []UserRestaurantRating) UserTaste {
+	t := UserTaste{User: u, Restaurants: int64(len(ratings))}
+	if len(ratings) == 0 {
+		return t
+	}
+	var sum, biasSum float64
+	for _, r := range ratings {
+		sum += r.AvgRating
+		biasSum += r.AvgRating - r.RestaurantAvgRating
+	}
+	t.AvgRating = roundRating(sum / float64(len(ratings)))
+	t.Bias = math.Round(biasSum/float64(len(ratings))*100) / 100
+	return t
+}
+
+// pearson returns the Pearson correlation of xs and ys rounded to 2 decimal places. It is false when it can't be
+// worked out because there are fewer than 2 pairs or either list doesn't vary.
+func pearson(xs []float64, ys []float64) (float64, bool) {
+	n := float64(len(xs))
+	if len(xs) < 2 {
+		return 0, false
+	}
+	var sumX, sumY float64
+	for i := range xs {
+		sumX += xs[i]
+		sumY += ys[i]
+	}
+	meanX, meanY := sumX/n, sumY/n
+	var cov, varX, varY float64
+	for i := range xs {
+		dx, dy := xs[i]-meanX, ys[i]-meanY
+		cov += dx * dy
+		varX += dx * dx
+		varY += dy * dy
+	}
+	// The mean isn't always exact so a list of the same value can be a tiny bit off.
+	if varX < 1e-9 || varY < 1e-9 {
+		return 0, false
+	}
+	return math.Round(cov/math.Sqrt(varX*varY)*100) / 100, true
+}
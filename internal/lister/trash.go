@@ -0,0 +1,63 @@
+package lister
+
+import "time"
+
+// TrashedRestaurant is a restaurant in the trash.
+type TrashedRestaurant struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	CityState CityState `json:"city_state"`
+	DeletedAt string    `json:"deleted_at"`
+}
+
+// TrashedVisit is a visit in the trash.
+type TrashedVisit struct {
+	ID             int64  `json:"id"`
+	RestaurantID   int64  `json:"restaurant_id"`
+	RestaurantName string `json:"restaurant_name"`
+	VisitDateTime  string `json:"visit_datetime"`
+	Note           string `json:"note"`
+	DeletedAt      string `json:"deleted_at"`
+}
+
+// Trash is everything that has been deleted but not purged yet.
+type Trash struct {
+	Restaurants []TrashedRestaurant
+	Visits      []TrashedVisit
+}
+
+// GetTrash returns the restaurants and visits in the trash, most recently deleted first.
+func (s service) GetTrash() (Trash, error) {
+	var t Trash
+	var err error
+	dateFormat := "2006-01-02"
+	t.Restaurants, err = s.r.GetTrashedRestaurants()
+	if err != nil {
+		return t, err
+	}
+	for i, r := range t.Restaurants {
+		deletedAt, err := time.Parse(time.RFC3339, r.DeletedAt)
+		if err != nil {
+			return t, err
+		}
+		t.Restaurants[i].DeletedAt = deletedAt.Format(dateFormat)
+	}
+
+	t.Visits, err = s.r.GetTrashedVisits()
+	if err != nil {
+		return t, err
+	}
+	for i, v := range t.Visits {
+		deletedAt, err := time.Parse(time.RFC3339, v.DeletedAt)
+		if err != nil {
+			return t, err
+		}
+		t.Visits[i].DeletedAt = deletedAt.Format(dateFormat)
+		visitDateTime, err := time.Parse(time.RFC3339, v.VisitDateTime)
+		if err != nil {
+			return t, err
+		}
+		t.Visits[i].VisitDateTime = visitDateTime.Format(dateFormat)
+	}
+	return t, nil
+}
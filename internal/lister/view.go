@@ -0,0 +1,101 @@
+package lister
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/kelvinatorr/restaurant-tracker/internal/storage"
+)
+
+// SavedView is a named filter and sort of the restaurant list. Query is the part of the list's query string it keeps,
+// see ViewQuery.
+type SavedView struct {
+	ID     int64 `json:"id"`
+	UserID int64 `json:"user_id"`
+	// UserFirstName is the first name of the user who owns it.
+	UserFirstName string `json:"user_first_name"`
+	Name          string `json:"name"`
+	Query         string `json:"query"`
+	// Shared is true when every user can use it, not just its owner.
+	Shared bool `json:"shared"`
+	// IsDefault is true when it is the restaurant list its owner sees on the home page.
+	IsDefault bool `json:"is_default"`
+}
+
+// ViewQuery returns the filter, sort and search params of a restaurant list query in a set order, which is what a
+// SavedView keeps. Everything else, like the page, is left out.
+func ViewQuery(qp url.Values) string {
+	kept := make(url.Values)
+	for k, v := range qp {
+		if strings.HasPrefix(k, "filter") || strings.HasPrefix(k, sortSpecParam) || k == "search" {
+			kept[k] = v
+		}
+	}
+	return kept.Encode()
+}
+
+// maxViewQueryLength is the longest query a saved view can have.
+const maxViewQueryLength = 2000
+
+// CheckSavedView checks the name and query of a view and returns the query as it is saved, see ViewQuery.
+func CheckSavedView(name string, query string) (string, error) {
+	if strings.TrimSpace(name) == "" {
+		return "", errors.New("A name is required")
+	}
+	if utf8.RuneCountInString(name) > 100 {
+		return "", errors.New("The name is limited to 100 characters")
+	}
+	qp, err := url.ParseQuery(strings.TrimPrefix(query, "?"))
+	if err != nil {
+		return "", errors.New("The view's query is not valid")
+	}
+	query = ViewQuery(qp)
+	if len(query) > maxViewQueryLength {
+		return "", fmt.Errorf("The view's query is limited to %d characters", maxViewQueryLength)
+	}
+	return query, nil
+}
+
+// GetSavedViews returns the views the user owns and the ones other users share, by name.
+func (s service) GetSavedViews(userID int64) ([]SavedView, error) {
+	return s.r.GetSavedViews(userID)
+}
+
+// GetSavedView returns a view the user owns or that is shared.
+func (s service) GetSavedView(id int64, userID int64) (SavedView, error) {
+	v, err := s.r.GetSavedView(id)
+	if storage.IsNotFound(err) || err == nil && v.UserID != userID && !v.Shared {
+		return SavedView{}, &ErrDoesNotExist{fmt.Sprintf("No saved view with id: %d", id)}
+	}
+	return v, err
+}
+
+// GetDefaultSavedView returns the view the user sees on the home page. Its ID is 0 if the user doesn't have one.
+func (s service) GetDefaultSavedView(userID int64) (SavedView, error) {
+	views, err := s.r.GetSavedViews(userID)
+	if err != nil {
+		return SavedView{}, err
+	}
+	for _, v := range views {
+		if v.UserID == userID && v.IsDefault {
+			return v, nil
+		}
+	}
+	return SavedView{}, nil
+}
+
+// CheckViewQuery returns an error if the filter or sort in a view's query can't be used on the restaurant list.
+func (s service) CheckViewQuery(query string) error {
+	qp, err := url.ParseQuery(strings.TrimPrefix(query, "?"))
+	if err != nil {
+		return fmt.Errorf("The view's query is not valid: %s", err)
+	}
+	if _, err := s.getFilter("restaurant", qp); err != nil {
+		return err
+	}
+	_, err = s.checkSort("restaurant", qp)
+	return err
+}
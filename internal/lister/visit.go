@@ -6,4 +6,9 @@ type Visit struct {
 	VisitDateTime string      `json:"visit_datetime"`
 	Note          string      `json:"note"`
 	VisitUsers    []VisitUser `json:"visit_users"`
+	// RestaurantName, AvgRating and Attendees come from the restaurant and the users at the visit. AvgRating is 0
+	// when nobody rated it.
+	RestaurantName string  `json:"restaurant_name"`
+	AvgRating      float32 `json:"avg_rating"`
+	Attendees      int64   `json:"attendees"`
 }
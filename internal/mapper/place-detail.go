@@ -28,8 +28,8 @@ type placeDetailResult struct {
 	Website              string             `json:"website"`
 	AddressComponents    []addressComponent `json:"address_components"`
 	Geometry             geometry           `json:"geometry"`
-	Address              string
-	ZipCode              string
+	Address              string             `json:"address"`
+	ZipCode              string             `json:"zip_code"`
 }
 
 type PlaceDetail struct {
@@ -1,93 +1,359 @@
 package remover
 
 import (
+	"fmt"
 	"log"
+	"time"
 
+	"github.com/kelvinatorr/restaurant-tracker/internal/audit"
 	"github.com/kelvinatorr/restaurant-tracker/internal/lister"
+	"github.com/kelvinatorr/restaurant-tracker/internal/storage"
 )
 
-// Service provides removing operations.
+// Service provides removing operations. The last int64 each of the first six take is the id of the signed in user who
+// is making the change, which is recorded in the audit log.
 type Service interface {
-	RemoveRestaurant(Restaurant) int64
-	RemoveVisit(Visit) int64
-	RemoveGmapsPlace(int64) int64
+	RemoveRestaurant(Restaurant, int64) (int64, error)
+	RemoveVisit(Visit, int64) (int64, error)
+	RemoveGmapsPlace(int64, int64) (int64, error)
+	RestoreRestaurant(int64, int64) (int64, error)
+	RestoreVisit(int64, int64) (int64, error)
+	RemoveSavedView(int64, int64) (int64, error)
+	RemoveAPIToken(int64, int64) (int64, error)
+	// Purge permanently deletes the restaurants and visits that were moved to the trash before the given time.
+	Purge(time.Time) (int64, error)
+	// RunPurge calls Purge every interval, and once straight away, for things that have been in the trash for longer
+	// than after. It returns when stop is closed.
+	RunPurge(interval time.Duration, after time.Duration, stop <-chan struct{})
+}
+
+// TxRepository provides access to restaurant repository within a transaction.
+type TxRepository interface {
+	RemoveRestaurant(Restaurant) (int64, error)
+	GetRestaurantsByCity(int64) ([]lister.Restaurant, error)
+	GetRestaurant(int64) (lister.Restaurant, error)
+	RemoveCity(int64) (int64, error)
+	RemoveVisit(int64) (int64, error)
+	RemoveGmapsPlace(int64) (int64, error)
+	GetGmapsPlaceRestaurantID(int64) (int64, error)
+	TrashRestaurant(id int64, deletedAt string) (int64, error)
+	TrashVisit(id int64, deletedAt string) (int64, error)
+	RestoreRestaurant(int64) (int64, error)
+	RestoreVisit(int64) (int64, error)
+	GetTrashedRestaurants() ([]lister.TrashedRestaurant, error)
+	GetTrashedVisits() ([]lister.TrashedVisit, error)
+	GetVisit(int64, int64) (lister.Visit, error)
+	AddAuditEntry(audit.Entry) (int64, error)
+	RemoveSavedView(int64) (int64, error)
+	GetSavedView(int64) (lister.SavedView, error)
+	RemoveAPIToken(int64) (int64, error)
+	GetAPIToken(int64) (lister.APIToken, error)
 }
 
 // Repository provides access to restaurant repository.
 type Repository interface {
-	Begin()
-	Commit()
-	Rollback()
-	RemoveRestaurant(Restaurant) int64
-	GetRestaurantsByCity(int64) []lister.Restaurant
-	GetRestaurant(int64) lister.Restaurant
-	RemoveCity(int64) int64
-	RemoveVisit(int64) int64
-	RemoveGmapsPlace(int64) int64
+	TxRepository
+	// WithTx calls fn with a TxRepository bound to a new transaction. The transaction is committed if fn returns nil
+	// and rolled back otherwise.
+	WithTx(fn func(TxRepository) error) error
 }
 
 type service struct {
 	r Repository
 }
 
-func (s service) RemoveRestaurant(r Restaurant) int64 {
-	s.r.Begin()
-	// Defer Rollback just in case thre is a problem.
-	defer s.r.Rollback()
-	// Get the restaurant first so we can get the cityID
-	savedRestaurant := s.r.GetRestaurant(r.ID)
-	if savedRestaurant.ID == 0 {
-		log.Printf("Restaurant id: %d does not exist.\n", r.ID)
-		return 0
+// RemoveRestaurant moves a restaurant and its visits to the trash. They stay there until they are restored or purged.
+func (s service) RemoveRestaurant(r Restaurant, userID int64) (int64, error) {
+	var recordsAffected int64
+	err := s.r.WithTx(func(tx TxRepository) error {
+		// Make sure the restaurant exists and isn't already in the trash.
+		if _, err := tx.GetRestaurant(r.ID); storage.IsNotFound(err) {
+			log.Printf("Restaurant id: %d does not exist.\n", r.ID)
+			return nil
+		} else if err != nil {
+			return err
+		}
+		deletedAt := now()
+		var err error
+		recordsAffected, err = tx.TrashRestaurant(r.ID, deletedAt)
+		if err != nil {
+			return err
+		}
+		log.Printf("Moved Restaurant id: %d to the trash. Records affected: %d\n", r.ID, recordsAffected)
+		return audit.Record(tx, userID, audit.EntityRestaurant, r.ID, r.ID, audit.ActionDelete, deletedAtField(nil),
+			deletedAtField(deletedAt))
+	})
+	if err != nil {
+		return 0, err
 	}
-	cityID := savedRestaurant.CityState.ID
-	// Remove the Restaurant
-	restaurantRecordsAffected := s.r.RemoveRestaurant(r)
-	log.Printf("Removed Restaurant id: %d. Records affected: %d\n", r.ID, restaurantRecordsAffected)
-
-	// Remove city too.
-	cityRecordsAffected := s.removeCity(cityID)
-	s.r.Commit()
-	// Return the total records affected
-	return restaurantRecordsAffected + cityRecordsAffected
+	return recordsAffected, nil
 }
 
-// removeCity removes a city if there are no longer any restaurants referencing it. Caller must call s.r.Commit()
-// otherwise cities won't actually be removed!
-func (s service) removeCity(cityID int64) int64 {
+// now returns the current time the way deleted_at is stored.
+func now() string {
+	return time.Now().UTC().Format(time.RFC3339)
+}
+
+// deletedAtField is what the audit log records for moving something in or out of the trash. A nil deletedAt is out of
+// the trash.
+func deletedAtField(deletedAt interface{}) map[string]interface{} {
+	return map[string]interface{}{"deleted_at": deletedAt}
+}
+
+// removeCity removes a city if there are no longer any restaurants referencing it. The city is only removed once the
+// caller's transaction is committed.
+func removeCity(tx TxRepository, cityID int64) (int64, error) {
 	var recordsAffected int64
 	// Check if there are any restaurants with this cityID
-	countOfRestaurants := len(s.r.GetRestaurantsByCity(cityID))
-	// If there's 1 or none then remove it (the 1 is the restaurant we are currently deleting).
-	if countOfRestaurants <= 1 {
-		recordsAffected = s.r.RemoveCity(cityID)
+	restaurantsInCity, err := tx.GetRestaurantsByCity(cityID)
+	if err != nil {
+		return 0, err
+	}
+	// If there are none then remove it. The restaurant we are deleting has already been removed in this transaction.
+	// Restaurants in the trash still count because they could be restored.
+	if len(restaurantsInCity) == 0 {
+		recordsAffected, err = tx.RemoveCity(cityID)
+		if err != nil {
+			return 0, err
+		}
 		log.Printf("Removed City id: %d. Records affected: %d\n", cityID, recordsAffected)
 	}
 	// Return the number of records affected.
-	return recordsAffected
+	return recordsAffected, nil
 }
 
-func (s service) RemoveVisit(v Visit) int64 {
-	s.r.Begin()
-	// Defer Rollback just in case thre is a problem.
-	defer s.r.Rollback()
+// RemoveVisit moves a visit to the trash.
+func (s service) RemoveVisit(v Visit, userID int64) (int64, error) {
+	var visitRecordsAffected int64
+	err := s.r.WithTx(func(tx TxRepository) error {
+		// Make sure the visit exists and isn't already in the trash.
+		if _, err := tx.GetVisit(v.ID, v.RestaurantID); storage.IsNotFound(err) {
+			log.Printf("Visit id: %d does not exist for Restaurant id: %d.\n", v.ID, v.RestaurantID)
+			return nil
+		} else if err != nil {
+			return err
+		}
+		deletedAt := now()
+		var err error
+		visitRecordsAffected, err = tx.TrashVisit(v.ID, deletedAt)
+		if err != nil {
+			return err
+		}
+		return audit.Record(tx, userID, audit.EntityVisit, v.ID, v.RestaurantID, audit.ActionDelete, deletedAtField(nil),
+			deletedAtField(deletedAt))
+	})
+	if err != nil {
+		return 0, err
+	}
+	// Return the total records affected
+	return visitRecordsAffected, nil
+}
 
-	visitRecordsAffected := s.r.RemoveVisit(v.ID)
+// RestoreRestaurant takes a restaurant and the visits that were deleted with it out of the trash.
+func (s service) RestoreRestaurant(id int64, userID int64) (int64, error) {
+	var recordsAffected int64
+	err := s.r.WithTx(func(tx TxRepository) error {
+		trashedRestaurants, err := tx.GetTrashedRestaurants()
+		if err != nil {
+			return err
+		}
+		for _, r := range trashedRestaurants {
+			if r.ID != id {
+				continue
+			}
+			recordsAffected, err = tx.RestoreRestaurant(id)
+			if err != nil {
+				return err
+			}
+			return audit.Record(tx, userID, audit.EntityRestaurant, id, id, audit.ActionRestore,
+				deletedAtField(r.DeletedAt), deletedAtField(nil))
+		}
+		log.Printf("Restaurant id: %d is not in the trash.\n", id)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return recordsAffected, nil
+}
 
-	s.r.Commit()
-	// Return the total records affected
-	return visitRecordsAffected
+// RestoreVisit takes a visit out of the trash.
+func (s service) RestoreVisit(id int64, userID int64) (int64, error) {
+	var recordsAffected int64
+	err := s.r.WithTx(func(tx TxRepository) error {
+		trashedVisits, err := tx.GetTrashedVisits()
+		if err != nil {
+			return err
+		}
+		for _, v := range trashedVisits {
+			if v.ID != id {
+				continue
+			}
+			recordsAffected, err = tx.RestoreVisit(id)
+			if err != nil {
+				return err
+			}
+			return audit.Record(tx, userID, audit.EntityVisit, id, v.RestaurantID, audit.ActionRestore,
+				deletedAtField(v.DeletedAt), deletedAtField(nil))
+		}
+		log.Printf("Visit id: %d is not in the trash.\n", id)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return recordsAffected, nil
+}
+
+func (s service) Purge(before time.Time) (int64, error) {
+	var recordsAffected int64
+	cutoff := before.UTC().Format(time.RFC3339)
+	err := s.r.WithTx(func(tx TxRepository) error {
+		trashedRestaurants, err := tx.GetTrashedRestaurants()
+		if err != nil {
+			return err
+		}
+		for _, r := range trashedRestaurants {
+			// deleted_at is always UTC RFC3339 so comparing the strings compares the times.
+			if r.DeletedAt >= cutoff {
+				continue
+			}
+			// Deleting the restaurant deletes its visits too.
+			n, err := tx.RemoveRestaurant(Restaurant{ID: r.ID})
+			if err != nil {
+				return err
+			}
+			log.Printf("Purged Restaurant id: %d. Records affected: %d\n", r.ID, n)
+			err = audit.Record(tx, 0, audit.EntityRestaurant, r.ID, r.ID, audit.ActionPurge, r, nil)
+			if err != nil {
+				return err
+			}
+			cityRecordsAffected, err := removeCity(tx, r.CityState.ID)
+			if err != nil {
+				return err
+			}
+			recordsAffected += n + cityRecordsAffected
+		}
+
+		trashedVisits, err := tx.GetTrashedVisits()
+		if err != nil {
+			return err
+		}
+		for _, v := range trashedVisits {
+			if v.DeletedAt >= cutoff {
+				continue
+			}
+			n, err := tx.RemoveVisit(v.ID)
+			if err != nil {
+				return err
+			}
+			log.Printf("Purged Visit id: %d. Records affected: %d\n", v.ID, n)
+			err = audit.Record(tx, 0, audit.EntityVisit, v.ID, v.RestaurantID, audit.ActionPurge, v, nil)
+			if err != nil {
+				return err
+			}
+			recordsAffected += n
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return recordsAffected, nil
 }
 
-func (s service) RemoveGmapsPlace(gpID int64) int64 {
-	s.r.Begin()
-	defer s.r.Rollback()
+func (s service) RunPurge(interval time.Duration, after time.Duration, stop <-chan struct{}) {
+	purge := func() {
+		if _, err := s.Purge(time.Now().Add(-after)); err != nil {
+			log.Println(fmt.Sprintf("Purging the trash failed: %s", err))
+		}
+	}
+	purge()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			purge()
+		case <-stop:
+			return
+		}
+	}
+}
 
-	gmapsPlaceRecordsAffected := s.r.RemoveGmapsPlace(gpID)
+// RemoveGmapsPlace unlinks a restaurant from its Google Maps place by deleting the place. The place is recorded in the
+// restaurant's history.
+func (s service) RemoveGmapsPlace(gpID int64, userID int64) (int64, error) {
+	var gmapsPlaceRecordsAffected int64
+	err := s.r.WithTx(func(tx TxRepository) error {
+		restaurantID, err := tx.GetGmapsPlaceRestaurantID(gpID)
+		if storage.IsNotFound(err) {
+			log.Printf("Google Maps place id: %d does not exist.\n", gpID)
+			return nil
+		} else if err != nil {
+			return err
+		}
+		res, err := tx.GetRestaurant(restaurantID)
+		if err != nil {
+			return err
+		}
+		gmapsPlaceRecordsAffected, err = tx.RemoveGmapsPlace(gpID)
+		if err != nil || gmapsPlaceRecordsAffected == 0 {
+			return err
+		}
+		return audit.Record(tx, userID, audit.EntityGmapsPlace, gpID, restaurantID, audit.ActionDelete,
+			res.GmapsPlace, nil)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return gmapsPlaceRecordsAffected, nil
+}
 
-	s.r.Commit()
-	return gmapsPlaceRecordsAffected
+// RemoveSavedView deletes one of the user's own views. Saved views don't go to the trash.
+func (s service) RemoveSavedView(id int64, userID int64) (int64, error) {
+	var recordsAffected int64
+	err := s.r.WithTx(func(tx TxRepository) error {
+		before, err := tx.GetSavedView(id)
+		if storage.IsNotFound(err) || err == nil && before.UserID != userID {
+			log.Printf("Saved view id: %d does not exist for User id: %d.\n", id, userID)
+			return nil
+		} else if err != nil {
+			return err
+		}
+		recordsAffected, err = tx.RemoveSavedView(id)
+		if err != nil {
+			return err
+		}
+		return audit.Record(tx, userID, audit.EntitySavedView, id, 0, audit.ActionDelete, before, nil)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return recordsAffected, nil
+}
 
+// RemoveAPIToken revokes one of the user's own API tokens by deleting it.
+func (s service) RemoveAPIToken(id int64, userID int64) (int64, error) {
+	var recordsAffected int64
+	err := s.r.WithTx(func(tx TxRepository) error {
+		before, err := tx.GetAPIToken(id)
+		if storage.IsNotFound(err) || err == nil && before.UserID != userID {
+			log.Printf("API token id: %d does not exist for User id: %d.\n", id, userID)
+			return nil
+		} else if err != nil {
+			return err
+		}
+		recordsAffected, err = tx.RemoveAPIToken(id)
+		if err != nil {
+			return err
+		}
+		return audit.Record(tx, userID, audit.EntityAPIToken, id, 0, audit.ActionDelete, before, nil)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return recordsAffected, nil
 }
 
 // NewService returns a new remover.service
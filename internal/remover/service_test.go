@@ -0,0 +1,270 @@
+package remover_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kelvinatorr/restaurant-tracker/internal/adder"
+	"github.com/kelvinatorr/restaurant-tracker/internal/audit"
+	"github.com/kelvinatorr/restaurant-tracker/internal/lister"
+	"github.com/kelvinatorr/restaurant-tracker/internal/mapper"
+	"github.com/kelvinatorr/restaurant-tracker/internal/remover"
+	"github.com/kelvinatorr/restaurant-tracker/internal/storage"
+	"github.com/kelvinatorr/restaurant-tracker/internal/storage/memory"
+)
+
+// fixture is an in-memory storage with two users and a restaurant that one of them has been to.
+type fixture struct {
+	s            *memory.Storage
+	r            remover.Service
+	a            adder.Service
+	userID       int64
+	otherUserID  int64
+	restaurantID int64
+	visitID      int64
+}
+
+// newFixture returns a fixture. Nothing is looked up on Google Maps so the mapper doesn't need a key.
+func newFixture(t *testing.T) fixture {
+	t.Helper()
+	s := memory.NewStorage()
+	f := fixture{s: s, r: remover.NewService(s.Remover()), a: adder.NewService(s.Adder(), mapper.NewService(""))}
+	var err error
+	for _, u := range []struct {
+		id    *int64
+		email string
+	}{{&f.userID, "alex@example.com"}, {&f.otherUserID, "sam@example.com"}} {
+		*u.id, err = f.a.AddUser(adder.User{FirstName: "Alex", LastName: "Rivera", Email: u.email, Password: "pw",
+			RepeatPassword: "pw"}, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	f.restaurantID, err = f.a.AddRestaurant(adder.Restaurant{Name: "Pho Saigon", Cuisine: "Vietnamese",
+		CityState: adder.CityState{Name: "Seattle", State: "WA"}}, f.userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.visitID, err = f.a.AddVisit(adder.Visit{RestaurantID: f.restaurantID, VisitDateTime: "2021-03-04",
+		VisitUsers: []adder.VisitUser{{UserID: f.userID, Rating: 4}}}, f.userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestTrash(t *testing.T) {
+	tests := []struct {
+		name string
+		// remove returns the records it affected.
+		remove          func(f fixture) (int64, error)
+		want            int64
+		wantRestaurants int
+		wantVisits      int
+	}{
+		{"remove restaurant", func(f fixture) (int64, error) {
+			return f.r.RemoveRestaurant(remover.Restaurant{ID: f.restaurantID}, f.userID)
+		}, 1, 0, 0},
+		{"remove missing restaurant", func(f fixture) (int64, error) {
+			return f.r.RemoveRestaurant(remover.Restaurant{ID: f.restaurantID + 1}, f.userID)
+		}, 0, 1, 1},
+		{"remove restaurant twice", func(f fixture) (int64, error) {
+			if _, err := f.r.RemoveRestaurant(remover.Restaurant{ID: f.restaurantID}, f.userID); err != nil {
+				return 0, err
+			}
+			return f.r.RemoveRestaurant(remover.Restaurant{ID: f.restaurantID}, f.userID)
+		}, 0, 0, 0},
+		{"remove visit", func(f fixture) (int64, error) {
+			return f.r.RemoveVisit(remover.Visit{ID: f.visitID, RestaurantID: f.restaurantID}, f.userID)
+		}, 1, 1, 0},
+		{"remove visit of another restaurant", func(f fixture) (int64, error) {
+			return f.r.RemoveVisit(remover.Visit{ID: f.visitID, RestaurantID: f.restaurantID + 1}, f.userID)
+		}, 0, 1, 1},
+		{"restore restaurant", func(f fixture) (int64, error) {
+			if _, err := f.r.RemoveRestaurant(remover.Restaurant{ID: f.restaurantID}, f.userID); err != nil {
+				return 0, err
+			}
+			return f.r.RestoreRestaurant(f.restaurantID, f.userID)
+		}, 1, 1, 1},
+		{"restore restaurant not in the trash", func(f fixture) (int64, error) {
+			return f.r.RestoreRestaurant(f.restaurantID, f.userID)
+		}, 0, 1, 1},
+		{"restore visit", func(f fixture) (int64, error) {
+			if _, err := f.r.RemoveVisit(remover.Visit{ID: f.visitID, RestaurantID: f.restaurantID},
+				f.userID); err != nil {
+				return 0, err
+			}
+			return f.r.RestoreVisit(f.visitID, f.userID)
+		}, 1, 1, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			got, err := tt.remove(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("%d records were affected, want %d", got, tt.want)
+			}
+			restaurants, err := f.s.GetRestaurants(nil, nil, lister.PageOperation{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			visits, err := f.s.GetVisits(nil, nil, lister.PageOperation{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(restaurants) != tt.wantRestaurants || len(visits) != tt.wantVisits {
+				t.Errorf("%d restaurants and %d visits are listed, want %d and %d", len(restaurants), len(visits),
+					tt.wantRestaurants, tt.wantVisits)
+			}
+		})
+	}
+}
+
+func TestPurge(t *testing.T) {
+	f := newFixture(t)
+	if _, err := f.r.RemoveRestaurant(remover.Restaurant{ID: f.restaurantID}, f.userID); err != nil {
+		t.Fatal(err)
+	}
+	cityID, err := f.s.GetCityIDByNameAndState("Seattle", "WA")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing has been in the trash for an hour yet.
+	n, err := f.r.Purge(time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("Purge() of an hour ago removed %d records, want 0", n)
+	}
+
+	// The restaurant and its city, which no other restaurant is in, are deleted for good.
+	if n, err = f.r.Purge(time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("Purge() removed %d records, want 2", n)
+	}
+	if _, err := f.s.GetRestaurant(f.restaurantID); !storage.IsNotFound(err) {
+		t.Errorf("GetRestaurant() of a purged restaurant returned %v, want a not found error", err)
+	}
+	trashed, err := f.s.GetTrashedRestaurants()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trashed) != 0 {
+		t.Errorf("%d restaurants are still in the trash", len(trashed))
+	}
+	if id, _ := f.s.GetCityIDByNameAndState("Seattle", "WA"); id == cityID {
+		t.Error("The city of the purged restaurant wasn't removed")
+	}
+}
+
+// TestOwnership checks that users can only remove their own saved views and API tokens.
+func TestOwnership(t *testing.T) {
+	tests := []struct {
+		name   string
+		add    func(f fixture) (int64, error)
+		remove func(f fixture, id int64, userID int64) (int64, error)
+	}{
+		{"saved view", func(f fixture) (int64, error) {
+			return f.a.AddSavedView(adder.SavedView{Name: "Thai", Query: "filter=cuisine:Thai"}, f.userID)
+		}, func(f fixture, id int64, userID int64) (int64, error) {
+			return f.r.RemoveSavedView(id, userID)
+		}},
+		{"api token", func(f fixture) (int64, error) {
+			id, _, err := f.a.AddAPIToken(adder.APIToken{Name: "backup script"}, f.userID)
+			return id, err
+		}, func(f fixture, id int64, userID int64) (int64, error) {
+			return f.r.RemoveAPIToken(id, userID)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			id, err := tt.add(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, c := range []struct {
+				userID int64
+				want   int64
+			}{{f.otherUserID, 0}, {f.userID, 1}, {f.userID, 0}} {
+				n, err := tt.remove(f, id, c.userID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if n != c.want {
+					t.Errorf("Removing it as user %d affected %d records, want %d", c.userID, n, c.want)
+				}
+			}
+		})
+	}
+}
+
+func TestWithTxRollback(t *testing.T) {
+	f := newFixture(t)
+	errFail := errors.New("fail")
+	err := f.s.Remover().WithTx(func(tx remover.TxRepository) error {
+		if _, err := tx.TrashRestaurant(f.restaurantID, time.Now().UTC().Format(time.RFC3339)); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tx.RemoveVisit(f.visitID); err != nil {
+			t.Fatal(err)
+		}
+		return errFail
+	})
+	if err != errFail {
+		t.Errorf("WithTx() returned %v, want the callback's error", err)
+	}
+	if _, err := f.s.GetRestaurant(f.restaurantID); err != nil {
+		t.Errorf("GetRestaurant() after the failed transaction returned %v", err)
+	}
+	if _, err := f.s.GetVisit(f.visitID, f.restaurantID); err != nil {
+		t.Errorf("GetVisit() after the failed transaction returned %v", err)
+	}
+}
+
+func TestRemoveGmapsPlace(t *testing.T) {
+	f := newFixture(t)
+	gpID, err := f.s.Adder().AddGmapsPlace(adder.GmapsPlace{PlaceID: "abc", Name: "Pho Saigon",
+		RestaurantID: f.restaurantID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := f.r.RemoveGmapsPlace(gpID, f.userID); err != nil || n != 1 {
+		t.Fatalf("RemoveGmapsPlace() returned %d, %v", n, err)
+	}
+	if n, err := f.r.RemoveGmapsPlace(gpID, f.userID); err != nil || n != 0 {
+		t.Errorf("RemoveGmapsPlace() of a removed place returned %d, %v", n, err)
+	}
+
+	// The removal is in the restaurant's history with what the place was.
+	entries, err := f.s.GetRestaurantAuditLog(f.restaurantID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found bool
+	for _, e := range entries {
+		if e.EntityType != audit.EntityGmapsPlace {
+			continue
+		}
+		found = true
+		var diff map[string]audit.Change
+		if err := json.Unmarshal(e.Diff, &diff); err != nil {
+			t.Fatal(err)
+		}
+		if e.Action != audit.ActionDelete || e.EntityID != gpID || diff["place_id"].Before != "abc" {
+			t.Errorf("The removal was recorded as %s of %d with %s", e.Action, e.EntityID, e.Diff)
+		}
+	}
+	if !found {
+		t.Error("The removal isn't in the restaurant's history")
+	}
+}
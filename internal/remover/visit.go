@@ -1,5 +1,6 @@
 package remover
 
 type Visit struct {
-	ID int64 `json:"id"`
+	ID           int64 `json:"id"`
+	RestaurantID int64 `json:"restaurant_id"`
 }
@@ -0,0 +1,61 @@
+// Package storage holds what is shared by every storage implementation, so the services can tell what went wrong
+// without depending on a particular database.
+package storage
+
+import "errors"
+
+// ErrNotFound is returned when the requested record is not in the repository.
+type ErrNotFound struct {
+	Msg string
+}
+
+func (e *ErrNotFound) Error() string {
+	return e.Msg
+}
+
+// ErrUniqueViolation is returned when a write would duplicate a value that must be unique, e.g. a user's email.
+type ErrUniqueViolation struct {
+	Msg string
+	Err error
+}
+
+func (e *ErrUniqueViolation) Error() string {
+	return e.Msg
+}
+
+func (e *ErrUniqueViolation) Unwrap() error {
+	return e.Err
+}
+
+// ErrConstraintViolation is returned when a write breaks a CHECK, NOT NULL or foreign key constraint, e.g. a rating
+// that is out of range.
+type ErrConstraintViolation struct {
+	Msg string
+	Err error
+}
+
+func (e *ErrConstraintViolation) Error() string {
+	return e.Msg
+}
+
+func (e *ErrConstraintViolation) Unwrap() error {
+	return e.Err
+}
+
+// IsNotFound returns true if err is, or wraps, an ErrNotFound
+func IsNotFound(err error) bool {
+	var e *ErrNotFound
+	return errors.As(err, &e)
+}
+
+// IsUniqueViolation returns true if err is, or wraps, an ErrUniqueViolation
+func IsUniqueViolation(err error) bool {
+	var e *ErrUniqueViolation
+	return errors.As(err, &e)
+}
+
+// IsConstraintViolation returns true if err is, or wraps, an ErrConstraintViolation
+func IsConstraintViolation(err error) bool {
+	var e *ErrConstraintViolation
+	return errors.As(err, &e)
+}
@@ -0,0 +1,155 @@
+package memory
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/kelvinatorr/restaurant-tracker/internal/adder"
+	"github.com/kelvinatorr/restaurant-tracker/internal/auther"
+	"github.com/kelvinatorr/restaurant-tracker/internal/lister"
+	"github.com/kelvinatorr/restaurant-tracker/internal/storage"
+)
+
+type apiToken struct {
+	id         int64
+	userID     int64
+	name       string
+	tokenHash  string
+	readOnly   bool
+	createdAt  string
+	lastUsedAt string // Empty until it is used.
+	expiresAt  string // Empty if it never expires.
+}
+
+func apiTokenToLister(t apiToken) lister.APIToken {
+	return lister.APIToken{
+		ID:         t.id,
+		UserID:     t.userID,
+		Name:       t.name,
+		ReadOnly:   t.readOnly,
+		CreatedAt:  t.createdAt,
+		LastUsedAt: t.lastUsedAt,
+		ExpiresAt:  t.expiresAt,
+	}
+}
+
+// checkAPIToken enforces the foreign key and unique indexes of the api_token table for t.
+func (d *data) checkAPIToken(t apiToken) error {
+	if _, ok := d.users[t.userID]; !ok {
+		return foreignKeyViolation()
+	}
+	for _, other := range d.apiTokens {
+		if other.id == t.id {
+			continue
+		}
+		if other.tokenHash == t.tokenHash {
+			return uniqueViolation("api_token.token_hash")
+		}
+		if other.userID == t.userID && other.name == t.name {
+			return uniqueViolation("api_token.user_id, api_token.name")
+		}
+	}
+	return nil
+}
+
+// AddAPIToken saves an API token and returns its primary key id.
+func (s Storage) AddAPIToken(t adder.APIToken) (int64, error) {
+	var id int64
+	err := s.write(func(d *data) error {
+		saved := apiToken{
+			id:        d.nextID("api_token"),
+			userID:    t.UserID,
+			name:      t.Name,
+			tokenHash: t.TokenHash,
+			readOnly:  t.ReadOnly,
+			createdAt: t.CreatedAt,
+			expiresAt: t.ExpiresAt,
+		}
+		if err := d.checkAPIToken(saved); err != nil {
+			return err
+		}
+		id = saved.id
+		d.apiTokens[id] = saved
+		return nil
+	})
+	return id, err
+}
+
+// RemoveAPIToken deletes a given API token and returns the rows affected.
+func (s Storage) RemoveAPIToken(id int64) (int64, error) {
+	var recordsAffected int64
+	err := s.write(func(d *data) error {
+		if _, ok := d.apiTokens[id]; ok {
+			delete(d.apiTokens, id)
+			recordsAffected = 1
+		}
+		return nil
+	})
+	return recordsAffected, err
+}
+
+// GetAPIToken returns the API token with the given id. Returns a storage.ErrNotFound if there isn't one.
+func (s Storage) GetAPIToken(id int64) (lister.APIToken, error) {
+	var t lister.APIToken
+	err := s.read(func(d *data) error {
+		saved, ok := d.apiTokens[id]
+		if !ok {
+			return &storage.ErrNotFound{Msg: fmt.Sprintf("No API token with id: %d", id)}
+		}
+		t = apiTokenToLister(saved)
+		return nil
+	})
+	return t, err
+}
+
+// GetAPITokens returns the user's API tokens, newest first.
+func (s Storage) GetAPITokens(userID int64) ([]lister.APIToken, error) {
+	var allTokens []lister.APIToken
+	err := s.read(func(d *data) error {
+		for _, t := range d.apiTokens {
+			if t.userID == userID {
+				allTokens = append(allTokens, apiTokenToLister(t))
+			}
+		}
+		sort.Slice(allTokens, func(i, j int) bool { return allTokens[i].ID > allTokens[j].ID })
+		return nil
+	})
+	return allTokens, err
+}
+
+// GetAPITokenByHash returns the API token with the given hash. Returns a storage.ErrNotFound if there isn't one.
+func (s Storage) GetAPITokenByHash(hash string) (auther.APIToken, error) {
+	var t auther.APIToken
+	err := s.read(func(d *data) error {
+		for _, saved := range d.apiTokens {
+			if saved.tokenHash == hash {
+				t = auther.APIToken{
+					ID:         saved.id,
+					UserID:     saved.userID,
+					ReadOnly:   saved.readOnly,
+					ExpiresAt:  saved.expiresAt,
+					LastUsedAt: saved.lastUsedAt,
+				}
+				return nil
+			}
+		}
+		return &storage.ErrNotFound{Msg: "No API token with this hash"}
+	})
+	return t, err
+}
+
+// UpdateAPITokenLastUsed saves when an API token was last used, returns the rows affected.
+func (s Storage) UpdateAPITokenLastUsed(id int64, lastUsedAt string) (int64, error) {
+	var recordsAffected int64
+	err := s.write(func(d *data) error {
+		t, ok := d.apiTokens[id]
+		if !ok {
+			return nil
+		}
+		t.lastUsedAt = lastUsedAt
+		d.apiTokens[id] = t
+		recordsAffected = 1
+		return nil
+	})
+	return recordsAffected, err
+}
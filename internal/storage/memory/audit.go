@@ -0,0 +1,68 @@
+package memory
+
+import (
+	"github.com/kelvinatorr/restaurant-tracker/internal/audit"
+	"github.com/kelvinatorr/restaurant-tracker/internal/lister"
+)
+
+type auditEntry struct {
+	id int64
+	audit.Entry
+}
+
+// AddAuditEntry adds an entry to the audit log and returns its primary key id.
+func (s Storage) AddAuditEntry(e audit.Entry) (int64, error) {
+	var id int64
+	err := s.write(func(d *data) error {
+		if _, ok := d.users[e.UserID]; e.UserID != 0 && !ok {
+			return foreignKeyViolation()
+		}
+		id = d.nextID("audit_log")
+		d.auditLog = append(d.auditLog, auditEntry{id: id, Entry: e})
+		return nil
+	})
+	return id, err
+}
+
+// GetAuditLog returns the changes to an entity, newest first.
+func (s Storage) GetAuditLog(entityType string, entityID int64) ([]lister.AuditEntry, error) {
+	return s.getAuditLog(func(e auditEntry) bool {
+		return e.EntityType == entityType && e.EntityID == entityID
+	})
+}
+
+// GetRestaurantAuditLog returns the changes to a restaurant and everything that belongs to it, newest first.
+func (s Storage) GetRestaurantAuditLog(restaurantID int64) ([]lister.AuditEntry, error) {
+	return s.getAuditLog(func(e auditEntry) bool {
+		return e.RestaurantID != 0 && e.RestaurantID == restaurantID
+	})
+}
+
+func (s Storage) getAuditLog(match func(auditEntry) bool) ([]lister.AuditEntry, error) {
+	var allEntries []lister.AuditEntry
+	err := s.read(func(d *data) error {
+		// Entries are appended in id order so walk backwards for newest first.
+		for i := len(d.auditLog) - 1; i >= 0; i-- {
+			e := d.auditLog[i]
+			if !match(e) {
+				continue
+			}
+			entry := lister.AuditEntry{
+				ID:           e.id,
+				UserID:       e.UserID,
+				EntityType:   e.EntityType,
+				EntityID:     e.EntityID,
+				RestaurantID: e.RestaurantID,
+				Action:       e.Action,
+				CreatedAt:    e.CreatedAt,
+				Diff:         []byte(e.Diff),
+			}
+			if u, ok := d.users[e.UserID]; ok {
+				entry.UserName = u.firstName + " " + u.lastName
+			}
+			allEntries = append(allEntries, entry)
+		}
+		return nil
+	})
+	return allEntries, err
+}
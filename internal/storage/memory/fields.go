@@ -0,0 +1,64 @@
+package memory
+
+import "github.com/kelvinatorr/restaurant-tracker/internal/lister"
+
+// The field names are only ever used by this package so they are just the column names of restaurantRow and visitRow.
+
+func (s Storage) RestaurantSortFields() map[string]string {
+	restaurantFields := make(map[string]string)
+	restaurantFields["name"] = "name"
+	restaurantFields["cuisine"] = "cuisine"
+	restaurantFields["city"] = "city_name"
+	restaurantFields["state"] = "state_name"
+	restaurantFields["last_visit"] = "last_visit_datetime"
+	restaurantFields["avg_rating"] = "avg_rating"
+	restaurantFields["distance"] = "distance"
+	restaurantFields["price_level"] = "price_level"
+	restaurantFields["gmaps_rating"] = "gmaps_rating"
+	restaurantFields["gmaps_ratings_total"] = "gmaps_ratings_total"
+	restaurantFields["gmaps_business_status"] = "gmaps_business_status"
+	restaurantFields["rating_diff"] = "rating_diff"
+	restaurantFields["id"] = "id"
+	return restaurantFields
+}
+
+func (s Storage) RestaurantFilterFields() map[string]lister.Field {
+	restaurantFields := make(map[string]lister.Field)
+	restaurantFields["name"] = lister.Field{Name: "name", Type: lister.FieldText}
+	restaurantFields["cuisine"] = lister.Field{Name: "cuisine", Type: lister.FieldText}
+	restaurantFields["city"] = lister.Field{Name: "city_name", Type: lister.FieldText}
+	restaurantFields["state"] = lister.Field{Name: "state_name", Type: lister.FieldText}
+	restaurantFields["last_visit"] = lister.Field{Name: "last_visit", Type: lister.FieldDate}
+	restaurantFields["avg_rating"] = lister.Field{Name: "avg_rating", Type: lister.FieldReal}
+	restaurantFields["business_status"] = lister.Field{Name: "business_status", Type: lister.FieldInt}
+	restaurantFields["location"] = lister.Field{Name: "location", Type: lister.FieldGeo}
+	// The Google Maps fields are NULL when they aren't known, unlike the sort fields which are coalesced.
+	restaurantFields["price_level"] = lister.Field{Name: "gp.price_level", Type: lister.FieldInt}
+	restaurantFields["gmaps_rating"] = lister.Field{Name: "gp.rating", Type: lister.FieldReal}
+	restaurantFields["gmaps_ratings_total"] = lister.Field{Name: "gp.user_ratings_total", Type: lister.FieldInt}
+	restaurantFields["gmaps_business_status"] = lister.Field{Name: "gp.business_status", Type: lister.FieldText}
+	restaurantFields["rating_diff"] = lister.Field{Name: "gp.rating_diff", Type: lister.FieldReal}
+	return restaurantFields
+}
+
+func (s Storage) VisitSortFields() map[string]string {
+	visitFields := make(map[string]string)
+	visitFields["date"] = "visit_datetime"
+	visitFields["rating"] = "avg_rating"
+	visitFields["attendees"] = "attendees"
+	visitFields["restaurant"] = "restaurant_name"
+	visitFields["id"] = "id"
+	return visitFields
+}
+
+func (s Storage) VisitFilterFields() map[string]lister.Field {
+	visitFields := make(map[string]lister.Field)
+	visitFields["date"] = lister.Field{Name: "visit_datetime", Type: lister.FieldDate}
+	visitFields["user"] = lister.Field{Name: "user_ids", Type: lister.FieldIDSet}
+	visitFields["rating"] = lister.Field{Name: "rating", Type: lister.FieldReal}
+	visitFields["attendees"] = lister.Field{Name: "attendees", Type: lister.FieldInt}
+	visitFields["has_note"] = lister.Field{Name: "has_note", Type: lister.FieldInt}
+	visitFields["restaurant"] = lister.Field{Name: "restaurant_name", Type: lister.FieldText}
+	visitFields["restaurant_id"] = lister.Field{Name: "restaurant_id", Type: lister.FieldInt}
+	return visitFields
+}
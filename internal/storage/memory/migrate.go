@@ -0,0 +1,21 @@
+package memory
+
+import "github.com/kelvinatorr/restaurant-tracker/internal/storage"
+
+// The data is created in its latest shape so there is no schema to migrate. These are here so Storage can be used like
+// the database backed ones.
+
+// LatestSchemaVersion always returns 0.
+func (s Storage) LatestSchemaVersion() int {
+	return 0
+}
+
+// SchemaVersion always returns 0.
+func (s Storage) SchemaVersion() (int, error) {
+	return 0, nil
+}
+
+// Migrate does nothing and never has migrations to apply.
+func (s Storage) Migrate(dryRun bool) ([]storage.Migration, error) {
+	return nil, nil
+}
@@ -0,0 +1,434 @@
+package memory
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kelvinatorr/restaurant-tracker/internal/lister"
+)
+
+// equalFold compares strings the way upper(a) = upper(b) does in sqlite, which only folds ASCII letters.
+func equalFold(a string, b string) bool {
+	return asciiUpper(a) == asciiUpper(b)
+}
+
+func asciiUpper(s string) string {
+	return strings.Map(func(r rune) rune {
+		if 'a' <= r && r <= 'z' {
+			return r - 'a' + 'A'
+		}
+		return r
+	}, s)
+}
+
+// filterRow is a row that can be filtered.
+type filterRow interface {
+	column(name string) (interface{}, error)
+}
+
+// column returns the value of a restaurant column by the name used in RestaurantFilterFields and
+// RestaurantSortFields. NULL is returned as nil, TEXT as a string and numbers as a float64.
+func (row restaurantRow) column(name string) (interface{}, error) {
+	switch name {
+	case "name":
+		return row.Name, nil
+	case "cuisine":
+		return row.Cuisine, nil
+	case "city_name":
+		return row.CityState.Name, nil
+	case "state_name":
+		return row.CityState.State, nil
+	case "last_visit":
+		if row.lastVisit == nil {
+			return nil, nil
+		}
+		return *row.lastVisit, nil
+	case "last_visit_datetime":
+		return row.LastVisitDatetime, nil
+	case "avg_rating":
+		// Coalesced to 0 like in the sqlite query.
+		if row.avgRating == nil {
+			return float64(0), nil
+		}
+		return *row.avgRating, nil
+	case "business_status":
+		return float64(row.BusinessStatus), nil
+	case "location":
+		// A latitude and longitude of 0 are stored as NULL by the sqlite query.
+		if row.Latitude == 0 || row.Longitude == 0 {
+			return nil, nil
+		}
+		return lister.GeoPoint{Lat: float64(row.Latitude), Lng: float64(row.Longitude)}, nil
+	case "distance":
+		return row.Distance, nil
+	case "price_level":
+		return float64(row.GmapsPlace.PriceLevel), nil
+	case "gmaps_rating":
+		return roundRating(row.GmapsPlace.Rating), nil
+	case "gmaps_ratings_total":
+		return float64(row.GmapsPlace.UserRatingsTotal), nil
+	case "gmaps_business_status":
+		return row.GmapsPlace.BusinessStatus, nil
+	case "rating_diff":
+		return row.RatingDiff, nil
+	// The sqlite query stores the Google Maps fields that are 0 or empty as NULL.
+	case "gp.price_level":
+		return nullIfZero(float64(row.GmapsPlace.PriceLevel)), nil
+	case "gp.rating":
+		return nullIfZero(roundRating(row.GmapsPlace.Rating)), nil
+	case "gp.user_ratings_total":
+		return nullIfZero(float64(row.GmapsPlace.UserRatingsTotal)), nil
+	case "gp.business_status":
+		if row.GmapsPlace.BusinessStatus == "" {
+			return nil, nil
+		}
+		return row.GmapsPlace.BusinessStatus, nil
+	case "gp.rating_diff":
+		if row.ratingDiff == nil {
+			return nil, nil
+		}
+		return *row.ratingDiff, nil
+	case "id":
+		return float64(row.ID), nil
+	default:
+		return nil, fmt.Errorf("no such column: %s", name)
+	}
+}
+
+// roundRating rounds r to 1 decimal place like the sqlite query does.
+func roundRating(r float32) float64 {
+	return math.Round(float64(r)*10) / 10
+}
+
+// nullIfZero returns nil for 0 and f otherwise.
+func nullIfZero(f float64) interface{} {
+	if f == 0 {
+		return nil
+	}
+	return f
+}
+
+// cast converts a filter value to fieldType like CAST($1 as fieldType) does. Values that aren't numbers are 0.
+func cast(value string, fieldType string) interface{} {
+	switch fieldType {
+	case lister.FieldReal:
+		f, _ := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		return f
+	case lister.FieldInt:
+		f, _ := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		return float64(int64(f))
+	default:
+		return value
+	}
+}
+
+// compare returns -1, 0 or 1 when a is less than, equal to or greater than b. Numbers sort before text like in sqlite.
+func compare(a interface{}, b interface{}) int {
+	switch a := a.(type) {
+	case float64:
+		b, ok := b.(float64)
+		switch {
+		case !ok:
+			return -1
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		default:
+			return 0
+		}
+	case string:
+		b, ok := b.(string)
+		if !ok {
+			return 1
+		}
+		return strings.Compare(a, b)
+	default:
+		return 0
+	}
+}
+
+// matchesFilter returns true if the filter operation is true for the row.
+func matchesFilter(row filterRow, fo lister.FilterOperation) (bool, error) {
+	col, err := row.column(fo.Field)
+	if err != nil {
+		return false, err
+	}
+
+	if fo.FieldType == lister.FieldGeo {
+		// Restaurants without a location aren't near anything.
+		p, ok := col.(lister.GeoPoint)
+		if !ok {
+			return false, nil
+		}
+		n, err := lister.NearFilter(fo)
+		if err != nil {
+			return false, err
+		}
+		minLat, maxLat, minLng, maxLng := n.BoundingBox()
+		if p.Lat < minLat || p.Lat > maxLat || p.Lng < minLng || p.Lng > maxLng {
+			return false, nil
+		}
+		return lister.DistanceKm(p, n.Origin) <= n.Km, nil
+	}
+
+	if fo.FieldType == lister.FieldIDSet {
+		ids, _ := col.([]int64)
+		has := func(v string) bool {
+			id, _ := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+			for _, i := range ids {
+				if i == id {
+					return true
+				}
+			}
+			return false
+		}
+		switch fo.Operator {
+		case "!=":
+			return !has(fo.Value), nil
+		case "in":
+			for _, v := range fo.Values {
+				if has(v) {
+					return true, nil
+				}
+			}
+			return false, nil
+		default:
+			return has(fo.Value), nil
+		}
+	}
+
+	if fo.Operator == "is" || fo.Operator == "is not" {
+		// is compares NULLs as equal instead of making the result NULL.
+		var isEqual bool
+		if fo.Value == "NULL" || col == nil {
+			isEqual = fo.Value == "NULL" && col == nil
+		} else {
+			isEqual = compare(col, cast(fo.Value, fo.FieldType)) == 0
+		}
+		return isEqual == (fo.Operator == "is"), nil
+	}
+
+	// Comparing anything to NULL is NULL which isn't true.
+	if col == nil {
+		return false, nil
+	}
+	switch fo.Operator {
+	case "contains", "starts with":
+		// Like LIKE in sqlite which ignores the case of ASCII letters.
+		text, ok := col.(string)
+		if !ok {
+			return false, nil
+		}
+		if fo.Operator == "contains" {
+			return strings.Contains(asciiUpper(text), asciiUpper(fo.Value)), nil
+		}
+		return strings.HasPrefix(asciiUpper(text), asciiUpper(fo.Value)), nil
+	case "in":
+		for _, v := range fo.Values {
+			if v != "NULL" && compare(col, cast(v, fo.FieldType)) == 0 {
+				return true, nil
+			}
+		}
+		return false, nil
+	case "between":
+		if fo.Values[0] == "NULL" || fo.Values[1] == "NULL" {
+			return false, nil
+		}
+		return compare(col, cast(fo.Values[0], fo.FieldType)) >= 0 && compare(col, cast(fo.Values[1], fo.FieldType)) <= 0,
+			nil
+	}
+	if fo.Value == "NULL" {
+		return false, nil
+	}
+	c := compare(col, cast(fo.Value, fo.FieldType))
+	switch fo.Operator {
+	case "=":
+		return c == 0, nil
+	case "<":
+		return c < 0, nil
+	case ">":
+		return c > 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">=":
+		return c >= 0, nil
+	case "!=":
+		return c != 0, nil
+	default:
+		return false, fmt.Errorf("Bad filter operator: %s", fo.Operator)
+	}
+}
+
+// matchesFilterExpr returns true if the filter expression is true for the row. A nil expression is always true.
+func matchesFilterExpr(row filterRow, expr lister.FilterExpr) (bool, error) {
+	switch e := expr.(type) {
+	case nil:
+		return true, nil
+	case lister.FilterOperation:
+		return matchesFilter(row, e)
+	case lister.FilterNot:
+		match, err := matchesFilterExpr(row, e.Expr)
+		return !match, err
+	case lister.FilterAnd:
+		for _, c := range e {
+			match, err := matchesFilterExpr(row, c)
+			if err != nil || !match {
+				return false, err
+			}
+		}
+		return true, nil
+	case lister.FilterOr:
+		for _, c := range e {
+			match, err := matchesFilterExpr(row, c)
+			if err != nil || match {
+				return match, err
+			}
+		}
+		return false, nil
+	default:
+		return false, fmt.Errorf("Unknown filter expression %T", expr)
+	}
+}
+
+// sortRows sorts n rows by the sort operations in order. column returns the value of a row's column. Rows that are
+// equal on every sort field keep their order.
+func sortRows(n int, sortOps []lister.SortOperation, column func(i int, name string) (interface{}, error),
+	swap func(i int, j int)) error {
+	for _, so := range sortOps {
+		if so.Direction != "asc" && so.Direction != "desc" {
+			return fmt.Errorf("Bad sort direction")
+		}
+		// Check the columns up front so less doesn't have to handle errors.
+		if n > 0 {
+			if _, err := column(0, so.Field); err != nil {
+				return err
+			}
+		}
+	}
+	less := func(i int, j int) bool {
+		for _, so := range sortOps {
+			a, _ := column(i, so.Field)
+			b, _ := column(j, so.Field)
+			c := compare(a, b)
+			if so.Direction == "desc" {
+				c = -c
+			}
+			if c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	}
+	sort.Stable(sorter{n: n, less: less, swap: swap})
+	return nil
+}
+
+type sorter struct {
+	n    int
+	less func(i int, j int) bool
+	swap func(i int, j int)
+}
+
+func (s sorter) Len() int           { return s.n }
+func (s sorter) Less(i, j int) bool { return s.less(i, j) }
+func (s sorter) Swap(i, j int)      { s.swap(i, j) }
+
+func sortRestaurants(rows []restaurantRow, sortOps []lister.SortOperation) error {
+	return sortRows(len(rows), sortOps, func(i int, name string) (interface{}, error) {
+		return rows[i].column(name)
+	}, func(i int, j int) {
+		rows[i], rows[j] = rows[j], rows[i]
+	})
+}
+
+// column returns the value of a visit column by the name used in VisitFilterFields and VisitSortFields, the same way
+// restaurantRow.column does.
+func (row visitRow) column(name string) (interface{}, error) {
+	switch name {
+	case "visit_datetime":
+		return row.VisitDateTime, nil
+	case "user_ids":
+		return row.userIDs, nil
+	case "rating":
+		if row.avgRating == nil {
+			return nil, nil
+		}
+		return *row.avgRating, nil
+	case "avg_rating":
+		// Coalesced to 0 like in the sqlite query.
+		if row.avgRating == nil {
+			return float64(0), nil
+		}
+		return *row.avgRating, nil
+	case "attendees":
+		return float64(row.Attendees), nil
+	case "has_note":
+		if row.Note == "" {
+			return float64(0), nil
+		}
+		return float64(1), nil
+	case "restaurant_name":
+		return row.RestaurantName, nil
+	case "restaurant_id":
+		return float64(row.RestaurantID), nil
+	case "id":
+		return float64(row.ID), nil
+	default:
+		return nil, fmt.Errorf("no such column: %s", name)
+	}
+}
+
+func sortVisits(rows []visitRow, sortOps []lister.SortOperation) error {
+	return sortRows(len(rows), sortOps, func(i int, name string) (interface{}, error) {
+		return rows[i].column(name)
+	}, func(i int, j int) {
+		rows[i], rows[j] = rows[j], rows[i]
+	})
+}
+
+// pageRows returns the indexes of the n sorted rows that are on the page, which are the first page.Limit rows that sort
+// after page.After.
+func pageRows(n int, sortOps []lister.SortOperation, page lister.PageOperation,
+	column func(i int, name string) (interface{}, error)) ([]int, error) {
+	var indexes []int
+	for i := 0; i < n; i++ {
+		if page.Limit > 0 && len(indexes) == page.Limit {
+			break
+		}
+		if len(page.After) == len(sortOps) && len(sortOps) > 0 {
+			after, err := sortsAfter(i, sortOps, page.After, column)
+			if err != nil {
+				return indexes, err
+			}
+			if !after {
+				continue
+			}
+		}
+		indexes = append(indexes, i)
+	}
+	return indexes, nil
+}
+
+// sortsAfter returns true if row i comes after the values in the order of the sort operations.
+func sortsAfter(i int, sortOps []lister.SortOperation, values []interface{},
+	column func(i int, name string) (interface{}, error)) (bool, error) {
+	for j, so := range sortOps {
+		col, err := column(i, so.Field)
+		if err != nil {
+			return false, err
+		}
+		c := compare(col, values[j])
+		if so.Direction == "desc" {
+			c = -c
+		}
+		if c != 0 {
+			return c > 0, nil
+		}
+	}
+	// Equal to the values so it is the row the cursor points at.
+	return false, nil
+}
@@ -0,0 +1,149 @@
+package memory
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/kelvinatorr/restaurant-tracker/internal/lister"
+)
+
+// snippetWords is how many words a search snippet has.
+const snippetWords = 12
+
+// searchColumn is a column of the search index and how much a match in it counts towards the rank.
+type searchColumn struct {
+	text   string
+	weight float64
+}
+
+// searchColumns returns the text of a restaurant that is searched, in the same columns the sqlite index has.
+func (d *data) searchColumns(res restaurant) []searchColumn {
+	c := d.cities[res.cityID]
+	var visitNotes []string
+	for _, id := range d.visitIDs() {
+		if v := d.visits[id]; v.restaurantID == res.id && v.note != "" && v.deletedAt == "" {
+			visitNotes = append(visitNotes, v.note)
+		}
+	}
+	return []searchColumn{
+		{text: res.name, weight: 10},
+		{text: res.cuisine, weight: 4},
+		{text: res.note, weight: 1},
+		{text: c.name + " " + c.state, weight: 4},
+		{text: strings.Join(visitNotes, " "), weight: 1},
+	}
+}
+
+// searchTokens splits text into lower case words made of letters and numbers.
+func searchTokens(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// matchesTerm returns true if any word in word starts with one of the terms. word can have punctuation in it.
+func matchesTerm(word string, terms []string) bool {
+	for _, token := range searchTokens(word) {
+		for _, t := range terms {
+			if strings.HasPrefix(token, t) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// snippet returns about snippetWords words of text starting a little before the first match with the matches marked.
+func snippet(text string, terms []string) string {
+	words := strings.Fields(text)
+	first := 0
+	for i, w := range words {
+		if matchesTerm(w, terms) {
+			first = i
+			break
+		}
+	}
+	start := first - snippetWords/4
+	if start < 0 {
+		start = 0
+	}
+	end := start + snippetWords
+	if end > len(words) {
+		end = len(words)
+	}
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("...")
+	}
+	for i := start; i < end; i++ {
+		if i > start {
+			b.WriteString(" ")
+		}
+		if matchesTerm(words[i], terms) {
+			b.WriteString(lister.SearchMatchStart + words[i] + lister.SearchMatchEnd)
+		} else {
+			b.WriteString(words[i])
+		}
+	}
+	if end < len(words) {
+		b.WriteString("...")
+	}
+	return b.String()
+}
+
+// SearchRestaurants finds the restaurants that have every term as a word prefix in one of their columns. Each matching
+// word adds its column's weight to the rank. The snippet is taken from the column that matches the most terms.
+func (s Storage) SearchRestaurants(terms []string, limit int) ([]lister.SearchMatch, error) {
+	var allMatches []lister.SearchMatch
+	err := s.read(func(d *data) error {
+		for _, id := range d.restaurantIDs() {
+			if d.restaurants[id].deletedAt != "" {
+				continue
+			}
+			columns := d.searchColumns(d.restaurants[id])
+			var rank float64
+			bestColumn, bestTerms := 0, 0
+			termFound := make(map[string]bool)
+			for i, c := range columns {
+				columnTerms := make(map[string]bool)
+				for _, token := range searchTokens(c.text) {
+					for _, t := range terms {
+						if strings.HasPrefix(token, t) {
+							rank += c.weight
+							columnTerms[t] = true
+							termFound[t] = true
+						}
+					}
+				}
+				if len(columnTerms) > bestTerms {
+					bestColumn, bestTerms = i, len(columnTerms)
+				}
+			}
+			if len(termFound) < len(uniqueStrings(terms)) {
+				continue
+			}
+			allMatches = append(allMatches, lister.SearchMatch{
+				RestaurantID: id,
+				Snippet:      snippet(columns[bestColumn].text, terms),
+				Rank:         rank,
+			})
+		}
+		sort.SliceStable(allMatches, func(i, j int) bool {
+			return allMatches[i].Rank > allMatches[j].Rank
+		})
+		if len(allMatches) > limit {
+			allMatches = allMatches[:limit]
+		}
+		return nil
+	})
+	return allMatches, err
+}
+
+func uniqueStrings(values []string) map[string]bool {
+	unique := make(map[string]bool)
+	for _, v := range values {
+		unique[v] = true
+	}
+	return unique
+}
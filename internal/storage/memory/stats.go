@@ -0,0 +1,106 @@
+package memory
+
+import (
+	"sort"
+
+	"github.com/kelvinatorr/restaurant-tracker/internal/lister"
+)
+
+// GetStatsVisits returns the visits that aren't in the trash with their restaurant and city, oldest first.
+func (s Storage) GetStatsVisits() ([]lister.StatsVisit, error) {
+	var allVisits []lister.StatsVisit
+	err := s.read(func(d *data) error {
+		for _, id := range d.visitIDs() {
+			v := d.visits[id]
+			res := d.restaurants[v.restaurantID]
+			if v.deletedAt != "" || res.deletedAt != "" {
+				continue
+			}
+			c := d.cities[res.cityID]
+			allVisits = append(allVisits, lister.StatsVisit{
+				ID:             v.id,
+				RestaurantID:   v.restaurantID,
+				RestaurantName: res.name,
+				Cuisine:        res.cuisine,
+				City:           c.name,
+				State:          c.state,
+				VisitDateTime:  v.visitDateTime,
+			})
+		}
+		// The visits are already by id.
+		sort.SliceStable(allVisits, func(i int, j int) bool {
+			return allVisits[i].VisitDateTime < allVisits[j].VisitDateTime
+		})
+		return nil
+	})
+	return allVisits, err
+}
+
+// GetStatsRatings returns the ratings of the visits that aren't in the trash. Users at a visit who didn't rate it are
+// left out.
+func (s Storage) GetStatsRatings() ([]lister.StatsRating, error) {
+	var allRatings []lister.StatsRating
+	err := s.read(func(d *data) error {
+		for _, id := range d.visitUserIDs() {
+			vu := d.visitUsers[id]
+			v := d.visits[vu.visitID]
+			if vu.rating == 0 || v.deletedAt != "" || d.restaurants[v.restaurantID].deletedAt != "" {
+				continue
+			}
+			allRatings = append(allRatings, lister.StatsRating{VisitID: vu.visitID, UserID: vu.userID, Rating: vu.rating})
+		}
+		sort.SliceStable(allRatings, func(i int, j int) bool {
+			if allRatings[i].VisitID != allRatings[j].VisitID {
+				return allRatings[i].VisitID < allRatings[j].VisitID
+			}
+			return allRatings[i].UserID < allRatings[j].UserID
+		})
+		return nil
+	})
+	return allRatings, err
+}
+
+// GetUserRestaurantRatings returns the user's average rating of each restaurant they rated and everyone's average
+// rating of it, by restaurant id. Visits and restaurants in the trash are left out.
+func (s Storage) GetUserRestaurantRatings(userID int64) ([]lister.UserRestaurantRating, error) {
+	var allRatings []lister.UserRestaurantRating
+	err := s.read(func(d *data) error {
+		userRatings := make(map[int64][]int64)
+		everyone := make(map[int64][]int64)
+		for _, id := range d.visitUserIDs() {
+			vu := d.visitUsers[id]
+			v := d.visits[vu.visitID]
+			if vu.rating == 0 || v.deletedAt != "" || d.restaurants[v.restaurantID].deletedAt != "" {
+				continue
+			}
+			everyone[v.restaurantID] = append(everyone[v.restaurantID], vu.rating)
+			if vu.userID == userID {
+				userRatings[v.restaurantID] = append(userRatings[v.restaurantID], vu.rating)
+			}
+		}
+		for _, id := range d.restaurantIDs() {
+			ratings, ok := userRatings[id]
+			if !ok {
+				continue
+			}
+			allRatings = append(allRatings, lister.UserRestaurantRating{
+				RestaurantID:        id,
+				RestaurantName:      d.restaurants[id].name,
+				AvgRating:           mean(ratings),
+				Ratings:             int64(len(ratings)),
+				RestaurantAvgRating: mean(everyone[id]),
+			})
+		}
+		return nil
+	})
+	return allRatings, err
+}
+
+// mean returns the average of the ratings without rounding it like avg(rating) does.
+func mean(ratings []int64) float64 {
+	var sum int64
+	for _, r := range ratings {
+		sum += r
+	}
+	return float64(sum) / float64(len(ratings))
+}
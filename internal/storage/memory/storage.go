@@ -0,0 +1,1168 @@
+// Package memory stores restaurant data in memory. It has the same behaviour as the sqlite storage, including its
+// constraints, so it can stand in for a database in tests and in demo mode. Nothing is saved when the process exits.
+package memory
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/kelvinatorr/restaurant-tracker/internal/adder"
+	"github.com/kelvinatorr/restaurant-tracker/internal/auther"
+	"github.com/kelvinatorr/restaurant-tracker/internal/lister"
+	"github.com/kelvinatorr/restaurant-tracker/internal/remover"
+	"github.com/kelvinatorr/restaurant-tracker/internal/storage"
+	"github.com/kelvinatorr/restaurant-tracker/internal/updater"
+)
+
+// NewStorage returns a new empty in-memory Storage
+func NewStorage() *Storage {
+	return &Storage{db: &database{data: newData()}}
+}
+
+// Storage stores restaurant data in memory
+type Storage struct {
+	db *database
+	// tx is the copy of the data a transaction works on. It is nil outside of withTx.
+	tx *data
+}
+
+// database is shared by a Storage and every Storage made from it by withTx.
+type database struct {
+	mu   sync.RWMutex
+	data *data
+}
+
+// data holds the tables. Rows are stored by value so copying the maps copies the tables.
+type data struct {
+	lastID      map[string]int64
+	cities      map[int64]city
+	restaurants map[int64]restaurant
+	gmapsPlaces map[int64]gmapsPlace
+	visits      map[int64]visit
+	visitUsers  map[int64]visitUser
+	users       map[int64]user
+	auditLog    []auditEntry
+	savedViews  map[int64]savedView
+	apiTokens   map[int64]apiToken
+}
+
+type city struct {
+	id    int64
+	name  string
+	state string
+}
+
+type restaurant struct {
+	id             int64
+	name           string
+	cuisine        string
+	note           string
+	address        string
+	cityID         int64
+	zipcode        string
+	latitude       float32
+	longitude      float32
+	businessStatus int
+	deletedAt      string // Empty unless it is in the trash.
+}
+
+type gmapsPlace struct {
+	id                   int64
+	lastUpdated          string
+	placeID              string
+	businessStatus       string
+	formattedPhoneNumber string
+	name                 string
+	priceLevel           int
+	rating               float32
+	url                  string
+	userRatingsTotal     int
+	utcOffset            int
+	website              string
+	restaurantID         int64
+}
+
+type visit struct {
+	id            int64
+	restaurantID  int64
+	visitDateTime string
+	note          string
+	deletedAt     string // Empty unless it is in the trash.
+}
+
+type visitUser struct {
+	id      int64
+	visitID int64
+	userID  int64
+	rating  int64
+}
+
+type user struct {
+	id            int64
+	firstName     string
+	lastName      string
+	email         string
+	passwordHash  string
+	rememberToken string
+	isAdmin       bool
+}
+
+func newData() *data {
+	return &data{
+		lastID:      make(map[string]int64),
+		cities:      make(map[int64]city),
+		restaurants: make(map[int64]restaurant),
+		gmapsPlaces: make(map[int64]gmapsPlace),
+		visits:      make(map[int64]visit),
+		visitUsers:  make(map[int64]visitUser),
+		users:       make(map[int64]user),
+		savedViews:  make(map[int64]savedView),
+		apiTokens:   make(map[int64]apiToken),
+	}
+}
+
+func (d *data) clone() *data {
+	c := newData()
+	for k, v := range d.lastID {
+		c.lastID[k] = v
+	}
+	for k, v := range d.cities {
+		c.cities[k] = v
+	}
+	for k, v := range d.restaurants {
+		c.restaurants[k] = v
+	}
+	for k, v := range d.gmapsPlaces {
+		c.gmapsPlaces[k] = v
+	}
+	for k, v := range d.visits {
+		c.visits[k] = v
+	}
+	for k, v := range d.visitUsers {
+		c.visitUsers[k] = v
+	}
+	for k, v := range d.users {
+		c.users[k] = v
+	}
+	c.auditLog = append(c.auditLog, d.auditLog...)
+	for k, v := range d.savedViews {
+		c.savedViews[k] = v
+	}
+	for k, v := range d.apiTokens {
+		c.apiTokens[k] = v
+	}
+	return c
+}
+
+// nextID returns the next primary key for a table. Like sqlite's INTEGER PRIMARY KEY it starts at 1.
+func (d *data) nextID(table string) int64 {
+	d.lastID[table]++
+	return d.lastID[table]
+}
+
+// CloseStorage does nothing, it is here so Storage can be used like the database backed ones.
+func (s Storage) CloseStorage() {}
+
+// read calls fn with the data this Storage sees.
+func (s Storage) read(fn func(*data) error) error {
+	if s.tx != nil {
+		return fn(s.tx)
+	}
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+	return fn(s.db.data)
+}
+
+// write calls fn with data it may change. Outside of a transaction each write is a transaction of its own so a
+// constraint violation part way through doesn't leave a partial change behind.
+func (s Storage) write(fn func(*data) error) error {
+	return s.withTx(func(tx Storage) error {
+		return fn(tx.tx)
+	})
+}
+
+// sortedIDs returns the keys of a table in ascending order, which is the order sqlite returns rows in when a query has
+// no ORDER BY.
+func sortedIDs(n int, each func(func(int64))) []int64 {
+	ids := make([]int64, 0, n)
+	each(func(id int64) {
+		ids = append(ids, id)
+	})
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func (d *data) restaurantIDs() []int64 {
+	return sortedIDs(len(d.restaurants), func(add func(int64)) {
+		for id := range d.restaurants {
+			add(id)
+		}
+	})
+}
+
+func (d *data) visitIDs() []int64 {
+	return sortedIDs(len(d.visits), func(add func(int64)) {
+		for id := range d.visits {
+			add(id)
+		}
+	})
+}
+
+func (d *data) visitUserIDs() []int64 {
+	return sortedIDs(len(d.visitUsers), func(add func(int64)) {
+		for id := range d.visitUsers {
+			add(id)
+		}
+	})
+}
+
+func (d *data) userIDs() []int64 {
+	return sortedIDs(len(d.users), func(add func(int64)) {
+		for id := range d.users {
+			add(id)
+		}
+	})
+}
+
+func uniqueViolation(constraint string) error {
+	msg := fmt.Sprintf("UNIQUE constraint failed: %s", constraint)
+	return &storage.ErrUniqueViolation{Msg: msg}
+}
+
+func checkViolation(table string) error {
+	msg := fmt.Sprintf("CHECK constraint failed: %s", table)
+	return &storage.ErrConstraintViolation{Msg: msg}
+}
+
+func foreignKeyViolation() error {
+	return &storage.ErrConstraintViolation{Msg: "FOREIGN KEY constraint failed"}
+}
+
+// AddRestaurant adds the given restaurant to memory
+func (s Storage) AddRestaurant(r adder.Restaurant) (int64, error) {
+	var id int64
+	err := s.write(func(d *data) error {
+		if _, ok := d.cities[r.CityID]; !ok {
+			return foreignKeyViolation()
+		}
+		id = d.nextID("restaurant")
+		d.restaurants[id] = restaurant{
+			id:             id,
+			name:           r.Name,
+			cuisine:        r.Cuisine,
+			note:           r.Note,
+			address:        r.Address,
+			cityID:         r.CityID,
+			zipcode:        r.Zipcode,
+			latitude:       r.Latitude,
+			longitude:      r.Longitude,
+			businessStatus: r.BusinessStatus,
+		}
+		return nil
+	})
+	return id, err
+}
+
+// IsDuplicateRestaurant returns true if there already is a restaurant with the same name in the same city and state
+func (s Storage) IsDuplicateRestaurant(r adder.Restaurant) (bool, error) {
+	var isDuplicate bool
+	err := s.read(func(d *data) error {
+		for _, res := range d.restaurants {
+			c := d.cities[res.cityID]
+			if res.deletedAt == "" && equalFold(res.name, r.Name) && equalFold(c.name, r.CityState.Name) &&
+				equalFold(c.state, r.CityState.State) {
+				isDuplicate = true
+				return nil
+			}
+		}
+		return nil
+	})
+	return isDuplicate, err
+}
+
+// GetCityIDByNameAndState returns the id of the city with the given name and state or 0 if there isn't one
+func (s Storage) GetCityIDByNameAndState(cityName string, stateName string) (int64, error) {
+	var id int64
+	err := s.read(func(d *data) error {
+		for _, c := range d.cities {
+			if equalFold(c.name, cityName) && equalFold(c.state, stateName) {
+				id = c.id
+				return nil
+			}
+		}
+		return nil
+	})
+	return id, err
+}
+
+// AddCity adds a city and returns its id. If the city is already there its id is returned.
+func (s Storage) AddCity(cityName string, stateName string) (int64, error) {
+	var id int64
+	err := s.write(func(d *data) error {
+		if utf8.RuneCountInString(stateName) != 2 {
+			return checkViolation("city")
+		}
+		for _, c := range d.cities {
+			if c.name == cityName && c.state == stateName {
+				id = c.id
+				return nil
+			}
+		}
+		id = d.nextID("city")
+		d.cities[id] = city{id: id, name: cityName, state: stateName}
+		return nil
+	})
+	return id, err
+}
+
+// AddGmapsPlace adds a Google Maps Place and returns its id. If there already is one with the same place id it is
+// updated instead.
+func (s Storage) AddGmapsPlace(g adder.GmapsPlace) (int64, error) {
+	var id int64
+	err := s.write(func(d *data) error {
+		if _, ok := d.restaurants[g.RestaurantID]; !ok {
+			return foreignKeyViolation()
+		}
+		for _, gp := range d.gmapsPlaces {
+			if gp.placeID == g.PlaceID {
+				id = gp.id
+				break
+			}
+		}
+		if id == 0 {
+			id = d.nextID("gmaps_place")
+		}
+		d.gmapsPlaces[id] = gmapsPlace{
+			id:                   id,
+			lastUpdated:          time.Now().UTC().Format(time.RFC3339),
+			placeID:              g.PlaceID,
+			businessStatus:       g.BusinessStatus,
+			formattedPhoneNumber: g.FormattedPhoneNumber,
+			name:                 g.Name,
+			priceLevel:           g.PriceLevel,
+			rating:               g.Rating,
+			url:                  g.URL,
+			userRatingsTotal:     g.UserRatingsTotal,
+			utcOffset:            g.UTCOffset,
+			website:              g.Website,
+			restaurantID:         g.RestaurantID,
+		}
+		return nil
+	})
+	return id, err
+}
+
+// restaurantRow is a restaurant joined to its city, gmaps place, last visit and ratings the way the sqlite query does
+// it. lastVisit and avgRating are nil when the restaurant has no visits or ratings, like NULL in sqlite.
+type restaurantRow struct {
+	lister.Restaurant
+	lastVisit *string
+	avgRating *float64
+	// ratingDiff is nil unless there are both ratings and a Google Maps rating.
+	ratingDiff *float64
+}
+
+func (d *data) restaurantRow(res restaurant) restaurantRow {
+	c := d.cities[res.cityID]
+	row := restaurantRow{Restaurant: lister.Restaurant{
+		ID:             res.id,
+		Name:           res.name,
+		Cuisine:        res.cuisine,
+		BusinessStatus: res.businessStatus,
+		Note:           res.note,
+		Address:        res.address,
+		CityState:      lister.CityState{ID: c.id, Name: c.name, State: c.state},
+		Zipcode:        res.zipcode,
+		Latitude:       res.latitude,
+		Longitude:      res.longitude,
+	}}
+
+	// Use the first gmaps place in id order, like the left join does.
+	var gp gmapsPlace
+	for _, g := range d.gmapsPlaces {
+		if g.restaurantID == res.id && (gp.id == 0 || g.id < gp.id) {
+			gp = g
+		}
+	}
+	row.GmapsPlace.ID = gp.id
+	row.GmapsPlace.LastUpdated = gp.lastUpdated
+	row.GmapsPlace.PlaceID = gp.placeID
+	row.GmapsPlace.BusinessStatus = gp.businessStatus
+	row.GmapsPlace.FormattedPhoneNumber = gp.formattedPhoneNumber
+	row.GmapsPlace.Name = gp.name
+	row.GmapsPlace.PriceLevel = gp.priceLevel
+	row.GmapsPlace.Rating = gp.rating
+	row.GmapsPlace.URL = gp.url
+	row.GmapsPlace.UserRatingsTotal = gp.userRatingsTotal
+	row.GmapsPlace.UTCOffset = gp.utcOffset
+	row.GmapsPlace.Website = gp.website
+
+	var ratings []int64
+	for _, v := range d.visits {
+		if v.restaurantID != res.id || v.deletedAt != "" {
+			continue
+		}
+		if row.lastVisit == nil || v.visitDateTime > *row.lastVisit {
+			lastVisit := v.visitDateTime
+			row.lastVisit = &lastVisit
+		}
+		for _, vu := range d.visitUsers {
+			if vu.visitID == v.id && vu.rating != 0 {
+				ratings = append(ratings, vu.rating)
+			}
+		}
+	}
+	if row.lastVisit != nil {
+		row.LastVisitDatetime = *row.lastVisit
+	}
+	if len(ratings) > 0 {
+		avgRating := averageRating(ratings)
+		row.avgRating = &avgRating
+		row.AvgRating = float32(avgRating)
+		if gp.rating != 0 {
+			// Rounded like round(ratings.avg_rating - gp.rating, 1)
+			ratingDiff := math.Round((avgRating-float64(gp.rating))*10) / 10
+			row.ratingDiff = &ratingDiff
+			row.RatingDiff = ratingDiff
+		}
+	}
+	return row
+}
+
+// averageRating returns the average rounded to 1 decimal place like round(avg(rating), 1) does.
+func averageRating(ratings []int64) float64 {
+	var sum int64
+	for _, r := range ratings {
+		sum += r
+	}
+	return math.Round(float64(sum)/float64(len(ratings))*10) / 10
+}
+
+// GetRestaurant returns the restaurant with the given id. Returns a storage.ErrNotFound if there isn't one.
+func (s Storage) GetRestaurant(id int64) (lister.Restaurant, error) {
+	var r lister.Restaurant
+	err := s.read(func(d *data) error {
+		res, ok := d.restaurants[id]
+		if !ok || res.deletedAt != "" {
+			return &storage.ErrNotFound{Msg: fmt.Sprintf("No restaurant with id: %d", id)}
+		}
+		r = d.restaurantRow(res).Restaurant
+		return nil
+	})
+	return r, err
+}
+
+// GetRestaurants returns a page of the restaurants that match the filter sorted by the sort operations.
+func (s Storage) GetRestaurants(sortOps []lister.SortOperation, filter lister.FilterExpr,
+	page lister.PageOperation) ([]lister.Restaurant, error) {
+	var allRestaurants []lister.Restaurant
+	err := s.read(func(d *data) error {
+		rows, err := d.filterRestaurants(filter)
+		if err != nil {
+			return err
+		}
+		if err := sortRestaurants(rows, sortOps); err != nil {
+			return err
+		}
+		indexes, err := pageRows(len(rows), sortOps, page, func(i int, name string) (interface{}, error) {
+			return rows[i].column(name)
+		})
+		if err != nil {
+			return err
+		}
+		for _, i := range indexes {
+			allRestaurants = append(allRestaurants, rows[i].Restaurant)
+		}
+		return nil
+	})
+	return allRestaurants, err
+}
+
+// CountRestaurants returns how many restaurants match the filter.
+func (s Storage) CountRestaurants(filter lister.FilterExpr) (int64, error) {
+	var count int64
+	err := s.read(func(d *data) error {
+		rows, err := d.filterRestaurants(filter)
+		count = int64(len(rows))
+		return err
+	})
+	return count, err
+}
+
+// filterRestaurants returns the rows of the restaurants that aren't in the trash and match the filter. Their distance
+// is from the origin of the filter's near filter if it has one.
+func (d *data) filterRestaurants(filter lister.FilterExpr) ([]restaurantRow, error) {
+	var rows []restaurantRow
+	origin, hasOrigin := lister.FilterOrigin(filter)
+	for _, id := range d.restaurantIDs() {
+		if d.restaurants[id].deletedAt != "" {
+			continue
+		}
+		row := d.restaurantRow(d.restaurants[id])
+		if hasOrigin {
+			if p, _ := row.column("location"); p != nil {
+				row.Distance = lister.DistanceKm(p.(lister.GeoPoint), origin)
+			}
+		}
+		match, err := matchesFilterExpr(row, filter)
+		if err != nil {
+			return rows, err
+		}
+		if match {
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
+}
+
+// GetRestaurantsByCity gives you all the restaurants with a given city id, including the ones in the trash.
+func (s Storage) GetRestaurantsByCity(cityID int64) ([]lister.Restaurant, error) {
+	var restaurantsInCity []lister.Restaurant
+	err := s.read(func(d *data) error {
+		for _, id := range d.restaurantIDs() {
+			if res := d.restaurants[id]; res.cityID == cityID {
+				restaurantsInCity = append(restaurantsInCity, d.restaurantRow(res).Restaurant)
+			}
+		}
+		return nil
+	})
+	return restaurantsInCity, err
+}
+
+// UpdateRestaurant updates a given restaurant, returns the rows affected
+func (s Storage) UpdateRestaurant(r updater.Restaurant) (int64, error) {
+	var recordsAffected int64
+	err := s.write(func(d *data) error {
+		res, ok := d.restaurants[r.ID]
+		if !ok {
+			return nil
+		}
+		if _, ok := d.cities[r.CityID]; !ok {
+			return foreignKeyViolation()
+		}
+		res.name = r.Name
+		res.cuisine = r.Cuisine
+		res.note = r.Note
+		res.cityID = r.CityID
+		res.address = r.Address
+		res.zipcode = r.Zipcode
+		res.latitude = r.Latitude
+		res.longitude = r.Longitude
+		res.businessStatus = r.BusinessStatus
+		d.restaurants[r.ID] = res
+		recordsAffected = 1
+		return nil
+	})
+	return recordsAffected, err
+}
+
+// UpdateGmapsPlace updates a given gmaps_place, returns the rows affected
+func (s Storage) UpdateGmapsPlace(gp updater.GmapsPlace) (int64, error) {
+	var recordsAffected int64
+	err := s.write(func(d *data) error {
+		if _, ok := d.gmapsPlaces[gp.ID]; !ok {
+			return nil
+		}
+		for _, g := range d.gmapsPlaces {
+			if g.id != gp.ID && g.placeID == gp.PlaceID {
+				return uniqueViolation("gmaps_place.place_id")
+			}
+		}
+		if _, ok := d.restaurants[gp.RestaurantID]; !ok {
+			return foreignKeyViolation()
+		}
+		d.gmapsPlaces[gp.ID] = gmapsPlace{
+			id:                   gp.ID,
+			lastUpdated:          gp.LastUpdated,
+			placeID:              gp.PlaceID,
+			businessStatus:       gp.BusinessStatus,
+			formattedPhoneNumber: gp.FormattedPhoneNumber,
+			name:                 gp.Name,
+			priceLevel:           gp.PriceLevel,
+			rating:               gp.Rating,
+			url:                  gp.URL,
+			userRatingsTotal:     gp.UserRatingsTotal,
+			utcOffset:            gp.UTCOffset,
+			website:              gp.Website,
+			restaurantID:         gp.RestaurantID,
+		}
+		recordsAffected = 1
+		return nil
+	})
+	return recordsAffected, err
+}
+
+// RemoveRestaurant deletes a given restaurant along with its visits and gmaps place and returns the rows affected.
+func (s Storage) RemoveRestaurant(r remover.Restaurant) (int64, error) {
+	var recordsAffected int64
+	err := s.write(func(d *data) error {
+		if _, ok := d.restaurants[r.ID]; !ok {
+			return nil
+		}
+		delete(d.restaurants, r.ID)
+		// ON DELETE CASCADE
+		for id, gp := range d.gmapsPlaces {
+			if gp.restaurantID == r.ID {
+				delete(d.gmapsPlaces, id)
+			}
+		}
+		for id, v := range d.visits {
+			if v.restaurantID == r.ID {
+				d.removeVisit(id)
+			}
+		}
+		recordsAffected = 1
+		return nil
+	})
+	return recordsAffected, err
+}
+
+// RemoveCity deletes a given city and returns the number of rows affected
+func (s Storage) RemoveCity(cityID int64) (int64, error) {
+	var recordsAffected int64
+	err := s.write(func(d *data) error {
+		if _, ok := d.cities[cityID]; !ok {
+			return nil
+		}
+		for _, res := range d.restaurants {
+			if res.cityID == cityID {
+				return foreignKeyViolation()
+			}
+		}
+		delete(d.cities, cityID)
+		recordsAffected = 1
+		return nil
+	})
+	return recordsAffected, err
+}
+
+// GetGmapsPlaceRestaurantID returns the id of the restaurant a Google Maps place belongs to. Returns a
+// storage.ErrNotFound if there is no place with the given id.
+func (s Storage) GetGmapsPlaceRestaurantID(gmapsID int64) (int64, error) {
+	var restaurantID int64
+	err := s.read(func(d *data) error {
+		g, ok := d.gmapsPlaces[gmapsID]
+		if !ok {
+			return &storage.ErrNotFound{Msg: fmt.Sprintf("No Google Maps place with id: %d", gmapsID)}
+		}
+		restaurantID = g.restaurantID
+		return nil
+	})
+	return restaurantID, err
+}
+
+func (s Storage) RemoveGmapsPlace(gmapsID int64) (int64, error) {
+	var recordsAffected int64
+	err := s.write(func(d *data) error {
+		if _, ok := d.gmapsPlaces[gmapsID]; ok {
+			delete(d.gmapsPlaces, gmapsID)
+			recordsAffected = 1
+		}
+		return nil
+	})
+	return recordsAffected, err
+}
+
+func (v visit) toLister() lister.Visit {
+	return lister.Visit{
+		ID:            v.id,
+		RestaurantID:  v.restaurantID,
+		VisitDateTime: v.visitDateTime,
+		Note:          v.note,
+	}
+}
+
+// GetVisit returns the visit with the given id and restaurant id. Returns a storage.ErrNotFound if there isn't one.
+func (s Storage) GetVisit(id int64, resID int64) (lister.Visit, error) {
+	var v lister.Visit
+	err := s.read(func(d *data) error {
+		saved, ok := d.visits[id]
+		if !ok || saved.restaurantID != resID || saved.deletedAt != "" {
+			return &storage.ErrNotFound{Msg: fmt.Sprintf("No visit with id: %d for restaurant: %d", id, resID)}
+		}
+		v = d.visitRow(saved).Visit
+		return nil
+	})
+	return v, err
+}
+
+// visitRow is a visit joined to its restaurant and the users at it the way the sqlite query does it. avgRating is nil
+// when nobody rated it, like NULL in sqlite.
+type visitRow struct {
+	lister.Visit
+	userIDs   []int64
+	avgRating *float64
+}
+
+func (d *data) visitRow(v visit) visitRow {
+	row := visitRow{Visit: v.toLister()}
+	row.RestaurantName = d.restaurants[v.restaurantID].name
+	var ratings []int64
+	for _, id := range d.visitUserIDs() {
+		vu := d.visitUsers[id]
+		if vu.visitID != v.id {
+			continue
+		}
+		row.userIDs = append(row.userIDs, vu.userID)
+		if vu.rating != 0 {
+			ratings = append(ratings, vu.rating)
+		}
+	}
+	row.Attendees = int64(len(row.userIDs))
+	if len(ratings) > 0 {
+		avgRating := averageRating(ratings)
+		row.avgRating = &avgRating
+		row.AvgRating = float32(avgRating)
+	}
+	return row
+}
+
+// GetVisits returns a page of the visits that match the filter sorted by the sort operations.
+func (s Storage) GetVisits(sortOps []lister.SortOperation, filter lister.FilterExpr,
+	page lister.PageOperation) ([]lister.Visit, error) {
+	var pageVisits []lister.Visit
+	err := s.read(func(d *data) error {
+		rows, err := d.filterVisits(filter)
+		if err != nil {
+			return err
+		}
+		if err := sortVisits(rows, sortOps); err != nil {
+			return err
+		}
+		indexes, err := pageRows(len(rows), sortOps, page, func(i int, name string) (interface{}, error) {
+			return rows[i].column(name)
+		})
+		if err != nil {
+			return err
+		}
+		for _, i := range indexes {
+			pageVisits = append(pageVisits, rows[i].Visit)
+		}
+		return nil
+	})
+	return pageVisits, err
+}
+
+// CountVisits returns how many visits match the filter.
+func (s Storage) CountVisits(filter lister.FilterExpr) (int64, error) {
+	var count int64
+	err := s.read(func(d *data) error {
+		rows, err := d.filterVisits(filter)
+		count = int64(len(rows))
+		return err
+	})
+	return count, err
+}
+
+// filterVisits returns the rows of the visits that match the filter. Visits in the trash, or to restaurants in the
+// trash, are left out.
+func (d *data) filterVisits(filter lister.FilterExpr) ([]visitRow, error) {
+	var rows []visitRow
+	for _, id := range d.visitIDs() {
+		v := d.visits[id]
+		if v.deletedAt != "" || d.restaurants[v.restaurantID].deletedAt != "" {
+			continue
+		}
+		row := d.visitRow(v)
+		match, err := matchesFilterExpr(row, filter)
+		if err != nil {
+			return rows, err
+		}
+		if match {
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
+}
+
+// GetVisitUsersByVisitID returns the users in the given visit
+func (s Storage) GetVisitUsersByVisitID(visitID int64) ([]lister.VisitUser, error) {
+	var allVisitUsers []lister.VisitUser
+	err := s.read(func(d *data) error {
+		for _, id := range d.visitUserIDs() {
+			vu := d.visitUsers[id]
+			if vu.visitID != visitID {
+				continue
+			}
+			u := d.users[vu.userID]
+			allVisitUsers = append(allVisitUsers, lister.VisitUser{
+				ID:     vu.id,
+				Rating: vu.rating,
+				User:   lister.User{ID: u.id, FirstName: u.firstName, LastName: u.lastName},
+			})
+		}
+		return nil
+	})
+	return allVisitUsers, err
+}
+
+// AddVisit adds the given visit to memory
+func (s Storage) AddVisit(v adder.Visit) (int64, error) {
+	var id int64
+	err := s.write(func(d *data) error {
+		if _, ok := d.restaurants[v.RestaurantID]; !ok {
+			return foreignKeyViolation()
+		}
+		if utf8.RuneCountInString(v.VisitDateTime) != 20 {
+			return checkViolation("visit")
+		}
+		id = d.nextID("visit")
+		d.visits[id] = visit{id: id, restaurantID: v.RestaurantID, visitDateTime: v.VisitDateTime, note: v.Note}
+		return nil
+	})
+	return id, err
+}
+
+// checkVisitUser checks the visit_user constraints. id is the visit user being updated, or 0 for a new one.
+func (d *data) checkVisitUser(id int64, visitID int64, userID int64, rating int64) error {
+	if _, ok := d.visits[visitID]; !ok {
+		return foreignKeyViolation()
+	}
+	if _, ok := d.users[userID]; !ok {
+		return foreignKeyViolation()
+	}
+	// 0 is saved as NULL which is allowed.
+	if rating != 0 && (rating < 1 || rating > 5) {
+		return checkViolation("visit_user")
+	}
+	for _, vu := range d.visitUsers {
+		if vu.id != id && vu.visitID == visitID && vu.userID == userID {
+			return uniqueViolation("visit_user.visit_id, visit_user.user_id")
+		}
+	}
+	return nil
+}
+
+// AddVisitUser adds the given user to a visit. If the user is already in the visit their rating is updated instead.
+func (s Storage) AddVisitUser(vu adder.VisitUser) (int64, error) {
+	var id int64
+	err := s.write(func(d *data) error {
+		for _, saved := range d.visitUsers {
+			if saved.visitID == vu.VisitID && saved.userID == vu.UserID {
+				id = saved.id
+				break
+			}
+		}
+		if err := d.checkVisitUser(id, vu.VisitID, vu.UserID, vu.Rating); err != nil {
+			return err
+		}
+		if id == 0 {
+			id = d.nextID("visit_user")
+		}
+		d.visitUsers[id] = visitUser{id: id, visitID: vu.VisitID, userID: vu.UserID, rating: vu.Rating}
+		return nil
+	})
+	return id, err
+}
+
+func (u user) toLister() lister.User {
+	return lister.User{ID: u.id, FirstName: u.firstName, LastName: u.lastName, Email: u.email, IsAdmin: u.isAdmin}
+}
+
+// GetUser returns the user with the given id. Returns a storage.ErrNotFound if there isn't one.
+func (s Storage) GetUser(id int64) (lister.User, error) {
+	var u lister.User
+	err := s.read(func(d *data) error {
+		saved, ok := d.users[id]
+		if !ok {
+			return &storage.ErrNotFound{Msg: fmt.Sprintf("No user with id: %d", id)}
+		}
+		u = saved.toLister()
+		return nil
+	})
+	return u, err
+}
+
+// GetUsers returns all the users.
+func (s Storage) GetUsers() ([]lister.User, error) {
+	var allUsers []lister.User
+	err := s.read(func(d *data) error {
+		for _, id := range d.userIDs() {
+			allUsers = append(allUsers, d.users[id].toLister())
+		}
+		return nil
+	})
+	return allUsers, err
+}
+
+// GetUserBy returns the user whose field has the given value. Returns a storage.ErrNotFound if there isn't one.
+func (s Storage) GetUserBy(field string, value string) (lister.User, error) {
+	var u lister.User
+	err := s.read(func(d *data) error {
+		if field != "email" {
+			return fmt.Errorf("no such column: %s", field)
+		}
+		for _, id := range d.userIDs() {
+			if saved := d.users[id]; saved.email == value {
+				u = saved.toLister()
+				return nil
+			}
+		}
+		return &storage.ErrNotFound{Msg: fmt.Sprintf("No user with %s: %s", field, value)}
+	})
+	return u, err
+}
+
+func (u user) toAuther() auther.User {
+	return auther.User{ID: u.id, PasswordHash: u.passwordHash, RememberToken: u.rememberToken}
+}
+
+// GetUserAuthByEmail returns the password and remember hashes of a given email. Returns a storage.ErrNotFound if there
+// isn't a user with that email.
+func (s Storage) GetUserAuthByEmail(email string) (auther.User, error) {
+	var uh auther.User
+	err := s.read(func(d *data) error {
+		for _, u := range d.users {
+			if u.email == email {
+				uh = u.toAuther()
+				return nil
+			}
+		}
+		return &storage.ErrNotFound{Msg: fmt.Sprintf("No user with email: %s", email)}
+	})
+	return uh, err
+}
+
+// GetUserAuthByID returns the password and remember hashes of a given user id. Returns a storage.ErrNotFound if there
+// isn't a user with that id.
+func (s Storage) GetUserAuthByID(id int64) (auther.User, error) {
+	var uh auther.User
+	err := s.read(func(d *data) error {
+		u, ok := d.users[id]
+		if !ok {
+			return &storage.ErrNotFound{Msg: fmt.Sprintf("No user with id: %d", id)}
+		}
+		uh = u.toAuther()
+		return nil
+	})
+	return uh, err
+}
+
+// GetUserCount returns the number of users.
+func (s Storage) GetUserCount() (int64, error) {
+	var userCount int64
+	err := s.read(func(d *data) error {
+		userCount = int64(len(d.users))
+		return nil
+	})
+	return userCount, err
+}
+
+// updateUser calls fn with the user with the given id and saves the user it returns. It returns the rows affected.
+func (s Storage) updateUser(id int64, fn func(*data, user) (user, error)) (int64, error) {
+	var recordsAffected int64
+	err := s.write(func(d *data) error {
+		u, ok := d.users[id]
+		if !ok {
+			return nil
+		}
+		u, err := fn(d, u)
+		if err != nil {
+			return err
+		}
+		d.users[id] = u
+		recordsAffected = 1
+		return nil
+	})
+	return recordsAffected, err
+}
+
+// UpdateUser updates a given user, returns the rows affected
+func (s Storage) UpdateUser(u updater.User) (int64, error) {
+	return s.updateUser(u.ID, func(d *data, saved user) (user, error) {
+		for _, other := range d.users {
+			if other.id != u.ID && other.email == u.Email {
+				return saved, uniqueViolation("user.email")
+			}
+		}
+		saved.firstName = u.FirstName
+		saved.lastName = u.LastName
+		saved.email = u.Email
+		return saved, nil
+	})
+}
+
+// UpdateUserPassword updates the password of the user with the given id
+func (s Storage) UpdateUserPassword(id int64, newPasswordHash string) (int64, error) {
+	return s.updateUser(id, func(d *data, saved user) (user, error) {
+		saved.passwordHash = newPasswordHash
+		return saved, nil
+	})
+}
+
+// UpdateUserRememberToken updates a user's remember_hash and then returns the number of rows affected.
+func (s Storage) UpdateUserRememberToken(u auther.User) (int64, error) {
+	return s.updateUser(u.ID, func(d *data, saved user) (user, error) {
+		saved.rememberToken = u.RememberToken
+		return saved, nil
+	})
+}
+
+// UpdateVisit updates a given visit, returns the rows affected
+func (s Storage) UpdateVisit(v updater.Visit) (int64, error) {
+	var recordsAffected int64
+	err := s.write(func(d *data) error {
+		if _, ok := d.visits[v.ID]; !ok {
+			return nil
+		}
+		if _, ok := d.restaurants[v.RestaurantID]; !ok {
+			return foreignKeyViolation()
+		}
+		if utf8.RuneCountInString(v.VisitDateTime) != 20 {
+			return checkViolation("visit")
+		}
+		d.visits[v.ID] = visit{id: v.ID, restaurantID: v.RestaurantID, visitDateTime: v.VisitDateTime, note: v.Note}
+		recordsAffected = 1
+		return nil
+	})
+	return recordsAffected, err
+}
+
+// UpdateVisitUser updates a given visit_user, returns the rows affected
+func (s Storage) UpdateVisitUser(vu updater.VisitUser) (int64, error) {
+	var recordsAffected int64
+	err := s.write(func(d *data) error {
+		if _, ok := d.visitUsers[vu.ID]; !ok {
+			return nil
+		}
+		if err := d.checkVisitUser(vu.ID, vu.VisitID, vu.UserID, vu.Rating); err != nil {
+			return err
+		}
+		d.visitUsers[vu.ID] = visitUser{id: vu.ID, visitID: vu.VisitID, userID: vu.UserID, rating: vu.Rating}
+		recordsAffected = 1
+		return nil
+	})
+	return recordsAffected, err
+}
+
+// removeVisit deletes a visit and its visit users.
+func (d *data) removeVisit(visitID int64) {
+	delete(d.visits, visitID)
+	// ON DELETE CASCADE
+	for id, vu := range d.visitUsers {
+		if vu.visitID == visitID {
+			delete(d.visitUsers, id)
+		}
+	}
+}
+
+// RemoveVisit deletes a given visit and returns the number of rows affected
+func (s Storage) RemoveVisit(visitID int64) (int64, error) {
+	var recordsAffected int64
+	err := s.write(func(d *data) error {
+		if _, ok := d.visits[visitID]; ok {
+			d.removeVisit(visitID)
+			recordsAffected = 1
+		}
+		return nil
+	})
+	return recordsAffected, err
+}
+
+// RemoveVisitUser deletes a given visit_user and returns the number of rows affected
+func (s Storage) RemoveVisitUser(visitUserID int64) (int64, error) {
+	var recordsAffected int64
+	err := s.write(func(d *data) error {
+		if _, ok := d.visitUsers[visitUserID]; ok {
+			delete(d.visitUsers, visitUserID)
+			recordsAffected = 1
+		}
+		return nil
+	})
+	return recordsAffected, err
+}
+
+// AddUser adds a given user and returns the new user id
+func (s Storage) AddUser(u adder.User) (int64, error) {
+	var id int64
+	err := s.write(func(d *data) error {
+		for _, saved := range d.users {
+			if saved.email == u.Email {
+				return uniqueViolation("user.email")
+			}
+		}
+		id = d.nextID("user")
+		d.users[id] = user{
+			id:           id,
+			firstName:    u.FirstName,
+			lastName:     u.LastName,
+			email:        u.Email,
+			passwordHash: u.PasswordHash,
+			isAdmin:      u.IsAdmin,
+		}
+		return nil
+	})
+	return id, err
+}
+
+// GetRestaurantAvgRatingByUser gets a given restaurants average rating group by user. If the returned value for a user
+// is 0 then the restaurant has no ratings for that user.
+func (s Storage) GetRestaurantAvgRatingByUser(restaurantID int64) ([]lister.AvgUserRating, error) {
+	var allRatings []lister.AvgUserRating
+	err := s.read(func(d *data) error {
+		ratings := make(map[int64][]int64)
+		for _, id := range d.visitUserIDs() {
+			vu := d.visitUsers[id]
+			if v := d.visits[vu.visitID]; v.restaurantID != restaurantID || v.deletedAt != "" {
+				continue
+			}
+			if _, ok := ratings[vu.userID]; !ok {
+				ratings[vu.userID] = []int64{}
+			}
+			if vu.rating != 0 {
+				ratings[vu.userID] = append(ratings[vu.userID], vu.rating)
+			}
+		}
+		for _, id := range d.userIDs() {
+			userRatings, ok := ratings[id]
+			if !ok {
+				continue
+			}
+			u := d.users[id]
+			ar := lister.AvgUserRating{ID: u.id, FirstName: u.firstName, LastName: u.lastName}
+			if len(userRatings) > 0 {
+				ar.AvgRating = float32(averageRating(userRatings))
+			}
+			allRatings = append(allRatings, ar)
+		}
+		return nil
+	})
+	return allRatings, err
+}
+
+// GetDistinct returns a sorted list of distinct values for a given column from a given table
+func (s Storage) GetDistinct(columnName string, tableName string) ([]string, error) {
+	var distinctValues []string
+	err := s.read(func(d *data) error {
+		seen := make(map[string]bool)
+		add := func(val string) {
+			if !seen[val] {
+				seen[val] = true
+				distinctValues = append(distinctValues, val)
+			}
+		}
+		switch tableName + "." + columnName {
+		case "restaurant.cuisine":
+			for _, r := range d.restaurants {
+				add(r.cuisine)
+			}
+		case "city.name":
+			for _, c := range d.cities {
+				add(c.name)
+			}
+		case "city.state":
+			for _, c := range d.cities {
+				add(c.state)
+			}
+		default:
+			return fmt.Errorf("no such column: %s.%s", tableName, columnName)
+		}
+		sort.Strings(distinctValues)
+		return nil
+	})
+	return distinctValues, err
+}
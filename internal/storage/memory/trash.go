@@ -0,0 +1,117 @@
+package memory
+
+import (
+	"sort"
+
+	"github.com/kelvinatorr/restaurant-tracker/internal/lister"
+)
+
+// TrashRestaurant moves a restaurant to the trash by setting its deleted_at and returns the rows affected. Its visits
+// are hidden with it but keep their own deleted_at.
+func (s Storage) TrashRestaurant(id int64, deletedAt string) (int64, error) {
+	var recordsAffected int64
+	err := s.write(func(d *data) error {
+		if res, ok := d.restaurants[id]; ok && res.deletedAt == "" {
+			res.deletedAt = deletedAt
+			d.restaurants[id] = res
+			recordsAffected = 1
+		}
+		return nil
+	})
+	return recordsAffected, err
+}
+
+// RestoreRestaurant takes a restaurant out of the trash and returns the rows affected.
+func (s Storage) RestoreRestaurant(id int64) (int64, error) {
+	var recordsAffected int64
+	err := s.write(func(d *data) error {
+		if res, ok := d.restaurants[id]; ok && res.deletedAt != "" {
+			res.deletedAt = ""
+			d.restaurants[id] = res
+			recordsAffected = 1
+		}
+		return nil
+	})
+	return recordsAffected, err
+}
+
+// TrashVisit moves a visit to the trash by setting its deleted_at and returns the rows affected.
+func (s Storage) TrashVisit(id int64, deletedAt string) (int64, error) {
+	var recordsAffected int64
+	err := s.write(func(d *data) error {
+		if v, ok := d.visits[id]; ok && v.deletedAt == "" {
+			v.deletedAt = deletedAt
+			d.visits[id] = v
+			recordsAffected = 1
+		}
+		return nil
+	})
+	return recordsAffected, err
+}
+
+// RestoreVisit takes a visit out of the trash and returns the rows affected.
+func (s Storage) RestoreVisit(id int64) (int64, error) {
+	var recordsAffected int64
+	err := s.write(func(d *data) error {
+		if v, ok := d.visits[id]; ok && v.deletedAt != "" {
+			v.deletedAt = ""
+			d.visits[id] = v
+			recordsAffected = 1
+		}
+		return nil
+	})
+	return recordsAffected, err
+}
+
+// GetTrashedRestaurants returns the restaurants in the trash, most recently deleted first.
+func (s Storage) GetTrashedRestaurants() ([]lister.TrashedRestaurant, error) {
+	var allRestaurants []lister.TrashedRestaurant
+	err := s.read(func(d *data) error {
+		for _, id := range d.restaurantIDs() {
+			res := d.restaurants[id]
+			if res.deletedAt == "" {
+				continue
+			}
+			c := d.cities[res.cityID]
+			allRestaurants = append(allRestaurants, lister.TrashedRestaurant{
+				ID:        res.id,
+				Name:      res.name,
+				CityState: lister.CityState{ID: c.id, Name: c.name, State: c.state},
+				DeletedAt: res.deletedAt,
+			})
+		}
+		sort.SliceStable(allRestaurants, func(i int, j int) bool {
+			return allRestaurants[i].DeletedAt > allRestaurants[j].DeletedAt
+		})
+		return nil
+	})
+	return allRestaurants, err
+}
+
+// GetTrashedVisits returns the visits in the trash, most recently deleted first. Visits of restaurants in the trash are
+// left out because they can only come back with their restaurant.
+func (s Storage) GetTrashedVisits() ([]lister.TrashedVisit, error) {
+	var allVisits []lister.TrashedVisit
+	err := s.read(func(d *data) error {
+		for _, id := range d.visitIDs() {
+			v := d.visits[id]
+			res := d.restaurants[v.restaurantID]
+			if v.deletedAt == "" || res.deletedAt != "" {
+				continue
+			}
+			allVisits = append(allVisits, lister.TrashedVisit{
+				ID:             v.id,
+				RestaurantID:   v.restaurantID,
+				RestaurantName: res.name,
+				VisitDateTime:  v.visitDateTime,
+				Note:           v.note,
+				DeletedAt:      v.deletedAt,
+			})
+		}
+		sort.SliceStable(allVisits, func(i int, j int) bool {
+			return allVisits[i].DeletedAt > allVisits[j].DeletedAt
+		})
+		return nil
+	})
+	return allVisits, err
+}
@@ -0,0 +1,89 @@
+package memory
+
+import (
+	"github.com/kelvinatorr/restaurant-tracker/internal/adder"
+	"github.com/kelvinatorr/restaurant-tracker/internal/auther"
+	"github.com/kelvinatorr/restaurant-tracker/internal/remover"
+	"github.com/kelvinatorr/restaurant-tracker/internal/updater"
+)
+
+// withTx calls fn with a Storage that works on a copy of the data, so reads see the writes made before them. The copy
+// replaces the data if fn returns nil and is thrown away otherwise. Transactions hold the write lock so they run one
+// at a time, like they do with sqlite.
+func (s Storage) withTx(fn func(Storage) error) error {
+	// Already in a transaction so just join it.
+	if s.tx != nil {
+		return fn(s)
+	}
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	tx := s.db.data.clone()
+	if err := fn(Storage{db: s.db, tx: tx}); err != nil {
+		return err
+	}
+	s.db.data = tx
+	return nil
+}
+
+// Each service defines a WithTx that takes its own TxRepository, so Storage is wrapped in a type per service that
+// implements that service's Repository.
+
+// Adder returns s as an adder.Repository
+func (s *Storage) Adder() adder.Repository {
+	return adderRepository{*s}
+}
+
+type adderRepository struct {
+	Storage
+}
+
+func (r adderRepository) WithTx(fn func(adder.TxRepository) error) error {
+	return r.withTx(func(tx Storage) error {
+		return fn(adderRepository{tx})
+	})
+}
+
+// Updater returns s as an updater.Repository
+func (s *Storage) Updater() updater.Repository {
+	return updaterRepository{*s}
+}
+
+type updaterRepository struct {
+	Storage
+}
+
+func (r updaterRepository) WithTx(fn func(updater.TxRepository) error) error {
+	return r.withTx(func(tx Storage) error {
+		return fn(updaterRepository{tx})
+	})
+}
+
+// Remover returns s as a remover.Repository
+func (s *Storage) Remover() remover.Repository {
+	return removerRepository{*s}
+}
+
+type removerRepository struct {
+	Storage
+}
+
+func (r removerRepository) WithTx(fn func(remover.TxRepository) error) error {
+	return r.withTx(func(tx Storage) error {
+		return fn(removerRepository{tx})
+	})
+}
+
+// Auther returns s as an auther.Repository
+func (s *Storage) Auther() auther.Repository {
+	return autherRepository{*s}
+}
+
+type autherRepository struct {
+	Storage
+}
+
+func (r autherRepository) WithTx(fn func(auther.TxRepository) error) error {
+	return r.withTx(func(tx Storage) error {
+		return fn(autherRepository{tx})
+	})
+}
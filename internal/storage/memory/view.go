@@ -0,0 +1,158 @@
+package memory
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/kelvinatorr/restaurant-tracker/internal/adder"
+	"github.com/kelvinatorr/restaurant-tracker/internal/lister"
+	"github.com/kelvinatorr/restaurant-tracker/internal/storage"
+	"github.com/kelvinatorr/restaurant-tracker/internal/updater"
+)
+
+type savedView struct {
+	id        int64
+	userID    int64
+	name      string
+	query     string
+	shared    bool
+	isDefault bool
+}
+
+func (d *data) savedViewToLister(v savedView) lister.SavedView {
+	return lister.SavedView{
+		ID:            v.id,
+		UserID:        v.userID,
+		UserFirstName: d.users[v.userID].firstName,
+		Name:          v.name,
+		Query:         v.query,
+		Shared:        v.shared,
+		IsDefault:     v.isDefault,
+	}
+}
+
+// checkSavedView enforces the foreign key and unique indexes of the saved_view table for v.
+func (d *data) checkSavedView(v savedView) error {
+	if _, ok := d.users[v.userID]; !ok {
+		return foreignKeyViolation()
+	}
+	for _, other := range d.savedViews {
+		if other.id == v.id || other.userID != v.userID {
+			continue
+		}
+		if other.name == v.name {
+			return uniqueViolation("saved_view.user_id, saved_view.name")
+		}
+		if other.isDefault && v.isDefault {
+			return uniqueViolation("saved_view.user_id")
+		}
+	}
+	return nil
+}
+
+// AddSavedView saves a view and returns its primary key id.
+func (s Storage) AddSavedView(v adder.SavedView) (int64, error) {
+	var id int64
+	err := s.write(func(d *data) error {
+		saved := savedView{
+			id:        d.nextID("saved_view"),
+			userID:    v.UserID,
+			name:      v.Name,
+			query:     v.Query,
+			shared:    v.Shared,
+			isDefault: v.IsDefault,
+		}
+		if err := d.checkSavedView(saved); err != nil {
+			return err
+		}
+		id = saved.id
+		d.savedViews[id] = saved
+		return nil
+	})
+	return id, err
+}
+
+// UpdateSavedView updates a given view, returns the rows affected. The user who owns it doesn't change.
+func (s Storage) UpdateSavedView(v updater.SavedView) (int64, error) {
+	var recordsAffected int64
+	err := s.write(func(d *data) error {
+		saved, ok := d.savedViews[v.ID]
+		if !ok {
+			return nil
+		}
+		saved.name = v.Name
+		saved.query = v.Query
+		saved.shared = v.Shared
+		saved.isDefault = v.IsDefault
+		if err := d.checkSavedView(saved); err != nil {
+			return err
+		}
+		d.savedViews[v.ID] = saved
+		recordsAffected = 1
+		return nil
+	})
+	return recordsAffected, err
+}
+
+// ClearDefaultSavedView makes none of the user's views their default, returns the rows affected.
+func (s Storage) ClearDefaultSavedView(userID int64) (int64, error) {
+	var recordsAffected int64
+	err := s.write(func(d *data) error {
+		for id, v := range d.savedViews {
+			if v.userID == userID && v.isDefault {
+				v.isDefault = false
+				d.savedViews[id] = v
+				recordsAffected++
+			}
+		}
+		return nil
+	})
+	return recordsAffected, err
+}
+
+// RemoveSavedView deletes a given view and returns the rows affected.
+func (s Storage) RemoveSavedView(id int64) (int64, error) {
+	var recordsAffected int64
+	err := s.write(func(d *data) error {
+		if _, ok := d.savedViews[id]; ok {
+			delete(d.savedViews, id)
+			recordsAffected = 1
+		}
+		return nil
+	})
+	return recordsAffected, err
+}
+
+// GetSavedView returns the view with the given id. Returns a storage.ErrNotFound if there isn't one.
+func (s Storage) GetSavedView(id int64) (lister.SavedView, error) {
+	var v lister.SavedView
+	err := s.read(func(d *data) error {
+		saved, ok := d.savedViews[id]
+		if !ok {
+			return &storage.ErrNotFound{Msg: fmt.Sprintf("No saved view with id: %d", id)}
+		}
+		v = d.savedViewToLister(saved)
+		return nil
+	})
+	return v, err
+}
+
+// GetSavedViews returns the views the user owns and the ones other users share, ordered by name.
+func (s Storage) GetSavedViews(userID int64) ([]lister.SavedView, error) {
+	var allViews []lister.SavedView
+	err := s.read(func(d *data) error {
+		for _, v := range d.savedViews {
+			if v.userID == userID || v.shared {
+				allViews = append(allViews, d.savedViewToLister(v))
+			}
+		}
+		sort.Slice(allViews, func(i, j int) bool {
+			if allViews[i].Name != allViews[j].Name {
+				return allViews[i].Name < allViews[j].Name
+			}
+			return allViews[i].ID < allViews[j].ID
+		})
+		return nil
+	})
+	return allViews, err
+}
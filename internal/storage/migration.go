@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Migration is a versioned change to a database schema. Each storage implementation keeps its own ordered list of
+// migrations written in its SQL dialect.
+type Migration struct {
+	Version     int
+	Description string
+	// Up is the SQL that applies the migration.
+	Up string
+}
+
+// MigrationDialect is the SQL a database needs to keep track of the migrations applied to it in the schema_migration
+// table.
+type MigrationDialect struct {
+	// TableExists selects whether the schema_migration table exists as a bool.
+	TableExists string
+	// CreateTable creates the schema_migration table if it doesn't exist.
+	CreateTable string
+	// Lock, if set, is run after CreateTable so only one server migrates a shared database at a time.
+	Lock string
+	// Insert records that the migration with version $1 and description $2 was applied now.
+	Insert string
+}
+
+// SchemaVersion returns the version of the last migration applied to db. A database that has never been migrated has
+// version 0.
+func SchemaVersion(db *sql.DB, d MigrationDialect) (int, error) {
+	var exists bool
+	if err := db.QueryRow(d.TableExists).Scan(&exists); err != nil {
+		return 0, err
+	}
+	if !exists {
+		return 0, nil
+	}
+	return currentSchemaVersion(db)
+}
+
+// Migrate applies every migration newer than db's schema version in order and returns the migrations that were
+// applied. All pending migrations are applied in a single transaction so a failure leaves the database untouched. If
+// dryRun is true the transaction is always rolled back, which checks that the migrations would succeed without
+// changing anything.
+func Migrate(db *sql.DB, d MigrationDialect, migrations []Migration, dryRun bool) ([]Migration, error) {
+	var applied []Migration
+
+	tx, err := db.Begin()
+	if err != nil {
+		return applied, err
+	}
+	// Defer rollback just in case there is a problem. This is a no-op after a commit.
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(d.CreateTable); err != nil {
+		return applied, err
+	}
+	if d.Lock != "" {
+		if _, err := tx.Exec(d.Lock); err != nil {
+			return applied, err
+		}
+	}
+
+	version, err := currentSchemaVersion(tx)
+	if err != nil {
+		return applied, err
+	}
+
+	for _, m := range migrations {
+		if m.Version <= version {
+			continue
+		}
+		if _, err := tx.Exec(m.Up); err != nil {
+			return applied, fmt.Errorf("Migration %d (%s) failed: %s", m.Version, m.Description, err)
+		}
+		if _, err := tx.Exec(d.Insert, m.Version, m.Description); err != nil {
+			return applied, err
+		}
+		applied = append(applied, m)
+	}
+
+	if dryRun {
+		return applied, nil
+	}
+	return applied, tx.Commit()
+}
+
+// queryRower implements the QueryRow function of sql.DB and sql.Tx
+type queryRower interface {
+	QueryRow(string, ...interface{}) *sql.Row
+}
+
+func currentSchemaVersion(q queryRower) (int, error) {
+	var version int
+	row := q.QueryRow(`SELECT COALESCE(max(version), 0) FROM schema_migration`)
+	err := row.Scan(&version)
+	return version, err
+}
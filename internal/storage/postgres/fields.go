@@ -0,0 +1,75 @@
+package postgres
+
+import "github.com/kelvinatorr/restaurant-tracker/internal/lister"
+
+// gmapsRatingSQL is the Google Maps rating. It is rounded because it is read into a float32 and the page cursors need
+// the same number back. Postgres only rounds a numeric to a number of places.
+const gmapsRatingSQL = "round(CAST(gp.rating as NUMERIC), 1)"
+
+// ratingDiffSQL is our average rating minus the Google Maps rating, NULL unless the restaurant has both.
+const ratingDiffSQL = "round(CAST(ratings.avg_rating - gp.rating as NUMERIC), 1)"
+
+// RestaurantSortFields uses the expressions of the select list instead of their aliases because the sort fields are
+// also compared in the WHERE clause when paging.
+func (s Storage) RestaurantSortFields() map[string]string {
+	restaurantFields := make(map[string]string)
+	restaurantFields["name"] = "res.name"
+	restaurantFields["cuisine"] = "res.cuisine"
+	restaurantFields["city"] = "city.name"
+	restaurantFields["state"] = "city.state"
+	restaurantFields["last_visit"] = "COALESCE(last_visits.last_visit, '')"
+	restaurantFields["avg_rating"] = "COALESCE(ratings.avg_rating, 0)"
+	restaurantFields["distance"] = distanceSort
+	restaurantFields["price_level"] = "COALESCE(gp.price_level, 0)"
+	restaurantFields["gmaps_rating"] = "COALESCE(" + gmapsRatingSQL + ", 0)"
+	restaurantFields["gmaps_ratings_total"] = "COALESCE(gp.user_ratings_total, 0)"
+	restaurantFields["gmaps_business_status"] = "COALESCE(gp.business_status, '')"
+	restaurantFields["rating_diff"] = "COALESCE(" + ratingDiffSQL + ", 0)"
+	restaurantFields["id"] = "res.id"
+	return restaurantFields
+}
+
+// RestaurantFilterFields uses the underlying columns instead of the select list aliases because Postgres doesn't allow
+// aliases in a WHERE clause. location is the latitude and longitude columns, see nearSQL.
+func (s Storage) RestaurantFilterFields() map[string]lister.Field {
+	restaurantFields := make(map[string]lister.Field)
+	restaurantFields["name"] = lister.Field{Name: "res.name", Type: lister.FieldText}
+	restaurantFields["cuisine"] = lister.Field{Name: "res.cuisine", Type: lister.FieldText}
+	restaurantFields["city"] = lister.Field{Name: "city.name", Type: lister.FieldText}
+	restaurantFields["state"] = lister.Field{Name: "city.state", Type: lister.FieldText}
+	restaurantFields["last_visit"] = lister.Field{Name: "last_visits.last_visit", Type: lister.FieldDate}
+	restaurantFields["avg_rating"] = lister.Field{Name: "ratings.avg_rating", Type: lister.FieldReal}
+	restaurantFields["business_status"] = lister.Field{Name: "res.business_status", Type: lister.FieldInt}
+	restaurantFields["location"] = lister.Field{Name: "res.latitude, res.longitude", Type: lister.FieldGeo}
+	restaurantFields["price_level"] = lister.Field{Name: "gp.price_level", Type: lister.FieldInt}
+	restaurantFields["gmaps_rating"] = lister.Field{Name: gmapsRatingSQL, Type: lister.FieldReal}
+	restaurantFields["gmaps_ratings_total"] = lister.Field{Name: "gp.user_ratings_total", Type: lister.FieldInt}
+	restaurantFields["gmaps_business_status"] = lister.Field{Name: "gp.business_status", Type: lister.FieldText}
+	restaurantFields["rating_diff"] = lister.Field{Name: ratingDiffSQL, Type: lister.FieldReal}
+	return restaurantFields
+}
+
+func (s Storage) VisitSortFields() map[string]string {
+	visitFields := make(map[string]string)
+	visitFields["date"] = "visit_datetime"
+	visitFields["rating"] = "COALESCE(visit_ratings.avg_rating, 0)"
+	visitFields["attendees"] = "COALESCE(visit_ratings.attendees, 0)"
+	visitFields["restaurant"] = "res.name"
+	visitFields["id"] = "v.id"
+	return visitFields
+}
+
+// VisitFilterFields uses the underlying columns like RestaurantFilterFields. user is the ids of the users at the visit.
+func (s Storage) VisitFilterFields() map[string]lister.Field {
+	visitFields := make(map[string]lister.Field)
+	visitFields["date"] = lister.Field{Name: "visit_datetime", Type: lister.FieldDate}
+	visitFields["user"] = lister.Field{Name: "SELECT user_id FROM visit_user WHERE visit_id = v.id",
+		Type: lister.FieldIDSet}
+	visitFields["rating"] = lister.Field{Name: "visit_ratings.avg_rating", Type: lister.FieldReal}
+	visitFields["attendees"] = lister.Field{Name: "COALESCE(visit_ratings.attendees, 0)", Type: lister.FieldInt}
+	visitFields["has_note"] = lister.Field{Name: "CASE WHEN COALESCE(v.note, '') = '' THEN 0 ELSE 1 END",
+		Type: lister.FieldInt}
+	visitFields["restaurant"] = lister.Field{Name: "res.name", Type: lister.FieldText}
+	visitFields["restaurant_id"] = lister.Field{Name: "v.restaurant_id", Type: lister.FieldInt}
+	return visitFields
+}
@@ -0,0 +1,42 @@
+package postgres
+
+import (
+	"github.com/kelvinatorr/restaurant-tracker/internal/storage"
+)
+
+// migrationDialect keeps track of the applied migrations in Postgres.
+var migrationDialect = storage.MigrationDialect{
+	TableExists: `SELECT to_regclass('schema_migration') IS NOT NULL`,
+	CreateTable: `
+		CREATE TABLE IF NOT EXISTS schema_migration (
+			version INTEGER PRIMARY KEY,
+			description TEXT NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL
+		)
+	`,
+	// Other web-servers may share this database, so only one of them migrates it at a time.
+	Lock: `LOCK TABLE schema_migration IN EXCLUSIVE MODE`,
+	Insert: `
+		INSERT INTO
+			schema_migration(version, description, applied_at)
+		VALUES
+			($1, $2, now())
+	`,
+}
+
+// LatestSchemaVersion returns the version of the newest migration that ships with this binary.
+func (s Storage) LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// SchemaVersion returns the version of the last migration applied to the database. A database that has never been
+// migrated has version 0.
+func (s Storage) SchemaVersion() (int, error) {
+	return storage.SchemaVersion(s.db, migrationDialect)
+}
+
+// Migrate applies the pending migrations, or only checks that they would succeed if dryRun is true. See
+// storage.Migrate.
+func (s Storage) Migrate(dryRun bool) ([]storage.Migration, error) {
+	return storage.Migrate(s.db, migrationDialect, migrations, dryRun)
+}
@@ -0,0 +1,168 @@
+package postgres
+
+import "github.com/kelvinatorr/restaurant-tracker/internal/storage"
+
+// migrations is the ordered list of schema changes that are applied to the database. Never edit a migration that has
+// already been released, add a new one to the end of the list instead. Versions and descriptions match the sqlite
+// migrations so both databases are at the same schema version at the same release.
+var migrations = []storage.Migration{
+	{
+		Version:     1,
+		Description: "Create the city, restaurant, visit, visit_user, user and gmaps_place tables",
+		// Datetimes are stored as RFC3339 text, like they are in sqlite, so both backends sort and filter them the same
+		// way. "user" is a reserved word in Postgres so it is always quoted.
+		Up: `
+			CREATE TABLE IF NOT EXISTS city (
+				id BIGSERIAL PRIMARY KEY,
+				name TEXT NOT NULL,
+				state TEXT NOT NULL,
+				CHECK (length(state) = 2) -- Use ISO 3166-1 alpha-2 country code if not a US state
+			);
+			CREATE UNIQUE INDEX IF NOT EXISTS city_name_state on city (name, state);
+
+			CREATE TABLE IF NOT EXISTS restaurant (
+				id BIGSERIAL PRIMARY KEY,
+				name TEXT NOT NULL,
+				cuisine TEXT NOT NULL,
+				note TEXT,
+				address TEXT,
+				city_id BIGINT NOT NULL REFERENCES city(id) ON UPDATE CASCADE, -- Must track id in city table
+				zipcode TEXT,
+				latitude DOUBLE PRECISION,
+				longitude DOUBLE PRECISION,
+				business_status INTEGER NOT NULL DEFAULT 1
+			);
+
+			CREATE TABLE IF NOT EXISTS visit (
+				id BIGSERIAL PRIMARY KEY,
+				restaurant_id BIGINT NOT NULL REFERENCES restaurant(id) ON UPDATE CASCADE ON DELETE CASCADE, -- Must track the id in restaurant table
+				visit_datetime TEXT NOT NULL DEFAULT (to_char(now() at time zone 'utc', 'YYYY-MM-DD"T"HH24:MI:SS"Z"')), -- RFC3339 UTC timezone
+				note TEXT,
+				CHECK (length(visit_datetime) = 20)
+			);
+			CREATE INDEX IF NOT EXISTS visit_restaurant on visit (restaurant_id);
+
+			CREATE TABLE IF NOT EXISTS "user" (
+				id BIGSERIAL PRIMARY KEY,
+				first_name TEXT NOT NULL,
+				last_name TEXT NOT NULL,
+				email TEXT NOT NULL,
+				password_hash TEXT NOT NULL,
+				remember_token TEXT
+			);
+			CREATE UNIQUE INDEX IF NOT EXISTS email on "user" (email);
+
+			CREATE TABLE IF NOT EXISTS visit_user (
+				id BIGSERIAL PRIMARY KEY,
+				visit_id BIGINT NOT NULL REFERENCES visit(id) ON UPDATE CASCADE ON DELETE CASCADE, -- Must track the id in the visit table
+				user_id BIGINT NOT NULL REFERENCES "user"(id) ON UPDATE CASCADE, -- Must track the id in user table
+				rating INTEGER,
+				CHECK ((rating > 0 and rating < 6) or rating is NULL)
+			);
+			CREATE INDEX IF NOT EXISTS visit_user_visit_id on visit_user (visit_id);
+			-- Can't have the same user more than once in the same visit.
+			CREATE UNIQUE INDEX IF NOT EXISTS visit_user_visit_id_user_id on visit_user (visit_id, user_id);
+
+			CREATE TABLE IF NOT EXISTS gmaps_place (
+				id BIGSERIAL PRIMARY KEY,
+				last_updated TEXT NOT NULL DEFAULT (to_char(now() at time zone 'utc', 'YYYY-MM-DD"T"HH24:MI:SS"Z"')), -- RFC3339 UTC timezone
+				place_id TEXT NOT NULL UNIQUE, -- Don't use this as the PK because it can change over time
+				business_status TEXT,
+				formatted_phone_number TEXT,
+				name TEXT NOT NULL,
+				price_level INTEGER,
+				rating DOUBLE PRECISION,
+				url TEXT, -- The url to this place Google Maps
+				user_ratings_total INTEGER,
+				utc_offset INTEGER, -- The number of minutes this place’s current timezone is offset from UTC
+				website TEXT,
+				restaurant_id BIGINT NOT NULL REFERENCES restaurant(id) ON UPDATE CASCADE ON DELETE CASCADE
+			);
+		`,
+	},
+	{
+		Version:     2,
+		Description: "Add is_admin to user and make the first user an admin",
+		Up: `
+			ALTER TABLE "user" ADD COLUMN is_admin BOOLEAN NOT NULL DEFAULT false;
+			UPDATE "user" SET is_admin = true WHERE id = (SELECT min(id) FROM "user");
+		`,
+	},
+	{
+		Version:     3,
+		Description: "Add deleted_at to restaurant and visit for the trash",
+		Up: `
+			ALTER TABLE restaurant ADD COLUMN deleted_at TEXT; -- RFC3339 UTC timezone, NULL unless it is in the trash
+			ALTER TABLE visit ADD COLUMN deleted_at TEXT; -- RFC3339 UTC timezone, NULL unless it is in the trash
+		`,
+	},
+	{
+		Version:     4,
+		Description: "Create the audit_log table",
+		// restaurant_id isn't a foreign key so a restaurant's history outlives it being purged.
+		Up: `
+			CREATE TABLE audit_log (
+				id BIGSERIAL PRIMARY KEY,
+				user_id BIGINT REFERENCES "user"(id) ON UPDATE CASCADE ON DELETE SET NULL, -- NULL if no user made the change
+				entity_type TEXT NOT NULL,
+				entity_id BIGINT NOT NULL,
+				restaurant_id BIGINT, -- The restaurant the entity belongs to
+				action TEXT NOT NULL,
+				created_at TEXT NOT NULL, -- RFC3339 UTC timezone
+				diff TEXT NOT NULL -- JSON object of the fields that changed
+			);
+			CREATE INDEX audit_log_entity on audit_log (entity_type, entity_id);
+			CREATE INDEX audit_log_restaurant on audit_log (restaurant_id);
+		`,
+	},
+	{
+		Version:     5,
+		Description: "Create the saved_view table",
+		Up: `
+			CREATE TABLE saved_view (
+				id BIGSERIAL PRIMARY KEY,
+				user_id BIGINT NOT NULL REFERENCES "user"(id) ON UPDATE CASCADE ON DELETE CASCADE, -- The user who owns it
+				name TEXT NOT NULL,
+				query TEXT NOT NULL, -- The filter, sort and search query params of the restaurant list
+				shared BOOLEAN NOT NULL DEFAULT false, -- true if every user can use it
+				is_default BOOLEAN NOT NULL DEFAULT false -- true if it is the restaurant list its owner sees on the home page
+			);
+			-- A user can't have two views with the same name or more than one default view.
+			CREATE UNIQUE INDEX saved_view_user_id_name on saved_view (user_id, name);
+			CREATE UNIQUE INDEX saved_view_default on saved_view (user_id) WHERE is_default;
+		`,
+	},
+	{
+		Version:     6,
+		Description: "Index the restaurant locations and add the haversine_km function",
+		// The bounding box of a near filter is a range of latitudes, which is all the index can narrow down.
+		// haversine_km(lat1, lng1, lat2, lng2) is the km between two points, like the sqlite function of the same name.
+		Up: `
+			CREATE INDEX restaurant_location on restaurant (latitude, longitude);
+			CREATE FUNCTION haversine_km(lat1 DOUBLE PRECISION, lng1 DOUBLE PRECISION, lat2 DOUBLE PRECISION,
+				lng2 DOUBLE PRECISION) RETURNS DOUBLE PRECISION AS $$
+				SELECT 2 * 6371.0 * asin(sqrt(least(1, power(sin(radians(lat2 - lat1) / 2), 2) +
+					cos(radians(lat1)) * cos(radians(lat2)) * power(sin(radians(lng2 - lng1) / 2), 2))))
+			$$ LANGUAGE SQL IMMUTABLE STRICT;
+		`,
+	},
+	{
+		Version:     7,
+		Description: "Create the api_token table",
+		Up: `
+			CREATE TABLE api_token (
+				id BIGSERIAL PRIMARY KEY,
+				user_id BIGINT NOT NULL REFERENCES "user"(id) ON UPDATE CASCADE ON DELETE CASCADE, -- The user who owns it
+				name TEXT NOT NULL,
+				token_hash TEXT NOT NULL, -- SHA-256 hex of the token, the token itself isn't saved
+				read_only BOOLEAN NOT NULL DEFAULT false, -- true if it can only be used to read
+				created_at TEXT NOT NULL, -- RFC3339 UTC timezone
+				last_used_at TEXT, -- RFC3339 UTC timezone, NULL until it is used
+				expires_at TEXT -- RFC3339 UTC timezone, NULL if it never expires
+			);
+			CREATE UNIQUE INDEX api_token_hash on api_token (token_hash);
+			-- A user can't have two tokens with the same name.
+			CREATE UNIQUE INDEX api_token_user_id_name on api_token (user_id, name);
+		`,
+	},
+}
@@ -0,0 +1,71 @@
+package postgres
+
+import (
+	"strings"
+
+	"github.com/kelvinatorr/restaurant-tracker/internal/lister"
+)
+
+// SearchRestaurants builds the text search documents when it runs instead of keeping an index, so it never goes stale.
+// That is fast enough for the few hundred restaurants one household tracks. Matches in the name count the most, then
+// the cuisine and city, then the notes.
+func (s Storage) SearchRestaurants(terms []string, limit int) ([]lister.SearchMatch, error) {
+	var allMatches []lister.SearchMatch
+	// Terms are only letters and numbers so they can't be read as tsquery syntax. :* makes them match as a prefix.
+	prefixes := make([]string, len(terms))
+	for i, t := range terms {
+		prefixes[i] = t + ":*"
+	}
+	sqlStatement := `
+		SELECT
+			id,
+			ts_headline('simple', body, query, 'MaxWords=12, MinWords=4, StartSel=' || $2 || ', StopSel=' || $3),
+			ts_rank(document, query) as score
+		FROM
+			(
+				SELECT
+					res.id,
+					setweight(to_tsvector('simple', res.name), 'A') ||
+						setweight(to_tsvector('simple', res.cuisine || ' ' || city.name || ' ' || city.state), 'B') ||
+						setweight(to_tsvector('simple', COALESCE(res.note, '') || ' ' || COALESCE(visit_notes.notes, '')), 'D')
+						as document,
+					concat_ws(' ', res.name, res.cuisine, city.name, city.state, res.note, visit_notes.notes) as body
+				FROM
+					restaurant as res
+					inner join city on city.id = res.city_id
+					left join (
+						SELECT
+							restaurant_id,
+							string_agg(note, ' ' ORDER BY id) as notes
+						FROM
+							visit
+						WHERE
+							deleted_at IS NULL
+						GROUP BY
+							restaurant_id
+					) as visit_notes on visit_notes.restaurant_id = res.id
+				WHERE
+					res.deleted_at IS NULL
+			) as docs,
+			to_tsquery('simple', $1) as query
+		WHERE
+			document @@ query
+		ORDER BY
+			score DESC, id
+		LIMIT $4
+	`
+	dbRows, err := s.q.Query(sqlStatement, strings.Join(prefixes, " & "), lister.SearchMatchStart,
+		lister.SearchMatchEnd, limit)
+	if err != nil {
+		return allMatches, err
+	}
+	defer dbRows.Close()
+	for dbRows.Next() {
+		var m lister.SearchMatch
+		if err := dbRows.Scan(&m.RestaurantID, &m.Snippet, &m.Rank); err != nil {
+			return allMatches, err
+		}
+		allMatches = append(allMatches, m)
+	}
+	return allMatches, dbRows.Err()
+}
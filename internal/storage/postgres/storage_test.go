@@ -0,0 +1,376 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kelvinatorr/restaurant-tracker/internal/adder"
+	"github.com/kelvinatorr/restaurant-tracker/internal/lister"
+	"github.com/kelvinatorr/restaurant-tracker/internal/remover"
+	"github.com/kelvinatorr/restaurant-tracker/internal/storage"
+	"github.com/kelvinatorr/restaurant-tracker/internal/updater"
+)
+
+// newTestStorage returns a migrated Storage in a schema of its own that is dropped when the test ends. The tests are
+// skipped unless RT_POSTGRES_DSN is the connection string of a database they can create schemas in.
+func newTestStorage(t *testing.T) *Storage {
+	dsn := os.Getenv("RT_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("Set RT_POSTGRES_DSN to run the Postgres tests")
+	}
+	admin, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := fmt.Sprintf("rt_test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		admin.Close()
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec("DROP SCHEMA " + schema + " CASCADE"); err != nil {
+			t.Error(err)
+		}
+		admin.Close()
+	})
+
+	s, err := NewStorage(withSearchPath(dsn, schema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.CloseStorage)
+	if _, err := s.Migrate(false); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// withSearchPath adds a search_path to a URL or key=value connection string so every connection uses schema.
+func withSearchPath(dsn string, schema string) string {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		sep := "?"
+		if strings.Contains(dsn, "?") {
+			sep = "&"
+		}
+		return dsn + sep + "search_path=" + schema
+	}
+	return dsn + " search_path=" + schema
+}
+
+// addRestaurant adds a restaurant and its city straight to the storage.
+func addRestaurant(t *testing.T, s *Storage, name string, cuisine string, city string) int64 {
+	t.Helper()
+	cityID, err := s.GetCityIDByNameAndState(city, "WA")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cityID == 0 {
+		if cityID, err = s.AddCity(city, "WA"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	id, err := s.AddRestaurant(adder.Restaurant{Name: name, Cuisine: cuisine, CityID: cityID, BusinessStatus: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func TestMigrate(t *testing.T) {
+	s := newTestStorage(t)
+
+	version, err := s.SchemaVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != s.LatestSchemaVersion() {
+		t.Errorf("SchemaVersion() = %d, want %d", version, s.LatestSchemaVersion())
+	}
+	applied, err := s.Migrate(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 0 {
+		t.Errorf("Migrate() on a migrated database applied %d migrations, want 0", len(applied))
+	}
+}
+
+func TestRestaurantCRUD(t *testing.T) {
+	s := newTestStorage(t)
+
+	id := addRestaurant(t, s, "Pho Saigon", "Vietnamese", "Seattle")
+	if _, err := s.AddGmapsPlace(adder.GmapsPlace{PlaceID: "place-1", Name: "Pho Saigon", Rating: 4.5,
+		RestaurantID: id}); err != nil {
+		t.Fatal(err)
+	}
+	r, err := s.GetRestaurant(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Name != "Pho Saigon" || r.CityState.Name != "Seattle" || r.GmapsPlace.PlaceID != "place-1" {
+		t.Errorf("GetRestaurant() = %+v", r)
+	}
+	dup, err := s.IsDuplicateRestaurant(adder.Restaurant{Name: "pho saigon",
+		CityState: adder.CityState{Name: "Seattle", State: "WA"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !dup {
+		t.Error("IsDuplicateRestaurant() = false for the same name in the same city")
+	}
+
+	n, err := s.UpdateRestaurant(updater.Restaurant{ID: id, Name: "Pho Saigon II", Cuisine: "Vietnamese",
+		CityID: r.CityState.ID, BusinessStatus: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("UpdateRestaurant() affected %d rows, want 1", n)
+	}
+	if r, err = s.GetRestaurant(id); err != nil {
+		t.Fatal(err)
+	}
+	if r.Name != "Pho Saigon II" {
+		t.Errorf("Name after UpdateRestaurant() = %s, want Pho Saigon II", r.Name)
+	}
+
+	userID, err := s.AddUser(adder.User{FirstName: "Alex", LastName: "Rivera", Email: "alex@example.com",
+		PasswordHash: "hash"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	visitID, err := s.AddVisit(adder.Visit{RestaurantID: id, VisitDateTime: "2021-03-04T00:00:00Z", Note: "Rare pho"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A rating of 0 is saved as no rating.
+	if _, err := s.AddVisitUser(adder.VisitUser{VisitID: visitID, UserID: userID, Rating: 0}); err != nil {
+		t.Fatal(err)
+	}
+	vus, err := s.GetVisitUsersByVisitID(visitID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vus) != 1 || vus[0].User.ID != userID || vus[0].Rating != 0 {
+		t.Errorf("GetVisitUsersByVisitID() = %+v", vus)
+	}
+
+	if n, err = s.RemoveRestaurant(remover.Restaurant{ID: id}); err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("RemoveRestaurant() affected %d rows, want 1", n)
+	}
+	if _, err := s.GetRestaurant(id); !storage.IsNotFound(err) {
+		t.Errorf("GetRestaurant() of a removed restaurant returned %v, want a not found error", err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	s := newTestStorage(t)
+	id := addRestaurant(t, s, "Pho Saigon", "Vietnamese", "Seattle")
+	user := adder.User{FirstName: "Alex", LastName: "Rivera", Email: "alex@example.com", PasswordHash: "hash"}
+	if _, err := s.AddUser(user); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		write func() error
+		is    func(error) bool
+	}{
+		{"missing restaurant", func() error {
+			_, err := s.GetRestaurant(id + 100)
+			return err
+		}, storage.IsNotFound},
+		{"duplicate email", func() error {
+			_, err := s.AddUser(user)
+			return err
+		}, storage.IsUniqueViolation},
+		{"state too long", func() error {
+			_, err := s.AddCity("Vancouver", "BCA")
+			return err
+		}, storage.IsConstraintViolation},
+		{"rating out of range", func() error {
+			visitID, err := s.AddVisit(adder.Visit{RestaurantID: id, VisitDateTime: "2021-03-04T00:00:00Z"})
+			if err != nil {
+				return err
+			}
+			_, err = s.AddVisitUser(adder.VisitUser{VisitID: visitID, UserID: 1, Rating: 6})
+			return err
+		}, storage.IsConstraintViolation},
+		{"visit of missing restaurant", func() error {
+			_, err := s.AddVisit(adder.Visit{RestaurantID: id + 100, VisitDateTime: "2021-03-04T00:00:00Z"})
+			return err
+		}, storage.IsConstraintViolation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.write(); !tt.is(err) {
+				t.Errorf("got error %v", err)
+			}
+		})
+	}
+}
+
+func TestSearch(t *testing.T) {
+	s := newTestStorage(t)
+	thai := addRestaurant(t, s, "Bangkok Kitchen", "Thai", "Seattle")
+	addRestaurant(t, s, "Taqueria", "Mexican", "Tacoma")
+	visitID, err := s.AddVisit(adder.Visit{RestaurantID: thai, VisitDateTime: "2021-03-04T00:00:00Z",
+		Note: "The khao soi was amazing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if visitID == 0 {
+		t.Fatal("AddVisit() returned id 0")
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"bangkok", []string{"Bangkok Kitchen"}},
+		{"tac", []string{"Taqueria"}},
+		{"khao", []string{"Bangkok Kitchen"}},
+		{"thai seattle", []string{"Bangkok Kitchen"}},
+		{"sushi", nil},
+	}
+	l := lister.NewService(s)
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			results, err := l.Search(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, r := range results {
+				got = append(got, r.Restaurant.Name)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Search(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPaging(t *testing.T) {
+	s := newTestStorage(t)
+	for _, name := range []string{"Echo", "Alpha", "Delta", "Charlie", "Bravo"} {
+		addRestaurant(t, s, name, "Thai", "Seattle")
+	}
+	addRestaurant(t, s, "Foxtrot", "Lao", "Tacoma")
+	l := lister.NewService(s)
+
+	tests := []struct {
+		name  string
+		query url.Values
+		want  string
+	}{
+		{"by name", url.Values{"sort": {"name"}, "limit": {"2"}}, "Alpha,Bravo,Charlie,Delta,Echo,Foxtrot"},
+		{"by name desc", url.Values{"sort": {"name:desc"}, "limit": {"4"}}, "Foxtrot,Echo,Delta,Charlie,Bravo,Alpha"},
+		{"filtered", url.Values{"sort": {"name"}, "limit": {"2"}, "filter": {"cuisine:Thai AND NOT name:Delta"}},
+			"Alpha,Bravo,Charlie,Echo"},
+		{"either city", url.Values{"sort": {"city,name"}, "limit": {"3"}, "filter": {"cuisine:Lao OR name:Echo"}},
+			"Echo,Foxtrot"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			qp := tt.query
+			for pages := 0; ; pages++ {
+				if pages > 10 {
+					t.Fatal("Too many pages")
+				}
+				page, err := l.GetRestaurants(qp)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, r := range page.Restaurants {
+					got = append(got, r.Name)
+				}
+				if page.Page.Total != int64(len(strings.Split(tt.want, ","))) {
+					t.Errorf("Total = %d, want %d", page.Page.Total, len(strings.Split(tt.want, ",")))
+				}
+				if page.Page.Next == "" {
+					break
+				}
+				next := url.Values{}
+				for k, v := range tt.query {
+					next[k] = v
+				}
+				next.Set("after", page.Page.Next)
+				qp = next
+			}
+			if strings.Join(got, ",") != tt.want {
+				t.Errorf("GetRestaurants() = %s, want %s", strings.Join(got, ","), tt.want)
+			}
+		})
+	}
+}
+
+func TestTrash(t *testing.T) {
+	s := newTestStorage(t)
+	kept := addRestaurant(t, s, "Alpha", "Thai", "Seattle")
+	trashed := addRestaurant(t, s, "Bravo", "Thai", "Seattle")
+	visitID, err := s.AddVisit(adder.Visit{RestaurantID: kept, VisitDateTime: "2021-03-04T00:00:00Z"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := lister.NewService(s)
+	r := remover.NewService(s.Remover())
+
+	names := func() string {
+		t.Helper()
+		page, err := l.GetRestaurants(url.Values{"sort": {"name"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, r := range page.Restaurants {
+			got = append(got, r.Name)
+		}
+		return strings.Join(got, ",")
+	}
+
+	if _, err := r.RemoveRestaurant(remover.Restaurant{ID: trashed}, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.RemoveVisit(remover.Visit{ID: visitID, RestaurantID: kept}, 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := names(); got != "Alpha" {
+		t.Errorf("Restaurants with Bravo in the trash = %s, want Alpha", got)
+	}
+	trash, err := l.GetTrash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trash.Restaurants) != 1 || trash.Restaurants[0].ID != trashed || len(trash.Visits) != 1 {
+		t.Errorf("GetTrash() = %+v", trash)
+	}
+
+	if _, err := r.RestoreRestaurant(trashed, 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := names(); got != "Alpha,Bravo" {
+		t.Errorf("Restaurants after restoring Bravo = %s, want Alpha,Bravo", got)
+	}
+
+	// The visit is still in the trash and is purged for good.
+	n, err := r.Purge(time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("Purge() removed %d rows, want 1", n)
+	}
+	if _, err := s.GetVisit(visitID, kept); !storage.IsNotFound(err) {
+		t.Errorf("GetVisit() of a purged visit returned %v, want a not found error", err)
+	}
+}
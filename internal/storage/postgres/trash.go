@@ -0,0 +1,122 @@
+package postgres
+
+import (
+	"fmt"
+
+	"github.com/kelvinatorr/restaurant-tracker/internal/lister"
+)
+
+// TrashRestaurant moves a restaurant to the trash by setting its deleted_at and returns the rows affected. Its visits
+// are hidden with it but keep their own deleted_at.
+func (s Storage) TrashRestaurant(id int64, deletedAt string) (int64, error) {
+	return s.setDeletedAt("restaurant", id, deletedAt)
+}
+
+// RestoreRestaurant takes a restaurant out of the trash and returns the rows affected.
+func (s Storage) RestoreRestaurant(id int64) (int64, error) {
+	return s.setDeletedAt("restaurant", id, nil)
+}
+
+// TrashVisit moves a visit to the trash by setting its deleted_at and returns the rows affected.
+func (s Storage) TrashVisit(id int64, deletedAt string) (int64, error) {
+	return s.setDeletedAt("visit", id, deletedAt)
+}
+
+// RestoreVisit takes a visit out of the trash and returns the rows affected.
+func (s Storage) RestoreVisit(id int64) (int64, error) {
+	return s.setDeletedAt("visit", id, nil)
+}
+
+// setDeletedAt sets the deleted_at of a row that isn't already in that state. A nil deletedAt restores the row.
+func (s Storage) setDeletedAt(tableName string, id int64, deletedAt interface{}) (int64, error) {
+	condition := "deleted_at IS NULL"
+	if deletedAt == nil {
+		condition = "deleted_at IS NOT NULL"
+	}
+	sqlStatement := `
+		UPDATE
+			%s
+		SET
+			deleted_at = $1
+		WHERE
+			id = $2
+			and %s
+	`
+	// Never pass tableName from user input!
+	sqlStatement = fmt.Sprintf(sqlStatement, tableName, condition)
+	return s.execRows(sqlStatement, deletedAt, id)
+}
+
+// GetTrashedRestaurants returns the restaurants in the trash, most recently deleted first.
+func (s Storage) GetTrashedRestaurants() ([]lister.TrashedRestaurant, error) {
+	var allRestaurants []lister.TrashedRestaurant
+	sqlStatement := `
+		SELECT
+			res.id,
+			res.name,
+			city.id,
+			city.name,
+			city.state,
+			res.deleted_at
+		FROM
+			restaurant as res
+			inner join city on city.id = res.city_id
+		WHERE
+			res.deleted_at IS NOT NULL
+		ORDER BY
+			res.deleted_at desc,
+			res.id
+	`
+	dbRows, err := s.q.Query(sqlStatement)
+	if err != nil {
+		return allRestaurants, err
+	}
+	defer dbRows.Close()
+	for dbRows.Next() {
+		var r lister.TrashedRestaurant
+		err = dbRows.Scan(&r.ID, &r.Name, &r.CityState.ID, &r.CityState.Name, &r.CityState.State, &r.DeletedAt)
+		if err != nil {
+			return allRestaurants, err
+		}
+		allRestaurants = append(allRestaurants, r)
+	}
+	return allRestaurants, dbRows.Err()
+}
+
+// GetTrashedVisits returns the visits in the trash, most recently deleted first. Visits of restaurants in the trash are
+// left out because they can only come back with their restaurant.
+func (s Storage) GetTrashedVisits() ([]lister.TrashedVisit, error) {
+	var allVisits []lister.TrashedVisit
+	sqlStatement := `
+		SELECT
+			v.id,
+			v.restaurant_id,
+			res.name,
+			v.visit_datetime,
+			COALESCE(v.note, ''),
+			v.deleted_at
+		FROM
+			visit as v
+			inner join restaurant as res on res.id = v.restaurant_id
+		WHERE
+			v.deleted_at IS NOT NULL
+			and res.deleted_at IS NULL
+		ORDER BY
+			v.deleted_at desc,
+			v.id
+	`
+	dbRows, err := s.q.Query(sqlStatement)
+	if err != nil {
+		return allVisits, err
+	}
+	defer dbRows.Close()
+	for dbRows.Next() {
+		var v lister.TrashedVisit
+		err = dbRows.Scan(&v.ID, &v.RestaurantID, &v.RestaurantName, &v.VisitDateTime, &v.Note, &v.DeletedAt)
+		if err != nil {
+			return allVisits, err
+		}
+		allVisits = append(allVisits, v)
+	}
+	return allVisits, dbRows.Err()
+}
@@ -0,0 +1,102 @@
+package postgres
+
+import (
+	"database/sql"
+
+	"github.com/kelvinatorr/restaurant-tracker/internal/adder"
+	"github.com/kelvinatorr/restaurant-tracker/internal/auther"
+	"github.com/kelvinatorr/restaurant-tracker/internal/remover"
+	"github.com/kelvinatorr/restaurant-tracker/internal/updater"
+)
+
+// writeLockKey is the advisory lock every transaction takes so they run one at a time, like they do with sqlite. The
+// services check for duplicates before they write, which is only safe if no other transaction writes in between. The
+// number is arbitrary, it just needs to not be used by other apps sharing the database.
+const writeLockKey int64 = 5263641730117
+
+// withTx begins a transaction and calls fn with a Storage that runs all of its queries in it, so reads see the writes
+// made before them. The transaction is committed if fn returns nil and rolled back otherwise. Each call gets its own
+// transaction so concurrent requests can't commit or roll back each other's work.
+func (s Storage) withTx(fn func(Storage) error) error {
+	// Already in a transaction so just join it.
+	if _, ok := s.q.(*sql.Tx); ok {
+		return fn(s)
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return translateError(err)
+	}
+	// Defer rollback just in case there is a problem. This is a no-op after a commit.
+	defer tx.Rollback()
+	// Released when the transaction ends.
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, writeLockKey); err != nil {
+		return err
+	}
+	if err := fn(Storage{db: s.db, q: tx}); err != nil {
+		return err
+	}
+	return translateError(tx.Commit())
+}
+
+// Each service defines a WithTx that takes its own TxRepository, so Storage is wrapped in a type per service that
+// implements that service's Repository.
+
+// Adder returns s as an adder.Repository
+func (s *Storage) Adder() adder.Repository {
+	return adderRepository{*s}
+}
+
+type adderRepository struct {
+	Storage
+}
+
+func (r adderRepository) WithTx(fn func(adder.TxRepository) error) error {
+	return r.withTx(func(tx Storage) error {
+		return fn(adderRepository{tx})
+	})
+}
+
+// Updater returns s as an updater.Repository
+func (s *Storage) Updater() updater.Repository {
+	return updaterRepository{*s}
+}
+
+type updaterRepository struct {
+	Storage
+}
+
+func (r updaterRepository) WithTx(fn func(updater.TxRepository) error) error {
+	return r.withTx(func(tx Storage) error {
+		return fn(updaterRepository{tx})
+	})
+}
+
+// Remover returns s as a remover.Repository
+func (s *Storage) Remover() remover.Repository {
+	return removerRepository{*s}
+}
+
+type removerRepository struct {
+	Storage
+}
+
+func (r removerRepository) WithTx(fn func(remover.TxRepository) error) error {
+	return r.withTx(func(tx Storage) error {
+		return fn(removerRepository{tx})
+	})
+}
+
+// Auther returns s as an auther.Repository
+func (s *Storage) Auther() auther.Repository {
+	return autherRepository{*s}
+}
+
+type autherRepository struct {
+	Storage
+}
+
+func (r autherRepository) WithTx(fn func(auther.TxRepository) error) error {
+	return r.withTx(func(tx Storage) error {
+		return fn(autherRepository{tx})
+	})
+}
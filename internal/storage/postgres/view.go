@@ -0,0 +1,127 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/kelvinatorr/restaurant-tracker/internal/adder"
+	"github.com/kelvinatorr/restaurant-tracker/internal/lister"
+	"github.com/kelvinatorr/restaurant-tracker/internal/storage"
+	"github.com/kelvinatorr/restaurant-tracker/internal/updater"
+)
+
+func generateSavedViewSQL() string {
+	sql := `
+		SELECT
+			sv.id,
+			sv.user_id,
+			u.first_name,
+			sv.name,
+			sv.query,
+			sv.shared,
+			sv.is_default
+		FROM
+			saved_view as sv
+			inner join "user" as u on u.id = sv.user_id
+	`
+	return sql
+}
+
+func fillSavedView(row scanner, v *lister.SavedView) error {
+	return row.Scan(
+		&v.ID,
+		&v.UserID,
+		&v.UserFirstName,
+		&v.Name,
+		&v.Query,
+		&v.Shared,
+		&v.IsDefault,
+	)
+}
+
+// AddSavedView saves a view and returns its primary key id.
+func (s Storage) AddSavedView(v adder.SavedView) (int64, error) {
+	sqlStatement := `
+		INSERT INTO
+			saved_view(user_id, name, query, shared, is_default)
+		VALUES
+			($1, $2, $3, $4, $5)
+		RETURNING id
+	`
+	return s.insertRow(sqlStatement, v.UserID, v.Name, v.Query, v.Shared, v.IsDefault)
+}
+
+// UpdateSavedView updates a given view, returns the rows affected. The user who owns it doesn't change.
+func (s Storage) UpdateSavedView(v updater.SavedView) (int64, error) {
+	sqlStatement := `
+		UPDATE
+			saved_view
+		SET
+			name = $1,
+			query = $2,
+			shared = $3,
+			is_default = $4
+		WHERE
+			id = $5
+	`
+	return s.execRows(sqlStatement, v.Name, v.Query, v.Shared, v.IsDefault, v.ID)
+}
+
+// ClearDefaultSavedView makes none of the user's views their default, returns the rows affected.
+func (s Storage) ClearDefaultSavedView(userID int64) (int64, error) {
+	sqlStatement := `
+		UPDATE
+			saved_view
+		SET
+			is_default = false
+		WHERE
+			user_id = $1
+			and is_default
+	`
+	return s.execRows(sqlStatement, userID)
+}
+
+// RemoveSavedView deletes a given view and returns the rows affected.
+func (s Storage) RemoveSavedView(id int64) (int64, error) {
+	return s.removeRow("saved_view", id)
+}
+
+// GetSavedView returns the view with the given id. Returns a storage.ErrNotFound if it is not in the database.
+func (s Storage) GetSavedView(id int64) (lister.SavedView, error) {
+	var v lister.SavedView
+	sqlStatement := generateSavedViewSQL() + `
+		WHERE
+			sv.id = $1
+	`
+	err := fillSavedView(s.q.QueryRow(sqlStatement, id), &v)
+	if err == sql.ErrNoRows {
+		return v, &storage.ErrNotFound{Msg: fmt.Sprintf("No saved view with id: %d", id)}
+	}
+	return v, err
+}
+
+// GetSavedViews returns the views the user owns and the ones other users share, ordered by name.
+func (s Storage) GetSavedViews(userID int64) ([]lister.SavedView, error) {
+	var allViews []lister.SavedView
+	var v lister.SavedView
+	sqlStatement := generateSavedViewSQL() + `
+		WHERE
+			sv.user_id = $1
+			or sv.shared
+		ORDER BY
+			sv.name,
+			sv.id
+	`
+	dbRows, err := s.q.Query(sqlStatement, userID)
+	if err != nil {
+		return allViews, err
+	}
+	defer dbRows.Close()
+	for dbRows.Next() {
+		if err := fillSavedView(dbRows, &v); err != nil {
+			return allViews, err
+		}
+		allViews = append(allViews, v)
+	}
+	return allViews, dbRows.Err()
+}
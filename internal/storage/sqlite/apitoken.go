@@ -0,0 +1,138 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/kelvinatorr/restaurant-tracker/internal/adder"
+	"github.com/kelvinatorr/restaurant-tracker/internal/auther"
+	"github.com/kelvinatorr/restaurant-tracker/internal/lister"
+	"github.com/kelvinatorr/restaurant-tracker/internal/storage"
+)
+
+func generateAPITokenSQL() string {
+	sql := `
+		SELECT
+			id,
+			user_id,
+			name,
+			read_only,
+			created_at,
+			COALESCE(last_used_at, '') as last_used_at,
+			COALESCE(expires_at, '') as expires_at
+		FROM
+			api_token
+	`
+	return sql
+}
+
+func fillAPIToken(row scanner, t *lister.APIToken) error {
+	return row.Scan(
+		&t.ID,
+		&t.UserID,
+		&t.Name,
+		&t.ReadOnly,
+		&t.CreatedAt,
+		&t.LastUsedAt,
+		&t.ExpiresAt,
+	)
+}
+
+// AddAPIToken saves an API token and returns its primary key id.
+func (s Storage) AddAPIToken(t adder.APIToken) (int64, error) {
+	// We use NULLIF to allow inserting nulls in the database
+	sqlStatement := `
+		INSERT INTO
+			api_token(user_id, name, token_hash, read_only, created_at, expires_at)
+		VALUES
+			($1, $2, $3, $4, $5, NULLIF($6, ''))
+	`
+	res, err := s.q.Exec(sqlStatement, t.UserID, t.Name, t.TokenHash, t.ReadOnly, t.CreatedAt, t.ExpiresAt)
+	if err != nil {
+		return 0, translateError(err)
+	}
+	return res.LastInsertId()
+}
+
+// RemoveAPIToken deletes a given API token and returns the rows affected.
+func (s Storage) RemoveAPIToken(id int64) (int64, error) {
+	return s.removeRow("api_token", id)
+}
+
+// GetAPIToken returns the API token with the given id. Returns a storage.ErrNotFound if it is not in the database.
+func (s Storage) GetAPIToken(id int64) (lister.APIToken, error) {
+	var t lister.APIToken
+	sqlStatement := generateAPITokenSQL() + `
+		WHERE
+			id = $1
+	`
+	err := fillAPIToken(s.q.QueryRow(sqlStatement, id), &t)
+	if err == sql.ErrNoRows {
+		return t, &storage.ErrNotFound{Msg: fmt.Sprintf("No API token with id: %d", id)}
+	}
+	return t, err
+}
+
+// GetAPITokens returns the user's API tokens, newest first.
+func (s Storage) GetAPITokens(userID int64) ([]lister.APIToken, error) {
+	var allTokens []lister.APIToken
+	var t lister.APIToken
+	sqlStatement := generateAPITokenSQL() + `
+		WHERE
+			user_id = $1
+		ORDER BY
+			id DESC
+	`
+	dbRows, err := s.q.Query(sqlStatement, userID)
+	if err != nil {
+		return allTokens, err
+	}
+	defer dbRows.Close()
+	for dbRows.Next() {
+		if err := fillAPIToken(dbRows, &t); err != nil {
+			return allTokens, err
+		}
+		allTokens = append(allTokens, t)
+	}
+	return allTokens, dbRows.Err()
+}
+
+// GetAPITokenByHash returns the API token with the given hash. Returns a storage.ErrNotFound if it is not in the
+// database.
+func (s Storage) GetAPITokenByHash(hash string) (auther.APIToken, error) {
+	var t auther.APIToken
+	sqlStatement := `
+		SELECT
+			id,
+			user_id,
+			read_only,
+			COALESCE(expires_at, '') as expires_at,
+			COALESCE(last_used_at, '') as last_used_at
+		FROM
+			api_token
+		WHERE
+			token_hash = $1
+	`
+	err := s.q.QueryRow(sqlStatement, hash).Scan(&t.ID, &t.UserID, &t.ReadOnly, &t.ExpiresAt, &t.LastUsedAt)
+	if err == sql.ErrNoRows {
+		return t, &storage.ErrNotFound{Msg: "No API token with this hash"}
+	}
+	return t, err
+}
+
+// UpdateAPITokenLastUsed saves when an API token was last used, returns the rows affected.
+func (s Storage) UpdateAPITokenLastUsed(id int64, lastUsedAt string) (int64, error) {
+	sqlStatement := `
+		UPDATE
+			api_token
+		SET
+			last_used_at = $1
+		WHERE
+			id = $2
+	`
+	res, err := s.q.Exec(sqlStatement, lastUsedAt, id)
+	if err != nil {
+		return 0, translateError(err)
+	}
+	return res.RowsAffected()
+}
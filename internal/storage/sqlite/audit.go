@@ -0,0 +1,88 @@
+package sqlite
+
+import (
+	"database/sql"
+
+	"github.com/kelvinatorr/restaurant-tracker/internal/audit"
+	"github.com/kelvinatorr/restaurant-tracker/internal/lister"
+)
+
+// nullIfZero returns nil for an id of 0 so it is saved as NULL.
+func nullIfZero(id int64) interface{} {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
+
+// AddAuditEntry adds an entry to the audit log and returns its primary key id.
+func (s Storage) AddAuditEntry(e audit.Entry) (int64, error) {
+	sqlStatement := `
+		INSERT INTO
+			audit_log(user_id, entity_type, entity_id, restaurant_id, action, created_at, diff)
+		VALUES
+			($1, $2, $3, $4, $5, $6, $7)
+	`
+	res, err := s.q.Exec(sqlStatement, nullIfZero(e.UserID), e.EntityType, e.EntityID, nullIfZero(e.RestaurantID),
+		e.Action, e.CreatedAt, e.Diff)
+	if err != nil {
+		return 0, translateError(err)
+	}
+	return res.LastInsertId()
+}
+
+// GetAuditLog returns the changes to an entity, newest first.
+func (s Storage) GetAuditLog(entityType string, entityID int64) ([]lister.AuditEntry, error) {
+	return s.getAuditLog("a.entity_type = $1 and a.entity_id = $2", entityType, entityID)
+}
+
+// GetRestaurantAuditLog returns the changes to a restaurant and everything that belongs to it, newest first.
+func (s Storage) GetRestaurantAuditLog(restaurantID int64) ([]lister.AuditEntry, error) {
+	return s.getAuditLog("a.restaurant_id = $1", restaurantID)
+}
+
+func (s Storage) getAuditLog(where string, args ...interface{}) ([]lister.AuditEntry, error) {
+	var allEntries []lister.AuditEntry
+	// Never pass where from user input!
+	sqlStatement := `
+		SELECT
+			a.id,
+			COALESCE(a.user_id, 0),
+			COALESCE(u.first_name || ' ' || u.last_name, ''),
+			a.entity_type,
+			a.entity_id,
+			COALESCE(a.restaurant_id, 0),
+			a.action,
+			a.created_at,
+			a.diff
+		FROM
+			audit_log as a
+			left join user as u on u.id = a.user_id
+		WHERE
+			` + where + `
+		ORDER BY
+			a.id desc
+	`
+	dbRows, err := s.q.Query(sqlStatement, args...)
+	if err != nil {
+		return allEntries, err
+	}
+	defer dbRows.Close()
+	for dbRows.Next() {
+		e, err := scanAuditEntry(dbRows)
+		if err != nil {
+			return allEntries, err
+		}
+		allEntries = append(allEntries, e)
+	}
+	return allEntries, dbRows.Err()
+}
+
+func scanAuditEntry(dbRows *sql.Rows) (lister.AuditEntry, error) {
+	var e lister.AuditEntry
+	var diff string
+	err := dbRows.Scan(&e.ID, &e.UserID, &e.UserName, &e.EntityType, &e.EntityID, &e.RestaurantID, &e.Action,
+		&e.CreatedAt, &diff)
+	e.Diff = []byte(diff)
+	return e, err
+}
@@ -0,0 +1,124 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// Backup writes a consistent copy of the database to a new file at path using sqlite's online backup API, so the
+// server can keep running while it is made.
+func (s Storage) Backup(path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("%s already exists", path)
+	}
+	dest, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return err
+	}
+	defer dest.Close()
+
+	// The backup API works on the underlying connections so borrow one from each pool.
+	ctx := context.Background()
+	destConn, err := dest.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer destConn.Close()
+	srcConn, err := s.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer srcConn.Close()
+
+	return destConn.Raw(func(destDriverConn interface{}) error {
+		return srcConn.Raw(func(srcDriverConn interface{}) error {
+			destSQLite, ok := destDriverConn.(*sqlite3.SQLiteConn)
+			if !ok {
+				return errors.New("Backup destination is not a sqlite3 connection")
+			}
+			srcSQLite, ok := srcDriverConn.(*sqlite3.SQLiteConn)
+			if !ok {
+				return errors.New("Backup source is not a sqlite3 connection")
+			}
+			b, err := destSQLite.Backup("main", srcSQLite, "main")
+			if err != nil {
+				return err
+			}
+			// Copy every page in one step so the copy is from a single point in time. Step returns false without an
+			// error while another connection is writing, so wait for it to finish and try again.
+			for {
+				done, err := b.Step(-1)
+				if err != nil {
+					b.Finish()
+					return err
+				}
+				if done {
+					break
+				}
+				time.Sleep(100 * time.Millisecond)
+			}
+			return b.Finish()
+		})
+	})
+}
+
+// Restore replaces the database at dbPath with the backup at backupPath and returns the backup's schema version.
+// Nothing is changed unless the backup passes sqlite's integrity check and has a schema version this binary can
+// migrate, i.e. no newer than LatestSchemaVersion. The old database is kept at dbPath + ".pre-restore". The web-server
+// must not be running while a database is restored.
+func Restore(backupPath string, dbPath string) (int, error) {
+	if _, err := os.Stat(backupPath); err != nil {
+		return 0, err
+	}
+	db, err := sql.Open("sqlite3", "file:"+backupPath+"?mode=ro")
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+	backup := Storage{db: db, q: db}
+
+	var integrity string
+	if err := db.QueryRow(`PRAGMA integrity_check`).Scan(&integrity); err != nil {
+		return 0, fmt.Errorf("%s is not a sqlite database: %s", backupPath, err)
+	}
+	if integrity != "ok" {
+		return 0, fmt.Errorf("%s failed the integrity check: %s", backupPath, integrity)
+	}
+
+	version, err := backup.SchemaVersion()
+	if err != nil {
+		return 0, err
+	}
+	if version == 0 {
+		return 0, fmt.Errorf("%s is not a restaurant tracker database, it has no schema version", backupPath)
+	}
+	if latest := backup.LatestSchemaVersion(); version > latest {
+		return version, fmt.Errorf("%s is at schema version %d which is newer than this web-server's %d. Upgrade the web-server first",
+			backupPath, version, latest)
+	}
+
+	// Copy the backup next to the database first so the swap is a rename, which can't leave a half written file.
+	restoring := dbPath + ".restoring"
+	os.Remove(restoring)
+	if err := backup.Backup(restoring); err != nil {
+		return version, err
+	}
+	if _, err := os.Stat(dbPath); err == nil {
+		if err := os.Rename(dbPath, dbPath+".pre-restore"); err != nil {
+			return version, err
+		}
+	}
+	// A journal left behind by the old database would be applied to the restored one.
+	for _, suffix := range []string{"-journal", "-wal", "-shm"} {
+		if err := os.Remove(dbPath + suffix); err != nil && !os.IsNotExist(err) {
+			return version, err
+		}
+	}
+	return version, os.Rename(restoring, dbPath)
+}
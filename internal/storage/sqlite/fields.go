@@ -2,6 +2,16 @@ package sqlite
 
 import "github.com/kelvinatorr/restaurant-tracker/internal/lister"
 
+// gmapsRatingSQL is the Google Maps rating. It is rounded because it is read into a float32 and the page cursors need
+// the same number back.
+const gmapsRatingSQL = "round(gp.rating, 1)"
+
+// ratingDiffSQL is our average rating minus the Google Maps rating, NULL unless the restaurant has both.
+const ratingDiffSQL = "round(ratings.avg_rating - gp.rating, 1)"
+
+// RestaurantSortFields are also compared in the WHERE clause when paging. avg_rating is spelled out because in a WHERE
+// clause it would be the ratings column, which is NULL for restaurants without ratings, instead of the alias. The
+// Google Maps fields are coalesced for the same reason.
 func (s Storage) RestaurantSortFields() map[string]string {
 	restaurantFields := make(map[string]string)
 	restaurantFields["name"] = "res.name"
@@ -9,24 +19,58 @@ func (s Storage) RestaurantSortFields() map[string]string {
 	restaurantFields["city"] = "city_name"
 	restaurantFields["state"] = "state_name"
 	restaurantFields["last_visit"] = "last_visit_datetime"
-	restaurantFields["avg_rating"] = "avg_rating"
+	restaurantFields["avg_rating"] = "COALESCE(ratings.avg_rating, 0)"
+	restaurantFields["distance"] = distanceSort
+	restaurantFields["price_level"] = "COALESCE(gp.price_level, 0)"
+	restaurantFields["gmaps_rating"] = "COALESCE(" + gmapsRatingSQL + ", 0)"
+	restaurantFields["gmaps_ratings_total"] = "COALESCE(gp.user_ratings_total, 0)"
+	restaurantFields["gmaps_business_status"] = "COALESCE(gp.business_status, '')"
+	restaurantFields["rating_diff"] = "COALESCE(" + ratingDiffSQL + ", 0)"
+	restaurantFields["id"] = "res.id"
 	return restaurantFields
 }
 
+// RestaurantFilterFields are compared in the WHERE clause of generateRestaurantSQL. location is the latitude and
+// longitude columns, see nearSQL.
 func (s Storage) RestaurantFilterFields() map[string]lister.Field {
 	restaurantFields := make(map[string]lister.Field)
-	restaurantFields["name"] = lister.Field{Name: "res.name", Type: "TEXT"}
-	restaurantFields["cuisine"] = lister.Field{Name: "cuisine", Type: "TEXT"}
-	restaurantFields["city"] = lister.Field{Name: "city_name", Type: "TEXT"}
-	restaurantFields["state"] = lister.Field{Name: "state_name", Type: "TEXT"}
-	restaurantFields["last_visit"] = lister.Field{Name: "last_visits.last_visit", Type: "TEXT"}
-	restaurantFields["avg_rating"] = lister.Field{Name: "avg_rating", Type: "REAL"}
-	restaurantFields["business_status"] = lister.Field{Name: "res.business_status", Type: "INT"}
+	restaurantFields["name"] = lister.Field{Name: "res.name", Type: lister.FieldText}
+	restaurantFields["cuisine"] = lister.Field{Name: "cuisine", Type: lister.FieldText}
+	restaurantFields["city"] = lister.Field{Name: "city_name", Type: lister.FieldText}
+	restaurantFields["state"] = lister.Field{Name: "state_name", Type: lister.FieldText}
+	restaurantFields["last_visit"] = lister.Field{Name: "last_visits.last_visit", Type: lister.FieldDate}
+	restaurantFields["avg_rating"] = lister.Field{Name: "avg_rating", Type: lister.FieldReal}
+	restaurantFields["business_status"] = lister.Field{Name: "res.business_status", Type: lister.FieldInt}
+	restaurantFields["location"] = lister.Field{Name: "res.latitude, res.longitude", Type: lister.FieldGeo}
+	restaurantFields["price_level"] = lister.Field{Name: "gp.price_level", Type: lister.FieldInt}
+	restaurantFields["gmaps_rating"] = lister.Field{Name: gmapsRatingSQL, Type: lister.FieldReal}
+	restaurantFields["gmaps_ratings_total"] = lister.Field{Name: "gp.user_ratings_total", Type: lister.FieldInt}
+	restaurantFields["gmaps_business_status"] = lister.Field{Name: "gp.business_status", Type: lister.FieldText}
+	restaurantFields["rating_diff"] = lister.Field{Name: ratingDiffSQL, Type: lister.FieldReal}
 	return restaurantFields
 }
 
 func (s Storage) VisitSortFields() map[string]string {
 	visitFields := make(map[string]string)
 	visitFields["date"] = "visit_datetime"
+	visitFields["rating"] = "COALESCE(visit_ratings.avg_rating, 0)"
+	visitFields["attendees"] = "COALESCE(visit_ratings.attendees, 0)"
+	visitFields["restaurant"] = "res.name"
+	visitFields["id"] = "v.id"
+	return visitFields
+}
+
+// VisitFilterFields are compared in the WHERE clause of generateVisitSQL. user is the ids of the users at the visit.
+func (s Storage) VisitFilterFields() map[string]lister.Field {
+	visitFields := make(map[string]lister.Field)
+	visitFields["date"] = lister.Field{Name: "visit_datetime", Type: lister.FieldDate}
+	visitFields["user"] = lister.Field{Name: "SELECT user_id FROM visit_user WHERE visit_id = v.id",
+		Type: lister.FieldIDSet}
+	visitFields["rating"] = lister.Field{Name: "visit_ratings.avg_rating", Type: lister.FieldReal}
+	visitFields["attendees"] = lister.Field{Name: "COALESCE(visit_ratings.attendees, 0)", Type: lister.FieldInt}
+	visitFields["has_note"] = lister.Field{Name: "CASE WHEN COALESCE(v.note, '') = '' THEN 0 ELSE 1 END",
+		Type: lister.FieldInt}
+	visitFields["restaurant"] = lister.Field{Name: "res.name", Type: lister.FieldText}
+	visitFields["restaurant_id"] = lister.Field{Name: "v.restaurant_id", Type: lister.FieldInt}
 	return visitFields
 }
@@ -0,0 +1,86 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/kelvinatorr/restaurant-tracker/internal/lister"
+	"github.com/mattn/go-sqlite3"
+)
+
+// driverName is the sqlite3 driver with the functions this package's queries need.
+const driverName = "sqlite3_restaurant_tracker"
+
+func init() {
+	sql.Register(driverName, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			// sqlite doesn't have the trig functions for the haversine formula, so it calls back to Go.
+			return conn.RegisterFunc("haversine_km", haversineKm, true)
+		},
+	})
+}
+
+// haversineKm is the haversine_km(lat1, lng1, lat2, lng2) sql function, the km between two points. A point with a
+// NULL in it is infinitely far away since restaurants without a latitude and longitude can't be near anything.
+func haversineKm(lat1 interface{}, lng1 interface{}, lat2 interface{}, lng2 interface{}) float64 {
+	var v [4]float64
+	for i, a := range []interface{}{lat1, lng1, lat2, lng2} {
+		switch a := a.(type) {
+		case float64:
+			v[i] = a
+		case int64:
+			v[i] = float64(a)
+		default:
+			return math.Inf(1)
+		}
+	}
+	return lister.DistanceKm(lister.GeoPoint{Lat: v[0], Lng: v[1]}, lister.GeoPoint{Lat: v[2], Lng: v[3]})
+}
+
+// distanceSort is the repository field of the distance sort. It is swapped for distanceSQL when the query is made since
+// it depends on where the distance is measured from.
+const distanceSort = "distance"
+
+// distanceSQL returns the km of a restaurant from the origin of the filter's near filter, or 0 if it doesn't have one.
+// The origin is a pair of numbers from the lister so they are written straight into the sql.
+func distanceSQL(filter lister.FilterExpr) string {
+	origin, ok := lister.FilterOrigin(filter)
+	if !ok {
+		return "0"
+	}
+	return fmt.Sprintf("haversine_km(res.latitude, res.longitude, %s, %s)",
+		strconv.FormatFloat(origin.Lat, 'f', -1, 64), strconv.FormatFloat(origin.Lng, 'f', -1, 64))
+}
+
+// withDistanceSQL returns the sort operations with the distance sort swapped for its sql.
+func withDistanceSQL(sortOps []lister.SortOperation, distance string) []lister.SortOperation {
+	result := make([]lister.SortOperation, len(sortOps))
+	for i, so := range sortOps {
+		if so.Field == distanceSort {
+			so.Field = distance
+		}
+		result[i] = so
+	}
+	return result
+}
+
+// nearSQL returns the condition for a near filter on a FieldGeo, whose name is its latitude and longitude columns
+// separated by a comma. The bounding box comes first so the restaurant_location index can be used. The values of its
+// parameters are appended to values.
+func nearSQL(filterOp lister.FilterOperation, values *[]interface{}) string {
+	param := func(f float64) string {
+		*values = append(*values, f)
+		return "$" + strconv.Itoa(len(*values))
+	}
+	columns := strings.SplitN(filterOp.Field, ",", 2)
+	lat, lng := strings.TrimSpace(columns[0]), strings.TrimSpace(columns[1])
+	// The lister has already checked the filter.
+	n, _ := lister.NearFilter(filterOp)
+	minLat, maxLat, minLng, maxLng := n.BoundingBox()
+	return fmt.Sprintf("%s BETWEEN %s AND %s AND %s BETWEEN %s AND %s AND haversine_km(%s, %s, %s, %s) <= %s",
+		lat, param(minLat), param(maxLat), lng, param(minLng), param(maxLng),
+		lat, lng, param(n.Origin.Lat), param(n.Origin.Lng), param(n.Km))
+}
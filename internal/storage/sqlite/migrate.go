@@ -0,0 +1,92 @@
+package sqlite
+
+import (
+	"errors"
+
+	"github.com/kelvinatorr/restaurant-tracker/internal/storage"
+)
+
+// migrationDialect keeps track of the applied migrations in sqlite.
+var migrationDialect = storage.MigrationDialect{
+	TableExists: `SELECT count(*) > 0 FROM sqlite_master WHERE type = 'table' AND name = 'schema_migration'`,
+	CreateTable: `
+		CREATE TABLE IF NOT EXISTS schema_migration (
+			version INTEGER PRIMARY KEY,
+			description TEXT NOT NULL,
+			applied_at TEXT NOT NULL -- RFC3339 UTC timezone
+		)
+	`,
+	Insert: `
+		INSERT INTO
+			schema_migration(version, description, applied_at)
+		VALUES
+			($1, $2, strftime('%Y-%m-%dT%H:%M:%SZ', 'now'))
+	`,
+}
+
+// LatestSchemaVersion returns the version of the newest migration that ships with this binary.
+func (s Storage) LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// SchemaVersion returns the version of the last migration applied to the database. A database that has never been
+// migrated has version 0.
+func (s Storage) SchemaVersion() (int, error) {
+	return storage.SchemaVersion(s.db, migrationDialect)
+}
+
+// Migrate applies the pending migrations, or only checks that they would succeed if dryRun is true. See
+// storage.Migrate.
+func (s Storage) Migrate(dryRun bool) ([]storage.Migration, error) {
+	fts5, err := s.hasFTS5()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := storage.Migrate(s.db, migrationDialect, withSearchTable(fts5), dryRun)
+	if err != nil {
+		return applied, err
+	}
+	// A database made by a build with FTS5 can't be written to by one without it.
+	isFTS5, err := s.searchIsFTS5()
+	if err != nil {
+		return applied, err
+	}
+	if isFTS5 && !fts5 {
+		return applied, errors.New("The search index of this database needs FTS5. Build web-server with -tags sqlite_fts5")
+	}
+	return applied, nil
+}
+
+// withSearchTable returns the migrations with the statement that creates the restaurant_search table added to the
+// start of migration 2. The table is an FTS5 index if sqlite has FTS5, otherwise it is a plain table with the same
+// columns that SearchRestaurants searches with LIKE.
+func withSearchTable(fts5 bool) []storage.Migration {
+	create := `CREATE TABLE restaurant_search (name TEXT, cuisine TEXT, note TEXT, city TEXT, visit_notes TEXT);`
+	if fts5 {
+		create = `CREATE VIRTUAL TABLE restaurant_search USING fts5(name, cuisine, note, city, visit_notes);`
+	}
+	ms := make([]storage.Migration, len(migrations))
+	copy(ms, migrations)
+	for i := range ms {
+		if ms[i].Version == 2 {
+			ms[i].Up = create + ms[i].Up
+		}
+	}
+	return ms
+}
+
+// hasFTS5 reports whether go-sqlite3 was built with FTS5, which it only is with -tags sqlite_fts5.
+func (s Storage) hasFTS5() (bool, error) {
+	var fts5 bool
+	err := s.q.QueryRow(`SELECT sqlite_compileoption_used('ENABLE_FTS5')`).Scan(&fts5)
+	return fts5, err
+}
+
+// searchIsFTS5 reports whether the restaurant_search table is an FTS5 index. It is false if the table doesn't exist.
+func (s Storage) searchIsFTS5() (bool, error) {
+	var isFTS5 bool
+	err := s.q.QueryRow(`
+		SELECT count(*) > 0 FROM sqlite_master WHERE name = 'restaurant_search' AND sql LIKE '%USING fts5%'
+	`).Scan(&isFTS5)
+	return isFTS5, err
+}
@@ -0,0 +1,240 @@
+package sqlite
+
+import "github.com/kelvinatorr/restaurant-tracker/internal/storage"
+
+// migrations is the ordered list of schema changes that are applied to the database. Never edit a migration that has
+// already been released, add a new one to the end of the list instead.
+var migrations = []storage.Migration{
+	{
+		Version:     1,
+		Description: "Create the city, restaurant, visit, visit_user, user and gmaps_place tables",
+		// IF NOT EXISTS so databases that were created by hand before migrations existed are adopted as is.
+		Up: `
+			CREATE TABLE IF NOT EXISTS city (
+				id INTEGER PRIMARY KEY, -- Autoincrements per the documentation
+				name TEXT NOT NULL,
+				state TEXT NOT NULL,
+				CHECK (length(state) == 2) -- Use ISO 3166-1 alpha-2 country code if not a US state
+			);
+			CREATE UNIQUE INDEX IF NOT EXISTS city_name_state on city (name, state);
+
+			CREATE TABLE IF NOT EXISTS restaurant (
+				id INTEGER PRIMARY KEY, -- Autoincrements per the documentation
+				name TEXT NOT NULL,
+				cuisine TEXT NOT NULL,
+				note TEXT,
+				address TEXT,
+				city_id INTEGER NOT NULL REFERENCES city(id) ON UPDATE CASCADE, -- Must track id in city table
+				zipcode TEXT,
+				latitude REAL,
+				longitude REAL,
+				business_status INTEGER NOT NULL DEFAULT 1
+			);
+
+			CREATE TABLE IF NOT EXISTS visit (
+				id INTEGER PRIMARY KEY, -- Autoincrements per the documentation
+				restaurant_id INTEGER NOT NULL REFERENCES restaurant(id) ON UPDATE CASCADE ON DELETE CASCADE, -- Must track the id in restaurant table
+				visit_datetime TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', CURRENT_TIMESTAMP)), -- RFC3339 UTC timezone
+				note TEXT,
+				CHECK (length(visit_datetime) == 20)
+			);
+			CREATE INDEX IF NOT EXISTS visit_restaurant on visit (restaurant_id);
+
+			CREATE TABLE IF NOT EXISTS visit_user (
+				id INTEGER PRIMARY KEY, -- Autoincrements per the documentation
+				visit_id INTEGER NOT NULL REFERENCES visit(id) ON UPDATE CASCADE ON DELETE CASCADE, -- Must track the id in the visit table
+				user_id INTEGER NOT NULL REFERENCES user(id) ON UPDATE CASCADE, -- Must track the id in user table
+				rating INTEGER,
+				CHECK ((rating > 0 and rating < 6) or rating is NULL)
+			);
+			CREATE INDEX IF NOT EXISTS visit_user_visit_id on visit_user (visit_id);
+			-- Can't have the same user more than once in the same visit.
+			CREATE UNIQUE INDEX IF NOT EXISTS visit_user_visit_id_user_id on visit_user (visit_id, user_id);
+
+			CREATE TABLE IF NOT EXISTS user (
+				id INTEGER PRIMARY KEY, -- Autoincrements per the documentation
+				first_name TEXT NOT NULL,
+				last_name TEXT NOT NULL,
+				email TEXT NOT NULL,
+				password_hash TEXT NOT NULL,
+				remember_token TEXT
+			);
+			CREATE UNIQUE INDEX IF NOT EXISTS email on user (email);
+
+			CREATE TABLE IF NOT EXISTS gmaps_place (
+				id INTEGER PRIMARY KEY, -- Autoincrements per the documentation
+				last_updated TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', CURRENT_TIMESTAMP)), -- RFC3339 UTC timezone
+				place_id TEXT NOT NULL UNIQUE, -- Don't use this as the PK because it can change over time
+				business_status TEXT,
+				formatted_phone_number TEXT,
+				name TEXT NOT NULL,
+				price_level INTEGER,
+				rating REAL,
+				url TEXT, -- The url to this place Google Maps
+				user_ratings_total INTEGER,
+				utc_offset INTEGER, -- The number of minutes this place’s current timezone is offset from UTC
+				website TEXT,
+				restaurant_id INTEGER NOT NULL REFERENCES restaurant(id) ON UPDATE CASCADE ON DELETE CASCADE
+			);
+		`,
+	},
+	{
+		Version:     2,
+		Description: "Create the restaurant_search full-text index",
+		// The rowid of restaurant_search is the restaurant id. Triggers rebuild a restaurant's row whenever the
+		// restaurant, its city or its visits change so the index never has to be rebuilt by hand. Migrate creates the
+		// restaurant_search table itself before this runs because it is only FTS5 when sqlite was built with it.
+		Up: `
+			CREATE VIEW restaurant_search_source AS
+				SELECT
+					res.id,
+					res.city_id,
+					res.name,
+					res.cuisine,
+					COALESCE(res.note, '') as note,
+					city.name || ' ' || city.state as city,
+					COALESCE((SELECT group_concat(note, ' ') FROM visit WHERE visit.restaurant_id = res.id), '') as visit_notes
+				FROM
+					restaurant as res
+					inner join city on city.id = res.city_id;
+
+			INSERT INTO restaurant_search(rowid, name, cuisine, note, city, visit_notes)
+				SELECT id, name, cuisine, note, city, visit_notes FROM restaurant_search_source;
+
+			CREATE TRIGGER restaurant_search_restaurant_insert AFTER INSERT ON restaurant BEGIN
+				INSERT INTO restaurant_search(rowid, name, cuisine, note, city, visit_notes)
+					SELECT id, name, cuisine, note, city, visit_notes FROM restaurant_search_source WHERE id = new.id;
+			END;
+			CREATE TRIGGER restaurant_search_restaurant_update AFTER UPDATE ON restaurant BEGIN
+				DELETE FROM restaurant_search WHERE rowid = old.id;
+				INSERT INTO restaurant_search(rowid, name, cuisine, note, city, visit_notes)
+					SELECT id, name, cuisine, note, city, visit_notes FROM restaurant_search_source WHERE id = new.id;
+			END;
+			CREATE TRIGGER restaurant_search_restaurant_delete AFTER DELETE ON restaurant BEGIN
+				DELETE FROM restaurant_search WHERE rowid = old.id;
+			END;
+
+			CREATE TRIGGER restaurant_search_city_update AFTER UPDATE ON city BEGIN
+				DELETE FROM restaurant_search WHERE rowid IN (SELECT id FROM restaurant WHERE city_id = new.id);
+				INSERT INTO restaurant_search(rowid, name, cuisine, note, city, visit_notes)
+					SELECT id, name, cuisine, note, city, visit_notes FROM restaurant_search_source WHERE city_id = new.id;
+			END;
+
+			CREATE TRIGGER restaurant_search_visit_insert AFTER INSERT ON visit BEGIN
+				DELETE FROM restaurant_search WHERE rowid = new.restaurant_id;
+				INSERT INTO restaurant_search(rowid, name, cuisine, note, city, visit_notes)
+					SELECT id, name, cuisine, note, city, visit_notes FROM restaurant_search_source WHERE id = new.restaurant_id;
+			END;
+			CREATE TRIGGER restaurant_search_visit_update AFTER UPDATE ON visit BEGIN
+				DELETE FROM restaurant_search WHERE rowid IN (old.restaurant_id, new.restaurant_id);
+				INSERT INTO restaurant_search(rowid, name, cuisine, note, city, visit_notes)
+					SELECT id, name, cuisine, note, city, visit_notes FROM restaurant_search_source
+					WHERE id IN (old.restaurant_id, new.restaurant_id);
+			END;
+			CREATE TRIGGER restaurant_search_visit_delete AFTER DELETE ON visit BEGIN
+				DELETE FROM restaurant_search WHERE rowid = old.restaurant_id;
+				INSERT INTO restaurant_search(rowid, name, cuisine, note, city, visit_notes)
+					SELECT id, name, cuisine, note, city, visit_notes FROM restaurant_search_source WHERE id = old.restaurant_id;
+			END;
+		`,
+	},
+	{
+		Version:     3,
+		Description: "Add is_admin to user and make the first user an admin",
+		Up: `
+			ALTER TABLE user ADD COLUMN is_admin INTEGER NOT NULL DEFAULT 0;
+			UPDATE user SET is_admin = 1 WHERE id = (SELECT min(id) FROM user);
+		`,
+	},
+	{
+		Version:     4,
+		Description: "Add deleted_at to restaurant and visit for the trash",
+		// The search index leaves out everything in the trash. The triggers rebuild a restaurant's row when deleted_at
+		// changes because that is an update.
+		Up: `
+			ALTER TABLE restaurant ADD COLUMN deleted_at TEXT; -- RFC3339 UTC timezone, NULL unless it is in the trash
+			ALTER TABLE visit ADD COLUMN deleted_at TEXT; -- RFC3339 UTC timezone, NULL unless it is in the trash
+
+			DROP VIEW restaurant_search_source;
+			CREATE VIEW restaurant_search_source AS
+				SELECT
+					res.id,
+					res.city_id,
+					res.name,
+					res.cuisine,
+					COALESCE(res.note, '') as note,
+					city.name || ' ' || city.state as city,
+					COALESCE((
+						SELECT group_concat(note, ' ') FROM visit WHERE visit.restaurant_id = res.id and visit.deleted_at IS NULL
+					), '') as visit_notes
+				FROM
+					restaurant as res
+					inner join city on city.id = res.city_id
+				WHERE
+					res.deleted_at IS NULL;
+		`,
+	},
+	{
+		Version:     5,
+		Description: "Create the audit_log table",
+		// restaurant_id isn't a foreign key so a restaurant's history outlives it being purged.
+		Up: `
+			CREATE TABLE audit_log (
+				id INTEGER PRIMARY KEY, -- Autoincrements per the documentation
+				user_id INTEGER REFERENCES user(id) ON UPDATE CASCADE ON DELETE SET NULL, -- NULL if no user made the change
+				entity_type TEXT NOT NULL,
+				entity_id INTEGER NOT NULL,
+				restaurant_id INTEGER, -- The restaurant the entity belongs to
+				action TEXT NOT NULL,
+				created_at TEXT NOT NULL, -- RFC3339 UTC timezone
+				diff TEXT NOT NULL -- JSON object of the fields that changed
+			);
+			CREATE INDEX audit_log_entity on audit_log (entity_type, entity_id);
+			CREATE INDEX audit_log_restaurant on audit_log (restaurant_id);
+		`,
+	},
+	{
+		Version:     6,
+		Description: "Create the saved_view table",
+		Up: `
+			CREATE TABLE saved_view (
+				id INTEGER PRIMARY KEY, -- Autoincrements per the documentation
+				user_id INTEGER NOT NULL REFERENCES user(id) ON UPDATE CASCADE ON DELETE CASCADE, -- The user who owns it
+				name TEXT NOT NULL,
+				query TEXT NOT NULL, -- The filter, sort and search query params of the restaurant list
+				shared INTEGER NOT NULL DEFAULT 0, -- 1 if every user can use it
+				is_default INTEGER NOT NULL DEFAULT 0 -- 1 if it is the restaurant list its owner sees on the home page
+			);
+			-- A user can't have two views with the same name or more than one default view.
+			CREATE UNIQUE INDEX saved_view_user_id_name on saved_view (user_id, name);
+			CREATE UNIQUE INDEX saved_view_default on saved_view (user_id) WHERE is_default = 1;
+		`,
+	},
+	{
+		Version:     7,
+		Description: "Index the restaurant locations",
+		// The bounding box of a near filter is a range of latitudes, which is all this can narrow down.
+		Up: `
+			CREATE INDEX restaurant_location on restaurant (latitude, longitude);
+		`,
+	},
+	{
+		Version:     8,
+		Description: "Create the api_token table",
+		Up: `
+			CREATE TABLE api_token (
+				id INTEGER PRIMARY KEY, -- Autoincrements per the documentation
+				user_id INTEGER NOT NULL REFERENCES user(id) ON UPDATE CASCADE ON DELETE CASCADE, -- The user who owns it
+				name TEXT NOT NULL,
+				token_hash TEXT NOT NULL, -- SHA-256 hex of the token, the token itself isn't saved
+				read_only INTEGER NOT NULL DEFAULT 0, -- 1 if it can only be used to read
+				created_at TEXT NOT NULL, -- RFC3339 UTC timezone
+				last_used_at TEXT, -- RFC3339 UTC timezone, NULL until it is used
+				expires_at TEXT -- RFC3339 UTC timezone, NULL if it never expires
+			);
+			CREATE UNIQUE INDEX api_token_hash on api_token (token_hash);
+			-- A user can't have two tokens with the same name.
+			CREATE UNIQUE INDEX api_token_user_id_name on api_token (user_id, name);
+		`,
+	},
+}
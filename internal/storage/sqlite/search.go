@@ -0,0 +1,180 @@
+package sqlite
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/kelvinatorr/restaurant-tracker/internal/lister"
+)
+
+// searchWeights is how much a match in each column of restaurant_search counts, in column order. They are the same
+// weights the FTS5 search passes to bm25.
+var searchWeights = []float64{10.0, 4.0, 1.0, 4.0, 1.0}
+
+// snippetWords is about how many words a snippet has.
+const snippetWords = 12
+
+// SearchRestaurants searches the restaurant_search full-text index. Matches in the name count the most, then the
+// cuisine and city, then the notes. Without FTS5 restaurant_search is a plain table that is searched with LIKE instead.
+func (s Storage) SearchRestaurants(terms []string, limit int) ([]lister.SearchMatch, error) {
+	var allMatches []lister.SearchMatch
+	isFTS5, err := s.searchIsFTS5()
+	if err != nil {
+		return allMatches, err
+	}
+	if !isFTS5 {
+		return s.searchRestaurantsLike(terms, limit)
+	}
+	// Quote every term so it is never read as FTS5 syntax and add * so it matches as a prefix.
+	quoted := make([]string, len(terms))
+	for i, t := range terms {
+		quoted[i] = fmt.Sprintf(`"%s"*`, strings.ReplaceAll(t, `"`, `""`))
+	}
+	sqlStatement := `
+		SELECT
+			rowid,
+			snippet(restaurant_search, -1, $1, $2, '...', 12),
+			bm25(restaurant_search, 10.0, 4.0, 1.0, 4.0, 1.0) as score
+		FROM
+			restaurant_search
+		WHERE
+			restaurant_search MATCH $3
+		ORDER BY
+			score, rowid
+		LIMIT $4
+	`
+	// go-sqlite3 binds the arguments in the order the placeholders first appear, not by their number.
+	dbRows, err := s.q.Query(sqlStatement, lister.SearchMatchStart, lister.SearchMatchEnd, strings.Join(quoted, " "),
+		limit)
+	if err != nil {
+		return allMatches, err
+	}
+	defer dbRows.Close()
+	for dbRows.Next() {
+		var m lister.SearchMatch
+		var score float64
+		if err := dbRows.Scan(&m.RestaurantID, &m.Snippet, &score); err != nil {
+			return allMatches, err
+		}
+		// bm25 is more negative the better the match.
+		m.Rank = -score
+		allMatches = append(allMatches, m)
+	}
+	return allMatches, dbRows.Err()
+}
+
+// searchRestaurantsLike finds the restaurants that have every term somewhere in restaurant_search. They are ranked by
+// the weights of the columns each term is in, and the snippet is from the best column that has a term.
+func (s Storage) searchRestaurantsLike(terms []string, limit int) ([]lister.SearchMatch, error) {
+	var allMatches []lister.SearchMatch
+	conds := make([]string, len(terms))
+	values := make([]interface{}, len(terms))
+	for i, t := range terms {
+		conds[i] = fmt.Sprintf(`(name || ' ' || cuisine || ' ' || note || ' ' || city || ' ' || visit_notes) LIKE $%d ESCAPE '\'`,
+			i+1)
+		values[i] = "%" + escapeLike(t) + "%"
+	}
+	sqlStatement := `
+		SELECT
+			rowid,
+			name,
+			cuisine,
+			note,
+			city,
+			visit_notes
+		FROM
+			restaurant_search
+		WHERE
+			` + strings.Join(conds, " AND ")
+	dbRows, err := s.q.Query(sqlStatement, values...)
+	if err != nil {
+		return allMatches, err
+	}
+	defer dbRows.Close()
+	lowerTerms := make([]string, len(terms))
+	for i, t := range terms {
+		lowerTerms[i] = strings.ToLower(t)
+	}
+	for dbRows.Next() {
+		var m lister.SearchMatch
+		cols := make([]string, len(searchWeights))
+		if err := dbRows.Scan(&m.RestaurantID, &cols[0], &cols[1], &cols[2], &cols[3], &cols[4]); err != nil {
+			return allMatches, err
+		}
+		best := -1
+		for i, c := range cols {
+			lower := strings.ToLower(c)
+			for _, t := range lowerTerms {
+				if strings.Contains(lower, t) {
+					m.Rank += searchWeights[i]
+					if best == -1 || searchWeights[i] > searchWeights[best] {
+						best = i
+					}
+				}
+			}
+		}
+		if best != -1 {
+			m.Snippet = likeSnippet(cols[best], lowerTerms)
+		}
+		allMatches = append(allMatches, m)
+	}
+	if err := dbRows.Err(); err != nil {
+		return allMatches, err
+	}
+	sort.SliceStable(allMatches, func(i, j int) bool {
+		if allMatches[i].Rank != allMatches[j].Rank {
+			return allMatches[i].Rank > allMatches[j].Rank
+		}
+		return allMatches[i].RestaurantID < allMatches[j].RestaurantID
+	})
+	if len(allMatches) > limit {
+		allMatches = allMatches[:limit]
+	}
+	return allMatches, nil
+}
+
+// likeSnippet returns about snippetWords words of text starting a little before the first word that has one of the
+// lowercase terms in it. Words with a term in them are marked the way snippet() marks them in FTS5.
+func likeSnippet(text string, lowerTerms []string) string {
+	words := strings.Fields(text)
+	matches := make([]bool, len(words))
+	first := -1
+	for i, w := range words {
+		lower := strings.ToLower(w)
+		for _, t := range lowerTerms {
+			if strings.Contains(lower, t) {
+				matches[i] = true
+			}
+		}
+		if matches[i] && first == -1 {
+			first = i
+		}
+	}
+	start := 0
+	if first > snippetWords/4 {
+		start = first - snippetWords/4
+	}
+	end := start + snippetWords
+	if end > len(words) {
+		end = len(words)
+	}
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("...")
+	}
+	for i := start; i < end; i++ {
+		if i > start {
+			b.WriteString(" ")
+		}
+		if matches[i] {
+			b.WriteString(lister.SearchMatchStart + words[i] + lister.SearchMatchEnd)
+		} else {
+			b.WriteString(words[i])
+		}
+	}
+	if end < len(words) {
+		b.WriteString("...")
+	}
+	return b.String()
+}
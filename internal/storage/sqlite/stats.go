@@ -0,0 +1,132 @@
+package sqlite
+
+import (
+	"github.com/kelvinatorr/restaurant-tracker/internal/lister"
+)
+
+// GetStatsVisits returns the visits that aren't in the trash with their restaurant and city, oldest first.
+func (s Storage) GetStatsVisits() ([]lister.StatsVisit, error) {
+	var allVisits []lister.StatsVisit
+	sqlStatement := `
+		SELECT
+			v.id,
+			v.restaurant_id,
+			res.name,
+			res.cuisine,
+			city.name,
+			city.state,
+			v.visit_datetime
+		FROM
+			visit as v
+			inner join restaurant as res on res.id = v.restaurant_id
+			inner join city on city.id = res.city_id
+		WHERE
+			v.deleted_at IS NULL
+			and res.deleted_at IS NULL
+		ORDER BY
+			v.visit_datetime,
+			v.id
+	`
+	dbRows, err := s.q.Query(sqlStatement)
+	if err != nil {
+		return allVisits, err
+	}
+	defer dbRows.Close()
+	for dbRows.Next() {
+		var v lister.StatsVisit
+		err = dbRows.Scan(&v.ID, &v.RestaurantID, &v.RestaurantName, &v.Cuisine, &v.City, &v.State, &v.VisitDateTime)
+		if err != nil {
+			return allVisits, err
+		}
+		allVisits = append(allVisits, v)
+	}
+	return allVisits, dbRows.Err()
+}
+
+// GetStatsRatings returns the ratings of the visits that aren't in the trash. Users at a visit who didn't rate it are
+// left out.
+func (s Storage) GetStatsRatings() ([]lister.StatsRating, error) {
+	var allRatings []lister.StatsRating
+	sqlStatement := `
+		SELECT
+			vu.visit_id,
+			vu.user_id,
+			vu.rating
+		FROM
+			visit_user as vu
+			inner join visit as v on v.id = vu.visit_id
+			inner join restaurant as res on res.id = v.restaurant_id
+		WHERE
+			vu.rating IS NOT NULL
+			and v.deleted_at IS NULL
+			and res.deleted_at IS NULL
+		ORDER BY
+			vu.visit_id,
+			vu.user_id
+	`
+	dbRows, err := s.q.Query(sqlStatement)
+	if err != nil {
+		return allRatings, err
+	}
+	defer dbRows.Close()
+	for dbRows.Next() {
+		var r lister.StatsRating
+		err = dbRows.Scan(&r.VisitID, &r.UserID, &r.Rating)
+		if err != nil {
+			return allRatings, err
+		}
+		allRatings = append(allRatings, r)
+	}
+	return allRatings, dbRows.Err()
+}
+
+// GetUserRestaurantRatings returns the user's average rating of each restaurant they rated and everyone's average
+// rating of it, by restaurant id. Visits and restaurants in the trash are left out.
+func (s Storage) GetUserRestaurantRatings(userID int64) ([]lister.UserRestaurantRating, error) {
+	var allRatings []lister.UserRestaurantRating
+	sqlStatement := `
+		SELECT
+			res.id,
+			res.name,
+			avg(vu.rating),
+			count(vu.rating),
+			(
+				SELECT
+					avg(everyone.rating)
+				FROM
+					visit_user as everyone
+					inner join visit as ev on ev.id = everyone.visit_id
+				WHERE
+					ev.restaurant_id = res.id
+					and ev.deleted_at IS NULL
+			)
+		FROM
+			visit_user as vu
+			inner join visit as v on v.id = vu.visit_id
+			inner join restaurant as res on res.id = v.restaurant_id
+		WHERE
+			vu.user_id = $1
+			and vu.rating IS NOT NULL
+			and v.deleted_at IS NULL
+			and res.deleted_at IS NULL
+		GROUP BY
+			res.id,
+			res.name
+		ORDER BY
+			res.id
+	`
+	dbRows, err := s.q.Query(sqlStatement, userID)
+	if err != nil {
+		return allRatings, err
+	}
+	defer dbRows.Close()
+	for dbRows.Next() {
+		var r lister.UserRestaurantRating
+		err = dbRows.Scan(&r.RestaurantID, &r.RestaurantName, &r.AvgRating, &r.Ratings, &r.RestaurantAvgRating)
+		if err != nil {
+			return allRatings, err
+		}
+		allRatings = append(allRatings, r)
+	}
+	return allRatings, dbRows.Err()
+}
@@ -2,12 +2,14 @@ package sqlite
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
-	"log"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/kelvinatorr/restaurant-tracker/internal/auther"
+	"github.com/kelvinatorr/restaurant-tracker/internal/storage"
 
 	"github.com/kelvinatorr/restaurant-tracker/internal/remover"
 	"github.com/kelvinatorr/restaurant-tracker/internal/updater"
@@ -16,20 +18,30 @@ import (
 
 	"github.com/kelvinatorr/restaurant-tracker/internal/adder"
 
-	_ "github.com/mattn/go-sqlite3" // Blank importing here because this is where we interact with the db
+	"github.com/mattn/go-sqlite3"
 )
 
 // NewStorage returns a new database/sql instance initialized with sqlite3
-func NewStorage(dbPath string) (Storage, error) {
-	db, err := sql.Open("sqlite3", dbPath+"?_fk=on")
-	s := Storage{db: db}
+func NewStorage(dbPath string) (*Storage, error) {
+	// _txlock=immediate takes the write lock when a transaction begins so two transactions can't both read and then
+	// fail to write. _busy_timeout makes a transaction wait for the lock instead of failing straight away.
+	db, err := sql.Open(driverName, dbPath+"?_fk=on&_busy_timeout=5000&_txlock=immediate")
+	s := &Storage{db: db, q: db}
 	return s, err
 }
 
 // Storage stores restaurant data in a sqlite3 database
 type Storage struct {
 	db *sql.DB
-	tx *sql.Tx
+	// q runs the queries. It is db, or the transaction when this Storage was made by withTx.
+	q querier
+}
+
+// querier implements the query functions of sql.DB and sql.Tx
+type querier interface {
+	Exec(string, ...interface{}) (sql.Result, error)
+	Query(string, ...interface{}) (*sql.Rows, error)
+	QueryRow(string, ...interface{}) *sql.Row
 }
 
 // CloseStorage closes the database by calling db.Close()
@@ -37,26 +49,8 @@ func (s Storage) CloseStorage() {
 	s.db.Close()
 }
 
-// Begin starts a transaction
-func (s *Storage) Begin() {
-	tx, err := s.db.Begin()
-	checkAndPanic(err)
-	s.tx = tx
-}
-
-// Commit "saves" the transaction to the database
-func (s Storage) Commit() {
-	err := s.tx.Commit()
-	checkAndPanic(err)
-}
-
-// Rollback rolls the inserts/updates back
-func (s Storage) Rollback() {
-	s.tx.Rollback()
-}
-
-// AddRestaurant adds the given restaurant to the sqlite database. Must call Commit() to commit transaction
-func (s Storage) AddRestaurant(r adder.Restaurant) int64 {
+// AddRestaurant adds the given restaurant to the sqlite database
+func (s Storage) AddRestaurant(r adder.Restaurant) (int64, error) {
 	// We use case when to allow inserting nulls in the database
 	sqlStatement := `
 		INSERT INTO 
@@ -84,7 +78,7 @@ func (s Storage) AddRestaurant(r adder.Restaurant) int64 {
 				$9
 			)
 	`
-	res, err := s.tx.Exec(sqlStatement,
+	res, err := s.q.Exec(sqlStatement,
 		r.Name,
 		r.Cuisine,
 		r.Note,
@@ -95,17 +89,17 @@ func (s Storage) AddRestaurant(r adder.Restaurant) int64 {
 		r.Longitude,
 		r.BusinessStatus,
 	)
-	checkAndPanic(err)
-	lastID, err := res.LastInsertId()
-	checkAndPanic(err)
-	return lastID
+	if err != nil {
+		return 0, translateError(err)
+	}
+	return res.LastInsertId()
 }
 
 // IsDuplicateRestaurant returns true if the database already has a restaurant with the same name in the same city and
 // state
-func (s Storage) IsDuplicateRestaurant(r adder.Restaurant) bool {
+func (s Storage) IsDuplicateRestaurant(r adder.Restaurant) (bool, error) {
 	// Query the database for a restaurant with the same name and city name and city state
-	dbRows, err := s.db.Query(`
+	dbRows, err := s.q.Query(`
 		SELECT 
 			restaurant.id
 		FROM 
@@ -115,22 +109,24 @@ func (s Storage) IsDuplicateRestaurant(r adder.Restaurant) bool {
 			upper(restaurant.name) = upper($1)
 			and upper(city.name) = upper($2)
 			and upper(city.state) = upper($3)
+			and restaurant.deleted_at IS NULL
 		`, r.Name, r.CityState.Name, r.CityState.State)
-	checkAndPanic(err)
+	if err != nil {
+		return false, err
+	}
 	defer dbRows.Close()
 	var id int64
 	for dbRows.Next() {
-		err = dbRows.Scan(&id)
-		checkAndPanic(err)
+		if err = dbRows.Scan(&id); err != nil {
+			return false, err
+		}
 	}
-	err = dbRows.Err()
-	checkAndPanic(err)
-	return id != 0
+	return id != 0, dbRows.Err()
 }
 
 // GetCityIDByNameAndState queries the database for a given city name and state name, returns the id of the row if it
-// exists
-func (s Storage) GetCityIDByNameAndState(cityName string, stateName string) int64 {
+// exists or 0 if it does not
+func (s Storage) GetCityIDByNameAndState(cityName string, stateName string) (int64, error) {
 	// upper() so we get better matching
 	sqlStatement := `
 		SELECT 
@@ -142,16 +138,16 @@ func (s Storage) GetCityIDByNameAndState(cityName string, stateName string) int6
 			and upper(state)=upper($2)
 	`
 	var id int64
-	row := s.db.QueryRow(sqlStatement, cityName, stateName)
+	row := s.q.QueryRow(sqlStatement, cityName, stateName)
 	err := row.Scan(&id)
-	if err != sql.ErrNoRows {
-		checkAndPanic(err)
+	if err == sql.ErrNoRows {
+		return 0, nil
 	}
-	return id
+	return id, err
 }
 
-// AddCity adds a city to the city table and returns the primary key id. Must call Commit() to commit transaction
-func (s Storage) AddCity(cityName string, stateName string) int64 {
+// AddCity adds a city to the city table and returns the primary key id
+func (s Storage) AddCity(cityName string, stateName string) (int64, error) {
 	sqlStatement := `
 		INSERT INTO 
 			city(name, state)
@@ -159,16 +155,15 @@ func (s Storage) AddCity(cityName string, stateName string) int64 {
 			($1, $2)
 		ON CONFLICT DO NOTHING
 	`
-	res, err := s.tx.Exec(sqlStatement, cityName, stateName)
-	checkAndPanic(err)
-	lastID, err := res.LastInsertId()
-	checkAndPanic(err)
-	return lastID
+	res, err := s.q.Exec(sqlStatement, cityName, stateName)
+	if err != nil {
+		return 0, translateError(err)
+	}
+	return res.LastInsertId()
 }
 
-// AddGmapsPlace adds a Google Maps Place to the database and returns the primary key id. Must call Commit() to commit
-// the transaction
-func (s Storage) AddGmapsPlace(g adder.GmapsPlace) int64 {
+// AddGmapsPlace adds a Google Maps Place to the database and returns the primary key id.
+func (s Storage) AddGmapsPlace(g adder.GmapsPlace) (int64, error) {
 	// We use case when to allow inserting nulls in the database
 	sqlStatement := `
 		INSERT INTO 
@@ -215,7 +210,7 @@ func (s Storage) AddGmapsPlace(g adder.GmapsPlace) int64 {
 			last_updated = $12
 	`
 	currentDateTime := time.Now()
-	res, err := s.tx.Exec(sqlStatement,
+	res, err := s.q.Exec(sqlStatement,
 		g.PlaceID,
 		g.BusinessStatus,
 		g.FormattedPhoneNumber,
@@ -229,13 +224,15 @@ func (s Storage) AddGmapsPlace(g adder.GmapsPlace) int64 {
 		g.RestaurantID,
 		currentDateTime.Format("2006-01-02T15:04:05Z"),
 	)
-	checkAndPanic(err)
-	lastID, err := res.LastInsertId()
-	checkAndPanic(err)
-	return lastID
+	if err != nil {
+		return 0, translateError(err)
+	}
+	return res.LastInsertId()
 }
 
-func generateRestaurantSQL() string {
+// generateRestaurantSQL returns the restaurant select statement. distance is the sql for the distance column, see
+// distanceSQL.
+func generateRestaurantSQL(distance string) string {
 	// Need COALESCE because this is the least ugly way to handle nullable columns in go
 	sql := `
 		SELECT
@@ -264,7 +261,9 @@ func generateRestaurantSQL() string {
 			COALESCE(user_ratings_total, 0) as user_ratings_total,
 			COALESCE(utc_offset, 0) as utc_offset,
 			COALESCE(website, "") as website,
-			COALESCE(ratings.avg_rating, 0) as avg_rating
+			COALESCE(ratings.avg_rating, 0) as avg_rating,
+			COALESCE(` + ratingDiffSQL + `, 0) as rating_diff,
+			` + distance + ` as distance
 		FROM
 			restaurant as res
 			inner join city on city.id = res.city_id
@@ -275,6 +274,8 @@ func generateRestaurantSQL() string {
 					max(visit_datetime) as last_visit
 				FROM
 					visit
+				WHERE
+					deleted_at IS NULL
 				GROUP BY
 					restaurant_id
 			) as last_visits on last_visits.restaurant_id = res.id
@@ -285,6 +286,8 @@ func generateRestaurantSQL() string {
 				FROM
 					visit_user as vu
 					left join visit as v on v.id = vu.visit_id
+				WHERE
+					v.deleted_at IS NULL
 				GROUP BY
 					v.restaurant_id
 			) as ratings on ratings.restaurant_id = res.id
@@ -300,9 +303,23 @@ func generateVisitSQL() string {
 			v.id,
 			v.restaurant_id,
 			visit_datetime,
-			COALESCE(v.note, "") as note
+			COALESCE(v.note, "") as note,
+			res.name as restaurant_name,
+			COALESCE(visit_ratings.avg_rating, 0) as avg_rating,
+			COALESCE(visit_ratings.attendees, 0) as attendees
 		FROM
 			visit as v
+			inner join restaurant as res on res.id = v.restaurant_id
+			left join (
+				SELECT
+					visit_id,
+					round(avg(rating), 1) as avg_rating,
+					count(*) as attendees
+				FROM
+					visit_user
+				GROUP BY
+					visit_id
+			) as visit_ratings on visit_ratings.visit_id = v.id
 	`
 
 	return sql
@@ -341,6 +358,8 @@ func fillRestaurant(row scanner, r *lister.Restaurant) error {
 		&r.GmapsPlace.UTCOffset,
 		&r.GmapsPlace.Website,
 		&r.AvgRating,
+		&r.RatingDiff,
+		&r.Distance,
 	)
 }
 
@@ -353,26 +372,149 @@ func addSortOps(sqlStatement string, sortOp lister.SortOperation, last bool) str
 	return sqlStatement
 }
 
-func addFilterOps(sqlStatement string, filterOp lister.FilterOperation, idx int) string {
-	var formatString string
-	if filterOp.Operator == "is" || filterOp.Operator == "is not" {
-		formatString = "%s %s $%s"
-	} else {
-		formatString = "%s %s CAST($%s as %s)"
+// filterSQLTypes are the types filter values are cast to for each type of lister.Field.
+var filterSQLTypes = map[string]string{
+	lister.FieldText:  "TEXT",
+	lister.FieldReal:  "REAL",
+	lister.FieldInt:   "INT",
+	lister.FieldDate:  "TEXT",
+	lister.FieldIDSet: "INT",
+}
+
+// filterOpSQL returns the condition for a filter operation. The values of its parameters are appended to values and
+// numbered after the values already there.
+func filterOpSQL(filterOp lister.FilterOperation, values *[]interface{}) string {
+	param := func(v string) string {
+		var fv interface{} = v
+		if fv == "NULL" {
+			fv = nil
+		}
+		*values = append(*values, fv)
+		return "$" + strconv.Itoa(len(*values))
+	}
+	cast := func(v string) string {
+		return fmt.Sprintf("CAST(%s as %s)", param(v), filterSQLTypes[filterOp.FieldType])
 	}
 
-	if idx != 0 {
-		formatString = "AND " + formatString
+	if filterOp.FieldType == lister.FieldGeo {
+		return nearSQL(filterOp, values)
 	}
 
-	if filterOp.Operator == "is" || filterOp.Operator == "is not" {
-		sqlStatement = sqlStatement + fmt.Sprintf(formatString, filterOp.Field, filterOp.Operator, strconv.Itoa(idx))
-	} else {
-		sqlStatement = sqlStatement + fmt.Sprintf(formatString, filterOp.Field, filterOp.Operator, strconv.Itoa(idx), filterOp.FieldType)
+	if filterOp.FieldType == lister.FieldIDSet {
+		// The field is a query of the ids in the set.
+		switch filterOp.Operator {
+		case "!=":
+			return fmt.Sprintf("%s NOT IN (%s)", cast(filterOp.Value), filterOp.Field)
+		case "in":
+			conds := make([]string, len(filterOp.Values))
+			for i, v := range filterOp.Values {
+				conds[i] = fmt.Sprintf("%s IN (%s)", cast(v), filterOp.Field)
+			}
+			return strings.Join(conds, " OR ")
+		default:
+			return fmt.Sprintf("%s IN (%s)", cast(filterOp.Value), filterOp.Field)
+		}
 	}
 
-	sqlStatement = sqlStatement + "\n"
-	return sqlStatement
+	switch filterOp.Operator {
+	case "is", "is not":
+		return fmt.Sprintf("%s %s %s", filterOp.Field, filterOp.Operator, param(filterOp.Value))
+	case "contains":
+		// LIKE ignores the case of ASCII letters.
+		return fmt.Sprintf(`%s LIKE %s ESCAPE '\'`, filterOp.Field, param("%"+escapeLike(filterOp.Value)+"%"))
+	case "starts with":
+		return fmt.Sprintf(`%s LIKE %s ESCAPE '\'`, filterOp.Field, param(escapeLike(filterOp.Value)+"%"))
+	case "in":
+		casts := make([]string, len(filterOp.Values))
+		for i, v := range filterOp.Values {
+			casts[i] = cast(v)
+		}
+		return fmt.Sprintf("%s IN (%s)", filterOp.Field, strings.Join(casts, ", "))
+	case "between":
+		return fmt.Sprintf("%s BETWEEN %s AND %s", filterOp.Field, cast(filterOp.Values[0]), cast(filterOp.Values[1]))
+	default:
+		return fmt.Sprintf("%s %s %s", filterOp.Field, filterOp.Operator, cast(filterOp.Value))
+	}
+}
+
+// escapeLike escapes the wildcards of LIKE in s so they match themselves. The escape character is \.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// filterExprSQL compiles a filter expression to a condition. The values of its parameters are appended to values in
+// the order they are numbered, which carries on from the values already there.
+func filterExprSQL(expr lister.FilterExpr, values *[]interface{}) (string, error) {
+	var children []lister.FilterExpr
+	var joiner string
+	switch e := expr.(type) {
+	case lister.FilterOperation:
+		return filterOpSQL(e, values), nil
+	case lister.FilterNot:
+		condition, err := filterExprSQL(e.Expr, values)
+		if err != nil {
+			return "", err
+		}
+		// A comparison with NULL is NULL and NOT NULL is still NULL, so treat it as false first.
+		return "NOT COALESCE(" + condition + ", 0)", nil
+	case lister.FilterAnd:
+		children, joiner = e, " AND "
+	case lister.FilterOr:
+		children, joiner = e, " OR "
+	default:
+		return "", fmt.Errorf("Unknown filter expression %T", expr)
+	}
+	conditions := make([]string, len(children))
+	for i, c := range children {
+		condition, err := filterExprSQL(c, values)
+		if err != nil {
+			return "", err
+		}
+		conditions[i] = "(" + condition + ")"
+	}
+	return strings.Join(conditions, joiner), nil
+}
+
+// addPageOps adds the keyset condition and the limit of a page after the WHERE clause and returns the values to bind.
+// Only the rows that sort after page.After are kept, which is true when a row's sort fields are equal to page.After up
+// to one that is further along in its sort direction. The first parameter is $idx.
+func addPageOps(sqlStatement string, sortOps []lister.SortOperation, page lister.PageOperation, idx int) (string,
+	[]interface{}) {
+	var values []interface{}
+	if len(page.After) == len(sortOps) && len(sortOps) > 0 {
+		var branches []string
+		for i, so := range sortOps {
+			var conds []string
+			for j := 0; j < i; j++ {
+				conds = append(conds, fmt.Sprintf("%s = $%d", sortOps[j].Field, idx+j))
+			}
+			op := ">"
+			if so.Direction == "desc" {
+				op = "<"
+			}
+			conds = append(conds, fmt.Sprintf("%s %s $%d", so.Field, op, idx+i))
+			branches = append(branches, "("+strings.Join(conds, " AND ")+")")
+		}
+		sqlStatement = sqlStatement + "AND (" + strings.Join(branches, " OR ") + ")\n"
+		values = append(values, page.After...)
+		idx += len(sortOps)
+	}
+
+	nSortOps := len(sortOps)
+	if nSortOps > 0 {
+		sqlStatement = sqlStatement + `
+			ORDER BY
+		`
+		for i, so := range sortOps {
+			sqlStatement = addSortOps(sqlStatement, so, i == nSortOps-1)
+		}
+	}
+
+	if page.Limit > 0 {
+		sqlStatement = sqlStatement + fmt.Sprintf("LIMIT $%d\n", idx)
+		values = append(values, page.Limit)
+	}
+	return sqlStatement, values
 }
 
 func fillVisit(row scanner, v *lister.Visit) error {
@@ -381,100 +523,118 @@ func fillVisit(row scanner, v *lister.Visit) error {
 		&v.RestaurantID,
 		&v.VisitDateTime,
 		&v.Note,
+		&v.RestaurantName,
+		&v.AvgRating,
+		&v.Attendees,
 	)
 }
 
-// GetRestaurant queries the restaurant table for the given id. If the returned restaurant has ID = 0 then it is not in
-// the database
-func (s Storage) GetRestaurant(id int64) lister.Restaurant {
+// GetRestaurant queries the restaurant table for the given id. Returns a storage.ErrNotFound if it is not in the
+// database
+func (s Storage) GetRestaurant(id int64) (lister.Restaurant, error) {
 	var r lister.Restaurant
-	sqlStatement := generateRestaurantSQL()
+	sqlStatement := generateRestaurantSQL("0")
 	// Add where clause by restaurant id
 	sqlStatement = sqlStatement + `
 		WHERE
 			res.id=$1
+			and res.deleted_at IS NULL
 	`
-	row := s.db.QueryRow(sqlStatement, id)
+	row := s.q.QueryRow(sqlStatement, id)
 	err := fillRestaurant(row, &r)
-	if err != sql.ErrNoRows {
-		checkAndPanic(err)
+	if err == sql.ErrNoRows {
+		return r, &storage.ErrNotFound{Msg: fmt.Sprintf("No restaurant with id: %d", id)}
 	}
-	return r
+	return r, err
 }
 
-// GetRestaurants queries the restaurant table for all restaurants.
-func (s Storage) GetRestaurants(sortOps []lister.SortOperation, filterOps []lister.FilterOperation) []lister.Restaurant {
+// GetRestaurants queries the restaurant table for a page of restaurants.
+func (s Storage) GetRestaurants(sortOps []lister.SortOperation, filter lister.FilterExpr,
+	page lister.PageOperation) ([]lister.Restaurant, error) {
 	var allResturants []lister.Restaurant
 	var r lister.Restaurant
-	// Generate the get sql statement without the where clause.
-	sqlStatement := generateRestaurantSQL()
-
-	var filterValues []interface{}
-	nFilterOps := len(filterOps)
-	if nFilterOps > 0 {
-		sqlStatement = sqlStatement + `
-			WHERE
-		`
-		// add filter statements
-		for i, fo := range filterOps {
-			sqlStatement = addFilterOps(sqlStatement, fo, i)
-			var fv interface{} = fo.Value
-			if fv == "NULL" {
-				fv = nil
-			}
-			filterValues = append(filterValues, fv)
-		}
+	sqlStatement, filterValues, err := filterRestaurantsSQL(filter)
+	if err != nil {
+		return allResturants, err
 	}
+	sortOps = withDistanceSQL(sortOps, distanceSQL(filter))
+	sqlStatement, pageValues := addPageOps(sqlStatement, sortOps, page, len(filterValues)+1)
 
-	nSortOps := len(sortOps)
-	if nSortOps > 0 {
-		sqlStatement = sqlStatement + `
-			ORDER BY
-		`
-		// add sort statements
-		for i, so := range sortOps {
-			sqlStatement = addSortOps(sqlStatement, so, i == nSortOps-1)
-		}
+	dbRows, err := s.q.Query(sqlStatement, append(filterValues, pageValues...)...)
+	if err != nil {
+		return allResturants, err
 	}
-
-	dbRows, err := s.db.Query(sqlStatement, filterValues...)
-	checkAndPanic(err)
 	defer dbRows.Close()
 	for dbRows.Next() {
 		err = fillRestaurant(dbRows, &r)
-		checkAndPanic(err)
+		if err != nil {
+			return allResturants, err
+		}
 		allResturants = append(allResturants, r)
 	}
-	err = dbRows.Err()
-	checkAndPanic(err)
-	return allResturants
+	return allResturants, dbRows.Err()
+}
+
+// CountRestaurants returns how many restaurants match the filter.
+func (s Storage) CountRestaurants(filter lister.FilterExpr) (int64, error) {
+	var count int64
+	sqlStatement, filterValues, err := filterRestaurantsSQL(filter)
+	if err != nil {
+		return count, err
+	}
+	err = s.q.QueryRow("SELECT count(*) FROM ("+sqlStatement+")", filterValues...).Scan(&count)
+	return count, err
+}
+
+// filterRestaurantsSQL returns the restaurant select statement with the WHERE clause for the filter and the values to
+// bind.
+func filterRestaurantsSQL(filter lister.FilterExpr) (string, []interface{}, error) {
+	// Generate the get sql statement without the where clause.
+	sqlStatement := generateRestaurantSQL(distanceSQL(filter))
+
+	// Restaurants in the trash are never listed.
+	sqlStatement = sqlStatement + `
+		WHERE
+			res.deleted_at IS NULL
+	`
+	var filterValues []interface{}
+	if filter != nil {
+		condition, err := filterExprSQL(filter, &filterValues)
+		if err != nil {
+			return "", nil, err
+		}
+		sqlStatement = sqlStatement + "AND (" + condition + ")\n"
+	}
+	return sqlStatement, filterValues, nil
 }
 
 // GetRestaurantsByCity gives you all the restaurants with a given city id.
-func (s Storage) GetRestaurantsByCity(cityID int64) []lister.Restaurant {
+func (s Storage) GetRestaurantsByCity(cityID int64) ([]lister.Restaurant, error) {
 	var restaurantsInCity []lister.Restaurant
 	var r lister.Restaurant
 	// Generate the get sql statement without the where clause.
-	sqlStatement := generateRestaurantSQL()
+	sqlStatement := generateRestaurantSQL("0")
 	sqlStatement = sqlStatement + `
 		WHERE
 			city.id=$1
 	`
-	dbRows, err := s.db.Query(sqlStatement, cityID)
-	checkAndPanic(err)
+	dbRows, err := s.q.Query(sqlStatement, cityID)
+	if err != nil {
+		return restaurantsInCity, err
+	}
 	defer dbRows.Close()
 	for dbRows.Next() {
 		err = fillRestaurant(dbRows, &r)
-		checkAndPanic(err)
+		if err != nil {
+			return restaurantsInCity, err
+		}
 		restaurantsInCity = append(restaurantsInCity, r)
 	}
-	err = dbRows.Err()
-	checkAndPanic(err)
-	return restaurantsInCity
+	return restaurantsInCity, dbRows.Err()
 }
 
-// UpdateRestaurant updates a given restaurant, returns the rows affected. Must call Commit() to commit transaction
-func (s Storage) UpdateRestaurant(r updater.Restaurant) int64 {
+// UpdateRestaurant updates a given restaurant, returns the rows affected
+func (s Storage) UpdateRestaurant(r updater.Restaurant) (int64, error) {
 	// We use case when to allow updating to nulls in the database
 	sqlStatement := `
 		UPDATE
@@ -493,7 +653,7 @@ func (s Storage) UpdateRestaurant(r updater.Restaurant) int64 {
 			id = $10
 	`
 
-	res, err := s.tx.Exec(sqlStatement,
+	res, err := s.q.Exec(sqlStatement,
 		r.Name,
 		r.Cuisine,
 		r.Note,
@@ -505,15 +665,14 @@ func (s Storage) UpdateRestaurant(r updater.Restaurant) int64 {
 		r.BusinessStatus,
 		r.ID,
 	)
-	checkAndPanic(err)
-	rowsAffected, err := res.RowsAffected()
-	checkAndPanic(err)
-	return rowsAffected
+	if err != nil {
+		return 0, translateError(err)
+	}
+	return res.RowsAffected()
 }
 
-// UpdateGmapsPlace updates a given gmaps_place, returns the rows affected. Caller must call Commit() to commit the
-// transaction
-func (s Storage) UpdateGmapsPlace(gp updater.GmapsPlace) int64 {
+// UpdateGmapsPlace updates a given gmaps_place, returns the rows affected
+func (s Storage) UpdateGmapsPlace(gp updater.GmapsPlace) (int64, error) {
 	// We use case when to allow updating to nulls in the database
 	sqlStatement := `
 		UPDATE
@@ -535,7 +694,7 @@ func (s Storage) UpdateGmapsPlace(gp updater.GmapsPlace) int64 {
 			id = $13
 	`
 
-	res, err := s.tx.Exec(sqlStatement,
+	res, err := s.q.Exec(sqlStatement,
 		gp.PlaceID,
 		gp.BusinessStatus,
 		gp.FormattedPhoneNumber,
@@ -550,29 +709,46 @@ func (s Storage) UpdateGmapsPlace(gp updater.GmapsPlace) int64 {
 		gp.LastUpdated,
 		gp.ID,
 	)
-	checkAndPanic(err)
-	rowsAffected, err := res.RowsAffected()
-	checkAndPanic(err)
-	return rowsAffected
+	if err != nil {
+		return 0, translateError(err)
+	}
+	return res.RowsAffected()
 }
 
-// RemoveRestaurant deletes a given restaurant from the database and returns the rows affected. Caller must call
-// Commit() to commit the transaction
-func (s Storage) RemoveRestaurant(r remover.Restaurant) int64 {
+// RemoveRestaurant deletes a given restaurant from the database and returns the rows affected.
+func (s Storage) RemoveRestaurant(r remover.Restaurant) (int64, error) {
 	return s.removeRow("restaurant", r.ID)
 }
 
-// RemoveCity deletes a given city and returns the number of rows affected. Caller must call Commit() to commit the
-// transaction
-func (s Storage) RemoveCity(cityID int64) int64 {
+// RemoveCity deletes a given city and returns the number of rows affected
+func (s Storage) RemoveCity(cityID int64) (int64, error) {
 	return s.removeRow("city", cityID)
 }
 
-func (s Storage) RemoveGmapsPlace(gmapsID int64) int64 {
+// GetGmapsPlaceRestaurantID returns the id of the restaurant a Google Maps place belongs to. Returns a
+// storage.ErrNotFound if there is no place with the given id.
+func (s Storage) GetGmapsPlaceRestaurantID(gmapsID int64) (int64, error) {
+	sqlStatement := `
+		SELECT
+			restaurant_id
+		FROM
+			gmaps_place
+		WHERE
+			id = $1
+	`
+	var restaurantID int64
+	err := s.q.QueryRow(sqlStatement, gmapsID).Scan(&restaurantID)
+	if err == sql.ErrNoRows {
+		return 0, &storage.ErrNotFound{Msg: fmt.Sprintf("No Google Maps place with id: %d", gmapsID)}
+	}
+	return restaurantID, err
+}
+
+func (s Storage) RemoveGmapsPlace(gmapsID int64) (int64, error) {
 	return s.removeRow("gmaps_place", gmapsID)
 }
 
-func (s Storage) removeRow(tableName string, rowID int64) int64 {
+func (s Storage) removeRow(tableName string, rowID int64) (int64, error) {
 	sqlStatement := `
 		DELETE FROM
 			%s
@@ -582,16 +758,16 @@ func (s Storage) removeRow(tableName string, rowID int64) int64 {
 	// Never pass tableName from user input!
 	sqlStatement = fmt.Sprintf(sqlStatement, tableName)
 
-	res, err := s.tx.Exec(sqlStatement, rowID)
-	checkAndPanic(err)
-	rowsAffected, err := res.RowsAffected()
-	checkAndPanic(err)
-	return rowsAffected
+	res, err := s.q.Exec(sqlStatement, rowID)
+	if err != nil {
+		return 0, translateError(err)
+	}
+	return res.RowsAffected()
 }
 
-// GetVisit queries the visit table for a given visit id and restaurant id. If the returned visit has ID = 0 then it is not in
-// the database
-func (s Storage) GetVisit(id int64, resID int64) lister.Visit {
+// GetVisit queries the visit table for a given visit id and restaurant id. Returns a storage.ErrNotFound if it is not
+// in the database
+func (s Storage) GetVisit(id int64, resID int64) (lister.Visit, error) {
 	var v lister.Visit
 	sqlStatement := generateVisitSQL()
 	// Add where clause by id
@@ -599,58 +775,76 @@ func (s Storage) GetVisit(id int64, resID int64) lister.Visit {
 		WHERE
 			v.id=$1
 			and v.restaurant_id=$2
+			and v.deleted_at IS NULL
 	`
-	row := s.db.QueryRow(sqlStatement, id, resID)
+	row := s.q.QueryRow(sqlStatement, id, resID)
 	err := fillVisit(row, &v)
-	if err != sql.ErrNoRows {
-		checkAndPanic(err)
+	if err == sql.ErrNoRows {
+		return v, &storage.ErrNotFound{Msg: fmt.Sprintf("No visit with id: %d for restaurant: %d", id, resID)}
 	}
-	return v
+	return v, err
 }
 
-// GetVisitsByRestaurantID gets all the visits for a given restaurant_id
-func (s Storage) GetVisitsByRestaurantID(restaurantID int64, sortOps []lister.SortOperation) []lister.Visit {
+// GetVisits queries the visit table for a page of visits.
+func (s Storage) GetVisits(sortOps []lister.SortOperation, filter lister.FilterExpr,
+	page lister.PageOperation) ([]lister.Visit, error) {
 	var allVisits []lister.Visit
 	var v lister.Visit
-	sqlStatement := generateVisitSQL()
-
-	// Add where clause by id
-	sqlStatement = sqlStatement + `
-		WHERE
-			v.restaurant_id=$1
-	`
-
-	sqlStatement = sqlStatement + `
-			ORDER BY
-		`
-	nSortOps := len(sortOps)
-	if nSortOps > 0 {
-		// add sort statements
-		for i, so := range sortOps {
-			sqlStatement = addSortOps(sqlStatement, so, i == nSortOps-1)
-		}
-	} else {
-		// By default sort by this
-		sqlStatement = sqlStatement + `
-			visit_datetime desc
-		`
+	sqlStatement, filterValues, err := filterVisitsSQL(filter)
+	if err != nil {
+		return allVisits, err
 	}
+	sqlStatement, pageValues := addPageOps(sqlStatement, sortOps, page, len(filterValues)+1)
 
-	dbRows, err := s.db.Query(sqlStatement, restaurantID)
-	checkAndPanic(err)
+	dbRows, err := s.q.Query(sqlStatement, append(filterValues, pageValues...)...)
+	if err != nil {
+		return allVisits, err
+	}
 	defer dbRows.Close()
 	for dbRows.Next() {
 		err = fillVisit(dbRows, &v)
-		checkAndPanic(err)
+		if err != nil {
+			return allVisits, err
+		}
 		allVisits = append(allVisits, v)
 	}
-	err = dbRows.Err()
-	checkAndPanic(err)
-	return allVisits
+	return allVisits, dbRows.Err()
+}
+
+// CountVisits returns how many visits match the filter.
+func (s Storage) CountVisits(filter lister.FilterExpr) (int64, error) {
+	var count int64
+	sqlStatement, filterValues, err := filterVisitsSQL(filter)
+	if err != nil {
+		return count, err
+	}
+	err = s.q.QueryRow("SELECT count(*) FROM ("+sqlStatement+")", filterValues...).Scan(&count)
+	return count, err
+}
+
+// filterVisitsSQL returns the visit select statement with the WHERE clause for the filter and the values to bind.
+func filterVisitsSQL(filter lister.FilterExpr) (string, []interface{}, error) {
+	sqlStatement := generateVisitSQL()
+
+	// Visits in the trash, or to restaurants in the trash, are never listed.
+	sqlStatement = sqlStatement + `
+		WHERE
+			v.deleted_at IS NULL
+			and res.deleted_at IS NULL
+	`
+	var filterValues []interface{}
+	if filter != nil {
+		condition, err := filterExprSQL(filter, &filterValues)
+		if err != nil {
+			return "", nil, err
+		}
+		sqlStatement = sqlStatement + "AND (" + condition + ")\n"
+	}
+	return sqlStatement, filterValues, nil
 }
 
 // GetVisitUsersByVisitID queries the db for user for the given visit_id
-func (s Storage) GetVisitUsersByVisitID(visitID int64) []lister.VisitUser {
+func (s Storage) GetVisitUsersByVisitID(visitID int64) ([]lister.VisitUser, error) {
 	var allVisitUsers []lister.VisitUser
 	var vu lister.VisitUser
 
@@ -668,8 +862,10 @@ func (s Storage) GetVisitUsersByVisitID(visitID int64) []lister.VisitUser {
 			visit_id = $1
 	`
 
-	dbRows, err := s.db.Query(sqlStatement, visitID)
-	checkAndPanic(err)
+	dbRows, err := s.q.Query(sqlStatement, visitID)
+	if err != nil {
+		return allVisitUsers, err
+	}
 	defer dbRows.Close()
 	for dbRows.Next() {
 		err = dbRows.Scan(
@@ -679,16 +875,16 @@ func (s Storage) GetVisitUsersByVisitID(visitID int64) []lister.VisitUser {
 			&vu.User.FirstName,
 			&vu.User.LastName,
 		)
-		checkAndPanic(err)
+		if err != nil {
+			return allVisitUsers, err
+		}
 		allVisitUsers = append(allVisitUsers, vu)
 	}
-	err = dbRows.Err()
-	checkAndPanic(err)
-	return allVisitUsers
+	return allVisitUsers, dbRows.Err()
 }
 
-// AddVisit adds the given visit to the sqlite database. Must call Commit() to commit transaction
-func (s Storage) AddVisit(v adder.Visit) int64 {
+// AddVisit adds the given visit to the sqlite database
+func (s Storage) AddVisit(v adder.Visit) (int64, error) {
 	// We use case when to allow inserting nulls in the database
 	sqlStatement := `
 		INSERT INTO 
@@ -704,19 +900,19 @@ func (s Storage) AddVisit(v adder.Visit) int64 {
 				CASE WHEN $3 == "" THEN NULL ELSE $3 END
 			)
 	`
-	res, err := s.tx.Exec(sqlStatement,
+	res, err := s.q.Exec(sqlStatement,
 		v.RestaurantID,
 		v.VisitDateTime,
 		v.Note,
 	)
-	checkAndPanic(err)
-	lastID, err := res.LastInsertId()
-	checkAndPanic(err)
-	return lastID
+	if err != nil {
+		return 0, translateError(err)
+	}
+	return res.LastInsertId()
 }
 
-// AddVisitUser adds the given visit to the sqlite database. Must call Commit() to commit transaction
-func (s Storage) AddVisitUser(vu adder.VisitUser) int64 {
+// AddVisitUser adds the given visit to the sqlite database
+func (s Storage) AddVisitUser(vu adder.VisitUser) (int64, error) {
 	// We use case when to allow inserting nulls in the database
 	sqlStatement := `
 		INSERT INTO 
@@ -737,46 +933,48 @@ func (s Storage) AddVisitUser(vu adder.VisitUser) int64 {
 			user_id = $2,
 			rating = CASE WHEN $3 == 0 THEN NULL ELSE $3 END
 	`
-	res, err := s.tx.Exec(sqlStatement,
+	res, err := s.q.Exec(sqlStatement,
 		vu.VisitID,
 		vu.UserID,
 		vu.Rating,
 	)
-	checkAndPanic(err)
-	lastID, err := res.LastInsertId()
-	checkAndPanic(err)
-	return lastID
+	if err != nil {
+		return 0, translateError(err)
+	}
+	return res.LastInsertId()
 }
 
-// GetUser queries the user table for a given user id. If the returned user has ID = 0 then it is not in the db.
-func (s Storage) GetUser(id int64) lister.User {
+// GetUser queries the user table for a given user id. Returns a storage.ErrNotFound if it is not in the db.
+func (s Storage) GetUser(id int64) (lister.User, error) {
 	var u lister.User
 	sqlStatement := `
 		SELECT 
 			id,
 			first_name,
 			last_name,
-			email
+			email,
+			is_admin
 		FROM
 			user 
 		WHERE 
 			id = $1
 	`
-	row := s.db.QueryRow(sqlStatement, id)
+	row := s.q.QueryRow(sqlStatement, id)
 	err := row.Scan(
 		&u.ID,
 		&u.FirstName,
 		&u.LastName,
 		&u.Email,
+		&u.IsAdmin,
 	)
-	if err != sql.ErrNoRows {
-		checkAndPanic(err)
+	if err == sql.ErrNoRows {
+		return u, &storage.ErrNotFound{Msg: fmt.Sprintf("No user with id: %d", id)}
 	}
-	return u
+	return u, err
 }
 
 // GetUsers queries the user table for all the users.
-func (s Storage) GetUsers() []lister.User {
+func (s Storage) GetUsers() ([]lister.User, error) {
 	var allUsers []lister.User
 	var u lister.User
 	sqlStatement := `
@@ -784,12 +982,15 @@ func (s Storage) GetUsers() []lister.User {
 			id,
 			first_name,
 			last_name,
-			email
+			email,
+			is_admin
 		FROM
 			user
 	`
-	dbRows, err := s.db.Query(sqlStatement)
-	checkAndPanic(err)
+	dbRows, err := s.q.Query(sqlStatement)
+	if err != nil {
+		return allUsers, err
+	}
 	defer dbRows.Close()
 	for dbRows.Next() {
 		err = dbRows.Scan(
@@ -797,46 +998,49 @@ func (s Storage) GetUsers() []lister.User {
 			&u.FirstName,
 			&u.LastName,
 			&u.Email,
+			&u.IsAdmin,
 		)
-		checkAndPanic(err)
+		if err != nil {
+			return allUsers, err
+		}
 		allUsers = append(allUsers, u)
 	}
-	err = dbRows.Err()
-	checkAndPanic(err)
-	return allUsers
+	return allUsers, dbRows.Err()
 }
 
 // GetUserBy queries the user table for a given user by field and value. Do not pass field arguments from untrusted
-// sources. If the returned user has ID = 0 then it is not in the db.
-func (s Storage) GetUserBy(field string, value string) lister.User {
+// sources. Returns a storage.ErrNotFound if it is not in the db.
+func (s Storage) GetUserBy(field string, value string) (lister.User, error) {
 	var u lister.User
 	sqlStatement := `
 		SELECT 
 			id,
 			first_name,
 			last_name,
-			email
+			email,
+			is_admin
 		FROM
 			user
 		WHERE
 			%s = $1
 	`
-	row := s.db.QueryRow(fmt.Sprintf(sqlStatement, field), value)
+	row := s.q.QueryRow(fmt.Sprintf(sqlStatement, field), value)
 	err := row.Scan(
 		&u.ID,
 		&u.FirstName,
 		&u.LastName,
 		&u.Email,
+		&u.IsAdmin,
 	)
-	if err != sql.ErrNoRows {
-		checkAndPanic(err)
+	if err == sql.ErrNoRows {
+		return u, &storage.ErrNotFound{Msg: fmt.Sprintf("No user with %s: %s", field, value)}
 	}
-	return u
+	return u, err
 }
 
-// GetUserAuthByEmail returns the password and remember hashes of a given email. If the returned user has ID = 0 then it
-// is not in the db.
-func (s Storage) GetUserAuthByEmail(email string) auther.User {
+// GetUserAuthByEmail returns the password and remember hashes of a given email. Returns a storage.ErrNotFound if it is
+// not in the db.
+func (s Storage) GetUserAuthByEmail(email string) (auther.User, error) {
 	var uh auther.User
 	sqlStatement := `
 		SELECT
@@ -848,21 +1052,21 @@ func (s Storage) GetUserAuthByEmail(email string) auther.User {
 		WHERE
 			email = $1
 	`
-	row := s.db.QueryRow(sqlStatement, email)
+	row := s.q.QueryRow(sqlStatement, email)
 	err := row.Scan(
 		&uh.ID,
 		&uh.PasswordHash,
 		&uh.RememberToken,
 	)
-	if err != sql.ErrNoRows {
-		checkAndPanic(err)
+	if err == sql.ErrNoRows {
+		return uh, &storage.ErrNotFound{Msg: fmt.Sprintf("No user with email: %s", email)}
 	}
-	return uh
+	return uh, err
 }
 
-// GetUserAuthByID returns the password and remember hashes of a given email. If the returned user has ID = 0 then it
-// is not in the db.
-func (s Storage) GetUserAuthByID(id int64) auther.User {
+// GetUserAuthByID returns the password and remember hashes of a given user id. Returns a storage.ErrNotFound if it is
+// not in the db.
+func (s Storage) GetUserAuthByID(id int64) (auther.User, error) {
 	var uh auther.User
 	sqlStatement := `
 		SELECT
@@ -874,20 +1078,20 @@ func (s Storage) GetUserAuthByID(id int64) auther.User {
 		WHERE
 			id = $1
 	`
-	row := s.db.QueryRow(sqlStatement, id)
+	row := s.q.QueryRow(sqlStatement, id)
 	err := row.Scan(
 		&uh.ID,
 		&uh.PasswordHash,
 		&uh.RememberToken,
 	)
-	if err != sql.ErrNoRows {
-		checkAndPanic(err)
+	if err == sql.ErrNoRows {
+		return uh, &storage.ErrNotFound{Msg: fmt.Sprintf("No user with id: %d", id)}
 	}
-	return uh
+	return uh, err
 }
 
 // GetUserCount returns the number of users in the db.
-func (s Storage) GetUserCount() int64 {
+func (s Storage) GetUserCount() (int64, error) {
 	var userCount int64
 	sqlStatement := `
 		SELECT 
@@ -895,18 +1099,15 @@ func (s Storage) GetUserCount() int64 {
 		FROM
 			user
 	`
-	row := s.db.QueryRow(sqlStatement)
+	row := s.q.QueryRow(sqlStatement)
 	err := row.Scan(
 		&userCount,
 	)
-	if err != sql.ErrNoRows {
-		checkAndPanic(err)
-	}
-	return userCount
+	return userCount, err
 }
 
-// UpdateUser updates a given user, returns the rows affected. Caller must call Commit() to commit the transaction
-func (s Storage) UpdateUser(u updater.User) int64 {
+// UpdateUser updates a given user, returns the rows affected
+func (s Storage) UpdateUser(u updater.User) (int64, error) {
 	sqlStatement := `
 		UPDATE
 			user
@@ -917,21 +1118,20 @@ func (s Storage) UpdateUser(u updater.User) int64 {
 		WHERE
 			id = $4
 	`
-	res, err := s.tx.Exec(sqlStatement,
+	res, err := s.q.Exec(sqlStatement,
 		u.FirstName,
 		u.LastName,
 		u.Email,
 		u.ID,
 	)
-	checkAndPanic(err)
-	rowsAffected, err := res.RowsAffected()
-	checkAndPanic(err)
-	return rowsAffected
+	if err != nil {
+		return 0, translateError(err)
+	}
+	return res.RowsAffected()
 }
 
-// UpdateUserPassword updates the password of the user with the given id. Caller must call Commit() to commit the
-// transaction
-func (s Storage) UpdateUserPassword(id int64, newPasswordHash string) int64 {
+// UpdateUserPassword updates the password of the user with the given id
+func (s Storage) UpdateUserPassword(id int64, newPasswordHash string) (int64, error) {
 	sqlStatement := `
 		UPDATE
 			user
@@ -940,19 +1140,18 @@ func (s Storage) UpdateUserPassword(id int64, newPasswordHash string) int64 {
 		WHERE
 			id = $2
 	`
-	res, err := s.tx.Exec(sqlStatement,
+	res, err := s.q.Exec(sqlStatement,
 		newPasswordHash,
 		id,
 	)
-	checkAndPanic(err)
-	rowsAffected, err := res.RowsAffected()
-	checkAndPanic(err)
-	return rowsAffected
+	if err != nil {
+		return 0, translateError(err)
+	}
+	return res.RowsAffected()
 }
 
-// UpdateVisit updates a given visit, returns the rows affected. Caller must call Commit() to commit the
-// transaction
-func (s Storage) UpdateVisit(v updater.Visit) int64 {
+// UpdateVisit updates a given visit, returns the rows affected
+func (s Storage) UpdateVisit(v updater.Visit) (int64, error) {
 	// We use case when to allow updating to nulls in the database
 	sqlStatement := `
 		UPDATE
@@ -965,21 +1164,20 @@ func (s Storage) UpdateVisit(v updater.Visit) int64 {
 			id = $4
 	`
 
-	res, err := s.tx.Exec(sqlStatement,
+	res, err := s.q.Exec(sqlStatement,
 		v.RestaurantID,
 		v.VisitDateTime,
 		v.Note,
 		v.ID,
 	)
-	checkAndPanic(err)
-	rowsAffected, err := res.RowsAffected()
-	checkAndPanic(err)
-	return rowsAffected
+	if err != nil {
+		return 0, translateError(err)
+	}
+	return res.RowsAffected()
 }
 
-// UpdateVisitUser updates a given visit_user, returns the rows affected. Caller must call Commit() to commit the
-// transaction
-func (s Storage) UpdateVisitUser(vu updater.VisitUser) int64 {
+// UpdateVisitUser updates a given visit_user, returns the rows affected
+func (s Storage) UpdateVisitUser(vu updater.VisitUser) (int64, error) {
 	// We use case when to allow updating to nulls in the database
 	sqlStatement := `
 		UPDATE
@@ -992,33 +1190,30 @@ func (s Storage) UpdateVisitUser(vu updater.VisitUser) int64 {
 			id = $4
 	`
 
-	res, err := s.tx.Exec(sqlStatement,
+	res, err := s.q.Exec(sqlStatement,
 		vu.VisitID,
 		vu.UserID,
 		vu.Rating,
 		vu.ID,
 	)
-	checkAndPanic(err)
-	rowsAffected, err := res.RowsAffected()
-	checkAndPanic(err)
-	return rowsAffected
+	if err != nil {
+		return 0, translateError(err)
+	}
+	return res.RowsAffected()
 }
 
-// RemoveVisit deletes a given visit and returns the number of rows affected. Caller must call Commit() to commit the
-// transaction
-func (s Storage) RemoveVisit(visitID int64) int64 {
+// RemoveVisit deletes a given visit and returns the number of rows affected
+func (s Storage) RemoveVisit(visitID int64) (int64, error) {
 	return s.removeRow("visit", visitID)
 }
 
-// RemoveVisitUser deletes a given visit_user and returns the number of rows affected. Caller must call Commit() to commit the
-// transaction
-func (s Storage) RemoveVisitUser(visitUserID int64) int64 {
+// RemoveVisitUser deletes a given visit_user and returns the number of rows affected
+func (s Storage) RemoveVisitUser(visitUserID int64) (int64, error) {
 	return s.removeRow("visit_user", visitUserID)
 }
 
-// AddUser adds a given user to the database and returns the new user id. Caller must call Commit() to commit the
-// transaction.
-func (s Storage) AddUser(u adder.User) int64 {
+// AddUser adds a given user to the database and returns the new user id
+func (s Storage) AddUser(u adder.User) (int64, error) {
 	// We use case when to allow inserting nulls in the database
 	sqlStatement := `
 		INSERT INTO 
@@ -1026,31 +1221,33 @@ func (s Storage) AddUser(u adder.User) int64 {
 				first_name,
 				last_name,
 				email,
-				password_hash
+				password_hash,
+				is_admin
 			)
 		VALUES
 			(
 				$1,
 				$2,
 				$3,
-				$4
+				$4,
+				$5
 			)
 	`
-	res, err := s.tx.Exec(sqlStatement,
+	res, err := s.q.Exec(sqlStatement,
 		u.FirstName,
 		u.LastName,
 		u.Email,
 		u.PasswordHash,
+		u.IsAdmin,
 	)
-	checkAndPanic(err)
-	lastID, err := res.LastInsertId()
-	checkAndPanic(err)
-	return lastID
+	if err != nil {
+		return 0, translateError(err)
+	}
+	return res.LastInsertId()
 }
 
-// UpdateUserRememberToken updates a user's remember_hash and then returns the number of rows affected. Caller must call
-// Commit() to commit the transaction.
-func (s Storage) UpdateUserRememberToken(u auther.User) int64 {
+// UpdateUserRememberToken updates a user's remember_hash and then returns the number of rows affected.
+func (s Storage) UpdateUserRememberToken(u auther.User) (int64, error) {
 	// We use case when to allow updating to nulls in the database
 	sqlStatement := `
 		UPDATE
@@ -1061,19 +1258,19 @@ func (s Storage) UpdateUserRememberToken(u auther.User) int64 {
 			id = $2
 	`
 
-	res, err := s.tx.Exec(sqlStatement,
+	res, err := s.q.Exec(sqlStatement,
 		u.RememberToken,
 		u.ID,
 	)
-	checkAndPanic(err)
-	rowsAffected, err := res.RowsAffected()
-	checkAndPanic(err)
-	return rowsAffected
+	if err != nil {
+		return 0, translateError(err)
+	}
+	return res.RowsAffected()
 }
 
 // GetRestaurantAvgRatingByUser gets a given restaurants average rating group by user. If the returned value for a user
 // is 0 then the restaurant has no ratings for that user.
-func (s Storage) GetRestaurantAvgRatingByUser(restaurantID int64) []lister.AvgUserRating {
+func (s Storage) GetRestaurantAvgRatingByUser(restaurantID int64) ([]lister.AvgUserRating, error) {
 	var allRatings []lister.AvgUserRating
 	var ar lister.AvgUserRating
 	sqlStatement := `
@@ -1088,12 +1285,15 @@ func (s Storage) GetRestaurantAvgRatingByUser(restaurantID int64) []lister.AvgUs
 			left join user as u on u.id = vu.user_id
 		WHERE
 			v.restaurant_id = $1
+			and v.deleted_at IS NULL
 		GROUP BY
 			u.first_name,
 			u.last_name
 	`
-	dbRows, err := s.db.Query(sqlStatement, restaurantID)
-	checkAndPanic(err)
+	dbRows, err := s.q.Query(sqlStatement, restaurantID)
+	if err != nil {
+		return allRatings, err
+	}
 	defer dbRows.Close()
 	for dbRows.Next() {
 		err = dbRows.Scan(
@@ -1102,16 +1302,16 @@ func (s Storage) GetRestaurantAvgRatingByUser(restaurantID int64) []lister.AvgUs
 			&ar.LastName,
 			&ar.AvgRating,
 		)
-		checkAndPanic(err)
+		if err != nil {
+			return allRatings, err
+		}
 		allRatings = append(allRatings, ar)
 	}
-	err = dbRows.Err()
-	checkAndPanic(err)
-	return allRatings
+	return allRatings, dbRows.Err()
 }
 
 // GetDistinct returns a list of distinct values for a given column from a given table
-func (s Storage) GetDistinct(columnName string, tableName string) []string {
+func (s Storage) GetDistinct(columnName string, tableName string) ([]string, error) {
 	var distinctValues []string
 	sqlStatement := `
 		SELECT
@@ -1123,22 +1323,33 @@ func (s Storage) GetDistinct(columnName string, tableName string) []string {
 	`
 	// Never pass tableName from user input!
 	sqlStatement = fmt.Sprintf(sqlStatement, columnName, tableName, columnName)
-	dbRows, err := s.db.Query(sqlStatement)
-	checkAndPanic(err)
+	dbRows, err := s.q.Query(sqlStatement)
+	if err != nil {
+		return distinctValues, err
+	}
 	defer dbRows.Close()
 	for dbRows.Next() {
 		var val string
 		err = dbRows.Scan(&val)
-		checkAndPanic(err)
+		if err != nil {
+			return distinctValues, err
+		}
 		distinctValues = append(distinctValues, val)
 	}
-	err = dbRows.Err()
-	checkAndPanic(err)
-	return distinctValues
+	return distinctValues, dbRows.Err()
 }
 
-func checkAndPanic(err error) {
-	if err != nil {
-		log.Panicln(err)
+// translateError turns sqlite constraint errors into the storage package's typed errors so the services can tell the
+// user what was wrong with their data. Any other error is returned as is.
+func translateError(err error) error {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) || sqliteErr.Code != sqlite3.ErrConstraint {
+		return err
+	}
+	switch sqliteErr.ExtendedCode {
+	case sqlite3.ErrConstraintUnique, sqlite3.ErrConstraintPrimaryKey:
+		return &storage.ErrUniqueViolation{Msg: sqliteErr.Error(), Err: err}
+	default:
+		return &storage.ErrConstraintViolation{Msg: sqliteErr.Error(), Err: err}
 	}
 }
@@ -12,39 +12,54 @@ import (
 	"github.com/kelvinatorr/restaurant-tracker/internal/mapper"
 
 	"github.com/kelvinatorr/restaurant-tracker/internal/adder"
+	"github.com/kelvinatorr/restaurant-tracker/internal/audit"
 	"github.com/kelvinatorr/restaurant-tracker/internal/auther"
+	"github.com/kelvinatorr/restaurant-tracker/internal/storage"
 )
 
-// Service provides listing operations.
+// Service provides updating operations. The int64 UpdateRestaurant and UpdateVisit take is the id of the signed in user
+// who is updating, which is recorded in the audit log. Users can only update themselves.
 type Service interface {
+	UpdateRestaurant(Restaurant, int64) (int64, error)
+	UpdateVisit(Visit, int64) (int64, error)
+	UpdateUser(User) (int64, error)
+	UpdateUserPassword(auther.UserChangePassword) (int64, error)
+	UpdateSavedView(SavedView, int64) (int64, error)
+}
+
+// TxRepository provides access to restaurant repository within a transaction.
+type TxRepository interface {
+	// UpdateRestaurant updates a given restaurant in the repository.
 	UpdateRestaurant(Restaurant) (int64, error)
+	GetCityIDByNameAndState(string, string) (int64, error)
+	AddCity(string, string) (int64, error)
+	AddGmapsPlace(adder.GmapsPlace) (int64, error)
+	UpdateGmapsPlace(GmapsPlace) (int64, error)
 	UpdateVisit(Visit) (int64, error)
+	UpdateVisitUser(VisitUser) (int64, error)
+	GetRestaurant(int64) (lister.Restaurant, error)
+	GetUser(int64) (lister.User, error)
+	AddVisitUser(adder.VisitUser) (int64, error)
+	GetVisitUsersByVisitID(int64) ([]lister.VisitUser, error)
+	RemoveVisitUser(int64) (int64, error)
+	GetUserBy(string, string) (lister.User, error)
 	UpdateUser(User) (int64, error)
-	UpdateUserPassword(auther.UserChangePassword) (int64, error)
+	UpdateUserPassword(int64, string) (int64, error)
+	GetUserAuthByID(int64) (auther.User, error)
+	GetVisit(int64, int64) (lister.Visit, error)
+	AddAuditEntry(audit.Entry) (int64, error)
+	UpdateSavedView(SavedView) (int64, error)
+	GetSavedView(int64) (lister.SavedView, error)
+	// ClearDefaultSavedView makes none of the user's views their default.
+	ClearDefaultSavedView(int64) (int64, error)
 }
 
 // Repository provides access to restaurant repository.
 type Repository interface {
-	Begin()
-	Commit()
-	Rollback()
-	// UpdateRestaurant updates a given restaurant in the repository.
-	UpdateRestaurant(Restaurant) int64
-	GetCityIDByNameAndState(string, string) int64
-	AddCity(string, string) int64
-	AddGmapsPlace(adder.GmapsPlace) int64
-	UpdateGmapsPlace(GmapsPlace) int64
-	UpdateVisit(Visit) int64
-	UpdateVisitUser(VisitUser) int64
-	GetRestaurant(int64) lister.Restaurant
-	GetUser(int64) lister.User
-	AddVisitUser(adder.VisitUser) int64
-	GetVisitUsersByVisitID(int64) []lister.VisitUser
-	RemoveVisitUser(int64) int64
-	GetUserBy(string, string) lister.User
-	UpdateUser(User) int64
-	UpdateUserPassword(int64, string) int64
-	GetUserAuthByID(int64) auther.User
+	TxRepository
+	// WithTx calls fn with a TxRepository bound to a new transaction. The transaction is committed if fn returns nil
+	// and rolled back otherwise.
+	WithTx(fn func(TxRepository) error) error
 }
 
 type Map interface {
@@ -56,29 +71,16 @@ type service struct {
 	m Map
 }
 
-func (s service) UpdateRestaurant(r Restaurant) (int64, error) {
+func (s service) UpdateRestaurant(r Restaurant, userID int64) (int64, error) {
 	err := checkRestaurantData(r)
 	if err != nil {
 		return 0, err
 	}
 
-	// Check if the city and state is already in the database, If it is, get the city id
-	cityID := s.r.GetCityIDByNameAndState(r.CityState.Name, r.CityState.State)
-	s.r.Begin()
-	// Defer rollback just in case there is a problem.
-	defer s.r.Rollback()
-	if cityID == 0 {
-		// If not, then add it to the city table and get the city id back
-		log.Println(fmt.Sprintf("%s, %s not found, adding...", r.CityState.Name, r.CityState.State))
-		cityID = s.r.AddCity(r.CityState.Name, r.CityState.State)
-	}
-	log.Println(fmt.Sprintf("%s, %s has cityID %d", r.CityState.Name, r.CityState.State, cityID))
-	// Add the city id to the restaurant object
-	r.CityID = cityID
-
-	// This restaurant did not have a GmapsPlace, but now has 1, so we insert it and get the id back.
+	// This restaurant did not have a GmapsPlace, but now has 1, so we get the Place Details for this PlaceID. This is
+	// done before the transaction is started so we don't hold it open while waiting on Google.
+	var newGmapsPlace adder.GmapsPlace
 	if r.GmapsPlace.ID == 0 && r.GmapsPlace.PlaceID != "" {
-		// Get the Place Details for this PlaceID
 		pd, err := s.m.PlaceDetails(r.GmapsPlace.PlaceID)
 		if err != nil {
 			return 0, err
@@ -89,9 +91,8 @@ func (s service) UpdateRestaurant(r Restaurant) (int64, error) {
 		r.Zipcode = pd.Result.ZipCode
 		r.Address = pd.Result.Address
 
-		log.Printf("Inserting new GmapsPlace with PlaceID %s\n", r.GmapsPlace.PlaceID)
 		// Create a new GmapsPlace for adding
-		newGmapsPlace := adder.GmapsPlace{
+		newGmapsPlace = adder.GmapsPlace{
 			PlaceID:              pd.Result.PlaceID,
 			BusinessStatus:       pd.Result.BusinessStatus,
 			FormattedPhoneNumber: pd.Result.FormattedPhoneNumber,
@@ -104,12 +105,7 @@ func (s service) UpdateRestaurant(r Restaurant) (int64, error) {
 			Website:              pd.Result.Website,
 			RestaurantID:         r.ID,
 		}
-		// No need to set LastUpdated because it has a default to current timestamp in the repository
-		// Add the GmapsPlace
-		s.r.AddGmapsPlace(newGmapsPlace)
 	} else if r.GmapsPlace.ID != 0 {
-		// This restaurant already has a GmapsPlace Record so we just update it.
-		log.Printf("Updating GmapsPlace id: %d.\n", r.GmapsPlace.ID)
 		// Make the gmaps foreign key the restaurant id
 		r.GmapsPlace.RestaurantID = r.ID
 		// Parse the last updated date into the proper full format
@@ -118,48 +114,77 @@ func (s service) UpdateRestaurant(r Restaurant) (int64, error) {
 			return 0, err
 		}
 		r.GmapsPlace.LastUpdated = lastUpdated.Format(time.RFC3339)
-		gmapsPlaceRecordsAffected := s.r.UpdateGmapsPlace(r.GmapsPlace)
-		log.Printf("%d GmapsPlace records affected.\n", gmapsPlaceRecordsAffected)
-	} else {
-		log.Printf("Restaurant id: %d has no GmapsPlace record and update data has no GmapsPlace data.", r.ID)
-	}
-
-	// Update the restaurant.
-	recordsAffected := s.r.UpdateRestaurant(r)
-	if recordsAffected == 0 {
-		// Rollback should occur because of the defer.
-		return 0, fmt.Errorf("Restaurant id: %d was not found", r.ID)
 	}
 
-	s.r.Commit()
-
-	return recordsAffected, nil
-}
+	var recordsAffected int64
+	err = s.r.WithTx(func(tx TxRepository) error {
+		// Get the restaurant as it is now for the audit log.
+		before, err := tx.GetRestaurant(r.ID)
+		if storage.IsNotFound(err) {
+			return fmt.Errorf("Restaurant id: %d was not found", r.ID)
+		} else if err != nil {
+			return err
+		}
+		// Check if the city and state is already in the database, If it is, get the city id
+		cityID, err := tx.GetCityIDByNameAndState(r.CityState.Name, r.CityState.State)
+		if err != nil {
+			return err
+		}
+		if cityID == 0 {
+			// If not, then add it to the city table and get the city id back
+			log.Println(fmt.Sprintf("%s, %s not found, adding...", r.CityState.Name, r.CityState.State))
+			cityID, err = tx.AddCity(r.CityState.Name, r.CityState.State)
+			if err != nil {
+				return err
+			}
+		}
+		log.Println(fmt.Sprintf("%s, %s has cityID %d", r.CityState.Name, r.CityState.State, cityID))
+		// Add the city id to the restaurant object
+		r.CityID = cityID
+
+		if newGmapsPlace.PlaceID != "" {
+			log.Printf("Inserting new GmapsPlace with PlaceID %s\n", newGmapsPlace.PlaceID)
+			// No need to set LastUpdated because it has a default to current timestamp in the repository
+			// Add the GmapsPlace
+			if _, err := tx.AddGmapsPlace(newGmapsPlace); err != nil {
+				return err
+			}
+		} else if r.GmapsPlace.ID != 0 {
+			// This restaurant already has a GmapsPlace Record so we just update it.
+			log.Printf("Updating GmapsPlace id: %d.\n", r.GmapsPlace.ID)
+			gmapsPlaceRecordsAffected, err := tx.UpdateGmapsPlace(r.GmapsPlace)
+			if err != nil {
+				return err
+			}
+			log.Printf("%d GmapsPlace records affected.\n", gmapsPlaceRecordsAffected)
+		} else {
+			log.Printf("Restaurant id: %d has no GmapsPlace record and update data has no GmapsPlace data.", r.ID)
+		}
 
-func (s service) UpdateVisit(v Visit) (int64, error) {
-	// Check that the restaurant id is valid
-	r := s.r.GetRestaurant(v.RestaurantID)
-	if r.ID == 0 {
-		errorMsg := fmt.Sprintf("There is no restaurant with id: %d", v.RestaurantID)
-		return 0, errors.New(errorMsg)
-	}
-	// Check that the user id is valid and that there is only 1 entry per user id
-	userIDs := make(map[int64]bool)
-	for i, vu := range v.VisitUsers {
-		u := s.r.GetUser(vu.UserID)
-		if u.ID == 0 {
-			errorMsg := fmt.Sprintf("There is no user with id: %d", vu.UserID)
-			return 0, errors.New(errorMsg)
+		// Update the restaurant.
+		recordsAffected, err = tx.UpdateRestaurant(r)
+		if err != nil {
+			return err
 		}
-		if _, ok := userIDs[vu.UserID]; ok {
-			errorMsg := fmt.Sprintf("The data has multiple users with id: %d", vu.UserID)
-			return 0, errors.New(errorMsg)
+		if recordsAffected == 0 {
+			// Returning an error rolls the transaction back.
+			return fmt.Errorf("Restaurant id: %d was not found", r.ID)
 		}
-		userIDs[vu.UserID] = true
-		// Add the visit id to each VisitUser
-		v.VisitUsers[i].VisitID = v.ID
+		after, err := tx.GetRestaurant(r.ID)
+		if err != nil {
+			return err
+		}
+		return audit.Record(tx, userID, audit.EntityRestaurant, r.ID, r.ID, audit.ActionUpdate,
+			audit.Restaurant(before), audit.Restaurant(after))
+	})
+	if err != nil {
+		return 0, err
 	}
 
+	return recordsAffected, nil
+}
+
+func (s service) UpdateVisit(v Visit, userID int64) (int64, error) {
 	visitDateTime, err := time.Parse("2006-01-02", v.VisitDateTime)
 	if err != nil {
 		log.Println(err)
@@ -167,17 +192,60 @@ func (s service) UpdateVisit(v Visit) (int64, error) {
 	}
 	v.VisitDateTime = visitDateTime.Format(time.RFC3339)
 
-	s.r.Begin()
-	// Defer rollback just in case there is a problem.
-	defer s.r.Rollback()
+	var visitRecordsAffected, visitUserRecordsAffected int64
+	err = s.r.WithTx(func(tx TxRepository) error {
+		// Check that the restaurant id is valid
+		if _, err := tx.GetRestaurant(v.RestaurantID); storage.IsNotFound(err) {
+			errorMsg := fmt.Sprintf("There is no restaurant with id: %d", v.RestaurantID)
+			return errors.New(errorMsg)
+		} else if err != nil {
+			return err
+		}
+		// Check that the user id is valid and that there is only 1 entry per user id
+		userIDs := make(map[int64]bool)
+		for i, vu := range v.VisitUsers {
+			if _, err := tx.GetUser(vu.UserID); storage.IsNotFound(err) {
+				errorMsg := fmt.Sprintf("There is no user with id: %d", vu.UserID)
+				return errors.New(errorMsg)
+			} else if err != nil {
+				return err
+			}
+			if _, ok := userIDs[vu.UserID]; ok {
+				errorMsg := fmt.Sprintf("The data has multiple users with id: %d", vu.UserID)
+				return errors.New(errorMsg)
+			}
+			userIDs[vu.UserID] = true
+			// A rating of 0 means the user went but didn't rate it and is saved as no rating.
+			if vu.Rating < 0 || vu.Rating > 5 {
+				return errors.New("Ratings must be between 1 and 5")
+			}
+			// Add the visit id to each VisitUser
+			v.VisitUsers[i].VisitID = v.ID
+		}
+
+		// Get the visit as it is now for the audit log.
+		before, err := audit.GetVisit(tx, v.ID, v.RestaurantID)
+		if storage.IsNotFound(err) {
+			log.Printf("Visit id: %d does not exist for Restaurant id: %d.\n", v.ID, v.RestaurantID)
+			return nil
+		} else if err != nil {
+			return err
+		}
 
-	var visitUserRecordsAffected int64
-	visitRecordsAffected := s.r.UpdateVisit(v)
-	log.Printf("%d Visit records affected.\n", visitRecordsAffected)
-	// Only update if the visit actually exists.
-	if visitRecordsAffected > 0 {
+		visitRecordsAffected, err = tx.UpdateVisit(v)
+		if err != nil {
+			return err
+		}
+		log.Printf("%d Visit records affected.\n", visitRecordsAffected)
+		// Only update if the visit actually exists.
+		if visitRecordsAffected == 0 {
+			return nil
+		}
 		// Get the saved VisitUsers so we can remove anything that's not in this update.
-		savedVisitUsers := s.r.GetVisitUsersByVisitID(v.ID)
+		savedVisitUsers, err := tx.GetVisitUsersByVisitID(v.ID)
+		if err != nil {
+			return err
+		}
 		// Convert it to a map of ids
 		visitUsersMap := make(map[int64]bool)
 		for _, vu := range savedVisitUsers {
@@ -186,7 +254,11 @@ func (s service) UpdateVisit(v Visit) (int64, error) {
 
 		for _, vu := range v.VisitUsers {
 			if vu.ID != 0 {
-				visitUserRecordsAffected = visitUserRecordsAffected + s.r.UpdateVisitUser(vu)
+				recordsAffected, err := tx.UpdateVisitUser(vu)
+				if err != nil {
+					return visitUserError(err)
+				}
+				visitUserRecordsAffected = visitUserRecordsAffected + recordsAffected
 				// Set this VisitUser to True in the map so it doesn't get deleted.
 				visitUsersMap[vu.ID] = true
 			} else {
@@ -195,7 +267,10 @@ func (s service) UpdateVisit(v Visit) (int64, error) {
 					UserID:  vu.UserID,
 					Rating:  vu.Rating,
 				}
-				newVisitUserID := s.r.AddVisitUser(newVisit)
+				newVisitUserID, err := tx.AddVisitUser(newVisit)
+				if err != nil {
+					return visitUserError(err)
+				}
 				log.Printf("Added User id: %d to Visit id: %d. New VisitUser id: %d", vu.UserID, vu.VisitID,
 					newVisitUserID)
 			}
@@ -205,17 +280,33 @@ func (s service) UpdateVisit(v Visit) (int64, error) {
 		// from the visit.
 		for k, val := range visitUsersMap {
 			if !val {
-				s.r.RemoveVisitUser(k)
+				if _, err := tx.RemoveVisitUser(k); err != nil {
+					return err
+				}
 				log.Printf("Removed VisitUser id: %d from Visit id: %d", k, v.ID)
 			}
 		}
+		after, err := audit.GetVisit(tx, v.ID, v.RestaurantID)
+		if err != nil {
+			return err
+		}
+		return audit.Record(tx, userID, audit.EntityVisit, v.ID, v.RestaurantID, audit.ActionUpdate, before, after)
+	})
+	if err != nil {
+		return 0, err
 	}
 
-	s.r.Commit()
-
 	return visitRecordsAffected + visitUserRecordsAffected, nil
 }
 
+// visitUserError turns a repository error from saving a VisitUser into one that can be shown to the user.
+func visitUserError(err error) error {
+	if storage.IsUniqueViolation(err) {
+		return errors.New("A user can only be in a visit once")
+	}
+	return err
+}
+
 func (s service) UpdateUser(u User) (int64, error) {
 	// Check that all the properties have values
 	if u.FirstName == "" || u.LastName == "" {
@@ -234,16 +325,37 @@ func (s service) UpdateUser(u User) (int64, error) {
 		return 0, errors.New("Invalid email address")
 	}
 	// Check that the email is not already in the database used by a different user
-	existingUser := s.r.GetUserBy("email", u.Email)
+	existingUser, err := s.r.GetUserBy("email", u.Email)
+	if err != nil && !storage.IsNotFound(err) {
+		return 0, err
+	}
 	if existingUser.ID != 0 && existingUser.ID != u.ID {
 		return 0, errors.New("A user with this email address already exists")
 	}
-	s.r.Begin()
-	// Defer rollback just in case there is a problem.
-	defer s.r.Rollback()
 	// Update the user
-	recordsAffected := s.r.UpdateUser(u)
-	s.r.Commit()
+	var recordsAffected int64
+	err = s.r.WithTx(func(tx TxRepository) error {
+		before, err := tx.GetUser(u.ID)
+		if err != nil {
+			return err
+		}
+		recordsAffected, err = tx.UpdateUser(u)
+		if storage.IsUniqueViolation(err) {
+			// Another request took this email after we checked above.
+			return errors.New("A user with this email address already exists")
+		} else if err != nil {
+			return err
+		}
+		after, err := tx.GetUser(u.ID)
+		if err != nil {
+			return err
+		}
+		// Users can only update themselves.
+		return audit.Record(tx, u.ID, audit.EntityUser, u.ID, 0, audit.ActionUpdate, before, after)
+	})
+	if err != nil {
+		return 0, err
+	}
 	return recordsAffected, nil
 }
 
@@ -264,12 +376,14 @@ func (s service) UpdateUserPassword(u auther.UserChangePassword) (int64, error)
 
 	// Check that the current password is correct using the auther service
 	// Check this id exists
-	foundUser := s.r.GetUserAuthByID(u.ID)
-	if foundUser.ID == 0 {
+	foundUser, err := s.r.GetUserAuthByID(u.ID)
+	if storage.IsNotFound(err) {
 		return 0, errors.New("There is no user with this id")
+	} else if err != nil {
+		return 0, err
 	}
 
-	err := auther.CheckPassword(foundUser.PasswordHash, u.CurrentPassword)
+	err = auther.CheckPassword(foundUser.PasswordHash, u.CurrentPassword)
 	if err != nil {
 		return 0, errors.New("Wrong current password")
 	}
@@ -285,11 +399,19 @@ func (s service) UpdateUserPassword(u auther.UserChangePassword) (int64, error)
 	u.RepeatNewPassword = ""
 
 	// Update the user's password
-	s.r.Begin()
-	// Defer rollback just in case there is a problem.
-	defer s.r.Rollback()
-	recordsAffected := s.r.UpdateUserPassword(u.ID, passwordHash)
-	s.r.Commit()
+	var recordsAffected int64
+	err = s.r.WithTx(func(tx TxRepository) error {
+		recordsAffected, err = tx.UpdateUserPassword(u.ID, passwordHash)
+		if err != nil {
+			return err
+		}
+		// Never put the password hash in the audit log, just that it changed.
+		return audit.Record(tx, u.ID, audit.EntityUser, u.ID, 0, audit.ActionUpdate, nil,
+			map[string]string{"password": "changed"})
+	})
+	if err != nil {
+		return 0, err
+	}
 
 	return recordsAffected, nil
 }
@@ -309,8 +431,8 @@ func checkRestaurantData(r Restaurant) error {
 		return fmt.Errorf("You must provide a city and state for %s", r.Name)
 	}
 
-	if utf8.RuneCountInString(r.CityState.State) > 2 {
-		return fmt.Errorf("State is limited to 2 characters")
+	if utf8.RuneCountInString(r.CityState.State) != 2 {
+		return fmt.Errorf("State must be 2 characters")
 	}
 
 	// Check that Cuisine is not null
@@ -321,6 +443,46 @@ func checkRestaurantData(r Restaurant) error {
 	return nil
 }
 
+// UpdateSavedView updates one of the user's own views. Making it the default replaces the user's default view.
+func (s service) UpdateSavedView(v SavedView, userID int64) (int64, error) {
+	query, err := lister.CheckSavedView(v.Name, v.Query)
+	if err != nil {
+		return 0, err
+	}
+	v.Query = query
+
+	var recordsAffected int64
+	err = s.r.WithTx(func(tx TxRepository) error {
+		// Other users' views are treated as missing so their ids aren't given away.
+		before, err := tx.GetSavedView(v.ID)
+		if storage.IsNotFound(err) || err == nil && before.UserID != userID {
+			return fmt.Errorf("Saved view id: %d was not found", v.ID)
+		} else if err != nil {
+			return err
+		}
+		if v.IsDefault {
+			if _, err := tx.ClearDefaultSavedView(userID); err != nil {
+				return err
+			}
+		}
+		recordsAffected, err = tx.UpdateSavedView(v)
+		if storage.IsUniqueViolation(err) {
+			return fmt.Errorf("You already have a view called %s", v.Name)
+		} else if err != nil {
+			return err
+		}
+		after, err := tx.GetSavedView(v.ID)
+		if err != nil {
+			return err
+		}
+		return audit.Record(tx, userID, audit.EntitySavedView, v.ID, 0, audit.ActionUpdate, before, after)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return recordsAffected, nil
+}
+
 // NewService returns a new updater.service
 func NewService(r Repository, m Map) Service {
 	return service{r, m}
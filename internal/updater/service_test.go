@@ -0,0 +1,215 @@
+package updater_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/kelvinatorr/restaurant-tracker/internal/adder"
+	"github.com/kelvinatorr/restaurant-tracker/internal/mapper"
+	"github.com/kelvinatorr/restaurant-tracker/internal/storage/memory"
+	"github.com/kelvinatorr/restaurant-tracker/internal/updater"
+)
+
+// fixture is an in-memory storage with a user who has been to a restaurant once.
+type fixture struct {
+	s            *memory.Storage
+	u            updater.Service
+	a            adder.Service
+	userID       int64
+	restaurantID int64
+	visitID      int64
+}
+
+// newFixture returns a fixture. Nothing is looked up on Google Maps so the mapper doesn't need a key.
+func newFixture(t *testing.T) fixture {
+	t.Helper()
+	s := memory.NewStorage()
+	f := fixture{
+		s: s,
+		u: updater.NewService(s.Updater(), mapper.NewService("")),
+		a: adder.NewService(s.Adder(), mapper.NewService("")),
+	}
+	var err error
+	f.userID, err = f.a.AddUser(adder.User{FirstName: "Alex", LastName: "Rivera", Email: "alex@example.com",
+		Password: "pw", RepeatPassword: "pw"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.restaurantID, err = f.a.AddRestaurant(adder.Restaurant{Name: "Pho Saigon", Cuisine: "Vietnamese",
+		CityState: adder.CityState{Name: "Seattle", State: "WA"}}, f.userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.visitID, err = f.a.AddVisit(adder.Visit{RestaurantID: f.restaurantID, VisitDateTime: "2021-03-04",
+		VisitUsers: []adder.VisitUser{{UserID: f.userID, Rating: 4}}}, f.userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestUpdateRestaurant(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  func(r *updater.Restaurant)
+		wantErr string
+	}{
+		{"valid", func(r *updater.Restaurant) {}, ""},
+		{"no id", func(r *updater.Restaurant) { r.ID = 0 }, "Update ID cannot be 0"},
+		{"no name", func(r *updater.Restaurant) { r.Name = "" }, "A name is required"},
+		{"no cuisine", func(r *updater.Restaurant) { r.Cuisine = "" }, "You must provide a cuisine"},
+		{"no state", func(r *updater.Restaurant) { r.CityState.State = "" },
+			"You must provide a city and state for Pho Saigon II"},
+		{"long state", func(r *updater.Restaurant) { r.CityState.State = "WAS" }, "State must be 2 characters"},
+		{"missing restaurant", func(r *updater.Restaurant) { r.ID = 100 }, "Restaurant id: 100 was not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			r := updater.Restaurant{ID: f.restaurantID, Name: "Pho Saigon II", Cuisine: "Vietnamese",
+				CityState: updater.CityState{Name: "Tacoma", State: "WA"}}
+			tt.change(&r)
+			_, err := f.u.UpdateRestaurant(r, f.userID)
+			saved, getErr := f.s.GetRestaurant(f.restaurantID)
+			if getErr != nil {
+				t.Fatal(getErr)
+			}
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if saved.Name != "Pho Saigon II" || saved.CityState.Name != "Tacoma" {
+					t.Errorf("The restaurant was saved as %s in %s", saved.Name, saved.CityState.Name)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("UpdateRestaurant() returned %v, want %s", err, tt.wantErr)
+			}
+			if saved.Name != "Pho Saigon" {
+				t.Errorf("The restaurant was renamed to %s by a failed update", saved.Name)
+			}
+		})
+	}
+}
+
+func TestUpdateVisit(t *testing.T) {
+	tests := []struct {
+		name       string
+		visitUsers func(f fixture) []updater.VisitUser
+		wantErr    string
+		wantRating int64
+	}{
+		{"new rating", func(f fixture) []updater.VisitUser {
+			return []updater.VisitUser{{ID: 1, UserID: f.userID, Rating: 2}}
+		}, "", 2},
+		{"rating too high", func(f fixture) []updater.VisitUser {
+			return []updater.VisitUser{{ID: 1, UserID: f.userID, Rating: 6}}
+		}, "Ratings must be between 1 and 5", 4},
+		{"missing user", func(f fixture) []updater.VisitUser {
+			return []updater.VisitUser{{UserID: f.userID + 1, Rating: 3}}
+		}, "There is no user with id: 2", 4},
+		{"user twice", func(f fixture) []updater.VisitUser {
+			return []updater.VisitUser{{ID: 1, UserID: f.userID, Rating: 3}, {UserID: f.userID, Rating: 3}}
+		}, "The data has multiple users with id: 1", 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			v := updater.Visit{ID: f.visitID, RestaurantID: f.restaurantID, VisitDateTime: "2021-03-05",
+				Note: "Updated", VisitUsers: tt.visitUsers(f)}
+			_, err := f.u.UpdateVisit(v, f.userID)
+			if tt.wantErr == "" && err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+				t.Errorf("UpdateVisit() returned %v, want %s", err, tt.wantErr)
+			}
+			vus, err := f.s.GetVisitUsersByVisitID(f.visitID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(vus) != 1 || vus[0].Rating != tt.wantRating {
+				t.Errorf("The visit's users are %+v, want one with rating %d", vus, tt.wantRating)
+			}
+		})
+	}
+}
+
+func TestUpdateUser(t *testing.T) {
+	tests := []struct {
+		name    string
+		user    updater.User
+		wantErr string
+	}{
+		{"no name", updater.User{LastName: "Rivera", Email: "alex@example.com"},
+			"First name and last name are required"},
+		{"no email", updater.User{FirstName: "Alex", LastName: "Rivera"}, "An email address is required"},
+		{"bad email", updater.User{FirstName: "Alex", LastName: "Rivera", Email: "alex"}, "Invalid email address"},
+		{"taken email", updater.User{FirstName: "Alex", LastName: "Rivera", Email: "sam@example.com"},
+			"A user with this email address already exists"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			if _, err := f.a.AddUser(adder.User{FirstName: "Sam", LastName: "Lee", Email: "sam@example.com",
+				Password: "pw", RepeatPassword: "pw"}, 0); err != nil {
+				t.Fatal(err)
+			}
+			tt.user.ID = f.userID
+			if _, err := f.u.UpdateUser(tt.user); err == nil || err.Error() != tt.wantErr {
+				t.Errorf("UpdateUser() returned %v, want %s", err, tt.wantErr)
+			}
+			if u, _ := f.s.GetUser(f.userID); u.Email != "alex@example.com" {
+				t.Errorf("The user's email was changed to %s by a failed update", u.Email)
+			}
+		})
+	}
+}
+
+func TestUpdateSavedViewUniqueName(t *testing.T) {
+	f := newFixture(t)
+	if _, err := f.a.AddSavedView(adder.SavedView{Name: "Thai", Query: "filter=cuisine:Thai"}, f.userID); err != nil {
+		t.Fatal(err)
+	}
+	laoID, err := f.a.AddSavedView(adder.SavedView{Name: "Lao", Query: "filter=cuisine:Lao"}, f.userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = f.u.UpdateSavedView(updater.SavedView{ID: laoID, Name: "Thai", Query: "filter=cuisine:Lao"}, f.userID)
+	if err == nil || err.Error() != "You already have a view called Thai" {
+		t.Errorf("UpdateSavedView() returned %v, want a duplicate name error", err)
+	}
+	if v, _ := f.s.GetSavedView(laoID); v.Name != "Lao" {
+		t.Errorf("The view was renamed to %s by a failed update", v.Name)
+	}
+}
+
+func TestWithTxRollback(t *testing.T) {
+	f := newFixture(t)
+	errFail := errors.New("fail")
+	err := f.s.Updater().WithTx(func(tx updater.TxRepository) error {
+		if _, err := tx.UpdateVisit(updater.Visit{ID: f.visitID, RestaurantID: f.restaurantID,
+			VisitDateTime: "2021-03-05T00:00:00Z", Note: "Changed"}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tx.UpdateVisitUser(updater.VisitUser{ID: 1, VisitID: f.visitID, UserID: f.userID,
+			Rating: 1}); err != nil {
+			t.Fatal(err)
+		}
+		return errFail
+	})
+	if err != errFail {
+		t.Errorf("WithTx() returned %v, want the callback's error", err)
+	}
+	v, err := f.s.GetVisit(f.visitID, f.restaurantID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Note != "" || v.VisitDateTime != "2021-03-04T00:00:00Z" {
+		t.Errorf("The visit changed in the failed transaction was saved as %+v", v)
+	}
+	if vus, _ := f.s.GetVisitUsersByVisitID(f.visitID); len(vus) != 1 || vus[0].Rating != 4 {
+		t.Errorf("The rating changed in the failed transaction was saved as %+v", vus)
+	}
+}
@@ -0,0 +1,10 @@
+package updater
+
+// SavedView is a named filter and sort of the restaurant list. Only the user who owns it can update it.
+type SavedView struct {
+	ID        int64  `json:"id" schema:"id,required"`
+	Name      string `json:"name" schema:"name,required"`
+	Query     string `json:"query" schema:"query"`
+	Shared    bool   `json:"shared" schema:"shared"`
+	IsDefault bool   `json:"is_default" schema:"isDefault"`
+}